@@ -5,12 +5,10 @@ package main
 
 import (
 	"bytes"
-	"context" //nolint:gosec
 	"crypto/subtle"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"path/filepath"
 	"strconv"
@@ -19,6 +17,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 
 	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
@@ -26,7 +25,6 @@ import (
 	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
 
 	"github.com/mattermost/mattermost/server/public/model"
-	"golang.org/x/oauth2"
 )
 
 type API struct {
@@ -50,6 +48,9 @@ const (
 	QueryParamPostID                          = "post_id"
 	QueryParamFromPreferences                 = "from_preferences"
 	QueryParamStateID                         = "state_id"
+	QueryParamSearch                          = "search"
+	QueryParamDryRun                          = "dry_run"
+	QueryParamCheckToken                      = "check_token"
 
 	maxWebhookBodySize int64 = 1 << 20 // 1 MB
 )
@@ -58,6 +59,15 @@ type UpdateWhitelistResult struct {
 	Count       int      `json:"count"`
 	Failed      []string `json:"failed"`
 	FailedLines []string `json:"failedLines"`
+	Duplicates  []string `json:"duplicates"`
+	DryRun      bool     `json:"dryRun,omitempty"`
+	Added       []string `json:"added,omitempty"`
+	Removed     []string `json:"removed,omitempty"`
+	Unchanged   int      `json:"unchanged,omitempty"`
+}
+
+type WhitelistUserRequest struct {
+	User string `json:"user"`
 }
 
 func NewAPI(p *Plugin, store store.Store) *API {
@@ -82,6 +92,19 @@ func NewAPI(p *Plugin, store store.Store) *API {
 	router.HandleFunc("/connected-users/download", api.getConnectedUsersFile).Methods(http.MethodGet)
 	router.HandleFunc("/whitelist", api.updateWhitelist).Methods(http.MethodPut)
 	router.HandleFunc("/whitelist/download", api.getWhitelistEmailsFile).Methods(http.MethodGet)
+	router.HandleFunc("/whitelist", api.adminRequired(api.getWhitelist)).Methods(http.MethodGet)
+	router.HandleFunc("/whitelist/users", api.adminRequired(api.addWhitelistUser)).Methods(http.MethodPost)
+	router.HandleFunc("/whitelist/users/{user}", api.adminRequired(api.getWhitelistUser)).Methods(http.MethodGet)
+	router.HandleFunc("/whitelist/users/{user}", api.adminRequired(api.removeWhitelistUser)).Methods(http.MethodDelete)
+	router.HandleFunc("/invites", api.adminRequired(api.getInvites)).Methods(http.MethodGet)
+	router.HandleFunc("/credentials/check", api.adminRequired(api.getCredentialsCheck)).Methods(http.MethodGet)
+	router.HandleFunc("/connection/test", api.adminRequired(api.testConnection)).Methods(http.MethodPost)
+	router.HandleFunc("/circuit-breakers", api.adminRequired(api.getCircuitBreakers)).Methods(http.MethodGet)
+	router.HandleFunc("/users/{user}/data", api.adminRequired(api.exportUserData)).Methods(http.MethodGet)
+	router.HandleFunc("/users/{user}/data", api.adminRequired(api.eraseUserData)).Methods(http.MethodDelete)
+	router.HandleFunc("/users/{user}/disconnect", api.adminRequired(api.forceDisconnectUser)).Methods(http.MethodPost)
+	router.HandleFunc("/users/disconnect", api.adminRequired(api.forceDisconnectUsers)).Methods(http.MethodPost)
+	router.HandleFunc("/users/disconnect/upload", api.adminRequired(api.forceDisconnectUsersFile)).Methods(http.MethodPost)
 	router.HandleFunc("/notify-connect", api.notifyConnect).Methods("GET")
 	router.HandleFunc("/account-connected", api.accountConnectedPage).Methods(http.MethodGet)
 	router.HandleFunc("/stats/site", api.siteStats).Methods("GET")
@@ -100,7 +123,10 @@ func (a *API) returnJSON(w http.ResponseWriter, data any) {
 	}
 }
 
-// processActivity handles the activity received from teams subscriptions
+// processActivity handles the activity received from teams subscriptions. Notifications are
+// validated the same way in every national cloud: the endpoint validation echoes the token sent
+// by Microsoft Graph, and each notification must carry the client state the subscription was
+// created with. Neither depends on the cloud the subscription was created in.
 func (a *API) processActivity(w http.ResponseWriter, req *http.Request) {
 	validationToken := req.URL.Query().Get("validationToken")
 	if validationToken != "" {
@@ -120,17 +146,27 @@ func (a *API) processActivity(w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
 	errors := ""
+	var presenceUserIDs []string
 	for _, activity := range activities.Value {
 		if subtle.ConstantTimeCompare([]byte(activity.ClientState), []byte(a.p.getConfiguration().WebhookSecret)) == 0 {
 			errors += "Invalid webhook secret"
 			continue
 		}
 
+		if teamsUserID, ok := msteams.GetPresenceUserID(activity.Resource); ok {
+			presenceUserIDs = append(presenceUserIDs, teamsUserID)
+			a.p.metricsService.ObserveChangeEvent(activity.ChangeType, metrics.DiscardedReasonNone)
+			continue
+		}
+
 		if err := a.p.activityHandler.Handle(activity); err != nil {
 			a.p.API.LogWarn("Unable to process created activity", "activity", activity, "error", err.Error())
 			errors += err.Error() + "\n"
 		}
 	}
+	if len(presenceUserIDs) > 0 {
+		a.p.activityHandler.HandlePresenceChanges(presenceUserIDs)
+	}
 	if errors != "" {
 		http.Error(w, errors, http.StatusBadRequest)
 		return
@@ -259,21 +295,16 @@ func (a *API) connect(w http.ResponseWriter, r *http.Request) {
 	postID := query.Get(QueryParamPostID)
 	stateID := query.Get(QueryParamStateID)
 
-	var state string
 	if fromPreferences == "true" {
 		// For preferences flow, create new state since there's no pre-stored state
-		stateSuffix := "fromPreferences:true"
-		state = fmt.Sprintf("%s_%s_%s", model.NewId(), userID, stateSuffix)
+		state := newOAuth2State(userID, storemodels.OAuth2StateOriginPreferences, "", "", oAuth2StateTimeToLive)
 		if err := a.store.StoreOAuth2State(state); err != nil {
 			a.p.API.LogWarn("Error in storing the OAuth state", "error", err.Error())
 			http.Error(w, "Error in trying to connect the account, please try again.", http.StatusInternalServerError)
 			return
 		}
-	} else if channelID != "" && postID != "" && stateID != "" {
-		// For bot message flow, reconstruct the state using the provided stateID
-		stateSuffix := fmt.Sprintf("fromBotMessage:%s|%s", channelID, postID)
-		state = fmt.Sprintf("%s_%s_%s", stateID, userID, stateSuffix)
-	} else {
+		stateID = state.ID
+	} else if channelID == "" || postID == "" || stateID == "" {
 		a.p.API.LogWarn("could not determine origin of the connect request", "channel_id", channelID, "post_id", postID, "from_preferences", fromPreferences, "state_id", stateID)
 		http.Error(w, "Missing required query parameters.", http.StatusBadRequest)
 		return
@@ -285,33 +316,54 @@ func (a *API) connect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if fromPreferences != "true" {
+		if _, err := a.store.VerifyOAuth2State(stateID, userID); err != nil {
+			// The link may outlive its state while an invite is pending, in which case the state
+			// is issued again. Otherwise, the flow fails once redirected back with the state.
+			if state, err := a.p.reissueBotMessageOAuth2State(userID, channelID, postID); err != nil {
+				a.p.API.LogDebug("Unable to issue the OAuth state again", "user_id", userID, "post_id", postID, "error", err.Error())
+			} else {
+				stateID = state.ID
+			}
+		}
+	}
+
+	// For bot message flow, the state was stored when the message was sent, and is bound to the
+	// user completing the flow through the state token.
+	state, err := encodeOAuth2StateToken(stateID, userID)
+	if err != nil {
+		a.p.API.LogWarn("Error in encoding the OAuth state", "error", err.Error())
+		http.Error(w, "Error in trying to connect the account, please try again.", http.StatusInternalServerError)
+		return
+	}
+
 	codeVerifier := model.NewId()
-	codeVerifierKey := "_code_verifier_" + userID
-	if appErr := a.p.API.KVSet(codeVerifierKey, []byte(codeVerifier)); appErr != nil {
-		a.p.API.LogWarn("Error in storing the code verifier", "error", appErr.Message)
+	if err = a.p.storeCodeVerifier(userID, codeVerifier); err != nil {
+		a.p.API.LogWarn("Error in storing the code verifier", "error", err.Error())
 		http.Error(w, "Error in trying to connect the account, please try again.", http.StatusInternalServerError)
 		return
 	}
 
 	a.p.API.LogInfo("Redirecting user to OAuth flow", "user_id", userID)
 
-	connectURL := msteams.GetAuthURL(a.p.GetURL()+"/oauth-redirect", a.p.configuration.TenantID, a.p.configuration.ClientID, a.p.configuration.ClientSecret, state, codeVerifier)
+	connectURL := msteams.GetAuthURL(a.p.configuration.Cloud(), a.p.GetURL()+"/oauth-redirect", a.p.configuration.TenantID, a.p.configuration.ClientID, a.p.configuration.ClientSecret, state, codeVerifier)
 	http.Redirect(w, r, connectURL, http.StatusSeeOther)
 }
 
 func (a *API) connectionStatus(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get("Mattermost-User-ID")
 
-	response := map[string]bool{"connected": false}
-	if storedToken, _ := a.p.store.GetTokenForMattermostUser(userID); storedToken != nil {
-		response["connected"] = true
-	}
+	// Checking the token costs a request to MS Teams, so it's up to the caller.
+	checkToken := r.URL.Query().Get(QueryParamCheckToken) == "true"
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		a.p.API.LogWarn("Error while writing response", "error", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+	status, err := a.p.getConnectionStatus(userID, checkToken)
+	if err != nil {
+		a.p.API.LogWarn("Unable to get the connection status", "user_id", userID, "error", err.Error())
+		http.Error(w, "unable to get the connection status", http.StatusInternalServerError)
+		return
 	}
+
+	a.returnJSON(w, status)
 }
 
 func (a *API) accountConnectedPage(w http.ResponseWriter, r *http.Request) {
@@ -360,83 +412,64 @@ func (a *API) oauthRedirectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	teamsDefaultScopes := []string{"https://graph.microsoft.com/.default"}
-	conf := &oauth2.Config{
-		ClientID:     a.p.configuration.ClientID,
-		ClientSecret: a.p.configuration.ClientSecret,
-		Scopes:       append(teamsDefaultScopes, "offline_access"),
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/authorize", a.p.configuration.TenantID),
-			TokenURL: fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", a.p.configuration.TenantID),
-		},
-		RedirectURL: a.p.GetURL() + "/oauth-redirect",
-	}
-
 	code := r.URL.Query().Get("code")
 	state := r.URL.Query().Get("state")
 
-	stateArr := strings.Split(state, "_")
-	if len(stateArr) != 3 {
+	stateToken, err := decodeOAuth2StateToken(state)
+	if err != nil {
+		a.p.API.LogWarn("Unable to decode OAuth state", "error", err.Error())
 		http.Error(w, "Invalid state", http.StatusBadRequest)
 		return
 	}
 
-	// determine origin of the connect request
-	// if the state is fromPreferences, the user is connecting from the preferences page
-	// if the state is fromBotMessage, the user is connecting from a bot message
-	originInfo := strings.Split(stateArr[2], ":")
-	if len(originInfo) != 2 {
-		a.p.API.LogWarn("unable to get origin info from state", "state", stateArr[2])
-		http.Error(w, "Invalid state", http.StatusBadRequest)
+	mmUserID := stateToken.UserID
+	storedState, err := a.store.VerifyOAuth2State(stateToken.ID, mmUserID)
+	if err != nil {
+		a.p.API.LogWarn("Unable to verify OAuth state", "user_id", mmUserID, "error", err.Error())
+		http.Error(w, "Unable to complete authentication.", http.StatusInternalServerError)
 		return
 	}
 
+	// determine origin of the connect request
+	// if the state is from preferences, the user is connecting from the preferences page
+	// if the state is from a bot message, the user is connecting from a bot message
 	channelID := ""
 	postID := ""
-	switch originInfo[0] {
-	case "fromPreferences":
+	switch storedState.Origin {
+	case storemodels.OAuth2StateOriginPreferences:
 		// do nothing
-	case "fromBotMessage":
-		fromBotMessageArgs := strings.Split(originInfo[1], "|")
-		if len(fromBotMessageArgs) != 2 {
-			a.p.API.LogWarn("unable to get args from bot message", "origin_info", originInfo[1])
-			http.Error(w, "Invalid state", http.StatusBadRequest)
-			return
-		}
-		channelID = fromBotMessageArgs[0]
-		postID = fromBotMessageArgs[1]
+	case storemodels.OAuth2StateOriginBotMessage:
+		channelID = storedState.ChannelID
+		postID = storedState.PostID
 	default:
 		http.Error(w, "Invalid state", http.StatusBadRequest)
 		return
 	}
 
-	mmUserID := stateArr[1]
-	if err := a.store.VerifyOAuth2State(state); err != nil {
-		a.p.API.LogWarn("Unable to verify OAuth state", "user_id", mmUserID, "error", err.Error())
-		http.Error(w, "Unable to complete authentication.", http.StatusInternalServerError)
-		return
-	}
-
-	codeVerifierBytes, appErr := a.p.API.KVGet("_code_verifier_" + mmUserID)
-	if appErr != nil {
-		a.p.API.LogWarn("Unable to get the code verifier", "error", appErr.Error())
+	codeVerifier, err := a.p.popCodeVerifier(mmUserID)
+	if err != nil {
+		a.p.API.LogWarn("Unable to get the code verifier", "error", err.Error())
 		http.Error(w, "failed to get the code verifier", http.StatusBadRequest)
 		return
 	}
-	appErr = a.p.API.KVDelete("_code_verifier_" + mmUserID)
-	if appErr != nil {
-		a.p.API.LogWarn("Unable to delete the used code verifier", "error", appErr.Error())
-	}
 
-	ctx := context.Background()
-	token, err := conf.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", string(codeVerifierBytes)))
+	config := a.p.getConfiguration()
+	token, err := msteams.ExchangeCode(config.Cloud(), a.p.GetURL()+"/oauth-redirect", config.TenantID, config.ClientID, config.AppCredentials(), config.HTTPTransport(), code, codeVerifier)
 	if err != nil {
 		a.p.API.LogWarn("Unable to get OAuth2 token", "error", err.Error())
 		http.Error(w, "Unable to complete authentication", http.StatusInternalServerError)
 		return
 	}
 
-	client := msteams.NewTokenClient(a.p.GetURL()+"/oauth-redirect", a.p.configuration.TenantID, a.p.configuration.ClientID, a.p.configuration.ClientSecret, token, &a.p.apiClient.Log)
+	// Only consume the state once the code was exchanged, so that a failed attempt can be retried
+	// with the same link.
+	if err = a.store.ConsumeOAuth2State(stateToken.ID, mmUserID); err != nil {
+		a.p.API.LogWarn("Unable to consume OAuth state", "user_id", mmUserID, "error", err.Error())
+		http.Error(w, "Unable to complete authentication.", http.StatusInternalServerError)
+		return
+	}
+
+	client := msteams.NewTokenClient(config.Cloud(), a.p.GetURL()+"/oauth-redirect", config.TenantID, config.ClientID, config.AppCredentials(), config.HTTPTransport(), token, &a.p.apiClient.Log)
 	if err = client.Connect(); err != nil {
 		a.p.API.LogWarn("Unable to connect to the client", "error", err.Error())
 		http.Error(w, "failed to connect to the client", http.StatusInternalServerError)
@@ -537,19 +570,19 @@ func (a *API) oauthRedirectHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Add("Content-Type", "text/html")
 
-	a.handleSyncNotificationsWelcomeMessage(originInfo[0], mmUserID, channelID, postID)
+	a.handleSyncNotificationsWelcomeMessage(storedState.Origin, mmUserID, channelID, postID)
 
 	http.Redirect(w, r, a.p.GetURL()+"/account-connected", http.StatusSeeOther)
 }
 
-func (a *API) handleSyncNotificationsWelcomeMessage(originInfo string, mmUserID, channelID, postID string) {
-	switch originInfo {
-	case "fromPreferences":
+func (a *API) handleSyncNotificationsWelcomeMessage(origin string, mmUserID, channelID, postID string) {
+	switch origin {
+	case storemodels.OAuth2StateOriginPreferences:
 		err := a.p.SendWelcomeMessage(mmUserID)
 		if err != nil {
 			a.p.API.LogWarn("Unable to send welcome post with notifications", "error", err.Error())
 		}
-	case "fromBotMessage":
+	case storemodels.OAuth2StateOriginBotMessage:
 		welcomePost := a.p.makeWelcomeMessagePost()
 		var originalPost *model.Post
 		originalPost, appErr := a.p.GetAPI().GetPost(postID)
@@ -734,46 +767,41 @@ func (a *API) updateWhitelist(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
-	columns, err := reader.Read()
-	if err != nil || strings.ToLower(columns[0]) != "email" || len(columns) != 1 {
+	parsed, err := a.p.parseEmailsCSV(file)
+	if err != nil {
 		a.p.API.LogWarn("Error parsing whitelist csv header")
 		http.Error(w, "error parsing whitelist - please check header and try again", http.StatusBadRequest)
 		return
 	}
+	ids := parsed.UserIDs
+	failed := parsed.Failed
+	csvLineErrs := parsed.FailedLines
 
-	var ids []string
-	var failed []string
+	if len(csvLineErrs) > UpdateWhitelistCsvParseErrThreshold {
+		a.p.API.LogWarn("Error parsing whitelist csv data", "lines", csvLineErrs)
+		http.Error(w, "error parsing whitelist - please check data at line(s) "+strings.Join(csvLineErrs, ", ")+" and try again", http.StatusBadRequest)
+		return
+	}
 
-	var csvLineErrs []string
-	var i = 1 // offset, start line 1
-	for {
-		i++
-		row, readErr := reader.Read()
-		if readErr == io.EOF {
-			break
-		}
-		if readErr != nil {
-			csvLineErrs = append(csvLineErrs, strconv.Itoa(i))
-			continue
-		}
-		if len(csvLineErrs) > UpdateWhitelistCsvParseErrThreshold {
-			break
-		}
-		email := row[0]
-		user, err := a.p.API.GetUserByEmail(email)
+	if r.URL.Query().Get(QueryParamDryRun) == "true" {
+		current, err := a.p.getWhitelistEmails()
 		if err != nil {
-			a.p.API.LogWarn("Error could not find user with email", "line", i)
-			failed = append(failed, email)
-			continue
+			a.p.API.LogWarn("Unable to get whitelist", "error", err.Error())
+			http.Error(w, "unable to get whitelist", http.StatusInternalServerError)
+			return
 		}
 
-		ids = append(ids, user.Id)
-	}
-
-	if len(csvLineErrs) > UpdateWhitelistCsvParseErrThreshold {
-		a.p.API.LogWarn("Error parsing whitelist csv data", "lines", csvLineErrs)
-		http.Error(w, "error parsing whitelist - please check data at line(s) "+strings.Join(csvLineErrs, ", ")+" and try again", http.StatusBadRequest)
+		added, removed, unchanged := diffWhitelist(current, parsed.Emails)
+		a.returnJSON(w, &UpdateWhitelistResult{
+			Count:       len(ids),
+			Failed:      failed,
+			FailedLines: csvLineErrs,
+			Duplicates:  parsed.Duplicates,
+			DryRun:      true,
+			Added:       added,
+			Removed:     removed,
+			Unchanged:   unchanged,
+		})
 		return
 	}
 
@@ -797,11 +825,277 @@ func (a *API) updateWhitelist(w http.ResponseWriter, r *http.Request) {
 		Count:       len(ids),
 		Failed:      failed,
 		FailedLines: csvLineErrs,
+		Duplicates:  parsed.Duplicates,
 	}); err != nil {
 		a.p.API.LogWarn("Error writing update whitelist response")
 	}
 }
 
+func (a *API) getWhitelist(w http.ResponseWriter, r *http.Request) {
+	page, perPage := GetPageAndPerPage(r)
+	emails, err := a.p.store.GetWhitelistEmails(r.URL.Query().Get(QueryParamSearch), page, perPage)
+	if err != nil {
+		a.p.API.LogWarn("Unable to get whitelist", "error", err.Error())
+		http.Error(w, "unable to get whitelist", http.StatusInternalServerError)
+		return
+	}
+
+	if emails == nil {
+		emails = []string{}
+	}
+
+	a.returnJSON(w, emails)
+}
+
+func (a *API) getWhitelistUser(w http.ResponseWriter, r *http.Request) {
+	user, err := a.p.lookupUser(mux.Vars(r)["user"])
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	entry, err := a.p.getWhitelistEntry(user)
+	if err != nil {
+		a.p.API.LogWarn("Unable to check whitelist", "user_id", user.Id, "error", err.Error())
+		http.Error(w, "unable to check whitelist", http.StatusInternalServerError)
+		return
+	}
+
+	a.returnJSON(w, entry)
+}
+
+func (a *API) addWhitelistUser(w http.ResponseWriter, r *http.Request) {
+	var request WhitelistUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "unable to parse the request", http.StatusBadRequest)
+		return
+	}
+
+	user, err := a.p.lookupUser(request.User)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	if err = a.p.store.StoreUserInWhitelist(user.Id); err != nil {
+		a.p.API.LogWarn("Unable to add user to whitelist", "user_id", user.Id, "error", err.Error())
+		http.Error(w, "unable to add user to whitelist", http.StatusInternalServerError)
+		return
+	}
+
+	a.p.API.LogInfo("User added to whitelist", "user_id", user.Id, "admin_user_id", r.Header.Get("Mattermost-User-ID"))
+
+	a.returnJSON(w, &WhitelistEntry{
+		UserID:      user.Id,
+		Username:    user.Username,
+		Email:       user.Email,
+		Whitelisted: true,
+	})
+}
+
+func (a *API) removeWhitelistUser(w http.ResponseWriter, r *http.Request) {
+	user, err := a.p.lookupUser(mux.Vars(r)["user"])
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	if err = a.p.store.DeleteUserFromWhitelist(user.Id); err != nil {
+		a.p.API.LogWarn("Unable to remove user from whitelist", "user_id", user.Id, "error", err.Error())
+		http.Error(w, "unable to remove user from whitelist", http.StatusInternalServerError)
+		return
+	}
+
+	a.p.API.LogInfo("User removed from whitelist", "user_id", user.Id, "admin_user_id", r.Header.Get("Mattermost-User-ID"))
+
+	a.returnJSON(w, &WhitelistEntry{
+		UserID:      user.Id,
+		Username:    user.Username,
+		Email:       user.Email,
+		Whitelisted: false,
+	})
+}
+
+func (a *API) getInvites(w http.ResponseWriter, _ *http.Request) {
+	report, err := a.p.getInvitesReport()
+	if err != nil {
+		a.p.API.LogWarn("Unable to get invites report", "error", err.Error())
+		http.Error(w, "unable to get invites report", http.StatusInternalServerError)
+		return
+	}
+
+	a.returnJSON(w, report)
+}
+
+func (a *API) getCredentialsCheck(w http.ResponseWriter, r *http.Request) {
+	result, err := a.p.getCredentialsCheckResult()
+	if err != nil {
+		a.p.API.LogWarn("Unable to get credentials check result", "error", err.Error())
+		http.Error(w, "unable to get credentials check result", http.StatusInternalServerError)
+		return
+	}
+
+	if result == nil || r.URL.Query().Get("refresh") == "true" {
+		report, err := a.p.runCredentialsCheck()
+		if err != nil {
+			a.p.API.LogWarn("Unable to check credentials", "error", err.Error())
+			http.Error(w, "unable to check credentials", http.StatusInternalServerError)
+			return
+		}
+
+		result = newCredentialsCheckResult(report)
+	}
+
+	a.returnJSON(w, result)
+}
+
+// getCircuitBreakers returns the state of the circuit breakers around Microsoft Graph on this server.
+func (a *API) getCircuitBreakers(w http.ResponseWriter, _ *http.Request) {
+	a.returnJSON(w, a.p.clientBreakers.Status())
+}
+
+func (a *API) testConnection(w http.ResponseWriter, r *http.Request) {
+	var request ConnectionTestRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "unable to parse the request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	credentials, err := request.resolve(a.p.getConfiguration())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.TenantID == "" || request.ClientID == "" || credentials.IsEmpty() {
+		http.Error(w, "tenant ID, client ID and a client secret or certificate are required", http.StatusBadRequest)
+		return
+	}
+
+	a.returnJSON(w, a.p.testConnection(&request, credentials, r.Header.Get("Mattermost-User-ID")))
+}
+
+func (a *API) exportUserData(w http.ResponseWriter, r *http.Request) {
+	user, err := a.p.lookupUser(mux.Vars(r)["user"])
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	export, err := a.p.exportUserData(user, r.Header.Get("Mattermost-User-ID"))
+	if err != nil {
+		a.p.API.LogWarn("Unable to export user data", "user_id", user.Id, "error", err.Error())
+		http.Error(w, "unable to export user data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", userDataExportFileName(user)))
+	a.returnJSON(w, export)
+}
+
+func (a *API) eraseUserData(w http.ResponseWriter, r *http.Request) {
+	user, err := a.p.lookupUser(mux.Vars(r)["user"])
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	deletePosts, _ := strconv.ParseBool(r.URL.Query().Get("delete_posts"))
+
+	erasure, err := a.p.eraseUserData(user, deletePosts, r.Header.Get("Mattermost-User-ID"))
+	if err != nil {
+		a.p.API.LogWarn("Unable to erase user data", "user_id", user.Id, "error", err.Error())
+		http.Error(w, "unable to erase user data", http.StatusInternalServerError)
+		return
+	}
+
+	a.returnJSON(w, erasure)
+}
+
+func (a *API) forceDisconnectUser(w http.ResponseWriter, r *http.Request) {
+	user, err := a.p.lookupUser(mux.Vars(r)["user"])
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	var request ForceDisconnectRequest
+	if r.ContentLength != 0 {
+		if err = json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "unable to parse the request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	disconnected, err := a.p.forceDisconnectUser(user, request.Reason, r.Header.Get("Mattermost-User-ID"))
+	if err != nil {
+		a.p.API.LogWarn("Unable to disconnect user", "user_id", user.Id, "error", err.Error())
+		http.Error(w, "unable to disconnect user", http.StatusInternalServerError)
+		return
+	}
+
+	result := &ForceDisconnectResult{}
+	result.add(user.Id, disconnected)
+	a.returnJSON(w, result)
+}
+
+func (a *API) forceDisconnectUsers(w http.ResponseWriter, r *http.Request) {
+	var request ForceDisconnectRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "unable to parse the request", http.StatusBadRequest)
+		return
+	}
+
+	if len(request.Users) == 0 {
+		http.Error(w, "no users given", http.StatusBadRequest)
+		return
+	}
+
+	result := &ForceDisconnectResult{}
+	var users []*model.User
+	for _, usernameOrEmail := range request.Users {
+		user, err := a.p.lookupUser(usernameOrEmail)
+		if err != nil {
+			result.NotFound = append(result.NotFound, usernameOrEmail)
+			continue
+		}
+
+		users = append(users, user)
+	}
+
+	a.p.forceDisconnectUsers(users, request.Reason, r.Header.Get("Mattermost-User-ID"), result)
+	a.returnJSON(w, result)
+}
+
+func (a *API) forceDisconnectUsersFile(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "error reading file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	parsed, err := a.p.parseEmailsCSV(file)
+	if err != nil {
+		http.Error(w, "error parsing file - please check header and try again", http.StatusBadRequest)
+		return
+	}
+
+	if len(parsed.FailedLines) > UpdateWhitelistCsvParseErrThreshold {
+		http.Error(w, "error parsing file - please check data at line(s) "+strings.Join(parsed.FailedLines, ", ")+" and try again", http.StatusBadRequest)
+		return
+	}
+
+	result := &ForceDisconnectResult{
+		NotFound:    parsed.Failed,
+		FailedLines: parsed.FailedLines,
+	}
+
+	a.p.forceDisconnectUsers(parsed.Users, r.FormValue("reason"), r.Header.Get("Mattermost-User-ID"), result)
+	a.returnJSON(w, result)
+}
+
 func (p *Plugin) getConnectedUsersList() ([]*storemodels.ConnectedUser, error) {
 	page := DefaultPage
 	perPage := MaxPerPage
@@ -828,7 +1122,7 @@ func (p *Plugin) getWhitelistEmails() ([]string, error) {
 	perPage := MaxPerPage
 	var result []string
 	for {
-		emails, err := p.store.GetWhitelistEmails(page, perPage)
+		emails, err := p.store.GetWhitelistEmails("", page, perPage)
 		if err != nil {
 			return nil, err
 		}
@@ -874,50 +1168,54 @@ func GetPageAndPerPage(r *http.Request) (page, perPage int) {
 	return page, perPage
 }
 
-func (a *API) siteStats(w http.ResponseWriter, r *http.Request) {
-	userID := r.Header.Get("Mattermost-User-ID")
-
-	if !a.p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
-		a.p.API.LogWarn("Insufficient permissions", "user_id", userID)
-		http.Error(w, "not able to authorize the user", http.StatusForbidden)
-		return
-	}
+// SiteStats summarizes the usage of the plugin across the site.
+type SiteStats struct {
+	TotalConnectedUsers   int64 `json:"total_connected_users"`
+	PendingInvitedUsers   int64 `json:"pending_invited_users"`
+	CurrentWhitelistUsers int64 `json:"current_whitelist_users"`
+	TotalActiveUsers      int64 `json:"total_active_users"`
+}
 
-	connectedUsersCount, err := a.p.store.GetConnectedUsersCount()
+func (p *Plugin) getSiteStats() (*SiteStats, error) {
+	connectedUsersCount, err := p.store.GetConnectedUsersCount()
 	if err != nil {
-		a.p.API.LogWarn("Failed to get connected users count", "error", err.Error())
-		http.Error(w, "unable to get connected users count", http.StatusInternalServerError)
-		return
+		return nil, errors.Wrap(err, "unable to get connected users count")
 	}
-	pendingInvites, err := a.p.store.GetInvitedCount()
+	pendingInvites, err := p.store.GetInvitedCount()
 	if err != nil {
-		a.p.API.LogWarn("Failed to get invited users count", "error", err.Error())
-		http.Error(w, "unable to get invited users count", http.StatusInternalServerError)
-		return
+		return nil, errors.Wrap(err, "unable to get invited users count")
 	}
-	whitelistedUsers, err := a.p.store.GetWhitelistCount()
+	whitelistedUsers, err := p.store.GetWhitelistCount()
 	if err != nil {
-		a.p.API.LogWarn("Failed to get whitelisted users count", "error", err.Error())
-		http.Error(w, "unable to get whitelisted users count", http.StatusInternalServerError)
-		return
+		return nil, errors.Wrap(err, "unable to get whitelisted users count")
 	}
-	totalActiveUsers, err := a.p.store.GetActiveUsersCount(metricsActiveUsersRange)
+	totalActiveUsers, err := p.store.GetActiveUsersCount(metricsActiveUsersRange)
 	if err != nil {
-		a.p.API.LogWarn("Failed to get users receiving count", "error", err.Error())
-		http.Error(w, "unable to get users receiving count", http.StatusInternalServerError)
-		return
+		return nil, errors.Wrap(err, "unable to get users receiving count")
 	}
 
-	siteStats := struct {
-		TotalConnectedUsers   int64 `json:"total_connected_users"`
-		PendingInvitedUsers   int64 `json:"pending_invited_users"`
-		CurrentWhitelistUsers int64 `json:"current_whitelist_users"`
-		TotalActiveUsers      int64 `json:"total_active_users"`
-	}{
+	return &SiteStats{
 		TotalConnectedUsers:   connectedUsersCount,
 		PendingInvitedUsers:   int64(pendingInvites),
 		CurrentWhitelistUsers: int64(whitelistedUsers),
 		TotalActiveUsers:      totalActiveUsers,
+	}, nil
+}
+
+func (a *API) siteStats(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-ID")
+
+	if !a.p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+		a.p.API.LogWarn("Insufficient permissions", "user_id", userID)
+		http.Error(w, "not able to authorize the user", http.StatusForbidden)
+		return
+	}
+
+	siteStats, err := a.p.getSiteStats()
+	if err != nil {
+		a.p.API.LogWarn("Failed to get site stats", "error", err.Error())
+		http.Error(w, "unable to get site stats", http.StatusInternalServerError)
+		return
 	}
 
 	a.returnJSON(w, siteStats)
@@ -7,9 +7,12 @@ import (
 	"bytes"
 	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,6 +23,7 @@ import (
 
 	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/client_breakerlayer"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
 	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
 )
@@ -168,6 +172,71 @@ func TestProcessActivity(t *testing.T) {
 		assert.Equal(t, http.StatusAccepted, statusCode)
 		assert.Empty(t, bodyString)
 	})
+
+	t.Run("presence changes handled right away", func(t *testing.T) {
+		th.Reset(t)
+
+		presences := []clientmodels.Presence{
+			{UserID: "teams-user-1", Activity: PresenceActivityAvailable, Availability: PresenceAvailabilityAvailable},
+			{UserID: "teams-user-2", Activity: PresenceActivityAvailable, Availability: PresenceAvailabilityAvailable},
+		}
+		th.p.presenceCache.Add(presences...)
+
+		activities := []msteams.Activity{
+			{
+				Resource:    "communications/presences('teams-user-1')",
+				ChangeType:  "updated",
+				ClientState: "webhooksecret",
+			},
+			{
+				Resource:    "communications/presences('teams-user-2')",
+				ChangeType:  "updated",
+				ClientState: "webhooksecret",
+			},
+		}
+
+		statusCode, bodyString := sendRequest(t, activities)
+		assert.Equal(t, http.StatusAccepted, statusCode)
+		assert.Empty(t, bodyString)
+
+		_, missing := th.p.presenceCache.Get([]string{"teams-user-1", "teams-user-2"})
+		assert.ElementsMatch(t, []string{"teams-user-1", "teams-user-2"}, missing)
+	})
+
+	for _, cloud := range []msteams.Cloud{msteams.CloudUSGovernment, msteams.CloudUSGovernmentDoD, msteams.CloudChina} {
+		t.Run("national cloud "+string(cloud), func(t *testing.T) {
+			th.Reset(t)
+			th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+				c.CloudEnvironment = string(cloud)
+			})
+
+			response, err := http.Post(apiURL+"?validationToken=test", "text/plain", nil)
+			require.NoError(t, err)
+			defer response.Body.Close()
+			bodyBytes, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, "test", string(bodyBytes))
+
+			activities := []msteams.Activity{
+				{
+					Resource:                       "test",
+					ChangeType:                     "created",
+					ClientState:                    "webhooksecret",
+					SubscriptionExpirationDateTime: time.Now().Add(10 * time.Minute),
+				},
+			}
+
+			statusCode, bodyString := sendRequest(t, activities)
+			assert.Equal(t, http.StatusAccepted, statusCode)
+			assert.Empty(t, bodyString)
+
+			activities[0].ClientState = "invalid"
+			statusCode, bodyString = sendRequest(t, activities)
+			assert.Equal(t, http.StatusBadRequest, statusCode)
+			assert.Equal(t, "Invalid webhook secret\n", bodyString)
+		})
+	}
 }
 
 func TestProcessLifecycle(t *testing.T) {
@@ -264,6 +333,36 @@ func TestProcessLifecycle(t *testing.T) {
 		assert.Equal(t, "Invalid webhook secret\n", bodyString)
 	})
 
+	for _, cloud := range []msteams.Cloud{msteams.CloudUSGovernment, msteams.CloudUSGovernmentDoD, msteams.CloudChina} {
+		t.Run("national cloud "+string(cloud), func(t *testing.T) {
+			th.Reset(t)
+			th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+				c.CloudEnvironment = string(cloud)
+			})
+
+			response, err := http.Post(apiURL+"?validationToken=test", "text/plain", nil)
+			require.NoError(t, err)
+			defer response.Body.Close()
+			bodyBytes, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, "test", string(bodyBytes))
+
+			activities := []msteams.Activity{
+				{
+					Resource:       "mockResource",
+					ChangeType:     "mockChangeType",
+					ClientState:    "mockClientState",
+					LifecycleEvent: "reauthorizationRequired",
+				},
+			}
+
+			statusCode, bodyString := sendRequest(t, activities)
+			assert.Equal(t, http.StatusBadRequest, statusCode)
+			assert.Equal(t, "Invalid webhook secret\n", bodyString)
+		})
+	}
+
 	t.Run("valid payload, unknown subscription", func(t *testing.T) {
 		th.Reset(t)
 
@@ -704,6 +803,66 @@ func TestConnect(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, "login.microsoftonline.com", actualURL.Host)
 		assert.Regexp(t, "oauth2/v2.0/authorize$", actualURL.Path)
+
+		stateToken, err := decodeOAuth2StateToken(actualURL.Query().Get("state"))
+		require.NoError(t, err)
+		assert.Equal(t, user1.Id, stateToken.UserID)
+	})
+
+	t.Run("bot message link with an expired state", func(t *testing.T) {
+		th.Reset(t)
+
+		user1 := th.SetupUser(t, team)
+		channel, appErr := th.p.API.GetDirectChannel(user1.Id, th.p.botUserID)
+		require.Nil(t, appErr)
+		post, appErr := th.p.API.CreatePost(&model.Post{
+			UserId:    th.p.botUserID,
+			ChannelId: channel.Id,
+			Message:   "Connect your account",
+		})
+		require.Nil(t, appErr)
+
+		statusCode, location := sendRequest(t, user1, channel.Id, post.Id)
+		assert.Equal(t, http.StatusSeeOther, statusCode)
+
+		actualURL, err := url.Parse(location)
+		require.NoError(t, err)
+		stateToken, err := decodeOAuth2StateToken(actualURL.Query().Get("state"))
+		require.NoError(t, err)
+
+		// The state is issued again for the bot message.
+		state, err := th.p.store.VerifyOAuth2State(stateToken.ID, user1.Id)
+		require.NoError(t, err)
+		assert.Equal(t, storemodels.OAuth2StateOriginBotMessage, state.Origin)
+		assert.Equal(t, channel.Id, state.ChannelID)
+		assert.Equal(t, post.Id, state.PostID)
+	})
+
+	t.Run("link to another user's post with an expired state", func(t *testing.T) {
+		th.Reset(t)
+
+		user1 := th.SetupUser(t, team)
+		user2 := th.SetupUser(t, team)
+		channel, appErr := th.p.API.GetDirectChannel(user2.Id, th.p.botUserID)
+		require.Nil(t, appErr)
+		post, appErr := th.p.API.CreatePost(&model.Post{
+			UserId:    th.p.botUserID,
+			ChannelId: channel.Id,
+			Message:   "Connect your account",
+		})
+		require.Nil(t, appErr)
+
+		statusCode, location := sendRequest(t, user1, channel.Id, post.Id)
+		assert.Equal(t, http.StatusSeeOther, statusCode)
+
+		actualURL, err := url.Parse(location)
+		require.NoError(t, err)
+		stateToken, err := decodeOAuth2StateToken(actualURL.Query().Get("state"))
+		require.NoError(t, err)
+
+		// No state is issued, so the flow fails once redirected back.
+		_, err = th.p.store.VerifyOAuth2State(stateToken.ID, user1.Id)
+		assert.Error(t, err)
 	})
 }
 
@@ -894,6 +1053,148 @@ func TestWhitelistDownload(t *testing.T) {
 	t.Skip()
 }
 
+func TestWhitelistUsers(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	sendRequest := func(t *testing.T, user *model.User, method, path string, body io.Reader) (int, string) {
+		t.Helper()
+		client1 := th.SetupClient(t, user.Id)
+
+		request, err := http.NewRequest(method, th.pluginURL(t, path), body)
+		require.NoError(t, err)
+
+		request.Header.Set(model.HeaderAuth, client1.AuthType+" "+client1.AuthToken)
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, response.Body.Close())
+		})
+
+		bodyBytes, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		return response.StatusCode, string(bodyBytes)
+	}
+
+	t.Run("insufficient permissions", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		statusCode, bodyString := sendRequest(t, user, http.MethodGet, "/whitelist", nil)
+		assert.Equal(t, http.StatusForbidden, statusCode)
+		assert.Equal(t, "not able to authorize the user\n", bodyString)
+
+		statusCode, _ = sendRequest(t, user, http.MethodPost, "/whitelist/users", strings.NewReader(`{"user":"`+user.Username+`"}`))
+		assert.Equal(t, http.StatusForbidden, statusCode)
+	})
+
+	t.Run("add, check and remove", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+
+		statusCode, bodyString := sendRequest(t, sysadmin, http.MethodPost, "/whitelist/users", strings.NewReader(`{"user":"@`+user.Username+`"}`))
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.JSONEq(t, fmt.Sprintf(`{"user_id":%q,"username":%q,"email":%q,"whitelisted":true}`, user.Id, user.Username, user.Email), bodyString)
+
+		statusCode, bodyString = sendRequest(t, sysadmin, http.MethodGet, "/whitelist/users/"+user.Email, nil)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.JSONEq(t, fmt.Sprintf(`{"user_id":%q,"username":%q,"email":%q,"whitelisted":true}`, user.Id, user.Username, user.Email), bodyString)
+
+		statusCode, bodyString = sendRequest(t, sysadmin, http.MethodDelete, "/whitelist/users/"+user.Username, nil)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.JSONEq(t, fmt.Sprintf(`{"user_id":%q,"username":%q,"email":%q,"whitelisted":false}`, user.Id, user.Username, user.Email), bodyString)
+
+		whitelisted, err := th.p.store.IsUserWhitelisted(user.Id)
+		require.NoError(t, err)
+		assert.False(t, whitelisted)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+
+		statusCode, _ := sendRequest(t, sysadmin, http.MethodPost, "/whitelist/users", strings.NewReader(`{"user":"unknown"}`))
+		assert.Equal(t, http.StatusNotFound, statusCode)
+
+		statusCode, _ = sendRequest(t, sysadmin, http.MethodGet, "/whitelist/users/unknown", nil)
+		assert.Equal(t, http.StatusNotFound, statusCode)
+	})
+
+	t.Run("list with search and pagination", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user1 := th.SetupUser(t, team)
+		user2 := th.SetupUser(t, team)
+		th.MarkUserWhitelisted(t, user1.Id)
+		th.MarkUserWhitelisted(t, user2.Id)
+
+		statusCode, bodyString := sendRequest(t, sysadmin, http.MethodGet, "/whitelist", nil)
+		assert.Equal(t, http.StatusOK, statusCode)
+		var emails []string
+		require.NoError(t, json.Unmarshal([]byte(bodyString), &emails))
+		assert.ElementsMatch(t, []string{user1.Email, user2.Email}, emails)
+
+		statusCode, bodyString = sendRequest(t, sysadmin, http.MethodGet, "/whitelist?search="+user2.Username, nil)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.JSONEq(t, fmt.Sprintf(`[%q]`, user2.Email), bodyString)
+
+		statusCode, bodyString = sendRequest(t, sysadmin, http.MethodGet, "/whitelist?page=1&per_page=2", nil)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.JSONEq(t, `[]`, bodyString)
+	})
+
+	t.Run("dry run upload", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user1 := th.SetupUser(t, team)
+		user2 := th.SetupUser(t, team)
+		th.MarkUserWhitelisted(t, user1.Id)
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("file", "whitelist.csv")
+		require.NoError(t, err)
+		_, err = part.Write([]byte("email\n" + user2.Email + "\n" + user2.Email + "\nunknown@example.com\n"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		client1 := th.SetupClient(t, sysadmin.Id)
+		request, err := http.NewRequest(http.MethodPut, th.pluginURL(t, "/whitelist?dry_run=true"), body)
+		require.NoError(t, err)
+		request.Header.Set(model.HeaderAuth, client1.AuthType+" "+client1.AuthToken)
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, response.Body.Close())
+		})
+		bodyBytes, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.JSONEq(t, fmt.Sprintf(`{
+			"count": 1,
+			"failed": ["unknown@example.com"],
+			"failedLines": null,
+			"duplicates": [%q],
+			"dryRun": true,
+			"added": [%q],
+			"removed": [%q]
+		}`, user2.Email, user2.Email, user1.Email), string(bodyBytes))
+
+		whitelisted, err := th.p.store.IsUserWhitelisted(user2.Id)
+		require.NoError(t, err)
+		assert.False(t, whitelisted)
+		whitelisted, err = th.p.store.IsUserWhitelisted(user1.Id)
+		require.NoError(t, err)
+		assert.True(t, whitelisted)
+	})
+}
+
 func TestNotifyConnect(t *testing.T) {
 	th := setupTestHelper(t)
 	apiURL := th.pluginURL(t, "/notify-connect")
@@ -1097,4 +1398,144 @@ func TestConnectionStatus(t *testing.T) {
 		connected := sendRequest(t, user)
 		assert.False(t, connected)
 	})
+
+	t.Run("connected users should get the connection details", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+		require.NoError(t, th.p.setNotificationPreference(user.Id, true))
+		require.NoError(t, th.p.store.SetUserLastChatReceivedAt(user.Id, time.Now().UnixMicro()))
+
+		client := th.SetupClient(t, user.Id)
+		request, err := http.NewRequest(http.MethodGet, apiURL, nil)
+		require.NoError(t, err)
+		request.Header.Set(model.HeaderAuth, client.AuthType+" "+client.AuthToken)
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, response.Body.Close())
+		})
+
+		var status ConnectionStatus
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&status))
+		assert.True(t, status.Connected)
+		assert.False(t, status.AutoLinked)
+		assert.Equal(t, "t"+user.Id, status.TeamsUserID)
+		assert.NotZero(t, status.ConnectedAt)
+		assert.True(t, status.NotificationsEnabled)
+		assert.NotZero(t, status.LastNotificationAt)
+
+		// The token is only checked when asked for.
+		th.clientMock.AssertNotCalled(t, "GetMe")
+		assert.Empty(t, status.TeamsDisplayName)
+		assert.Nil(t, status.TokenValid)
+		assert.False(t, status.TokenCheckFailed)
+	})
+
+	t.Run("connected users should get the Teams account when checking the token", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+
+		th.clientMock.On("GetMe").Return(&clientmodels.User{ID: "t" + user.Id, DisplayName: "Teams User", Mail: user.Email}, nil).Times(1)
+
+		client := th.SetupClient(t, user.Id)
+		request, err := http.NewRequest(http.MethodGet, apiURL+"?check_token=true", nil)
+		require.NoError(t, err)
+		request.Header.Set(model.HeaderAuth, client.AuthType+" "+client.AuthToken)
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, response.Body.Close())
+		})
+
+		var status ConnectionStatus
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&status))
+		assert.True(t, status.Connected)
+		assert.Equal(t, "Teams User", status.TeamsDisplayName)
+		assert.Equal(t, user.Email, status.TeamsEmail)
+		require.NotNil(t, status.TokenValid)
+		assert.True(t, *status.TokenValid)
+		assert.False(t, status.TokenCheckFailed)
+	})
+
+	t.Run("connected users with a rejected token should be reported disconnected", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+
+		th.clientMock.On("GetMe").Return(nil, &msteams.GraphAPIError{StatusCode: http.StatusUnauthorized}).Times(1)
+
+		client := th.SetupClient(t, user.Id)
+		request, err := http.NewRequest(http.MethodGet, apiURL+"?check_token=true", nil)
+		require.NoError(t, err)
+		request.Header.Set(model.HeaderAuth, client.AuthType+" "+client.AuthToken)
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, response.Body.Close())
+		})
+
+		var status ConnectionStatus
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&status))
+		assert.False(t, status.Connected)
+		assert.NotZero(t, status.DisconnectedAt)
+		require.NotNil(t, status.TokenValid)
+		assert.False(t, *status.TokenValid)
+	})
+}
+
+func TestGetCircuitBreakers(t *testing.T) {
+	th := setupTestHelper(t)
+	apiURL := th.pluginURL(t, "/circuit-breakers")
+	team := th.SetupTeam(t)
+
+	sendRequest := func(t *testing.T, user *model.User) (int, string) {
+		t.Helper()
+		client1 := th.SetupClient(t, user.Id)
+
+		request, err := http.NewRequest(http.MethodGet, apiURL, nil)
+		require.NoError(t, err)
+
+		request.Header.Set(model.HeaderAuth, client1.AuthType+" "+client1.AuthToken)
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, response.Body.Close())
+		})
+
+		bodyBytes, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		return response.StatusCode, string(bodyBytes)
+	}
+
+	t.Run("insufficient permissions", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		statusCode, bodyString := sendRequest(t, user)
+		assert.Equal(t, http.StatusForbidden, statusCode)
+		assert.Equal(t, "not able to authorize the user\n", bodyString)
+	})
+
+	t.Run("breaker states", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+
+		previousBreakers := th.p.clientBreakers
+		th.p.clientBreakers = client_breakerlayer.NewBreakers(th.p.GetMetrics(), 50)
+		t.Cleanup(func() {
+			th.p.clientBreakers = previousBreakers
+		})
+		require.NoError(t, th.p.clientBreakers.Do("GetChat", func() error { return nil }))
+
+		statusCode, bodyString := sendRequest(t, sysadmin)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.JSONEq(t, `[{"family":"chats", "state":"closed", "requests":1, "failures":0}]`, bodyString)
+	})
 }
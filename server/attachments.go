@@ -114,15 +114,18 @@ func (ah *ActivityHandler) handleAttachments(channelID, userID, text string, msg
 	parentID := ""
 	countNonFileAttachments := 0
 	countFileAttachments := 0
-	var client msteams.Client
+	// The hosted contents and code snippets of chat messages may be fetched with the application
+	// permissions, but the files shared in chats live in the OneDrive of their owner, which only
+	// the chat members may access.
+	var client, fileClient msteams.Client
 	if chat == nil {
 		client = ah.plugin.GetClientForApp()
+		fileClient = client
 	} else {
-		for _, member := range chat.Members {
-			client, _ = ah.plugin.GetClientForTeamsUser(member.UserID)
-			if client != nil {
-				break
-			}
+		fileClient = ah.getClientForChatMember(chat)
+		client = fileClient
+		if ah.plugin.getConfiguration().UseAppClientForMessages() {
+			client = ah.plugin.GetClientForApp()
 		}
 	}
 
@@ -205,7 +208,14 @@ func (ah *ActivityHandler) handleAttachments(channelID, userID, text string, msg
 				continue
 			}
 		} else {
-			fileSize, downloadURL, err = client.GetFileSizeAndDownloadURL(a.ContentURL)
+			if fileClient == nil {
+				logger.Warn("no connected chat member to download the file")
+				ah.plugin.GetMetrics().ObserveFile(metrics.ActionCreated, metrics.ActionSourceMSTeams, metrics.DiscardedReasonNoConnectedUser, isDirectOrGroupMessage)
+				skippedFileAttachments++
+				continue
+			}
+
+			fileSize, downloadURL, err = fileClient.GetFileSizeAndDownloadURL(a.ContentURL)
 			if err != nil {
 				logger.WithError(err).Warn("failed to get file size and download URL")
 				ah.plugin.GetMetrics().ObserveFile(metrics.ActionCreated, metrics.ActionSourceMSTeams, metrics.DiscardedReasonUnableToGetTeamsData, isDirectOrGroupMessage)
@@ -227,7 +237,7 @@ func (ah *ActivityHandler) handleAttachments(channelID, userID, text string, msg
 
 			// If the file size is less than or equal to the configurable value, then download the file directly instead of streaming
 			if fileSize <= int64(ah.plugin.GetMaxSizeForCompleteDownload()*1024*1024) {
-				attachmentData, err = client.GetFileContent(downloadURL)
+				attachmentData, err = fileClient.GetFileContent(downloadURL)
 				if err != nil {
 					logger.WithError(err).Warn("failed to get file content")
 					ah.plugin.GetMetrics().ObserveFile(metrics.ActionCreated, metrics.ActionSourceMSTeams, metrics.DiscardedReasonUnableToGetTeamsData, isDirectOrGroupMessage)
@@ -240,7 +250,7 @@ func (ah *ActivityHandler) handleAttachments(channelID, userID, text string, msg
 		if attachmentData != nil {
 			fileInfoID, errorFound = ah.ProcessAndUploadFileToMM(attachmentData, a.Name, channelID)
 		} else {
-			fileInfoID = ah.GetFileFromTeamsAndUploadToMM(downloadURL, client, &model.UploadSession{
+			fileInfoID = ah.GetFileFromTeamsAndUploadToMM(downloadURL, fileClient, &model.UploadSession{
 				Id:        model.NewId(),
 				Type:      model.UploadTypeAttachment,
 				ChannelId: channelID,
@@ -703,6 +703,63 @@ snippet content
 		assert.False(t, errorsFound)
 	})
 
+	t.Run("chat without connected members, fetching messages with app client", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.FetchMessagesWithAppClient = true
+		})
+
+		user := th.SetupUser(t, team)
+		th.DisconnectUser(t, user.Id)
+		channel := th.SetupPublicChannel(t, team, WithMembers(user))
+
+		text := "message"
+		message := &clientmodels.Message{
+			Attachments: []clientmodels.Attachment{
+				{
+					Name:        "mock-name",
+					ContentType: "application/vnd.microsoft.card.codesnippet",
+					Content:     `{"language": "go", "codeSnippetUrl": "https://example.com/version/chats/mock-chat-id/messages/mock-message-id/hostedContents/mock-content-id/$value"}`,
+				},
+				{
+					Name:        "mock-file",
+					ContentType: "reference",
+					ContentURL:  "https://example.com/path/to/file.png",
+				},
+			},
+			ChatID: "mock-chat-id",
+		}
+		chat := &clientmodels.Chat{
+			ID: "mock-chat-id",
+			Members: []clientmodels.ChatMember{
+				{UserID: "t" + user.Id},
+			},
+		}
+		existingFileIDs := []string{}
+
+		th.appClientMock.On("GetCodeSnippet", "https://example.com/version/chats/mock-chat-id/messages/mock-message-id/hostedContents/mock-content-id/$value").Return("snippet content", nil).Once()
+
+		newText, attachmentIDs, parentID, skippedFileAttachments, errorsFound := th.p.activityHandler.handleAttachments(
+			channel.Id,
+			user.Id,
+			text,
+			message,
+			chat,
+			existingFileIDs,
+		)
+
+		// The file shared in the chat can't be fetched without a connected member.
+		assert.Equal(t, `message
+`+"```"+`go
+snippet content
+`+"```"+`
+`, newText)
+		assert.Len(t, attachmentIDs, 0)
+		assert.Equal(t, "", parentID)
+		assert.Equal(t, 1, skippedFileAttachments)
+		assert.False(t, errorsFound)
+	})
+
 	t.Run("message reference", func(t *testing.T) {
 		th.Reset(t)
 
@@ -0,0 +1,35 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	auditEventCleanupUser         = "msteamsCleanupUser"
+	auditEventExportUserData      = "msteamsExportUserData"
+	auditEventEraseUserData       = "msteamsEraseUserData"
+	auditEventForceDisconnectUser = "msteamsForceDisconnectUser"
+)
+
+// newAuditRecord starts an audit record for an action taken by the given user, or by the
+// plugin itself when actorUserID is empty.
+func newAuditRecord(eventName, actorUserID string) *model.AuditRecord {
+	return &model.AuditRecord{
+		EventName: eventName,
+		Status:    model.AuditStatusAttempt,
+		EventData: model.AuditEventData{
+			Parameters:  map[string]any{},
+			PriorState:  map[string]any{},
+			ResultState: map[string]any{},
+		},
+		Actor: model.AuditEventActor{
+			UserId: actorUserID,
+		},
+		Meta: map[string]any{
+			"plugin_id": pluginID,
+		},
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"database/sql"
+	"runtime/debug"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+const autoLinkUsersPerPage = 100
+
+// autoLinkUsers maps Mattermost users to Teams users with the same email address, without
+// requiring the users to connect their account. Linked users have no token stored, so
+// everything done on their behalf relies on the application client.
+func (p *Plugin) autoLinkUsers() {
+	defer func() {
+		if r := recover(); r != nil {
+			p.GetMetrics().ObserveGoroutineFailure()
+			p.API.LogError("Recovering from panic", "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+
+	if !p.getConfiguration().AutoLinkUsers {
+		return
+	}
+
+	done := p.GetMetrics().ObserveWorker(metrics.WorkerAutoLinkUsers)
+	defer done()
+
+	p.API.LogInfo("Running the auto-link users job")
+
+	teamsUsers, err := p.GetClientForApp().ListUsers()
+	if err != nil {
+		p.API.LogWarn("Failed to list Teams users", "error", err.Error())
+		return
+	}
+
+	teamsUsersByEmail := make(map[string]clientmodels.User, len(teamsUsers))
+	for _, teamsUser := range teamsUsers {
+		if teamsUser.Mail == "" || teamsUser.Type == msteamsUserTypeGuest || !teamsUser.IsAccountEnabled {
+			continue
+		}
+		teamsUsersByEmail[strings.ToLower(teamsUser.Mail)] = teamsUser
+	}
+
+	linked := 0
+	for page := 0; ; page++ {
+		users, appErr := p.API.GetUsers(&model.UserGetOptions{
+			Page:    page,
+			PerPage: autoLinkUsersPerPage,
+			Active:  true,
+		})
+		if appErr != nil {
+			p.API.LogWarn("Failed to list Mattermost users", "page", page, "error", appErr.Error())
+			return
+		}
+
+		for _, user := range users {
+			if user.IsBot || user.IsGuest() {
+				continue
+			}
+
+			teamsUser, ok := teamsUsersByEmail[strings.ToLower(user.Email)]
+			if !ok {
+				continue
+			}
+
+			wasLinked, linkErr := p.autoLinkUser(user.Id, teamsUser.ID)
+			if linkErr != nil {
+				p.API.LogWarn("Failed to auto-link user", "user_id", user.Id, "teams_user_id", teamsUser.ID, "error", linkErr.Error())
+				continue
+			}
+			if wasLinked {
+				linked++
+			}
+		}
+
+		if len(users) < autoLinkUsersPerPage {
+			break
+		}
+	}
+
+	p.API.LogInfo("Finished the auto-link users job", "linked_users", linked)
+}
+
+// autoLinkUser records the mapping between the given users unless either side is already
+// mapped, in which case the existing mapping (and any token) is left untouched.
+func (p *Plugin) autoLinkUser(mmUserID, teamsUserID string) (bool, error) {
+	if _, err := p.store.MattermostToTeamsUserID(mmUserID); err == nil {
+		return false, nil
+	} else if err != sql.ErrNoRows {
+		return false, errors.Wrap(err, "error in getting the Teams user for the Mattermost user")
+	}
+
+	if _, err := p.store.TeamsToMattermostUserID(teamsUserID); err == nil {
+		return false, nil
+	} else if err != sql.ErrNoRows {
+		return false, errors.Wrap(err, "error in getting the Mattermost user for the Teams user")
+	}
+
+	if err := p.store.SetUserInfo(mmUserID, teamsUserID, nil); err != nil {
+		return false, errors.Wrap(err, "error in storing the user mapping")
+	}
+
+	p.API.LogInfo("Auto-linked user to Teams", "user_id", mmUserID, "teams_user_id", teamsUserID)
+
+	return true, nil
+}
+
+// IsUserLinked reports whether notifications can be delivered to the given user, either because
+// they connected their account or because they were auto-linked by email.
+func (p *Plugin) IsUserLinked(userID string) (bool, error) {
+	isConnected, err := p.IsUserConnected(userID)
+	if err != nil {
+		return false, err
+	}
+	if isConnected || !p.getConfiguration().AutoLinkUsers {
+		return isConnected, nil
+	}
+
+	if _, err := p.store.MattermostToTeamsUserID(userID); err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "Unable to determine if user is linked to MS Teams")
+	}
+
+	return true, nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+func TestAutoLinkUsers(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	t.Run("disabled", func(t *testing.T) {
+		th.Reset(t)
+
+		th.p.autoLinkUsers()
+	})
+
+	t.Run("links users by email", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.AutoLinkUsers = true
+		})
+
+		user1 := th.SetupUser(t, team)
+		user2 := th.SetupUser(t, team)
+		guestUser := th.SetupUser(t, team)
+		disabledUser := th.SetupUser(t, team)
+		unmatchedUser := th.SetupUser(t, team)
+
+		connectedUser := th.SetupUser(t, team)
+		th.ConnectUser(t, connectedUser.Id)
+
+		th.appClientMock.On("ListUsers").Return([]clientmodels.User{
+			{ID: "teams-user1", Mail: user1.Email, IsAccountEnabled: true},
+			{ID: "teams-user2", Mail: user2.Email, IsAccountEnabled: true},
+			{ID: "teams-guest", Mail: guestUser.Email, IsAccountEnabled: true, Type: msteamsUserTypeGuest},
+			{ID: "teams-disabled", Mail: disabledUser.Email, IsAccountEnabled: false},
+			{ID: "teams-connected", Mail: connectedUser.Email, IsAccountEnabled: true},
+		}, nil).Times(1)
+
+		th.p.autoLinkUsers()
+
+		teamsUserID, err := th.p.store.MattermostToTeamsUserID(user1.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "teams-user1", teamsUserID)
+
+		teamsUserID, err = th.p.store.MattermostToTeamsUserID(user2.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "teams-user2", teamsUserID)
+
+		for _, user := range []*model.User{guestUser, disabledUser, unmatchedUser} {
+			_, err = th.p.store.MattermostToTeamsUserID(user.Id)
+			assert.Error(t, err)
+		}
+
+		// The existing connection is preserved.
+		teamsUserID, err = th.p.store.MattermostToTeamsUserID(connectedUser.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "t"+connectedUser.Id, teamsUserID)
+		token, err := th.p.store.GetTokenForMattermostUser(connectedUser.Id)
+		require.NoError(t, err)
+		assert.NotNil(t, token)
+	})
+
+	t.Run("matches mixed-case emails", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.AutoLinkUsers = true
+		})
+
+		user := th.SetupUser(t, team)
+
+		th.appClientMock.On("ListUsers").Return([]clientmodels.User{
+			{ID: "teams-user", Mail: strings.ToUpper(user.Username[:1]) + user.Username[1:] + "@Example.com", IsAccountEnabled: true},
+		}, nil).Times(1)
+
+		th.p.autoLinkUsers()
+
+		teamsUserID, err := th.p.store.MattermostToTeamsUserID(user.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "teams-user", teamsUserID)
+	})
+}
+
+func TestIsUserLinked(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	for _, autoLinkUsers := range []bool{false, true} {
+		t.Run(fmt.Sprintf("auto-link %t", autoLinkUsers), func(t *testing.T) {
+			th.Reset(t)
+			th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+				c.AutoLinkUsers = autoLinkUsers
+			})
+
+			unlinkedUser := th.SetupUser(t, team)
+
+			connectedUser := th.SetupUser(t, team)
+			th.ConnectUser(t, connectedUser.Id)
+
+			linkedUser := th.SetupUser(t, team)
+			th.DisconnectUser(t, linkedUser.Id)
+
+			isLinked, err := th.p.IsUserLinked(unlinkedUser.Id)
+			require.NoError(t, err)
+			assert.False(t, isLinked)
+
+			isLinked, err = th.p.IsUserLinked(connectedUser.Id)
+			require.NoError(t, err)
+			assert.True(t, isLinked)
+
+			isLinked, err = th.p.IsUserLinked(linkedUser.Id)
+			require.NoError(t, err)
+			assert.Equal(t, autoLinkUsers, isLinked)
+		})
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
 )
 
 func (p *Plugin) botSendDirectPost(userID string, post *model.Post) error {
@@ -30,15 +32,41 @@ func (p *Plugin) botSendDirectPost(userID string, post *model.Post) error {
 
 // createAndStoreOAuthState creates an OAuth state for a bot message connect URL and stores it
 func (p *Plugin) createAndStoreOAuthState(userID, channelID, postID string) string {
-	stateSuffix := fmt.Sprintf("fromBotMessage:%s|%s", channelID, postID)
-	stateID := model.NewId()
-	state := fmt.Sprintf("%s_%s_%s", stateID, userID, stateSuffix)
-
+	state := newOAuth2State(userID, storemodels.OAuth2StateOriginBotMessage, channelID, postID, oAuth2BotMessageStateTimeToLive)
 	if err := p.store.StoreOAuth2State(state); err != nil {
 		p.GetAPI().LogWarn("Error in storing the OAuth state", "error", err.Error())
 	}
 
-	return fmt.Sprintf(p.GetURL()+"/connect?post_id=%s&channel_id=%s&state_id=%s", postID, channelID, stateID)
+	return fmt.Sprintf(p.GetURL()+"/connect?post_id=%s&channel_id=%s&state_id=%s", postID, channelID, state.ID)
+}
+
+// reissueBotMessageOAuth2State stores a new OAuth state for the connect link of a post the bot sent
+// to the user, whose state expired before the link was clicked.
+func (p *Plugin) reissueBotMessageOAuth2State(userID, channelID, postID string) (*storemodels.OAuth2State, error) {
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get the bot message")
+	}
+
+	if post.UserId != p.botUserID || post.ChannelId != channelID {
+		return nil, errors.New("not a bot message in the given channel")
+	}
+
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get the channel of the bot message")
+	}
+
+	if channel.Name != model.GetDMNameFromIds(userID, p.botUserID) {
+		return nil, errors.New("not a bot message sent to the user")
+	}
+
+	state := newOAuth2State(userID, storemodels.OAuth2StateOriginBotMessage, channelID, postID, oAuth2StateTimeToLive)
+	if err := p.store.StoreOAuth2State(state); err != nil {
+		return nil, errors.Wrap(err, "failed to store the OAuth state")
+	}
+
+	return state, nil
 }
 
 func (p *Plugin) SendEphemeralConnectMessage(channelID string, userID string, message string) {
@@ -174,24 +202,58 @@ func (p *Plugin) notifyChat(recipientUserID string, actorDisplayName string, cha
 
 func (p *Plugin) SendInviteMessage(user *model.User) error {
 	message := fmt.Sprintf("@%s, you've been invited by your administrator to connect your Mattermost account with Microsoft Teams.", user.Username)
-	invitePost := &model.Post{
+
+	return p.botSendConnectLinkPost(user.Id, "invitation", message, "Click here to connect your account")
+}
+
+func (p *Plugin) SendInviteReminderMessage(user *model.User) error {
+	message := fmt.Sprintf("@%s, this is a reminder that you've been invited by your administrator to connect your Mattermost account with Microsoft Teams.", user.Username)
+
+	return p.botSendConnectLinkPost(user.Id, "invitation reminder", message, "Click here to connect your account")
+}
+
+func (p *Plugin) SendMissedMessagesMessage(user *model.User, missed int) error {
+	message := fmt.Sprintf("You missed %d Teams messages.", missed)
+	if missed == 1 {
+		message = "You missed 1 Teams message."
+	}
+
+	return p.botSendConnectLinkPost(user.Id, "missed messages", message, "Connect your account to get notified about Teams chats in Mattermost", "missed_messages", missed)
+}
+
+// botSendConnectLinkPost sends the message to the user in a direct post from the bot, then appends
+// a connect link, labelled with linkLabel, bound to the post. The kind of message is logged and
+// named in errors.
+func (p *Plugin) botSendConnectLinkPost(userID, kind, message, linkLabel string, keyValuePairs ...any) error {
+	post := &model.Post{
 		Message: message,
 	}
 
-	if err := p.botSendDirectPost(user.Id, invitePost); err != nil {
-		p.GetAPI().LogWarn("Failed to send invitation message", "user_id", user.Id, "error", err)
-		return errors.Wrapf(err, "error sending invitation bot message")
+	if err := p.botSendDirectPost(userID, post); err != nil {
+		p.GetAPI().LogWarn("Failed to send bot message with connect link", "kind", kind, "user_id", userID, "error", err)
+		return errors.Wrapf(err, "error sending %s bot message", kind)
+	}
+
+	connectURL := p.createAndStoreOAuthState(userID, post.ChannelId, post.Id)
+
+	post.Message = fmt.Sprintf("%s [%s](%s).", post.Message, linkLabel, connectURL)
+	if err := p.apiClient.Post.UpdatePost(post); err != nil {
+		p.GetAPI().LogWarn("Failed to update bot message with connect link", "kind", kind, "user_id", userID, "error", err)
+		return errors.Wrapf(err, "error updating %s bot message", kind)
 	}
 
-	connectURL := p.createAndStoreOAuthState(user.Id, invitePost.ChannelId, invitePost.Id)
+	p.GetAPI().LogInfo("Sent bot message with connect link to user", append([]any{"kind", kind, "user_id", userID}, keyValuePairs...)...)
+
+	return nil
+}
 
-	invitePost.Message = fmt.Sprintf("%s [Click here to connect your account](%s).", invitePost.Message, connectURL)
-	if err := p.apiClient.Post.UpdatePost(invitePost); err != nil {
-		p.GetAPI().LogWarn("Failed to update invitation message", "user_id", user.Id, "error", err)
-		return errors.Wrapf(err, "error updating invitation bot message")
+func (p *Plugin) SendDisconnectedByAdminMessage(user *model.User, reason string) error {
+	message := fmt.Sprintf("@%s, your Microsoft Teams account has been disconnected by your administrator.\nReason: %s", user.Username, reason)
+	if err := p.botSendDirectPost(user.Id, &model.Post{Message: message}); err != nil {
+		return errors.Wrapf(err, "error sending disconnected by admin bot message")
 	}
 
-	p.GetAPI().LogInfo("Sent invitation message to user", "user_id", user.Id)
+	p.GetAPI().LogInfo("Sent disconnected by admin message to user", "user_id", user.Id)
 
 	return nil
 }
@@ -0,0 +1,243 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"database/sql"
+	"runtime/debug"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
+)
+
+const (
+	cleanupReasonMattermostUserDeactivated = "mattermost_user_deactivated"
+	cleanupReasonTeamsAccountDisabled      = "teams_account_disabled"
+
+	reconcileUsersPerPage = 100
+)
+
+// UserHasBeenDeactivated cleans up the plugin data of deactivated Mattermost users.
+func (p *Plugin) UserHasBeenDeactivated(_ *plugin.Context, user *model.User) {
+	if _, err := p.cleanupUser(user.Id, cleanupReasonMattermostUserDeactivated); err != nil {
+		p.API.LogWarn("Failed to clean up deactivated user", "user_id", user.Id, "error", err.Error())
+	}
+}
+
+// cleanupUser unlinks a user who can no longer use the integration, revoking the sign-in sessions
+// of connected users before dropping their token and marking them as disconnected, or forgetting
+// the mapping of auto-linked users. Their invite, whitelist entry and missed messages are cleared
+// too. An audit record is logged whenever there was something to clean up, which is reported back.
+func (p *Plugin) cleanupUser(mmUserID, reason string) (bool, error) {
+	teamsUserID, err := p.store.MattermostToTeamsUserID(mmUserID)
+	if err != nil && err != sql.ErrNoRows {
+		return false, errors.Wrap(err, "error in getting the Teams user for the Mattermost user")
+	}
+
+	isConnected := false
+	isAutoLinked := false
+	if teamsUserID != "" {
+		connectStatus, err := p.store.GetUserConnectStatus(mmUserID)
+		if err != nil {
+			return false, errors.Wrap(err, "error in getting the user connect status")
+		}
+		isConnected = connectStatus.Connected
+		// Users without a token are only linked through their mapping while auto-linking is
+		// enabled. Otherwise, the mapping is kept as a record of the user having connected.
+		isAutoLinked = !connectStatus.Connected && p.getConfiguration().AutoLinkUsers
+	}
+
+	invitedUser, err := p.store.GetInvitedUser(mmUserID)
+	if err != nil {
+		return false, errors.Wrap(err, "error in getting user invite")
+	}
+	isWhitelisted, err := p.store.IsUserWhitelisted(mmUserID)
+	if err != nil {
+		return false, errors.Wrap(err, "error in checking if user is whitelisted")
+	}
+	missedMessages, err := p.store.GetMissedMessages(mmUserID)
+	if err != nil {
+		return false, errors.Wrap(err, "error in getting missed messages")
+	}
+
+	if !isConnected && !isAutoLinked && invitedUser == nil && !isWhitelisted && missedMessages == nil {
+		return false, nil
+	}
+
+	rec := newAuditRecord(auditEventCleanupUser, "")
+	defer p.API.LogAuditRec(rec)
+	model.AddEventParameterToAuditRec(rec, "user_id", mmUserID)
+	model.AddEventParameterToAuditRec(rec, "teams_user_id", teamsUserID)
+	model.AddEventParameterToAuditRec(rec, "reason", reason)
+	rec.EventData.PriorState["connected"] = isConnected
+	rec.EventData.PriorState["auto_linked"] = isAutoLinked
+	rec.EventData.PriorState["invited"] = invitedUser != nil
+	rec.EventData.PriorState["whitelisted"] = isWhitelisted
+
+	sessionsRevoked := false
+	if isConnected {
+		// The token is dropped regardless, but the refresh token stays valid at Microsoft until
+		// the sign-in sessions are revoked.
+		if err := p.GetClientForApp().RevokeSignInSessions(teamsUserID); err != nil {
+			p.API.LogWarn("Failed to revoke the sign-in sessions of the user", "user_id", mmUserID, "teams_user_id", teamsUserID, "error", err.Error())
+			rec.AddErrorDesc(err.Error())
+		} else {
+			sessionsRevoked = true
+		}
+	}
+	rec.EventData.ResultState["sessions_revoked"] = sessionsRevoked
+
+	if err := p.cleanupUserData(mmUserID, teamsUserID, isConnected, isAutoLinked, invitedUser != nil, isWhitelisted); err != nil {
+		rec.AddErrorDesc(err.Error())
+		rec.Fail()
+		return false, err
+	}
+
+	rec.EventData.ResultState["connected"] = false
+	rec.EventData.ResultState["auto_linked"] = false
+	rec.EventData.ResultState["invited"] = false
+	rec.EventData.ResultState["whitelisted"] = false
+	rec.Success()
+
+	p.API.LogInfo("Cleaned up user", "user_id", mmUserID, "teams_user_id", teamsUserID, "reason", reason, "was_connected", isConnected, "sessions_revoked", sessionsRevoked, "was_auto_linked", isAutoLinked, "was_invited", invitedUser != nil, "was_whitelisted", isWhitelisted)
+
+	return true, nil
+}
+
+func (p *Plugin) cleanupUserData(mmUserID, teamsUserID string, isConnected, isAutoLinked, isInvited, isWhitelisted bool) error {
+	if isConnected {
+		if err := p.disconnectUser(mmUserID, teamsUserID); err != nil {
+			return errors.Wrap(err, "error in disconnecting user")
+		}
+	}
+
+	if isAutoLinked {
+		if err := p.store.DeleteUserInfo(mmUserID); err != nil {
+			return errors.Wrap(err, "error in unlinking user")
+		}
+	}
+
+	if isInvited {
+		if err := p.store.DeleteUserInvite(mmUserID); err != nil {
+			return errors.Wrap(err, "error in deleting user invite")
+		}
+	}
+
+	if isWhitelisted {
+		if err := p.store.DeleteUserFromWhitelist(mmUserID); err != nil {
+			return errors.Wrap(err, "error in deleting user from whitelist")
+		}
+	}
+
+	if err := p.store.DeleteMissedMessages(mmUserID); err != nil {
+		return errors.Wrap(err, "error in deleting missed messages")
+	}
+
+	return nil
+}
+
+// reconcileUsers cleans up linked and invited users whose Mattermost account was deactivated, or,
+// if enabled, whose Teams account was disabled, without the plugin being told.
+func (p *Plugin) reconcileUsers() {
+	defer func() {
+		if r := recover(); r != nil {
+			p.GetMetrics().ObserveGoroutineFailure()
+			p.API.LogError("Recovering from panic", "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+
+	done := p.GetMetrics().ObserveWorker(metrics.WorkerReconcileUsers)
+	defer done()
+
+	p.API.LogInfo("Running the reconcile users job")
+
+	disabledTeamsUsers := make(map[string]bool)
+	if p.getConfiguration().DisconnectDisabledTeamsUsers {
+		teamsUsers, err := p.GetClientForApp().ListUsers()
+		if err != nil {
+			// Still clean up deactivated Mattermost users.
+			p.API.LogWarn("Failed to list Teams users", "error", err.Error())
+		}
+		for _, teamsUser := range teamsUsers {
+			if !teamsUser.IsAccountEnabled {
+				disabledTeamsUsers[teamsUser.ID] = true
+			}
+		}
+	}
+
+	var linkedUsers []*storemodels.ConnectedUser
+	for page := 0; ; page++ {
+		users, err := p.store.GetLinkedUsers(page, reconcileUsersPerPage)
+		if err != nil {
+			p.API.LogWarn("Failed to get linked users", "page", page, "error", err.Error())
+			return
+		}
+
+		linkedUsers = append(linkedUsers, users...)
+		if len(users) < reconcileUsersPerPage {
+			break
+		}
+	}
+
+	userIDs := make([]string, 0, len(linkedUsers))
+	reasons := make(map[string]string)
+	for _, linkedUser := range linkedUsers {
+		userIDs = append(userIDs, linkedUser.MattermostUserID)
+		if disabledTeamsUsers[linkedUser.TeamsUserID] {
+			reasons[linkedUser.MattermostUserID] = cleanupReasonTeamsAccountDisabled
+		}
+	}
+
+	invitedUsers, err := p.store.GetInvitedUsers()
+	if err != nil {
+		p.API.LogWarn("Failed to get invited users", "error", err.Error())
+	}
+	for _, invitedUser := range invitedUsers {
+		userIDs = append(userIDs, invitedUser.ID)
+	}
+
+	cleanedUp := 0
+	for start := 0; start < len(userIDs); start += reconcileUsersPerPage {
+		pageUserIDs := userIDs[start:min(start+reconcileUsersPerPage, len(userIDs))]
+
+		users, appErr := p.API.GetUsersByIds(pageUserIDs)
+		if appErr != nil {
+			p.API.LogWarn("Failed to get users", "error", appErr.Error())
+			continue
+		}
+
+		// Users missing altogether were deleted, and are cleaned up like deactivated ones.
+		activeUsers := make(map[string]bool, len(users))
+		for _, user := range users {
+			if user.DeleteAt == 0 {
+				activeUsers[user.Id] = true
+			}
+		}
+
+		for _, userID := range pageUserIDs {
+			reason, ok := reasons[userID]
+			if !ok {
+				if activeUsers[userID] {
+					continue
+				}
+				reason = cleanupReasonMattermostUserDeactivated
+			}
+
+			cleanedUpUser, err := p.cleanupUser(userID, reason)
+			if err != nil {
+				p.API.LogWarn("Failed to clean up user", "user_id", userID, "reason", reason, "error", err.Error())
+				continue
+			}
+			if cleanedUpUser {
+				cleanedUp++
+			}
+		}
+	}
+
+	p.API.LogInfo("Finished the reconcile users job", "cleaned_up_users", cleanedUp)
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+func (th *testHelper) assertUserCleanedUp(t *testing.T, userID string) {
+	t.Helper()
+
+	token, _ := th.p.store.GetTokenForMattermostUser(userID)
+	assert.Nil(t, token)
+
+	invitedUser, err := th.p.store.GetInvitedUser(userID)
+	require.NoError(t, err)
+	assert.Nil(t, invitedUser)
+
+	isWhitelisted, err := th.p.store.IsUserWhitelisted(userID)
+	require.NoError(t, err)
+	assert.False(t, isWhitelisted)
+
+	missedMessages, err := th.p.store.GetMissedMessages(userID)
+	require.NoError(t, err)
+	assert.Nil(t, missedMessages)
+}
+
+func TestUserHasBeenDeactivated(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	t.Run("connected user", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+		th.MarkUserWhitelisted(t, user.Id)
+
+		th.appClientMock.On("RevokeSignInSessions", "t"+user.Id).Return(nil).Times(1)
+
+		appErr := th.p.API.UpdateUserActive(user.Id, false)
+		require.Nil(t, appErr)
+
+		require.EventuallyWithT(t, func(c *assert.CollectT) {
+			token, _ := th.p.store.GetTokenForMattermostUser(user.Id)
+			assert.Nil(c, token)
+		}, 5*time.Second, 100*time.Millisecond)
+		th.assertUserCleanedUp(t, user.Id)
+
+		// The mapping is kept, and the disconnection recorded.
+		teamsUserID, err := th.p.store.MattermostToTeamsUserID(user.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "t"+user.Id, teamsUserID)
+
+		connectStatus, err := th.p.store.GetUserConnectStatus(user.Id)
+		require.NoError(t, err)
+		assert.False(t, connectStatus.Connected)
+		assert.False(t, connectStatus.LastDisconnectAt.IsZero())
+	})
+
+	t.Run("connected user whose sessions cannot be revoked", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+
+		th.appClientMock.On("RevokeSignInSessions", "t"+user.Id).Return(errors.New("forbidden")).Times(1)
+
+		cleanedUp, err := th.p.cleanupUser(user.Id, cleanupReasonMattermostUserDeactivated)
+		require.NoError(t, err)
+		assert.True(t, cleanedUp)
+
+		// The token is still dropped.
+		th.assertUserCleanedUp(t, user.Id)
+	})
+
+	t.Run("invited user", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		th.MarkUserInvited(t, user.Id)
+		require.NoError(t, th.p.store.RecordMissedMessage(user.Id, "t"+user.Id, time.Now()))
+
+		th.p.UserHasBeenDeactivated(nil, user)
+
+		th.assertUserCleanedUp(t, user.Id)
+	})
+
+	t.Run("auto-linked user", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.AutoLinkUsers = true
+		})
+		user := th.SetupUser(t, team)
+		require.NoError(t, th.p.store.SetUserInfo(user.Id, "t"+user.Id, nil))
+
+		th.p.UserHasBeenDeactivated(nil, user)
+
+		th.assertUserCleanedUp(t, user.Id)
+		_, err := th.p.store.MattermostToTeamsUserID(user.Id)
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	t.Run("mapped user without auto-linking", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		require.NoError(t, th.p.store.SetUserInfo(user.Id, "t"+user.Id, nil))
+
+		th.p.UserHasBeenDeactivated(nil, user)
+
+		th.assertUserCleanedUp(t, user.Id)
+
+		// The mapping isn't guessed to be auto-linked.
+		teamsUserID, err := th.p.store.MattermostToTeamsUserID(user.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "t"+user.Id, teamsUserID)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.p.UserHasBeenDeactivated(nil, user)
+
+		th.assertUserCleanedUp(t, user.Id)
+
+		cleanedUp, err := th.p.cleanupUser(user.Id, cleanupReasonMattermostUserDeactivated)
+		require.NoError(t, err)
+		assert.False(t, cleanedUp)
+	})
+}
+
+func TestReconcileUsers(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	t.Run("disabled and deactivated users", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.DisconnectDisabledTeamsUsers = true
+			c.AutoLinkUsers = true
+		})
+
+		activeUser := th.SetupUser(t, team)
+		th.ConnectUser(t, activeUser.Id)
+
+		disabledInTeamsUser := th.SetupUser(t, team)
+		th.ConnectUser(t, disabledInTeamsUser.Id)
+
+		deactivatedUser := th.SetupUser(t, team)
+		th.MarkUserInvited(t, deactivatedUser.Id)
+		appErr := th.p.API.UpdateUserActive(deactivatedUser.Id, false)
+		require.Nil(t, appErr)
+		// The hook may have already cleaned up, so invite again to exercise the job.
+		th.MarkUserInvited(t, deactivatedUser.Id)
+
+		deactivatedAutoLinkedUser := th.SetupUser(t, team)
+		appErr = th.p.API.UpdateUserActive(deactivatedAutoLinkedUser.Id, false)
+		require.Nil(t, appErr)
+		require.NoError(t, th.p.store.SetUserInfo(deactivatedAutoLinkedUser.Id, "t"+deactivatedAutoLinkedUser.Id, nil))
+
+		th.appClientMock.On("ListUsers").Return([]clientmodels.User{
+			{ID: "t" + activeUser.Id, Mail: activeUser.Email, IsAccountEnabled: true},
+			{ID: "t" + disabledInTeamsUser.Id, Mail: disabledInTeamsUser.Email, IsAccountEnabled: false},
+		}, nil).Times(1)
+		th.appClientMock.On("RevokeSignInSessions", "t"+disabledInTeamsUser.Id).Return(nil).Times(1)
+
+		th.p.reconcileUsers()
+
+		token, err := th.p.store.GetTokenForMattermostUser(activeUser.Id)
+		require.NoError(t, err)
+		assert.NotNil(t, token)
+
+		th.assertUserCleanedUp(t, disabledInTeamsUser.Id)
+		th.assertUserCleanedUp(t, deactivatedUser.Id)
+		_, err = th.p.store.MattermostToTeamsUserID(deactivatedAutoLinkedUser.Id)
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	t.Run("Teams accounts not checked unless enabled", func(t *testing.T) {
+		th.Reset(t)
+
+		activeUser := th.SetupUser(t, team)
+		th.ConnectUser(t, activeUser.Id)
+
+		th.p.reconcileUsers()
+
+		th.appClientMock.AssertNotCalled(t, "ListUsers")
+		token, err := th.p.store.GetTokenForMattermostUser(activeUser.Id)
+		require.NoError(t, err)
+		assert.NotNil(t, token)
+	})
+
+	t.Run("failing to list Teams users", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.DisconnectDisabledTeamsUsers = true
+		})
+
+		activeUser := th.SetupUser(t, team)
+		th.ConnectUser(t, activeUser.Id)
+
+		th.appClientMock.On("ListUsers").Return(nil, errors.New("unavailable")).Times(1)
+
+		th.p.reconcileUsers()
+
+		token, err := th.p.store.GetTokenForMattermostUser(activeUser.Id)
+		require.NoError(t, err)
+		assert.NotNil(t, token)
+	})
+}
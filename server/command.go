@@ -97,6 +97,10 @@ func (p *Plugin) ExecuteCommand(_ *plugin.Context, args *model.CommandArgs) (*mo
 		parameters = split[2:]
 	}
 
+	if command == "/"+msteamsAdminCommand {
+		return p.executeAdminCommand(args, action, parameters)
+	}
+
 	if command != "/"+msteamsCommand {
 		return &model.CommandResponse{}, nil
 	}
@@ -166,34 +170,22 @@ func (p *Plugin) executeDisconnectCommand(args *model.CommandArgs) (*model.Comma
 		return p.cmdSuccess(args, "Error: the account is not connected")
 	}
 
-	err = p.store.SetUserInfo(args.UserId, teamsUserID, nil)
+	err = p.disconnectUser(args.UserId, teamsUserID)
 	if err != nil {
 		return p.cmdSuccess(args, fmt.Sprintf("Error: unable to disconnect your account, %s", err.Error()))
 	}
 
-	p.API.LogInfo("User disconnected from Teams", "user_id", args.UserId, "teams_user_id", teamsUserID)
-
-	p.API.PublishWebSocketEvent(WSEventUserDisconnected, map[string]any{}, &model.WebsocketBroadcast{
-		UserId: args.UserId,
-	})
-
-	err = p.setNotificationPreference(args.UserId, false)
-	if err != nil {
-		p.API.LogWarn("unable to disable notifications preference", "error", err.Error())
-	}
-
 	return p.cmdSuccess(args, "Your account has been disconnected.")
 }
 
 func (p *Plugin) executeStatusCommand(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
-	if storedToken, err := p.store.GetTokenForMattermostUser(args.UserId); err != nil {
-		// TODO: We will need to distinguish real errors from "row not found" later.
-		return p.cmdSuccess(args, "Your account is not connected to Teams.")
-	} else if storedToken != nil {
-		return p.cmdSuccess(args, "Your account is connected to Teams.")
+	status, err := p.getConnectionStatus(args.UserId, true)
+	if err != nil {
+		p.API.LogWarn("Unable to get the connection status", "user_id", args.UserId, "error", err.Error())
+		return p.cmdError(args, "Error: Unable to get the connection status")
 	}
 
-	return p.cmdSuccess(args, "Your account is not connected to Teams.")
+	return p.cmdSuccess(args, formatConnectionStatus(status, p.getUserTimezoneLocation(args.UserId)))
 }
 
 func (p *Plugin) executeNotificationsCommand(args *model.CommandArgs, parameters []string) (*model.CommandResponse, *model.AppError) {
@@ -201,12 +193,12 @@ func (p *Plugin) executeNotificationsCommand(args *model.CommandArgs, parameters
 		return p.cmdSuccess(args, "Invalid notifications command, one argument is required.")
 	}
 
-	isConnected, err := p.IsUserConnected(args.UserId)
+	isLinked, err := p.IsUserLinked(args.UserId)
 	if err != nil {
 		p.API.LogWarn("unable to check if the user is connected", "error", err.Error())
 		return p.cmdError(args, "Error: Unable to get the connection status")
 	}
-	if !isConnected {
+	if !isLinked {
 		return p.cmdSuccess(args, "Error: Your account is not connected to Teams. To use this feature, please connect your account with `/msteams connect`.")
 	}
 
@@ -0,0 +1,428 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/experimental/command"
+)
+
+const (
+	msteamsAdminCommand   = "msteams-admin"
+	adminWhitelistPerPage = 50
+	adminUsersPerPage     = 50
+	adminDataDeletePosts  = "delete-posts"
+)
+
+func (p *Plugin) createAdminCommand() *model.Command {
+	iconData, err := command.GetIconData(p.API, "assets/icon.svg")
+	if err != nil {
+		p.API.LogWarn("Unable to get the MS Teams icon for the slash command")
+	}
+
+	return &model.Command{
+		Trigger:              msteamsAdminCommand,
+		AutoComplete:         true,
+		AutoCompleteDesc:     "Administer the MS Teams Integration with Mattermost",
+		AutoCompleteHint:     "[command]",
+		Username:             botUsername,
+		DisplayName:          botDisplayName,
+		AutocompleteData:     getAdminAutocompleteData(),
+		AutocompleteIconData: iconData,
+	}
+}
+
+func getAdminAutocompleteData() *model.AutocompleteData {
+	cmd := model.NewAutocompleteData(msteamsAdminCommand, "[command]", "Administer MS Teams")
+	cmd.RoleID = model.SystemAdminRoleId
+
+	whitelist := model.NewAutocompleteData("whitelist", "[add|remove|list|check]", "Manage the users allowed to connect when connections are restricted")
+
+	whitelistAdd := model.NewAutocompleteData("add", "[@username|email]", "Add a user to the whitelist")
+	whitelistAdd.AddTextArgument("Username or email of the user", "[@username|email]", "")
+	whitelist.AddCommand(whitelistAdd)
+
+	whitelistRemove := model.NewAutocompleteData("remove", "[@username|email]", "Remove a user from the whitelist")
+	whitelistRemove.AddTextArgument("Username or email of the user", "[@username|email]", "")
+	whitelist.AddCommand(whitelistRemove)
+
+	whitelistList := model.NewAutocompleteData("list", "[search]", "List the whitelisted users, optionally filtered by username or email")
+	whitelistList.AddTextArgument("Part of the username or email to search for", "[search]", "")
+	whitelist.AddCommand(whitelistList)
+
+	whitelistCheck := model.NewAutocompleteData("check", "[@username|email]", "Check if a user is whitelisted")
+	whitelistCheck.AddTextArgument("Username or email of the user", "[@username|email]", "")
+	whitelist.AddCommand(whitelistCheck)
+
+	cmd.AddCommand(whitelist)
+
+	data := model.NewAutocompleteData("data", "[export|erase]", "Export or erase the data held about a user")
+
+	dataExport := model.NewAutocompleteData("export", "[@username|email]", "Export the data held about a user as a JSON file")
+	dataExport.AddTextArgument("Username or email of the user", "[@username|email]", "")
+	data.AddCommand(dataExport)
+
+	dataErase := model.NewAutocompleteData("erase", "[@username|email] [delete-posts]", "Erase the data held about a user, optionally deleting the bot notifications sent to them")
+	dataErase.AddTextArgument("Username or email of the user", "[@username|email]", "")
+	dataErase.AddStaticListArgument("Also delete the bot notifications sent to the user", false, []model.AutocompleteListItem{
+		{Item: adminDataDeletePosts, HelpText: "Delete the bot notifications sent to the user"},
+	})
+	data.AddCommand(dataErase)
+
+	cmd.AddCommand(data)
+
+	stats := model.NewAutocompleteData("stats", "", "Show the site statistics")
+	cmd.AddCommand(stats)
+
+	users := model.NewAutocompleteData("users", "[search]", "List the connected users, optionally filtered by name, username or email")
+	users.AddTextArgument("Part of the name, username or email to search for", "[search]", "")
+	cmd.AddCommand(users)
+
+	disconnect := model.NewAutocompleteData("disconnect", "[@username|email]", "Disconnect a user from MS Teams")
+	disconnect.AddTextArgument("Username or email of the user", "[@username|email]", "")
+	cmd.AddCommand(disconnect)
+
+	invite := model.NewAutocompleteData("invite", "[@username|email]", "Invite a user to connect, regardless of the invite pool")
+	invite.AddTextArgument("Username or email of the user", "[@username|email]", "")
+	cmd.AddCommand(invite)
+
+	mapping := model.NewAutocompleteData("mapping", "[@username|email]", "Show the MS Teams user mapped to a user")
+	mapping.AddTextArgument("Username or email of the user", "[@username|email]", "")
+	cmd.AddCommand(mapping)
+
+	credentials := model.NewAutocompleteData("credentials", "", "Check the client secret or certificate and API permissions of the configured application")
+	cmd.AddCommand(credentials)
+
+	return cmd
+}
+
+func (p *Plugin) executeAdminCommand(args *model.CommandArgs, action string, parameters []string) (*model.CommandResponse, *model.AppError) {
+	if !p.API.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return p.cmdError(args, "Error: You must be a system administrator to use this command.")
+	}
+
+	if action == "whitelist" {
+		return p.executeAdminWhitelistCommand(args, parameters)
+	}
+
+	if action == "data" {
+		return p.executeAdminDataCommand(args, parameters)
+	}
+
+	if action == "stats" {
+		return p.executeAdminStatsCommand(args)
+	}
+
+	if action == "users" {
+		return p.executeAdminUsersCommand(args, parameters)
+	}
+
+	if action == "credentials" {
+		return p.executeAdminCredentialsCommand(args)
+	}
+
+	if action == "disconnect" || action == "invite" || action == "mapping" {
+		if len(parameters) != 1 {
+			return p.cmdError(args, fmt.Sprintf("Invalid %s command, a username or email is required.", action))
+		}
+
+		user, err := p.lookupUser(parameters[0])
+		if err != nil {
+			return p.cmdError(args, fmt.Sprintf("Error: Unable to find user %s.", parameters[0]))
+		}
+
+		switch action {
+		case "disconnect":
+			return p.executeAdminDisconnectCommand(args, user)
+		case "invite":
+			return p.executeAdminInviteCommand(args, user)
+		default:
+			return p.executeAdminMappingCommand(args, user)
+		}
+	}
+
+	return p.cmdError(args, "Unknown command. Valid options: whitelist, data, stats, users, disconnect, invite, mapping, credentials")
+}
+
+func (p *Plugin) executeAdminWhitelistCommand(args *model.CommandArgs, parameters []string) (*model.CommandResponse, *model.AppError) {
+	if len(parameters) == 0 {
+		return p.cmdError(args, "Invalid whitelist command. Valid options: add, remove, list, check")
+	}
+
+	subAction := parameters[0]
+	parameters = parameters[1:]
+
+	if subAction == "list" {
+		search := strings.Join(parameters, " ")
+		emails, err := p.store.GetWhitelistEmails(search, 0, adminWhitelistPerPage+1)
+		if err != nil {
+			p.API.LogWarn("Unable to get whitelist", "error", err.Error())
+			return p.cmdError(args, "Error: Unable to get the whitelist.")
+		}
+
+		if len(emails) == 0 {
+			return p.cmdSuccess(args, "No whitelisted users found.")
+		}
+
+		more := len(emails) > adminWhitelistPerPage
+		if more {
+			emails = emails[:adminWhitelistPerPage]
+		}
+
+		message := "Whitelisted users:\n* " + strings.Join(emails, "\n* ")
+		if more {
+			message += fmt.Sprintf("\n\nOnly the first %d users are shown. Refine the search to see more.", adminWhitelistPerPage)
+		}
+		return p.cmdSuccess(args, message)
+	}
+
+	if subAction != "add" && subAction != "remove" && subAction != "check" {
+		return p.cmdError(args, subAction+" is not a valid argument. Valid options: add, remove, list, check")
+	}
+
+	if len(parameters) != 1 {
+		return p.cmdError(args, "Invalid whitelist command, a username or email is required.")
+	}
+
+	user, err := p.lookupUser(parameters[0])
+	if err != nil {
+		return p.cmdError(args, fmt.Sprintf("Error: Unable to find user %s.", parameters[0]))
+	}
+
+	switch subAction {
+	case "add":
+		if err = p.store.StoreUserInWhitelist(user.Id); err != nil {
+			p.API.LogWarn("Unable to add user to whitelist", "user_id", user.Id, "error", err.Error())
+			return p.cmdError(args, "Error: Unable to add the user to the whitelist.")
+		}
+		p.API.LogInfo("User added to whitelist", "user_id", user.Id, "admin_user_id", args.UserId)
+		return p.cmdSuccess(args, fmt.Sprintf("@%s has been added to the whitelist.", user.Username))
+	case "remove":
+		if err = p.store.DeleteUserFromWhitelist(user.Id); err != nil {
+			p.API.LogWarn("Unable to remove user from whitelist", "user_id", user.Id, "error", err.Error())
+			return p.cmdError(args, "Error: Unable to remove the user from the whitelist.")
+		}
+		p.API.LogInfo("User removed from whitelist", "user_id", user.Id, "admin_user_id", args.UserId)
+		return p.cmdSuccess(args, fmt.Sprintf("@%s has been removed from the whitelist.", user.Username))
+	default:
+		entry, err := p.getWhitelistEntry(user)
+		if err != nil {
+			p.API.LogWarn("Unable to check whitelist", "user_id", user.Id, "error", err.Error())
+			return p.cmdError(args, "Error: Unable to check the whitelist.")
+		}
+		if entry.Whitelisted {
+			return p.cmdSuccess(args, fmt.Sprintf("@%s is whitelisted.", user.Username))
+		}
+		return p.cmdSuccess(args, fmt.Sprintf("@%s is not whitelisted.", user.Username))
+	}
+}
+
+func (p *Plugin) executeAdminDataCommand(args *model.CommandArgs, parameters []string) (*model.CommandResponse, *model.AppError) {
+	if len(parameters) == 0 {
+		return p.cmdError(args, "Invalid data command. Valid options: export, erase")
+	}
+
+	subAction := parameters[0]
+	parameters = parameters[1:]
+
+	if subAction != "export" && subAction != "erase" {
+		return p.cmdError(args, subAction+" is not a valid argument. Valid options: export, erase")
+	}
+
+	if len(parameters) == 0 {
+		return p.cmdError(args, "Invalid data command, a username or email is required.")
+	}
+
+	deletePosts := false
+	if subAction == "erase" && len(parameters) == 2 && parameters[1] == adminDataDeletePosts {
+		deletePosts = true
+		parameters = parameters[:1]
+	}
+
+	if len(parameters) != 1 {
+		return p.cmdError(args, "Invalid data command, too many arguments.")
+	}
+
+	user, err := p.lookupUser(parameters[0])
+	if err != nil {
+		return p.cmdError(args, fmt.Sprintf("Error: Unable to find user %s.", parameters[0]))
+	}
+
+	if subAction == "export" {
+		export, err := p.exportUserData(user, args.UserId)
+		if err != nil {
+			p.API.LogWarn("Unable to export user data", "user_id", user.Id, "error", err.Error())
+			return p.cmdError(args, "Error: Unable to export the user data.")
+		}
+
+		if err = p.sendUserDataExport(args.UserId, user, export); err != nil {
+			p.API.LogWarn("Unable to send user data export", "user_id", user.Id, "error", err.Error())
+			return p.cmdError(args, "Error: Unable to send the user data export.")
+		}
+
+		return p.cmdSuccess(args, fmt.Sprintf("The data held about @%s has been sent to you in a direct message.", user.Username))
+	}
+
+	erasure, err := p.eraseUserData(user, deletePosts, args.UserId)
+	if err != nil {
+		p.API.LogWarn("Unable to erase user data", "user_id", user.Id, "error", err.Error())
+		return p.cmdError(args, "Error: Unable to erase the user data.")
+	}
+
+	message := fmt.Sprintf("The data held about @%s has been erased.", user.Username)
+	if deletePosts {
+		message += fmt.Sprintf(" Bot notifications deleted: %d.", erasure.DeletedNotifications)
+	}
+	return p.cmdSuccess(args, message)
+}
+
+func (p *Plugin) executeAdminStatsCommand(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	stats, err := p.getSiteStats()
+	if err != nil {
+		p.API.LogWarn("Unable to get site stats", "error", err.Error())
+		return p.cmdError(args, "Error: Unable to get the site stats.")
+	}
+
+	lines := []string{
+		"Site stats:",
+		fmt.Sprintf("* Connected users: %d", stats.TotalConnectedUsers),
+		fmt.Sprintf("* Pending invites: %d", stats.PendingInvitedUsers),
+		fmt.Sprintf("* Whitelisted users: %d", stats.CurrentWhitelistUsers),
+		fmt.Sprintf("* Active users: %d", stats.TotalActiveUsers),
+	}
+	return p.cmdSuccess(args, strings.Join(lines, "\n"))
+}
+
+func (p *Plugin) executeAdminUsersCommand(args *model.CommandArgs, parameters []string) (*model.CommandResponse, *model.AppError) {
+	search := strings.Join(parameters, " ")
+	connectedUsers, err := p.store.SearchConnectedUsers(search, 0, adminUsersPerPage+1)
+	if err != nil {
+		p.API.LogWarn("Unable to get connected users", "error", err.Error())
+		return p.cmdError(args, "Error: Unable to get the connected users.")
+	}
+
+	if len(connectedUsers) == 0 {
+		return p.cmdSuccess(args, "No connected users found.")
+	}
+
+	more := len(connectedUsers) > adminUsersPerPage
+	if more {
+		connectedUsers = connectedUsers[:adminUsersPerPage]
+	}
+
+	lines := []string{"Connected users:"}
+	for _, connectedUser := range connectedUsers {
+		name := strings.TrimSpace(connectedUser.FirstName + " " + connectedUser.LastName)
+		if name == "" {
+			lines = append(lines, fmt.Sprintf("* %s, Teams user ID: %s", connectedUser.Email, connectedUser.TeamsUserID))
+		} else {
+			lines = append(lines, fmt.Sprintf("* %s (%s), Teams user ID: %s", name, connectedUser.Email, connectedUser.TeamsUserID))
+		}
+	}
+
+	message := strings.Join(lines, "\n")
+	if more {
+		message += fmt.Sprintf("\n\nOnly the first %d users are shown. Refine the search to see more.", adminUsersPerPage)
+	}
+	return p.cmdSuccess(args, message)
+}
+
+func (p *Plugin) executeAdminDisconnectCommand(args *model.CommandArgs, user *model.User) (*model.CommandResponse, *model.AppError) {
+	disconnected, err := p.forceDisconnectUser(user, "", args.UserId)
+	if err != nil {
+		p.API.LogWarn("Unable to disconnect user", "user_id", user.Id, "error", err.Error())
+		return p.cmdError(args, "Error: Unable to disconnect the user.")
+	}
+
+	if !disconnected {
+		return p.cmdError(args, fmt.Sprintf("@%s is not connected to Teams.", user.Username))
+	}
+
+	return p.cmdSuccess(args, fmt.Sprintf("@%s has been disconnected from Teams.", user.Username))
+}
+
+func (p *Plugin) executeAdminInviteCommand(args *model.CommandArgs, user *model.User) (*model.CommandResponse, *model.AppError) {
+	if err := p.inviteUser(user, time.Now()); err != nil {
+		p.API.LogWarn("Unable to invite user", "user_id", user.Id, "error", err.Error())
+		return p.cmdError(args, fmt.Sprintf("Error: Unable to invite @%s, %s.", user.Username, err.Error()))
+	}
+
+	p.API.LogInfo("User invited by admin", "user_id", user.Id, "admin_user_id", args.UserId)
+	return p.cmdSuccess(args, fmt.Sprintf("@%s has been invited to connect to Teams.", user.Username))
+}
+
+func (p *Plugin) executeAdminMappingCommand(args *model.CommandArgs, user *model.User) (*model.CommandResponse, *model.AppError) {
+	userInfo, err := p.store.GetUserInfo(user.Id)
+	if err != nil {
+		p.API.LogWarn("Unable to get user info", "user_id", user.Id, "error", err.Error())
+		return p.cmdError(args, "Error: Unable to get the user mapping.")
+	}
+
+	if userInfo == nil || userInfo.TeamsUserID == "" {
+		return p.cmdSuccess(args, fmt.Sprintf("@%s is not mapped to a Teams user.", user.Username))
+	}
+
+	location := p.getUserTimezoneLocation(args.UserId)
+	lines := []string{
+		fmt.Sprintf("@%s is mapped to Teams user ID %s.", user.Username, userInfo.TeamsUserID),
+	}
+	if userInfo.Connected {
+		lines = append(lines, "* Connected: yes")
+	} else {
+		lines = append(lines, "* Connected: no")
+	}
+	if !userInfo.LastConnectAt.IsZero() {
+		lines = append(lines, "* Last connected: "+userInfo.LastConnectAt.In(location).Format(displayTimeFormat))
+	}
+	if !userInfo.LastDisconnectAt.IsZero() {
+		lines = append(lines, "* Last disconnected: "+userInfo.LastDisconnectAt.In(location).Format(displayTimeFormat))
+	}
+	return p.cmdSuccess(args, strings.Join(lines, "\n"))
+}
+
+func (p *Plugin) executeAdminCredentialsCommand(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	report, err := p.runCredentialsCheck()
+	if err != nil {
+		p.API.LogWarn("Unable to check credentials", "error", err.Error())
+		return p.cmdError(args, "Error: Unable to check the credentials.")
+	}
+
+	label := "Client secret"
+	if report.Certificate {
+		label = "Client certificate"
+	}
+
+	lines := []string{"Credentials check:"}
+	if report.Credential != nil {
+		lines = append(lines, fmt.Sprintf("* %s: %s (%s), expires %s", label, report.Credential.Name, report.Credential.ID, report.Credential.EndDateTime.In(p.getUserTimezoneLocation(args.UserId)).Format(displayTimeFormat)))
+	} else {
+		lines = append(lines, fmt.Sprintf("* %s: none of the application's %ss match the configured one", label, report.credentialKind()))
+	}
+
+	if len(report.MissingPermissions) == 0 {
+		lines = append(lines, "* Missing permissions: none")
+	} else {
+		names := make([]string, 0, len(report.MissingPermissions))
+		for _, permission := range report.MissingPermissions {
+			names = append(names, permission.Name)
+		}
+		lines = append(lines, "* Missing permissions: "+strings.Join(names, ", "))
+	}
+
+	if len(report.RedundantPermissions) == 0 {
+		lines = append(lines, "* Redundant permissions: none")
+	} else {
+		ids := make([]string, 0, len(report.RedundantPermissions))
+		for _, resourceAccess := range report.RedundantPermissions {
+			ids = append(ids, fmt.Sprintf("%s (%s)", resourceAccess.ID, describeResourceAccessType(resourceAccess)))
+		}
+		lines = append(lines, "* Redundant permissions: "+strings.Join(ids, ", "))
+	}
+
+	return p.cmdSuccess(args, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,409 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+func TestExecuteAdminWhitelistCommand(t *testing.T) {
+	th := setupTestHelper(t)
+
+	team := th.SetupTeam(t)
+	sysadmin := th.SetupSysadmin(t, team)
+	user1 := th.SetupUser(t, team)
+
+	th.SetupWebsocketClientForUser(t, sysadmin.Id)
+	th.SetupWebsocketClientForUser(t, user1.Id)
+
+	execute := func(t *testing.T, userID string, parameters ...string) *model.CommandArgs {
+		t.Helper()
+
+		args := &model.CommandArgs{
+			UserId:    userID,
+			ChannelId: model.NewId(),
+		}
+
+		commandResponse, appErr := th.p.executeAdminCommand(args, "whitelist", parameters)
+		require.Nil(t, appErr)
+		assertNoCommandResponse(t, commandResponse)
+
+		return args
+	}
+
+	t.Run("not a system admin", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, user1.Id, "add", user1.Username)
+		assertEphemeralResponse(th, t, args, "Error: You must be a system administrator to use this command.")
+
+		whitelisted, err := th.p.store.IsUserWhitelisted(user1.Id)
+		require.NoError(t, err)
+		assert.False(t, whitelisted)
+	})
+
+	t.Run("invalid sub command", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "invalid")
+		assertEphemeralResponse(th, t, args, "invalid is not a valid argument. Valid options: add, remove, list, check")
+	})
+
+	t.Run("missing user", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "add")
+		assertEphemeralResponse(th, t, args, "Invalid whitelist command, a username or email is required.")
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "add", "@unknown")
+		assertEphemeralResponse(th, t, args, "Error: Unable to find user @unknown.")
+	})
+
+	t.Run("add by username", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "add", "@"+user1.Username)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("@%s has been added to the whitelist.", user1.Username))
+
+		whitelisted, err := th.p.store.IsUserWhitelisted(user1.Id)
+		require.NoError(t, err)
+		assert.True(t, whitelisted)
+	})
+
+	t.Run("remove by email", func(t *testing.T) {
+		th.Reset(t)
+		th.MarkUserWhitelisted(t, user1.Id)
+
+		args := execute(t, sysadmin.Id, "remove", user1.Email)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("@%s has been removed from the whitelist.", user1.Username))
+
+		whitelisted, err := th.p.store.IsUserWhitelisted(user1.Id)
+		require.NoError(t, err)
+		assert.False(t, whitelisted)
+	})
+
+	t.Run("check", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "check", user1.Username)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("@%s is not whitelisted.", user1.Username))
+
+		th.MarkUserWhitelisted(t, user1.Id)
+
+		args = execute(t, sysadmin.Id, "check", user1.Username)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("@%s is whitelisted.", user1.Username))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "list")
+		assertEphemeralResponse(th, t, args, "No whitelisted users found.")
+
+		th.MarkUserWhitelisted(t, user1.Id)
+
+		args = execute(t, sysadmin.Id, "list")
+		assertEphemeralResponse(th, t, args, "Whitelisted users:\n* "+user1.Email)
+
+		args = execute(t, sysadmin.Id, "list", "no-such-user")
+		assertEphemeralResponse(th, t, args, "No whitelisted users found.")
+	})
+}
+
+func TestExecuteAdminDataCommand(t *testing.T) {
+	th := setupTestHelper(t)
+
+	team := th.SetupTeam(t)
+	sysadmin := th.SetupSysadmin(t, team)
+	user1 := th.SetupUser(t, team)
+
+	th.SetupWebsocketClientForUser(t, sysadmin.Id)
+	th.SetupWebsocketClientForUser(t, user1.Id)
+
+	execute := func(t *testing.T, userID string, parameters ...string) *model.CommandArgs {
+		t.Helper()
+
+		args := &model.CommandArgs{
+			UserId:    userID,
+			ChannelId: model.NewId(),
+		}
+
+		commandResponse, appErr := th.p.executeAdminCommand(args, "data", parameters)
+		require.Nil(t, appErr)
+		assertNoCommandResponse(t, commandResponse)
+
+		return args
+	}
+
+	t.Run("not a system admin", func(t *testing.T) {
+		th.Reset(t)
+		th.MarkUserWhitelisted(t, user1.Id)
+
+		args := execute(t, user1.Id, "erase", user1.Username)
+		assertEphemeralResponse(th, t, args, "Error: You must be a system administrator to use this command.")
+
+		whitelisted, err := th.p.store.IsUserWhitelisted(user1.Id)
+		require.NoError(t, err)
+		assert.True(t, whitelisted)
+	})
+
+	t.Run("invalid sub command", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "invalid")
+		assertEphemeralResponse(th, t, args, "invalid is not a valid argument. Valid options: export, erase")
+	})
+
+	t.Run("missing user", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "export")
+		assertEphemeralResponse(th, t, args, "Invalid data command, a username or email is required.")
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "erase", "@unknown")
+		assertEphemeralResponse(th, t, args, "Error: Unable to find user @unknown.")
+	})
+
+	t.Run("export", func(t *testing.T) {
+		th.Reset(t)
+		th.ConnectUser(t, user1.Id)
+
+		args := execute(t, sysadmin.Id, "export", "@"+user1.Username)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("The data held about @%s has been sent to you in a direct message.", user1.Username))
+		th.assertDMFromUserRe(t, th.p.botUserID, sysadmin.Id, fmt.Sprintf("Here is the data held about @%s.", user1.Username))
+	})
+
+	t.Run("erase and delete posts", func(t *testing.T) {
+		th.Reset(t)
+		th.ConnectUser(t, user1.Id)
+		require.NoError(t, th.p.SendMissedMessagesMessage(user1, 2))
+
+		args := execute(t, sysadmin.Id, "erase", user1.Email, "delete-posts")
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("The data held about @%s has been erased. Bot notifications deleted: 1.", user1.Username))
+		th.assertUserCleanedUp(t, user1.Id)
+	})
+}
+
+func TestExecuteAdminUserCommands(t *testing.T) {
+	th := setupTestHelper(t)
+
+	team := th.SetupTeam(t)
+	sysadmin := th.SetupSysadmin(t, team)
+	user1 := th.SetupUser(t, team)
+
+	th.SetupWebsocketClientForUser(t, sysadmin.Id)
+	th.SetupWebsocketClientForUser(t, user1.Id)
+
+	execute := func(t *testing.T, userID, action string, parameters ...string) *model.CommandArgs {
+		t.Helper()
+
+		args := &model.CommandArgs{
+			UserId:    userID,
+			ChannelId: model.NewId(),
+		}
+
+		commandResponse, appErr := th.p.executeAdminCommand(args, action, parameters)
+		require.Nil(t, appErr)
+		assertNoCommandResponse(t, commandResponse)
+
+		return args
+	}
+
+	t.Run("not a system admin", func(t *testing.T) {
+		th.Reset(t)
+		th.ConnectUser(t, user1.Id)
+
+		args := execute(t, user1.Id, "disconnect", user1.Username)
+		assertEphemeralResponse(th, t, args, "Error: You must be a system administrator to use this command.")
+
+		connected, err := th.p.IsUserConnected(user1.Id)
+		require.NoError(t, err)
+		assert.True(t, connected)
+	})
+
+	t.Run("unknown command", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "invalid")
+		assertEphemeralResponse(th, t, args, "Unknown command. Valid options: whitelist, data, stats, users, disconnect, invite, mapping, credentials")
+	})
+
+	t.Run("missing user", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "mapping")
+		assertEphemeralResponse(th, t, args, "Invalid mapping command, a username or email is required.")
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "invite", "@unknown")
+		assertEphemeralResponse(th, t, args, "Error: Unable to find user @unknown.")
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		th.Reset(t)
+		th.ConnectUser(t, user1.Id)
+
+		args := execute(t, sysadmin.Id, "stats")
+		assertEphemeralResponse(th, t, args, "Site stats:\n* Connected users: 1\n* Pending invites: 0\n* Whitelisted users: 0\n* Active users: 0")
+	})
+
+	t.Run("users", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "users")
+		assertEphemeralResponse(th, t, args, "No connected users found.")
+
+		th.ConnectUser(t, user1.Id)
+
+		args = execute(t, sysadmin.Id, "users", user1.Username)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("Connected users:\n* %s, Teams user ID: t%s", user1.Email, user1.Id))
+
+		args = execute(t, sysadmin.Id, "users", "no-such-user")
+		assertEphemeralResponse(th, t, args, "No connected users found.")
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "disconnect", user1.Username)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("@%s is not connected to Teams.", user1.Username))
+
+		th.ConnectUser(t, user1.Id)
+
+		args = execute(t, sysadmin.Id, "disconnect", "@"+user1.Username)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("@%s has been disconnected from Teams.", user1.Username))
+
+		connected, err := th.p.IsUserConnected(user1.Id)
+		require.NoError(t, err)
+		assert.False(t, connected)
+	})
+
+	t.Run("invite", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 0
+			c.ConnectedUsersMaxPendingInvites = 0
+		})
+
+		args := execute(t, sysadmin.Id, "invite", user1.Email)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("@%s has been invited to connect to Teams.", user1.Username))
+		th.assertDMFromUserRe(t, th.p.botUserID, user1.Id, "you've been invited by your administrator")
+
+		invitedUser, err := th.p.store.GetInvitedUser(user1.Id)
+		require.NoError(t, err)
+		require.NotNil(t, invitedUser)
+		assert.NotZero(t, invitedUser.InviteLastSentAt)
+
+		th.ConnectUser(t, user1.Id)
+
+		args = execute(t, sysadmin.Id, "invite", user1.Email)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("Error: Unable to invite @%s, user already connected.", user1.Username))
+	})
+
+	t.Run("mapping", func(t *testing.T) {
+		th.Reset(t)
+
+		args := execute(t, sysadmin.Id, "mapping", user1.Username)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf("@%s is not mapped to a Teams user.", user1.Username))
+
+		th.ConnectUser(t, user1.Id)
+
+		args = execute(t, sysadmin.Id, "mapping", user1.Username)
+		post := th.retrieveEphemeralPost(t, args.UserId, args.ChannelId)
+		assert.Contains(t, post.Message, fmt.Sprintf("@%s is mapped to Teams user ID t%s.\n* Connected: yes\n* Last connected: ", user1.Username, user1.Id))
+	})
+}
+
+func TestExecuteAdminCredentialsCommand(t *testing.T) {
+	th := setupTestHelper(t)
+
+	team := th.SetupTeam(t)
+	sysadmin := th.SetupSysadmin(t, team)
+
+	th.SetupWebsocketClientForUser(t, sysadmin.Id)
+
+	execute := func(t *testing.T) *model.CommandArgs {
+		t.Helper()
+
+		args := &model.CommandArgs{
+			UserId:    sysadmin.Id,
+			ChannelId: model.NewId(),
+		}
+
+		commandResponse, appErr := th.p.executeAdminCommand(args, "credentials", nil)
+		require.Nil(t, appErr)
+		assertNoCommandResponse(t, commandResponse)
+
+		return args
+	}
+
+	t.Run("unable to get the app", func(t *testing.T) {
+		th.Reset(t)
+		th.appClientMock.On("GetApp", mock.Anything).Return(nil, errors.New("failed")).Once()
+
+		args := execute(t)
+		assertEphemeralResponse(th, t, args, "Error: Unable to check the credentials.")
+	})
+
+	t.Run("matching secret and missing permission", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ClientSecret = "abcdefghijklmnop"
+		})
+
+		app := &clientmodels.App{
+			Credentials: []clientmodels.Credential{
+				{Name: "other", ID: "id1", Hint: "xyz", EndDateTime: time.Now().Add(24 * time.Hour)},
+				{Name: "current", ID: "id2", Hint: "abc", EndDateTime: time.Now().Add(48 * time.Hour)},
+			},
+		}
+		for _, permission := range getExpectedPermissions(th.p.getConfiguration())[1:] {
+			app.RequiredResources = append(app.RequiredResources, permission.ResourceAccess)
+		}
+		th.appClientMock.On("GetApp", mock.Anything).Return(app, nil).Once()
+
+		args := execute(t)
+		post := th.retrieveEphemeralPost(t, args.UserId, args.ChannelId)
+		assert.Contains(t, post.Message, "* Client secret: current (id2), expires ")
+		assert.Contains(t, post.Message, "* Missing permissions: "+getExpectedPermissions(th.p.getConfiguration())[0].Name)
+		assert.Contains(t, post.Message, "* Redundant permissions: none")
+	})
+
+	t.Run("no matching secret", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ClientSecret = "abcdefghijklmnop"
+		})
+
+		app := &clientmodels.App{}
+		for _, permission := range getExpectedPermissions(th.p.getConfiguration()) {
+			app.RequiredResources = append(app.RequiredResources, permission.ResourceAccess)
+		}
+		th.appClientMock.On("GetApp", mock.Anything).Return(app, nil).Once()
+
+		args := execute(t)
+		assertEphemeralResponse(th, t, args, "Credentials check:\n* Client secret: none of the application's client secrets match the configured one\n* Missing permissions: none\n* Redundant permissions: none")
+	})
+}
@@ -5,12 +5,16 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
 	"golang.org/x/oauth2"
 
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
 	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
 
 	"github.com/stretchr/testify/assert"
@@ -283,11 +287,99 @@ func TestStatusCommand(t *testing.T) {
 
 		err := th.p.store.SetUserInfo(user1.Id, "team_user_id", &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(10 * time.Minute)})
 		require.NoError(t, err)
+		require.NoError(t, th.p.setNotificationPreference(user1.Id, true))
+
+		th.clientMock.On("GetMe").Return(&clientmodels.User{ID: "team_user_id", DisplayName: "Teams User", Mail: user1.Email}, nil).Times(1)
+
+		connectStatus, err := th.p.store.GetUserConnectStatus(user1.Id)
+		require.NoError(t, err)
+		connectedSince := connectStatus.LastConnectAt.In(user1.GetTimezoneLocation()).Format(displayTimeFormat)
+
+		commandResponse, appErr := th.p.executeStatusCommand(args)
+		require.Nil(t, appErr)
+		assertNoCommandResponse(t, commandResponse)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf(`Your account is connected to Teams.
+* Teams account: Teams User (%s)
+* Connected since: %s
+* Token: valid
+* Notifications: enabled
+* Last notification received: never`, user1.Email, connectedSince))
+	})
+
+	t.Run("connected with an invalid token", func(t *testing.T) {
+		th.Reset(t)
+
+		args := &model.CommandArgs{
+			UserId:    user1.Id,
+			ChannelId: model.NewId(),
+		}
+
+		err := th.p.store.SetUserInfo(user1.Id, "team_user_id", &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(10 * time.Minute)})
+		require.NoError(t, err)
+
+		th.clientMock.On("GetMe").Return(nil, &msteams.GraphAPIError{StatusCode: http.StatusUnauthorized}).Times(1)
+
+		commandResponse, appErr := th.p.executeStatusCommand(args)
+		require.Nil(t, appErr)
+		assertNoCommandResponse(t, commandResponse)
+
+		// The rejected token disconnects the user, which the status reports.
+		connectStatus, err := th.p.store.GetUserConnectStatus(user1.Id)
+		require.NoError(t, err)
+		assert.False(t, connectStatus.Connected)
+		disconnectedSince := connectStatus.LastDisconnectAt.In(user1.GetTimezoneLocation()).Format(displayTimeFormat)
+
+		assertEphemeralResponse(th, t, args, fmt.Sprintf(`Your account is not connected to Teams.
+* Disconnected since: %s
+* Token: rejected by Microsoft Teams, please reconnect your account with `+"`/msteams connect`"+`.`, disconnectedSince))
+	})
+
+	t.Run("connected with a token that cannot be checked", func(t *testing.T) {
+		th.Reset(t)
+
+		args := &model.CommandArgs{
+			UserId:    user1.Id,
+			ChannelId: model.NewId(),
+		}
+
+		err := th.p.store.SetUserInfo(user1.Id, "team_user_id", &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(10 * time.Minute)})
+		require.NoError(t, err)
+
+		th.clientMock.On("GetMe").Return(nil, errors.New("connection reset")).Times(1)
+
+		connectStatus, err := th.p.store.GetUserConnectStatus(user1.Id)
+		require.NoError(t, err)
+		connectedSince := connectStatus.LastConnectAt.In(user1.GetTimezoneLocation()).Format(displayTimeFormat)
+
+		commandResponse, appErr := th.p.executeStatusCommand(args)
+		require.Nil(t, appErr)
+		assertNoCommandResponse(t, commandResponse)
+		assertEphemeralResponse(th, t, args, fmt.Sprintf(`Your account is connected to Teams.
+* Connected since: %s
+* Token: unable to check it at the moment, please try again later.
+* Notifications: disabled
+* Last notification received: never`, connectedSince))
+	})
+
+	t.Run("disconnected", func(t *testing.T) {
+		th.Reset(t)
+
+		args := &model.CommandArgs{
+			UserId:    user1.Id,
+			ChannelId: model.NewId(),
+		}
+
+		th.ConnectUser(t, user1.Id)
+		th.DisconnectUser(t, user1.Id)
+
+		connectStatus, err := th.p.store.GetUserConnectStatus(user1.Id)
+		require.NoError(t, err)
+		disconnectedSince := connectStatus.LastDisconnectAt.In(user1.GetTimezoneLocation()).Format(displayTimeFormat)
 
 		commandResponse, appErr := th.p.executeStatusCommand(args)
 		require.Nil(t, appErr)
 		assertNoCommandResponse(t, commandResponse)
-		assertEphemeralResponse(th, t, args, "Your account is connected to Teams.")
+		assertEphemeralResponse(th, t, args, "Your account is not connected to Teams.\n* Disconnected since: "+disconnectedSince)
 	})
 }
 
@@ -5,10 +5,15 @@ package main
 
 import (
 	"encoding/json"
+	"net/http"
+	"os"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
 )
 
 // configuration captures the plugin's external configuration as exposed in the Mattermost server
@@ -23,44 +28,234 @@ import (
 // If you add non-reference types to your configuration struct, be sure to rewrite Clone as a deep
 // copy appropriate for your types.
 type configuration struct {
-	TenantID                        string `json:"tenantid"`
-	ClientID                        string `json:"clientid"`
-	ClientSecret                    string `json:"clientsecret"`
-	EncryptionKey                   string `json:"encryptionkey"`
-	EvaluationAPI                   bool   `json:"evaluationapi"`
-	WebhookSecret                   string `json:"webhooksecret"`
-	MaxSizeForCompleteDownload      int    `json:"maxSizeForCompleteDownload"`
-	BufferSizeForFileStreaming      int    `json:"bufferSizeForFileStreaming"`
-	ConnectedUsersAllowed           int    `json:"connectedUsersAllowed"`
-	ConnectedUsersRestricted        bool   `json:"connectedUsersRestricted"`
-	ConnectedUsersMaxPendingInvites int    `json:"connectedUsersMaxPendingInvites"`
-	DisableCheckCredentials         bool   `json:"internalDisableCheckCredentials"`
+	CloudEnvironment                     string `json:"cloudEnvironment"`
+	TenantID                             string `json:"tenantid"`
+	ClientID                             string `json:"clientid"`
+	ClientSecret                         string `json:"clientsecret"`
+	ClientCertificate                    string `json:"clientCertificate"`
+	ClientCertificateFile                string `json:"clientCertificateFile"`
+	ProxyURL                             string `json:"proxyUrl"`
+	NoProxy                              string `json:"noProxy"`
+	RootCAs                              string `json:"rootCAs"`
+	EncryptionKey                        string `json:"encryptionkey"`
+	EvaluationAPI                        bool   `json:"evaluationapi"`
+	WebhookSecret                        string `json:"webhooksecret"`
+	MaxSizeForCompleteDownload           int    `json:"maxSizeForCompleteDownload"`
+	BufferSizeForFileStreaming           int    `json:"bufferSizeForFileStreaming"`
+	CircuitBreakerErrorRate              int    `json:"circuitBreakerErrorRate"`
+	ConnectedUsersAllowed                int    `json:"connectedUsersAllowed"`
+	ConnectedUsersRestricted             bool   `json:"connectedUsersRestricted"`
+	ConnectedUsersMaxPendingInvites      int    `json:"connectedUsersMaxPendingInvites"`
+	ConnectedUsersInviteReminderDays     int    `json:"connectedUsersInviteReminderDays"`
+	ConnectedUsersInviteMaxReminders     int    `json:"connectedUsersInviteMaxReminders"`
+	ConnectedUsersInviteExpiryDays       int    `json:"connectedUsersInviteExpiryDays"`
+	ConnectedUsersInviteByMissedMessages bool   `json:"connectedUsersInviteByMissedMessages"`
+	ConnectedUsersMissedMessagesNudge    bool   `json:"connectedUsersMissedMessagesNudge"`
+	ConnectedUsersAllowedGroups          string `json:"connectedUsersAllowedGroups"`
+	ConnectedUsersAllowedTeams           string `json:"connectedUsersAllowedTeams"`
+	ConnectedUsersEnforceMembership      bool   `json:"connectedUsersEnforceMembership"`
+	AutoLinkUsers                        bool   `json:"autoLinkUsers"`
+	FetchMessagesWithAppClient           bool   `json:"fetchMessagesWithAppClient"`
+	DisconnectDisabledTeamsUsers         bool   `json:"disconnectDisabledTeamsUsers"`
+	ClientSecretExpiryAlertDays          string `json:"clientSecretExpiryAlertDays"`
+	ClientSecretExpiryAlertChannelID     string `json:"clientSecretExpiryAlertChannelId"`
+	DisableCheckCredentials              bool   `json:"internalDisableCheckCredentials"`
+
+	// clientCertificate is parsed from the configured client certificate, or the file holding it.
+	clientCertificate *msteams.ClientCertificate
+	// httpTransport reaches Microsoft through the configured proxy, trusting the configured root CAs.
+	httpTransport *http.Transport
 }
 
 func (c *configuration) ProcessConfiguration() {
+	c.CloudEnvironment = strings.ToLower(strings.TrimSpace(c.CloudEnvironment))
+	if c.CloudEnvironment == "" {
+		c.CloudEnvironment = string(msteams.CloudPublic)
+	}
 	c.TenantID = strings.TrimSpace(c.TenantID)
 	c.ClientID = strings.TrimSpace(c.ClientID)
 	c.ClientSecret = strings.TrimSpace(c.ClientSecret)
+	c.ClientCertificate = strings.TrimSpace(c.ClientCertificate)
+	c.ClientCertificateFile = strings.TrimSpace(c.ClientCertificateFile)
+	c.ProxyURL = strings.TrimSpace(c.ProxyURL)
+	c.NoProxy = strings.TrimSpace(c.NoProxy)
+	c.RootCAs = strings.TrimSpace(c.RootCAs)
 	c.EncryptionKey = strings.TrimSpace(c.EncryptionKey)
 	c.WebhookSecret = strings.TrimSpace(c.WebhookSecret)
+	c.ClientSecretExpiryAlertChannelID = strings.TrimSpace(c.ClientSecretExpiryAlertChannelID)
 	if c.MaxSizeForCompleteDownload < 0 {
 		c.MaxSizeForCompleteDownload = 0
 	}
 	if c.BufferSizeForFileStreaming <= 0 {
 		c.BufferSizeForFileStreaming = 20
 	}
+	if c.CircuitBreakerErrorRate < 0 {
+		c.CircuitBreakerErrorRate = 0
+	}
+	if c.CircuitBreakerErrorRate > 100 {
+		c.CircuitBreakerErrorRate = 100
+	}
+	if c.ConnectedUsersInviteReminderDays < 0 {
+		c.ConnectedUsersInviteReminderDays = 0
+	}
+	if c.ConnectedUsersInviteMaxReminders < 0 {
+		c.ConnectedUsersInviteMaxReminders = 0
+	}
+	if c.ConnectedUsersInviteExpiryDays < 0 {
+		c.ConnectedUsersInviteExpiryDays = 0
+	}
+}
+
+// AllowedGroups returns the names of the Mattermost groups whose members may connect in
+// restricted mode.
+func (c *configuration) AllowedGroups() []string {
+	return splitList(c.ConnectedUsersAllowedGroups)
+}
+
+// AllowedTeams returns the names of the Mattermost teams whose members may connect in
+// restricted mode.
+func (c *configuration) AllowedTeams() []string {
+	return splitList(c.ConnectedUsersAllowedTeams)
+}
+
+// HasMembershipRestrictions reports whether connections are restricted by group or team membership.
+func (c *configuration) HasMembershipRestrictions() bool {
+	return c.ConnectedUsersRestricted && (len(c.AllowedGroups()) > 0 || len(c.AllowedTeams()) > 0)
+}
+
+// TrackMissedMessages reports whether Teams messages missed by users who have not connected
+// should be recorded.
+func (c *configuration) TrackMissedMessages() bool {
+	return c.ConnectedUsersInviteByMissedMessages || c.ConnectedUsersMissedMessagesNudge
+}
+
+// UseAppClientForMessages reports whether chat messages and their hosted contents are fetched with
+// the application permissions rather than with the client of a connected chat member.
+func (c *configuration) UseAppClientForMessages() bool {
+	return c.FetchMessagesWithAppClient || c.AutoLinkUsers
+}
+
+// ClientSecretExpiryAlertThresholds returns the number of days before the client secret expires
+// at which to alert the system admins, ignoring invalid values.
+func (c *configuration) ClientSecretExpiryAlertThresholds() []int {
+	var thresholds []int
+	for _, value := range splitList(c.ClientSecretExpiryAlertDays) {
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			continue
+		}
+		thresholds = append(thresholds, days)
+	}
+
+	return thresholds
+}
+
+// Cloud returns the Microsoft cloud the tenant lives in.
+func (c *configuration) Cloud() msteams.Cloud {
+	return msteams.Cloud(c.CloudEnvironment)
+}
+
+// AppCredentials returns the credentials to authenticate the application with, preferring the
+// client certificate over the client secret.
+func (c *configuration) AppCredentials() msteams.AppCredentials {
+	if c.clientCertificate != nil {
+		return msteams.AppCredentials{Certificate: c.clientCertificate}
+	}
+
+	return msteams.AppCredentials{ClientSecret: c.ClientSecret}
 }
 
+// parseClientCertificate parses the client certificate given inline or in a file, if any.
+func (c *configuration) parseClientCertificate() (*msteams.ClientCertificate, error) {
+	if c.ClientCertificate != "" && c.ClientCertificateFile != "" {
+		return nil, errors.New("only one of the client certificate and the client certificate file should be set")
+	}
+
+	data := []byte(c.ClientCertificate)
+	if c.ClientCertificateFile != "" {
+		var err error
+		data, err = os.ReadFile(c.ClientCertificateFile) //#nosec G304 -- The path is set by a system admin
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the client certificate file")
+		}
+	}
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	certificate, err := msteams.ParseClientCertificate(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid client certificate")
+	}
+
+	return certificate, nil
+}
+
+// HTTPTransport returns the transport to reach Microsoft with, or nil to use the default one.
+func (c *configuration) HTTPTransport() *http.Transport {
+	return c.httpTransport
+}
+
+// newHTTPTransport builds the transport applying the configured proxy and root CAs, if any.
+func (c *configuration) newHTTPTransport() (*http.Transport, error) {
+	transport, err := msteams.NewTransport(c.ProxyURL, c.NoProxy, []byte(c.RootCAs))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid proxy settings")
+	}
+
+	return transport, nil
+}
+
+// withClientResources returns a copy of the configuration holding the client certificate and the
+// HTTP transport it describes.
+func (c *configuration) withClientResources() (*configuration, error) {
+	clone := c.Clone()
+
+	var err error
+	if clone.clientCertificate, err = c.parseClientCertificate(); err != nil {
+		return nil, err
+	}
+	if clone.httpTransport, err = c.newHTTPTransport(); err != nil {
+		return nil, err
+	}
+
+	return clone, nil
+}
+
+// splitList splits a comma separated setting into its trimmed, non-empty, lower-cased values.
+func splitList(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item != "" {
+			values = append(values, item)
+		}
+	}
+
+	return values
+}
+
+// validateConfiguration checks the processed configuration, without modifying it.
 func (p *Plugin) validateConfiguration(configuration *configuration) error {
-	configuration.ProcessConfiguration()
+	if !configuration.Cloud().IsValid() {
+		return errors.Errorf("invalid cloud environment %q", configuration.CloudEnvironment)
+	}
 	if configuration.TenantID == "" {
 		return errors.New("tenant ID should not be empty")
 	}
 	if configuration.ClientID == "" {
 		return errors.New("client ID should not be empty")
 	}
-	if configuration.ClientSecret == "" {
-		return errors.New("client secret should not be empty")
+	clientCertificate, err := configuration.parseClientCertificate()
+	if err != nil {
+		return err
+	}
+	if _, err := configuration.newHTTPTransport(); err != nil {
+		return err
+	}
+	if configuration.ClientSecret == "" && clientCertificate == nil {
+		return errors.New("client secret or client certificate should not be empty")
 	}
 	if configuration.EncryptionKey == "" {
 		return errors.New("encryption key should not be empty")
@@ -143,12 +338,24 @@ func (p *Plugin) OnConfigurationChange() error {
 		return errors.Wrap(err, "failed to load plugin configuration")
 	}
 
+	configuration.ProcessConfiguration()
 	if err := p.validateConfiguration(configuration); err != nil {
 		return err
 	}
 
+	configuration, err := configuration.withClientResources()
+	if err != nil {
+		return err
+	}
+
+	previousConfiguration := p.getConfiguration()
 	p.setConfiguration(configuration)
 
+	// The cached tokens were decrypted with the previous key, which no longer matches those stored.
+	if p.userCaches != nil && configuration.EncryptionKey != previousConfiguration.EncryptionKey {
+		p.userCaches.Purge()
+	}
+
 	// Only restart the application if the OnActivate is already executed
 	if p.store != nil {
 		go p.restart()
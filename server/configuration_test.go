@@ -0,0 +1,93 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+)
+
+func TestValidateConfigurationCloud(t *testing.T) {
+	p := &Plugin{}
+	newConfiguration := func(cloudEnvironment string) *configuration {
+		return &configuration{
+			CloudEnvironment: cloudEnvironment,
+			TenantID:         "tenant-id",
+			ClientID:         "client-id",
+			ClientSecret:     "secret",
+			EncryptionKey:    "encryption-key",
+			WebhookSecret:    "webhook-secret",
+		}
+	}
+
+	t.Run("defaults to the global service", func(t *testing.T) {
+		config := newConfiguration("")
+		config.ProcessConfiguration()
+		require.NoError(t, p.validateConfiguration(config))
+		assert.Equal(t, msteams.CloudPublic, config.Cloud())
+	})
+
+	t.Run("national cloud", func(t *testing.T) {
+		config := newConfiguration(" USGovernment ")
+		config.ProcessConfiguration()
+		require.NoError(t, p.validateConfiguration(config))
+		assert.Equal(t, msteams.CloudUSGovernment, config.Cloud())
+	})
+
+	t.Run("unknown cloud", func(t *testing.T) {
+		assert.EqualError(t, p.validateConfiguration(newConfiguration("moon")), `invalid cloud environment "moon"`)
+	})
+}
+
+func TestValidateConfigurationProxy(t *testing.T) {
+	p := &Plugin{}
+	newConfiguration := func(proxyURL, rootCAs string) *configuration {
+		return &configuration{
+			TenantID:      "tenant-id",
+			ClientID:      "client-id",
+			ClientSecret:  "secret",
+			EncryptionKey: "encryption-key",
+			WebhookSecret: "webhook-secret",
+			ProxyURL:      proxyURL,
+			NoProxy:       "localhost",
+			RootCAs:       rootCAs,
+		}
+	}
+
+	t.Run("no proxy settings", func(t *testing.T) {
+		config := newConfiguration("", "")
+		require.NoError(t, p.validateConfiguration(config))
+
+		config, err := config.withClientResources()
+		require.NoError(t, err)
+		assert.Nil(t, config.HTTPTransport())
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		config := newConfiguration(" http://proxy.example.com:3128 ", "")
+		config.ProcessConfiguration()
+		require.NoError(t, p.validateConfiguration(config))
+		// The transport is only built into a copy of the validated configuration.
+		assert.Nil(t, config.HTTPTransport())
+
+		config, err := config.withClientResources()
+		require.NoError(t, err)
+		require.NotNil(t, config.HTTPTransport())
+		assert.NotNil(t, config.HTTPTransport().Proxy)
+	})
+
+	t.Run("invalid proxy URL", func(t *testing.T) {
+		err := p.validateConfiguration(newConfiguration("proxy.example.com", ""))
+		assert.EqualError(t, err, `invalid proxy settings: invalid proxy URL "proxy.example.com"`)
+	})
+
+	t.Run("invalid root CAs", func(t *testing.T) {
+		err := p.validateConfiguration(newConfiguration("", "not a certificate"))
+		assert.EqualError(t, err, "invalid proxy settings: found no certificate in the root CAs")
+	})
+}
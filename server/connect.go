@@ -6,12 +6,20 @@ package main
 import (
 	"time"
 
+	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/pkg/errors"
 
 	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
 )
 
 func (p *Plugin) MaybeSendInviteMessage(userID string, currentTime time.Time) (bool, error) {
+	return p.maybeSendInviteMessage(userID, currentTime, false)
+}
+
+// maybeSendInviteMessage sends an invite or reminder to the given user if one is due. When
+// invites are prioritized by missed messages, new invites are only sent to the users picked
+// from that ranking.
+func (p *Plugin) maybeSendInviteMessage(userID string, currentTime time.Time, prioritized bool) (bool, error) {
 	if p.getConfiguration().ConnectedUsersMaxPendingInvites == 0 {
 		return false, nil
 	}
@@ -46,16 +54,37 @@ func (p *Plugin) MaybeSendInviteMessage(userID string, currentTime time.Time) (b
 		return false, nil
 	}
 
+	isLinked, err := p.IsUserLinked(user.Id)
+	if err != nil {
+		return false, errors.Wrapf(err, "error checking user linked status")
+	}
+
+	if isLinked {
+		// user auto-linked, no need to connect
+		return false, nil
+	}
+
 	invitedUser, err := p.store.GetInvitedUser(user.Id)
 	if err != nil {
 		return false, errors.Wrapf(err, "error getting user invite")
 	}
 
-	var pendingSince time.Time
+	if invitedUser == nil {
+		if p.getConfiguration().ConnectedUsersInviteByMissedMessages && !prioritized {
+			// the invite pool is reserved for users missing the most messages
+			return false, nil
+		}
+
+		expiredInvite, err := p.store.GetExpiredInvite(user.Id)
+		if err != nil {
+			return false, errors.Wrapf(err, "error getting expired user invite")
+		}
+
+		if expiredInvite != nil {
+			// don't invite again once an invite expired
+			return false, nil
+		}
 
-	if invitedUser != nil {
-		pendingSince = invitedUser.InvitePendingSince
-	} else {
 		canInvite, err := p.canInviteUser(user.Id)
 		if err != nil {
 			return false, errors.Wrapf(err, "error checking if can invite")
@@ -66,33 +95,41 @@ func (p *Plugin) MaybeSendInviteMessage(userID string, currentTime time.Time) (b
 		}
 	}
 
-	if !p.shouldSendInviteMessage(pendingSince, currentTime, user.GetTimezoneLocation()) {
+	if !p.shouldSendInviteMessage(invitedUser, currentTime, user.GetTimezoneLocation()) {
 		return false, nil
 	}
 
-	if err := p.SendInviteMessage(user); err != nil {
-		return false, errors.Wrapf(err, "error sending invite")
-	}
+	if invitedUser == nil {
+		if err := p.SendInviteMessage(user); err != nil {
+			return false, errors.Wrapf(err, "error sending invite")
+		}
 
-	invitedUser = &storemodels.InvitedUser{
-		ID:                 user.Id,
-		InvitePendingSince: pendingSince,
-		InviteLastSentAt:   currentTime,
-	}
-	if invitedUser.InvitePendingSince.IsZero() {
-		invitedUser.InvitePendingSince = currentTime
+		invitedUser = &storemodels.InvitedUser{
+			ID:                 user.Id,
+			InvitePendingSince: currentTime,
+		}
+	} else {
+		if err := p.SendInviteReminderMessage(user); err != nil {
+			return false, errors.Wrapf(err, "error sending invite reminder")
+		}
+
+		invitedUser.InviteRemindersSent++
 	}
+
+	invitedUser.InviteLastSentAt = currentTime
 	if err := p.store.StoreInvitedUser(invitedUser); err != nil {
 		return false, errors.Wrapf(err, "error storing user in invite list")
 	}
 
-	p.apiClient.Log.Info("Recorded user invite", "user_id", invitedUser.ID, "pending_since", invitedUser.InvitePendingSince, "last_sent_at", invitedUser.InviteLastSentAt)
+	p.apiClient.Log.Info("Recorded user invite", "user_id", invitedUser.ID, "pending_since", invitedUser.InvitePendingSince, "last_sent_at", invitedUser.InviteLastSentAt, "reminders_sent", invitedUser.InviteRemindersSent)
 
 	return true, nil
 }
 
+// shouldSendInviteMessage reports whether an invite, or a reminder for an existing invite, is
+// due at the given time.
 func (p *Plugin) shouldSendInviteMessage(
-	pendingSince time.Time,
+	invitedUser *storemodels.InvitedUser,
 	currentTime time.Time,
 	timezone *time.Location,
 ) bool {
@@ -103,27 +140,41 @@ func (p *Plugin) shouldSendInviteMessage(
 		return false
 	}
 
-	if !pendingSince.IsZero() {
-		// only send once
+	if invitedUser == nil {
+		return true
+	}
+
+	config := p.getConfiguration()
+	if config.ConnectedUsersInviteReminderDays == 0 || invitedUser.InviteRemindersSent >= config.ConnectedUsersInviteMaxReminders {
+		// no more reminders
 		return false
 	}
 
-	return true
+	lastSentAt := invitedUser.InviteLastSentAt
+	if lastSentAt.IsZero() {
+		lastSentAt = invitedUser.InvitePendingSince
+	}
+
+	return !currentTime.Before(lastSentAt.AddDate(0, 0, config.ConnectedUsersInviteReminderDays))
 }
 
 func (p *Plugin) canInviteUser(userID string) (bool, error) {
-	if p.getConfiguration().ConnectedUsersRestricted {
-		isWhitelisted, err := p.store.IsUserWhitelisted(userID)
-		if err != nil {
-			return false, errors.Wrapf(err, "error in checking if user is whitelisted")
-		}
+	isAllowed, err := p.isUserAllowedToConnect(userID)
+	if err != nil {
+		return false, err
+	}
 
-		if !isWhitelisted {
-			// only whitelisted users can connect in restricted mode
-			return false, nil
-		}
+	if !isAllowed {
+		// only whitelisted users or members of allowed groups and teams can connect in restricted mode
+		return false, nil
 	}
 
+	return p.hasRoomInInvitePool()
+}
+
+// hasRoomInInvitePool reports whether another user can be invited without exceeding the pending
+// invites or the users allowed to connect.
+func (p *Plugin) hasRoomInInvitePool() (bool, error) {
 	nConnected, err := p.store.GetHasConnectedCount()
 	if err != nil {
 		return false, errors.Wrapf(err, "error in getting has-connected count")
@@ -148,6 +199,11 @@ func (p *Plugin) UserHasRightToConnect(mmUserID string) (bool, error) {
 	}
 
 	if hasConnected {
+		if p.getConfiguration().ConnectedUsersEnforceMembership && p.getConfiguration().HasMembershipRestrictions() {
+			// users who left the allowed groups and teams cannot reconnect, unless whitelisted
+			return p.isUserWhitelistedOrInAllowedGroupsOrTeams(mmUserID)
+		}
+
 		return true, nil
 	}
 
@@ -176,17 +232,117 @@ func (p *Plugin) UserCanOpenlyConnect(mmUserID string) (bool, int, error) {
 
 	nAvailable := p.getConfiguration().ConnectedUsersAllowed - nConnected - nInvited
 
-	if p.getConfiguration().ConnectedUsersRestricted {
-		isWhitelisted, err := p.store.IsUserWhitelisted(mmUserID)
-		if err != nil {
-			return false, 0, errors.Wrapf(err, "error in checking if user is whitelisted")
-		}
+	isAllowed, err := p.isUserAllowedToConnect(mmUserID)
+	if err != nil {
+		return false, 0, err
+	}
 
-		if !isWhitelisted {
-			// only whitelisted users can connect in restricted mode
-			return false, nAvailable, nil
-		}
+	if !isAllowed {
+		// only whitelisted users or members of allowed groups and teams can connect in restricted mode
+		return false, nAvailable, nil
 	}
 
 	return nAvailable > 0, nAvailable, nil
 }
+
+// isUserAllowedToConnect reports whether the user passes the restricted mode checks, i.e. is
+// whitelisted or a member of one of the allowed groups or teams.
+func (p *Plugin) isUserAllowedToConnect(mmUserID string) (bool, error) {
+	if !p.getConfiguration().ConnectedUsersRestricted {
+		return true, nil
+	}
+
+	return p.isUserWhitelistedOrInAllowedGroupsOrTeams(mmUserID)
+}
+
+// isUserWhitelistedOrInAllowedGroupsOrTeams reports whether the user is whitelisted, or a member
+// of one of the allowed groups or teams. Whitelisted users are exempt from the membership checks.
+func (p *Plugin) isUserWhitelistedOrInAllowedGroupsOrTeams(mmUserID string) (bool, error) {
+	isWhitelisted, err := p.store.IsUserWhitelisted(mmUserID)
+	if err != nil {
+		return false, errors.Wrapf(err, "error in checking if user is whitelisted")
+	}
+
+	if isWhitelisted {
+		return true, nil
+	}
+
+	return p.isUserInAllowedGroupsOrTeams(mmUserID)
+}
+
+// disconnectUser drops the stored token of a connected user, keeping the mapping to the Teams user.
+func (p *Plugin) disconnectUser(mmUserID, teamsUserID string) error {
+	if err := p.store.SetUserInfo(mmUserID, teamsUserID, nil); err != nil {
+		return err
+	}
+
+	p.API.LogInfo("User disconnected from Teams", "user_id", mmUserID, "teams_user_id", teamsUserID)
+
+	p.API.PublishWebSocketEvent(WSEventUserDisconnected, map[string]any{}, &model.WebsocketBroadcast{
+		UserId: mmUserID,
+	})
+
+	if err := p.setNotificationPreference(mmUserID, false); err != nil {
+		p.API.LogWarn("unable to disable notifications preference", "error", err.Error())
+	}
+
+	return nil
+}
+
+// inviteUser sends an invite to the given user regardless of the invite pool, e.g. on request of
+// a system admin. Users already invited get the invite again, keeping their pending invite.
+func (p *Plugin) inviteUser(user *model.User, currentTime time.Time) error {
+	if user.Id == p.botUserID || user.IsBot {
+		return errors.New("bot accounts cannot be invited")
+	}
+
+	if user.IsGuest() {
+		return errors.New("guest accounts cannot be invited")
+	}
+
+	p.connectClusterMutex.Lock()
+	defer p.connectClusterMutex.Unlock()
+
+	isConnected, err := p.IsUserConnected(user.Id)
+	if err != nil {
+		return errors.Wrapf(err, "error checking user connected status")
+	}
+
+	if isConnected {
+		return errors.New("user already connected")
+	}
+
+	isLinked, err := p.IsUserLinked(user.Id)
+	if err != nil {
+		return errors.Wrapf(err, "error checking user linked status")
+	}
+
+	if isLinked {
+		return errors.New("user already linked")
+	}
+
+	invitedUser, err := p.store.GetInvitedUser(user.Id)
+	if err != nil {
+		return errors.Wrapf(err, "error getting user invite")
+	}
+
+	if invitedUser == nil {
+		invitedUser = &storemodels.InvitedUser{
+			ID:                 user.Id,
+			InvitePendingSince: currentTime,
+		}
+	}
+
+	if err := p.SendInviteMessage(user); err != nil {
+		return errors.Wrapf(err, "error sending invite")
+	}
+
+	invitedUser.InviteLastSentAt = currentTime
+	if err := p.store.StoreInvitedUser(invitedUser); err != nil {
+		return errors.Wrapf(err, "error storing user in invite list")
+	}
+
+	p.API.LogInfo("Recorded user invite", "user_id", invitedUser.ID, "pending_since", invitedUser.InvitePendingSince, "last_sent_at", invitedUser.InviteLastSentAt)
+
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
 )
 
 func TestMaybeSendInviteMessage(t *testing.T) {
@@ -161,6 +163,134 @@ func TestMaybeSendInviteMessage(t *testing.T) {
 		th.assertDMFromUserRe(t, botUser.Id, user.Id, "you've been invited by your administrator")
 	})
 
+	t.Run("don't send invite, invite expired", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 1
+			c.ConnectedUsersMaxPendingInvites = 1
+		})
+
+		err := th.p.GetStore().StoreExpiredInvite(&storemodels.ExpiredInvite{
+			ID:                 user.Id,
+			InvitePendingSince: tuesdayNoon.AddDate(0, 0, -30),
+			InviteExpiredAt:    tuesdayNoon.AddDate(0, 0, -1),
+		})
+		require.NoError(t, err)
+
+		result, err := th.p.MaybeSendInviteMessage(user.Id, tuesdayNoon)
+		assert.NoError(t, err)
+		assert.Equal(t, false, result)
+		th.assertNoDMFromUser(t, botUser.Id, user.Id, model.GetMillisForTime(time.Now().Add(-5*time.Second)))
+	})
+
+	t.Run("don't send reminder, interval not elapsed", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 1
+			c.ConnectedUsersMaxPendingInvites = 1
+			c.ConnectedUsersInviteReminderDays = 7
+			c.ConnectedUsersInviteMaxReminders = 3
+		})
+
+		err := th.p.GetStore().StoreInvitedUser(&storemodels.InvitedUser{
+			ID:                 user.Id,
+			InvitePendingSince: tuesdayNoon.AddDate(0, 0, -6),
+			InviteLastSentAt:   tuesdayNoon.AddDate(0, 0, -6),
+		})
+		require.NoError(t, err)
+
+		result, err := th.p.MaybeSendInviteMessage(user.Id, tuesdayNoon)
+		assert.NoError(t, err)
+		assert.Equal(t, false, result)
+		th.assertNoDMFromUser(t, botUser.Id, user.Id, model.GetMillisForTime(time.Now().Add(-5*time.Second)))
+	})
+
+	t.Run("don't send reminder, max reminders reached", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 1
+			c.ConnectedUsersMaxPendingInvites = 1
+			c.ConnectedUsersInviteReminderDays = 7
+			c.ConnectedUsersInviteMaxReminders = 3
+		})
+
+		err := th.p.GetStore().StoreInvitedUser(&storemodels.InvitedUser{
+			ID:                  user.Id,
+			InvitePendingSince:  tuesdayNoon.AddDate(0, 0, -28),
+			InviteLastSentAt:    tuesdayNoon.AddDate(0, 0, -7),
+			InviteRemindersSent: 3,
+		})
+		require.NoError(t, err)
+
+		result, err := th.p.MaybeSendInviteMessage(user.Id, tuesdayNoon)
+		assert.NoError(t, err)
+		assert.Equal(t, false, result)
+		th.assertNoDMFromUser(t, botUser.Id, user.Id, model.GetMillisForTime(time.Now().Add(-5*time.Second)))
+	})
+
+	t.Run("don't send reminder, weekend", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 1
+			c.ConnectedUsersMaxPendingInvites = 1
+			c.ConnectedUsersInviteReminderDays = 7
+			c.ConnectedUsersInviteMaxReminders = 3
+		})
+
+		err := th.p.GetStore().StoreInvitedUser(&storemodels.InvitedUser{
+			ID:                 user.Id,
+			InvitePendingSince: saturdayEvening.AddDate(0, 0, -14),
+			InviteLastSentAt:   saturdayEvening.AddDate(0, 0, -14),
+		})
+		require.NoError(t, err)
+
+		result, err := th.p.MaybeSendInviteMessage(user.Id, saturdayEvening)
+		assert.NoError(t, err)
+		assert.Equal(t, false, result)
+		th.assertNoDMFromUser(t, botUser.Id, user.Id, model.GetMillisForTime(time.Now().Add(-5*time.Second)))
+	})
+
+	t.Run("send reminder, interval elapsed", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 1
+			c.ConnectedUsersMaxPendingInvites = 1
+			c.ConnectedUsersInviteReminderDays = 7
+			c.ConnectedUsersInviteMaxReminders = 3
+		})
+
+		pendingSince := tuesdayNoon.AddDate(0, 0, -14)
+		err := th.p.GetStore().StoreInvitedUser(&storemodels.InvitedUser{
+			ID:                  user.Id,
+			InvitePendingSince:  pendingSince,
+			InviteLastSentAt:    tuesdayNoon.AddDate(0, 0, -7),
+			InviteRemindersSent: 1,
+		})
+		require.NoError(t, err)
+
+		result, err := th.p.MaybeSendInviteMessage(user.Id, tuesdayNoon)
+		assert.NoError(t, err)
+		assert.Equal(t, true, result)
+		th.assertDMFromUserRe(t, botUser.Id, user.Id, "this is a reminder that you've been invited by your administrator")
+
+		invitedUser, err := th.p.GetStore().GetInvitedUser(user.Id)
+		require.NoError(t, err)
+		require.NotNil(t, invitedUser)
+		assert.Equal(t, 2, invitedUser.InviteRemindersSent)
+		assert.True(t, pendingSince.Equal(invitedUser.InvitePendingSince))
+		assert.True(t, tuesdayNoon.Equal(invitedUser.InviteLastSentAt))
+	})
+
 	t.Run("send invite, whitelist restricted", func(t *testing.T) {
 		th.Reset(t)
 		user := th.SetupUser(t, team)
@@ -244,6 +374,21 @@ func TestCanInviteUser(t *testing.T) {
 		assert.Equal(t, false, result)
 	})
 
+	t.Run("can invite, member of allowed team", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersMaxPendingInvites = 1
+			c.ConnectedUsersRestricted = true
+			c.ConnectedUsersAllowedTeams = team.Name
+		})
+
+		result, err := th.p.canInviteUser(user.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, true, result)
+	})
+
 	t.Run("can invite, whitelist restricted", func(t *testing.T) {
 		th.Reset(t)
 		user := th.SetupUser(t, team)
@@ -305,6 +450,27 @@ func TestUserHasRightToConnect(t *testing.T) {
 		assert.Equal(t, true, result)
 	})
 
+	t.Run("does not have right to connect, has connected before but left allowed groups", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		otherUser := th.SetupUser(t, team)
+		group := th.SetupGroup(t, otherUser)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 0
+			c.ConnectedUsersRestricted = true
+			c.ConnectedUsersAllowedGroups = *group.Name
+			c.ConnectedUsersEnforceMembership = true
+		})
+
+		th.ConnectUser(t, user.Id)
+		th.DisconnectUser(t, user.Id)
+
+		result, err := th.p.UserHasRightToConnect(user.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, false, result)
+	})
+
 	t.Run("does not have right to connect, is plugin bot", func(t *testing.T) {
 		th.Reset(t)
 
@@ -395,4 +561,25 @@ func TestUserCanOpenlyConnect(t *testing.T) {
 		assert.Equal(t, true, result)
 		assert.Equal(t, 1, nAvailable)
 	})
+
+	t.Run("can openly connect, member of allowed group", func(t *testing.T) {
+		th.Reset(t)
+
+		group := th.SetupGroup(t, user)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 1
+			c.ConnectedUsersRestricted = true
+			c.ConnectedUsersAllowedGroups = *group.Name
+		})
+
+		result, nAvailable, err := th.p.UserCanOpenlyConnect(user.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, true, result)
+		assert.Equal(t, 1, nAvailable)
+
+		result, _, err = th.p.UserCanOpenlyConnect(otherUser.Id)
+		assert.NoError(t, err)
+		assert.Equal(t, false, result)
+	})
 }
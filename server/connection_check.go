@@ -0,0 +1,160 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+)
+
+const (
+	connectionCheckConnect  = "connect"
+	connectionCheckGetApp   = "get_app"
+	connectionCheckPresence = "presence"
+	connectionCheckWebhook  = "webhook"
+
+	connectionCheckWebhookTimeout = 10 * time.Second
+)
+
+// ConnectionTestRequest holds the proposed app settings to test. Settings left empty, or masked
+// by the System Console, fall back to the saved configuration. A client certificate, given as
+// PEM, takes precedence over a client secret.
+type ConnectionTestRequest struct {
+	TenantID          string `json:"tenant_id"`
+	ClientID          string `json:"client_id"`
+	ClientSecret      string `json:"client_secret"`
+	ClientCertificate string `json:"client_certificate"`
+}
+
+// ConnectionTestReport is the outcome of testing the app settings, one entry per check.
+type ConnectionTestReport struct {
+	Success bool               `json:"success"`
+	Checks  []*ConnectionCheck `json:"checks"`
+}
+
+type ConnectionCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (r *ConnectionTestReport) add(name string, err error) bool {
+	check := &ConnectionCheck{Name: name, Passed: err == nil}
+	if err != nil {
+		check.Error = err.Error()
+		r.Success = false
+	}
+	r.Checks = append(r.Checks, check)
+
+	return err == nil
+}
+
+func (r *ConnectionTestReport) skip(name, reason string) {
+	r.Checks = append(r.Checks, &ConnectionCheck{Name: name, Skipped: true, Error: reason})
+}
+
+// resolve fills in the settings not given in the request from the saved configuration,
+// returning the credentials to test.
+func (r *ConnectionTestRequest) resolve(config *configuration) (msteams.AppCredentials, error) {
+	if r.TenantID == "" {
+		r.TenantID = config.TenantID
+	}
+	if r.ClientID == "" {
+		r.ClientID = config.ClientID
+	}
+
+	if r.ClientCertificate != "" && r.ClientCertificate != model.FakeSetting {
+		certificate, err := msteams.ParseClientCertificate([]byte(r.ClientCertificate))
+		if err != nil {
+			return msteams.AppCredentials{}, errors.Wrap(err, "invalid client certificate")
+		}
+		return msteams.AppCredentials{Certificate: certificate}, nil
+	}
+	if r.ClientSecret != "" && r.ClientSecret != model.FakeSetting {
+		return msteams.AppCredentials{ClientSecret: r.ClientSecret}, nil
+	}
+
+	return config.AppCredentials(), nil
+}
+
+// testConnection checks the given app settings against MS Teams with a temporary app client,
+// without affecting the app client in use, and checks that the webhook is reachable.
+func (p *Plugin) testConnection(request *ConnectionTestRequest, credentials msteams.AppCredentials, actorUserID string) *ConnectionTestReport {
+	report := &ConnectionTestReport{Success: true}
+
+	client := p.appClientBuilder(p.getConfiguration().Cloud(), request.TenantID, request.ClientID, credentials, p.getConfiguration().HTTPTransport(), &p.apiClient.Log)
+	if !report.add(connectionCheckConnect, client.Connect()) {
+		report.skip(connectionCheckGetApp, "unable to connect")
+		report.skip(connectionCheckPresence, "unable to connect")
+	} else {
+		_, err := client.GetApp(request.ClientID)
+		report.add(connectionCheckGetApp, err)
+
+		teamsUserID, err := p.getTeamsUserIDForConnectionCheck(actorUserID)
+		if err != nil {
+			report.add(connectionCheckPresence, err)
+		} else if teamsUserID == "" {
+			report.skip(connectionCheckPresence, "no connected user to look up")
+		} else {
+			_, err = client.GetPresencesForUsers([]string{teamsUserID})
+			report.add(connectionCheckPresence, err)
+		}
+	}
+
+	report.add(connectionCheckWebhook, checkWebhookReachable(p.GetURL()+"/changes"))
+
+	p.API.LogInfo("Tested the connection to MS Teams", "admin_user_id", actorUserID, "success", report.Success)
+
+	return report
+}
+
+// getTeamsUserIDForConnectionCheck picks the Teams user to look up the presence of, preferring
+// the admin running the check.
+func (p *Plugin) getTeamsUserIDForConnectionCheck(actorUserID string) (string, error) {
+	if teamsUserID, err := p.store.MattermostToTeamsUserID(actorUserID); err == nil && teamsUserID != "" {
+		return teamsUserID, nil
+	}
+
+	connectedUsers, err := p.store.GetConnectedUsers(0, 1)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get a connected user")
+	}
+	if len(connectedUsers) == 0 {
+		return "", nil
+	}
+
+	return connectedUsers[0].TeamsUserID, nil
+}
+
+// checkWebhookReachable performs the validation handshake MS Teams does when subscribing to
+// changes against the given webhook URL.
+func checkWebhookReachable(webhookURL string) error {
+	validationToken := model.NewId()
+
+	client := &http.Client{Timeout: connectionCheckWebhookTimeout}
+	response, err := client.Post(webhookURL+"?validationToken="+url.QueryEscape(validationToken), "text/plain", nil)
+	if err != nil {
+		return errors.Wrap(err, "webhook not reachable")
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1024))
+	if err != nil {
+		return errors.Wrap(err, "unable to read the webhook response")
+	}
+
+	if response.StatusCode != http.StatusOK || string(body) != validationToken {
+		return errors.Errorf("unexpected webhook response with status %d, check the Site URL", response.StatusCode)
+	}
+
+	return nil
+}
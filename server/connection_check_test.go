@@ -0,0 +1,194 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+func TestCheckWebhookReachable(t *testing.T) {
+	t.Run("echoes the validation token", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.URL.Query().Get("validationToken")))
+		}))
+		t.Cleanup(server.Close)
+
+		assert.NoError(t, checkWebhookReachable(server.URL+"/changes"))
+	})
+
+	t.Run("unexpected response", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "not found", http.StatusNotFound)
+		}))
+		t.Cleanup(server.Close)
+
+		assert.EqualError(t, checkWebhookReachable(server.URL+"/changes"), "unexpected webhook response with status 404, check the Site URL")
+	})
+}
+
+func TestConnectionTestRequestResolve(t *testing.T) {
+	config := &configuration{TenantID: "tenant-id", ClientID: "client-id", ClientSecret: "secret"}
+
+	t.Run("saved settings", func(t *testing.T) {
+		request := &ConnectionTestRequest{ClientSecret: model.FakeSetting, ClientCertificate: model.FakeSetting}
+		credentials, err := request.resolve(config)
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-id", request.TenantID)
+		assert.Equal(t, "client-id", request.ClientID)
+		assert.Equal(t, msteams.AppCredentials{ClientSecret: "secret"}, credentials)
+	})
+
+	t.Run("proposed client secret", func(t *testing.T) {
+		request := &ConnectionTestRequest{ClientID: "new-client-id", ClientSecret: "new-secret"}
+		credentials, err := request.resolve(config)
+		require.NoError(t, err)
+		assert.Equal(t, "new-client-id", request.ClientID)
+		assert.Equal(t, msteams.AppCredentials{ClientSecret: "new-secret"}, credentials)
+	})
+
+	t.Run("proposed client certificate", func(t *testing.T) {
+		request := &ConnectionTestRequest{ClientSecret: "new-secret", ClientCertificate: generateClientCertificatePEM(t)}
+		credentials, err := request.resolve(config)
+		require.NoError(t, err)
+		assert.NotNil(t, credentials.Certificate)
+		assert.Empty(t, credentials.ClientSecret)
+	})
+
+	t.Run("invalid client certificate", func(t *testing.T) {
+		request := &ConnectionTestRequest{ClientCertificate: "not a certificate"}
+		_, err := request.resolve(config)
+		assert.ErrorContains(t, err, "invalid client certificate")
+	})
+}
+
+func TestTestConnection(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	sendRequest := func(t *testing.T, userID string, request *ConnectionTestRequest) (int, *ConnectionTestReport) {
+		t.Helper()
+		client := th.SetupClient(t, userID)
+
+		body, err := json.Marshal(request)
+		require.NoError(t, err)
+
+		httpRequest, err := http.NewRequest(http.MethodPost, th.pluginURL(t, "/connection/test"), bytes.NewReader(body))
+		require.NoError(t, err)
+		httpRequest.Header.Set(model.HeaderAuth, client.AuthType+" "+client.AuthToken)
+
+		response, err := http.DefaultClient.Do(httpRequest)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, response.Body.Close())
+		})
+
+		if response.StatusCode != http.StatusOK {
+			return response.StatusCode, nil
+		}
+
+		var report ConnectionTestReport
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&report))
+		return response.StatusCode, &report
+	}
+
+	getCheck := func(t *testing.T, report *ConnectionTestReport, name string) *ConnectionCheck {
+		t.Helper()
+		for _, check := range report.Checks {
+			if check.Name == name {
+				return check
+			}
+		}
+		require.Failf(t, "check not found", name)
+		return nil
+	}
+
+	t.Run("insufficient permissions", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		statusCode, _ := sendRequest(t, user.Id, &ConnectionTestRequest{})
+		assert.Equal(t, http.StatusForbidden, statusCode)
+	})
+
+	t.Run("graph checks pass with the proposed settings", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		th.ConnectUser(t, sysadmin.Id)
+
+		var builtWith []string
+		th.p.appClientBuilder = func(_ msteams.Cloud, tenantID, clientID string, credentials msteams.AppCredentials, _ *http.Transport, _ *pluginapi.LogService) msteams.Client {
+			builtWith = []string{tenantID, clientID, credentials.ClientSecret}
+			return th.appClientMock
+		}
+
+		th.appClientMock.On("Connect").Return(nil).Once()
+		th.appClientMock.On("GetApp", "new-client-id").Return(&clientmodels.App{}, nil).Once()
+		th.appClientMock.On("GetPresencesForUsers", []string{"t" + sysadmin.Id}).Return(map[string]clientmodels.Presence{}, nil).Once()
+
+		statusCode, report := sendRequest(t, sysadmin.Id, &ConnectionTestRequest{
+			ClientID:     "new-client-id",
+			ClientSecret: model.FakeSetting,
+		})
+		require.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, []string{th.p.getConfiguration().TenantID, "new-client-id", th.p.getConfiguration().ClientSecret}, builtWith)
+
+		for _, name := range []string{connectionCheckConnect, connectionCheckGetApp, connectionCheckPresence} {
+			assert.True(t, getCheck(t, report, name).Passed, name)
+		}
+
+		// The Site URL of the test server isn't reachable.
+		webhook := getCheck(t, report, connectionCheckWebhook)
+		assert.False(t, webhook.Passed)
+		assert.NotEmpty(t, webhook.Error)
+		assert.False(t, report.Success)
+	})
+
+	t.Run("unable to connect", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+
+		th.appClientMock.On("Connect").Return(errors.New("invalid client secret")).Once()
+
+		statusCode, report := sendRequest(t, sysadmin.Id, &ConnectionTestRequest{ClientSecret: "wrong"})
+		require.Equal(t, http.StatusOK, statusCode)
+		assert.False(t, report.Success)
+
+		connect := getCheck(t, report, connectionCheckConnect)
+		assert.False(t, connect.Passed)
+		assert.Equal(t, "invalid client secret", connect.Error)
+		assert.True(t, getCheck(t, report, connectionCheckGetApp).Skipped)
+		assert.True(t, getCheck(t, report, connectionCheckPresence).Skipped)
+	})
+
+	t.Run("no connected user for the presence lookup", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+
+		th.appClientMock.On("Connect").Return(nil).Once()
+		th.appClientMock.On("GetApp", mock.Anything).Return(nil, errors.New("forbidden")).Once()
+
+		statusCode, report := sendRequest(t, sysadmin.Id, &ConnectionTestRequest{})
+		require.Equal(t, http.StatusOK, statusCode)
+		assert.False(t, report.Success)
+		assert.Equal(t, "forbidden", getCheck(t, report, connectionCheckGetApp).Error)
+
+		presence := getCheck(t, report, connectionCheckPresence)
+		assert.True(t, presence.Skipped)
+		assert.False(t, presence.Passed)
+	})
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+)
+
+const displayTimeFormat = "Jan 2, 2006 15:04 MST"
+
+// ConnectionStatus describes the connection of a Mattermost user to MS Teams.
+type ConnectionStatus struct {
+	// Connected is true for users with a token, as well as for auto-linked users, who don't need
+	// to connect to manage their notifications.
+	Connected            bool   `json:"connected"`
+	AutoLinked           bool   `json:"auto_linked"`
+	TeamsUserID          string `json:"teams_user_id,omitempty"`
+	TeamsDisplayName     string `json:"teams_display_name,omitempty"`
+	TeamsEmail           string `json:"teams_email,omitempty"`
+	ConnectedAt          int64  `json:"connected_at,omitempty"`
+	DisconnectedAt       int64  `json:"disconnected_at,omitempty"`
+	NotificationsEnabled bool   `json:"notifications_enabled"`
+	LastNotificationAt   int64  `json:"last_notification_at,omitempty"`
+	// TokenValid, along with the Teams display name and email, is only set when the token was checked.
+	TokenValid *bool `json:"token_valid,omitempty"`
+	// TokenCheckFailed is set when the token could not be checked, without proving it invalid.
+	TokenCheckFailed bool `json:"token_check_failed,omitempty"`
+}
+
+// getConnectionStatus gathers the connection status of the given user, optionally checking the
+// stored token against MS Teams if the user is connected, at the cost of a request.
+func (p *Plugin) getConnectionStatus(userID string, checkToken bool) (*ConnectionStatus, error) {
+	status := &ConnectionStatus{}
+
+	userInfo, err := p.store.GetUserInfo(userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getting user info")
+	}
+
+	if userInfo != nil {
+		status.TeamsUserID = userInfo.TeamsUserID
+		status.LastNotificationAt = toMillis(userInfo.LastChatReceivedAt)
+		if userInfo.Connected {
+			status.ConnectedAt = toMillis(userInfo.LastConnectAt)
+		} else {
+			status.DisconnectedAt = toMillis(userInfo.LastDisconnectAt)
+		}
+	}
+
+	if status.Connected, err = p.IsUserLinked(userID); err != nil {
+		return nil, errors.Wrap(err, "error in checking if user is linked")
+	}
+
+	if status.Connected {
+		status.NotificationsEnabled = p.getNotificationPreference(userID)
+	}
+
+	if userInfo == nil || !userInfo.Connected {
+		status.AutoLinked = status.Connected
+		return status, nil
+	}
+
+	if !checkToken {
+		return status, nil
+	}
+
+	client, err := p.GetClientForUser(userID)
+	if err != nil {
+		p.API.LogDebug("Unable to get the client for user", "user_id", userID, "error", err.Error())
+		return p.getFailedTokenCheckStatus(userID, status, err)
+	}
+
+	teamsUser, err := client.GetMe()
+	if err != nil {
+		p.API.LogDebug("Unable to get the MS Teams user", "user_id", userID, "error", err.Error())
+		return p.getFailedTokenCheckStatus(userID, status, err)
+	}
+
+	status.TokenValid = model.NewPointer(true)
+	status.TeamsDisplayName = teamsUser.DisplayName
+	status.TeamsEmail = teamsUser.Mail
+
+	return status, nil
+}
+
+// getFailedTokenCheckStatus records the failed token check in the status. A rejected token
+// disconnects the user as a side effect of the client, in which case the connection status is
+// gathered again to report the user as disconnected.
+func (p *Plugin) getFailedTokenCheckStatus(userID string, status *ConnectionStatus, err error) (*ConnectionStatus, error) {
+	setTokenCheckError(status, err)
+	if status.TokenValid == nil {
+		return status, nil
+	}
+
+	userInfo, err := p.store.GetUserInfo(userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getting user info")
+	}
+	if userInfo != nil && userInfo.Connected {
+		return status, nil
+	}
+
+	disconnectedStatus, err := p.getConnectionStatus(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	disconnectedStatus.TokenValid = status.TokenValid
+
+	return disconnectedStatus, nil
+}
+
+// setTokenCheckError records the outcome of a failed token check, telling the token being
+// rejected apart from errors that might be transient.
+func setTokenCheckError(status *ConnectionStatus, err error) {
+	var graphErr *msteams.GraphAPIError
+	if msteams.IsOAuthError(err) || (errors.As(err, &graphErr) && graphErr.StatusCode == http.StatusUnauthorized) {
+		status.TokenValid = model.NewPointer(false)
+		return
+	}
+
+	status.TokenCheckFailed = true
+}
+
+// formatConnectionStatus renders the connection status as a message, with times in the given location.
+func formatConnectionStatus(status *ConnectionStatus, location *time.Location) string {
+	formatTime := func(millis int64) string {
+		return time.UnixMilli(millis).In(location).Format(displayTimeFormat)
+	}
+
+	var lines []string
+	switch {
+	case status.Connected && !status.AutoLinked:
+		lines = append(lines, "Your account is connected to Teams.")
+		if status.TeamsDisplayName != "" {
+			lines = append(lines, fmt.Sprintf("* Teams account: %s (%s)", status.TeamsDisplayName, status.TeamsEmail))
+		}
+		if status.ConnectedAt != 0 {
+			lines = append(lines, "* Connected since: "+formatTime(status.ConnectedAt))
+		}
+		switch {
+		case status.TokenValid != nil && *status.TokenValid:
+			lines = append(lines, "* Token: valid")
+		case status.TokenValid != nil:
+			lines = append(lines, "* Token: invalid, please reconnect your account with `/msteams connect`.")
+		case status.TokenCheckFailed:
+			lines = append(lines, "* Token: unable to check it at the moment, please try again later.")
+		}
+	case status.AutoLinked:
+		lines = append(lines, "Your account is linked to Teams.")
+	default:
+		lines = append(lines, "Your account is not connected to Teams.")
+		if status.DisconnectedAt != 0 {
+			lines = append(lines, "* Disconnected since: "+formatTime(status.DisconnectedAt))
+		}
+		if status.TokenValid != nil && !*status.TokenValid {
+			lines = append(lines, "* Token: rejected by Microsoft Teams, please reconnect your account with `/msteams connect`.")
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	if status.NotificationsEnabled {
+		lines = append(lines, "* Notifications: enabled")
+	} else {
+		lines = append(lines, "* Notifications: disabled")
+	}
+
+	if status.LastNotificationAt != 0 {
+		lines = append(lines, "* Last notification received: "+formatTime(status.LastNotificationAt))
+	} else {
+		lines = append(lines, "* Last notification received: never")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (p *Plugin) getUserTimezoneLocation(userID string) *time.Location {
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		return time.UTC
+	}
+
+	return user.GetTimezoneLocation()
+}
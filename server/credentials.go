@@ -6,6 +6,7 @@ package main
 import (
 	"fmt"
 	"runtime/debug"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -20,8 +21,11 @@ const (
 )
 
 type expectedPermission struct {
+	// Name is the name of the permission on the Microsoft Graph resource of the configured cloud.
 	Name           string
 	ResourceAccess clientmodels.ResourceAccess
+	// Feature names the features needing the permission, unless needed to connect accounts.
+	Feature string
 }
 
 // getResourceAccessKey makes a map key for the resource access that simplifies checking
@@ -44,60 +48,137 @@ func describeResourceAccessType(resourceAccess clientmodels.ResourceAccess) stri
 	}
 }
 
-// getExpectedPermissions returns the set of expected permissions, keyed by the
-// name the enduser would expect to see in the Azure tenant.
-func getExpectedPermissions() []expectedPermission {
-	return []expectedPermission{
-		{
-			Name: "https://graph.microsoft.com/Chat.Read",
-			ResourceAccess: clientmodels.ResourceAccess{
-				ID:   "f501c180-9344-439a-bca0-6cbf209fd270",
-				Type: "Scope",
-			},
+var (
+	permissionChatRead = expectedPermission{
+		Name: "Chat.Read",
+		ResourceAccess: clientmodels.ResourceAccess{
+			ID:   "f501c180-9344-439a-bca0-6cbf209fd270",
+			Type: "Scope",
 		},
-		{
-			Name: "https://graph.microsoft.com/ChatMessage.Read",
-			ResourceAccess: clientmodels.ResourceAccess{
-				ID:   "cdcdac3a-fd45-410d-83ef-554db620e5c7",
-				Type: "Scope",
-			},
+	}
+	permissionChatMessageRead = expectedPermission{
+		Name: "ChatMessage.Read",
+		ResourceAccess: clientmodels.ResourceAccess{
+			ID:   "cdcdac3a-fd45-410d-83ef-554db620e5c7",
+			Type: "Scope",
+		},
+	}
+	permissionFilesReadAll = expectedPermission{
+		Name: "Files.Read.All",
+		ResourceAccess: clientmodels.ResourceAccess{
+			ID:   "df85f4d6-205c-4ac5-a5ea-6bf408dba283",
+			Type: "Scope",
 		},
-		{
-			Name: "https://graph.microsoft.com/Files.Read.All",
-			ResourceAccess: clientmodels.ResourceAccess{
-				ID:   "df85f4d6-205c-4ac5-a5ea-6bf408dba283",
-				Type: "Scope",
-			},
+	}
+	permissionOfflineAccess = expectedPermission{
+		Name: "offline_access",
+		ResourceAccess: clientmodels.ResourceAccess{
+			ID:   "7427e0e9-2fba-42fe-b0c0-848c9e6a8182",
+			Type: "Scope",
+		},
+	}
+	permissionUserRead = expectedPermission{
+		Name: "User.Read",
+		ResourceAccess: clientmodels.ResourceAccess{
+			ID:   "e1fe6dd8-ba31-4d61-89e7-88639da4683d",
+			Type: "Scope",
 		},
-		{
-			Name: "https://graph.microsoft.com/offline_access",
-			ResourceAccess: clientmodels.ResourceAccess{
-				ID:   "7427e0e9-2fba-42fe-b0c0-848c9e6a8182",
-				Type: "Scope",
-			},
+	}
+	permissionChatReadAll = expectedPermission{
+		Name: "Chat.Read.All",
+		ResourceAccess: clientmodels.ResourceAccess{
+			ID:   "6b7d71aa-70aa-4810-a8d9-5d9fb2830017",
+			Type: "Role",
 		},
-		{
-			Name: "https://graph.microsoft.com/User.Read",
-			ResourceAccess: clientmodels.ResourceAccess{
-				ID:   "e1fe6dd8-ba31-4d61-89e7-88639da4683d",
-				Type: "Scope",
-			},
+	}
+	permissionPresenceReadAll = expectedPermission{
+		Name: "Presence.Read.All",
+		ResourceAccess: clientmodels.ResourceAccess{
+			ID:   "a70e0c2d-e793-494c-94c4-118fa0a67f42",
+			Type: "Role",
 		},
-		{
-			Name: "https://graph.microsoft.com/Chat.Read.All",
-			ResourceAccess: clientmodels.ResourceAccess{
-				ID:   "6b7d71aa-70aa-4810-a8d9-5d9fb2830017",
-				Type: "Role",
-			},
+	}
+	permissionUserRevokeSessionsAll = expectedPermission{
+		Name: "User.RevokeSessions.All",
+		ResourceAccess: clientmodels.ResourceAccess{
+			ID:   "77f3a031-c388-4f99-b373-dc68676a979e",
+			Type: "Role",
 		},
-		{
-			Name: "https://graph.microsoft.com/Presence.Read.All",
-			ResourceAccess: clientmodels.ResourceAccess{
-				ID:   "a70e0c2d-e793-494c-94c4-118fa0a67f42",
-				Type: "Role",
-			},
+	}
+	permissionUserReadAll = expectedPermission{
+		Name: "User.Read.All",
+		ResourceAccess: clientmodels.ResourceAccess{
+			ID:   "df021288-bdef-4463-88db-98f22de89214",
+			Type: "Role",
 		},
 	}
+)
+
+// getExpectedPermissions returns the set of expected permissions for the features enabled in
+// the given configuration, keyed by the name the enduser would expect to see in the Azure tenant.
+// Permissions needed by several features are listed once, naming all of them.
+func getExpectedPermissions(config *configuration) []expectedPermission {
+	var permissions []expectedPermission
+	graphURL := config.Cloud().GraphURL()
+	need := func(feature string, required ...expectedPermission) {
+		for _, permission := range required {
+			permission.Name = graphURL + "/" + permission.Name
+			permission.Feature = feature
+			i := slices.IndexFunc(permissions, func(expected expectedPermission) bool {
+				return expected.ResourceAccess == permission.ResourceAccess
+			})
+			if i < 0 {
+				permissions = append(permissions, permission)
+			} else if permissions[i].Feature != "" && feature != "" {
+				permissions[i].Feature += ", " + feature
+			} else {
+				// Permissions needed to connect are needed regardless of the features.
+				permissions[i].Feature = ""
+			}
+		}
+	}
+
+	// Connecting accounts, and fetching messages and files on behalf of connected users.
+	need("", permissionChatRead, permissionChatMessageRead, permissionFilesReadAll, permissionOfflineAccess, permissionUserRead)
+
+	// The subscription to all chats, delivering the notifications.
+	need("Chat notifications", permissionChatReadAll)
+
+	// The presence subscriptions of connected users, and the presence lookups when polling.
+	need("Presence subscriptions", permissionPresenceReadAll)
+
+	// Cleaning up deactivated and disabled users revokes the sessions of those connected.
+	need("Clean up users", permissionUserRevokeSessionsAll)
+
+	if config.UseAppClientForMessages() {
+		// Chat messages and their hosted contents are fetched with the application permissions.
+		need("Fetch messages with the application permissions", permissionChatReadAll)
+	}
+
+	if config.AutoLinkUsers {
+		// Auto-linking lists the users of the tenant.
+		need("Auto-link users", permissionUserReadAll)
+	}
+
+	if config.DisconnectDisabledTeamsUsers {
+		// Reconciling users lists the users of the tenant to find the disabled ones.
+		need("Disconnect users disabled in Microsoft Teams", permissionUserReadAll)
+	}
+
+	return permissions
+}
+
+// CredentialsReport is the outcome of checking the configured application against MS Teams.
+type CredentialsReport struct {
+	// Certificate is true when the application authenticates with a client certificate rather
+	// than a client secret.
+	Certificate bool
+	// Credential is the earliest expiring client secret or certificate matching the
+	// configuration, if any.
+	Credential           *clientmodels.Credential
+	ExpectedPermissions  []expectedPermission
+	MissingPermissions   []expectedPermission
+	RedundantPermissions []clientmodels.ResourceAccess
 }
 
 func (p *Plugin) checkCredentials() {
@@ -113,48 +194,63 @@ func (p *Plugin) checkCredentials() {
 
 	p.API.LogInfo("Running the check credentials job")
 
-	app, err := p.GetClientForApp().GetApp(p.getConfiguration().ClientID)
+	report, err := p.runCredentialsCheck()
 	if err != nil {
 		p.API.LogWarn("Failed to get app credentials", "error", err.Error())
 		return
 	}
 
+	if err := p.alertClientSecretExpiry(report); err != nil {
+		p.API.LogWarn("Failed to alert about the client secret", "error", err.Error())
+	}
+}
+
+// runCredentialsCheck checks the client secret and API permissions of the configured application,
+// logging and reporting metrics for what it finds.
+func (p *Plugin) runCredentialsCheck() (*CredentialsReport, error) {
+	app, err := p.GetClientForApp().GetApp(p.getConfiguration().ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	config := p.getConfiguration()
+	report := &CredentialsReport{Certificate: config.clientCertificate != nil}
 	credentials := app.Credentials
 
 	// We sort by earliest end date to cover the unlikely event we encounter two credentials
-	// with the same hint when reporting the single metric below.
+	// with the same hint or thumbprint when reporting the single metric below.
 	sort.SliceStable(credentials, func(i, j int) bool {
 		return credentials[i].EndDateTime.Before(credentials[j].EndDateTime)
 	})
 
-	found := false
-	for _, credential := range credentials {
-		if strings.HasPrefix(p.getConfiguration().ClientSecret, credential.Hint) {
+	for i, credential := range credentials {
+		if credentialMatchesConfiguration(credential, config) {
 			p.API.LogInfo("Found matching credential", "credential_name", credential.Name, "credential_id", credential.ID, "credential_end_date_time", credential.EndDateTime)
 
-			if !found {
-				// Report the first one that matches the hint.
+			if report.Credential == nil {
+				// Report the first one that matches.
+				report.Credential = &credentials[i]
 				p.GetMetrics().ObserveClientSecretEndDateTime(credential.EndDateTime)
 			} else {
-				// If we happen to get more than one with the same hint, we'll have reported the metric of the
-				// earlier one by virtue of the sort above, and we'll have the extra metadata we need in the logs.
-				p.API.LogWarn("Found more than one secret with same hint", "credential_id", credential.ID)
+				// If we happen to get more than one match, we'll have reported the metric of the earlier
+				// one by virtue of the sort above, and we'll have the extra metadata we need in the logs.
+				p.API.LogWarn("Found more than one credential matching configuration", "credential_id", credential.ID)
 			}
 
 			// Note that we keep going to log all the credentials found.
-			found = true
 		} else {
 			p.API.LogInfo("Found other credential", "credential_name", credential.Name, "credential_id", credential.ID, "credential_end_date_time", credential.EndDateTime)
 		}
 	}
 
-	if !found {
+	if report.Credential == nil {
 		p.API.LogWarn("Failed to find credential matching configuration")
 		p.GetMetrics().ObserveClientSecretEndDateTime(time.Time{})
 	}
 
-	missingPermissions, redundantResourceAccess := p.checkPermissions(app)
-	for _, permission := range missingPermissions {
+	report.ExpectedPermissions = getExpectedPermissions(config)
+	report.MissingPermissions, report.RedundantPermissions = p.checkPermissions(app)
+	for _, permission := range report.MissingPermissions {
 		p.API.LogWarn(
 			"Application missing required API Permission",
 			"permission", permission.Name,
@@ -164,7 +260,7 @@ func (p *Plugin) checkCredentials() {
 		)
 	}
 
-	for _, resourceAccess := range redundantResourceAccess {
+	for _, resourceAccess := range report.RedundantPermissions {
 		p.API.LogWarn(
 			"Application has redundant API Permission",
 			"resource_id", resourceAccess.ID,
@@ -172,6 +268,32 @@ func (p *Plugin) checkCredentials() {
 			"application_id", p.getConfiguration().ClientID,
 		)
 	}
+
+	if err := p.saveCredentialsCheckResult(report); err != nil {
+		p.API.LogWarn("Failed to save the credentials check result", "error", err.Error())
+	}
+
+	return report, nil
+}
+
+// credentialMatchesConfiguration reports whether the application credential is the configured
+// one: the certificate with the same thumbprint, or else the client secret with a matching hint.
+func credentialMatchesConfiguration(credential clientmodels.Credential, config *configuration) bool {
+	if config.clientCertificate != nil {
+		return credential.Thumbprint == config.clientCertificate.Thumbprint()
+	}
+
+	return credential.Thumbprint == "" && strings.HasPrefix(config.ClientSecret, credential.Hint)
+}
+
+// credentialKind names the kind of credential the application authenticates with, as shown to
+// system admins.
+func (r *CredentialsReport) credentialKind() string {
+	if r.Certificate {
+		return "client certificate"
+	}
+
+	return "client secret"
 }
 
 func (p *Plugin) checkPermissions(app *clientmodels.App) ([]expectedPermission, []clientmodels.ResourceAccess) {
@@ -187,7 +309,7 @@ func (p *Plugin) checkPermissions(app *clientmodels.App) ([]expectedPermission,
 		)
 	}
 
-	expectedPermissions := getExpectedPermissions()
+	expectedPermissions := getExpectedPermissions(p.getConfiguration())
 	expectedPermissionsMap := make(map[string]expectedPermission, len(expectedPermissions))
 	for _, expectedPermission := range expectedPermissions {
 		expectedPermissionsMap[getResourceAccessKey(expectedPermission.ResourceAccess)] = expectedPermission
@@ -0,0 +1,194 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	clientSecretAlertKey = "client_secret_expiry_alert" //#nosec G101 -- This is a false positive
+	systemAdminsPerPage  = 100
+)
+
+// clientSecretAlertState records the last alert sent about the client secret or certificate, so
+// that each threshold only fires once per credential.
+type clientSecretAlertState struct {
+	CredentialID string    `json:"credential_id,omitempty"`
+	EndDateTime  time.Time `json:"end_date_time,omitempty"`
+	Threshold    int       `json:"threshold"`
+	Missing      bool      `json:"missing,omitempty"`
+}
+
+// nextClientSecretAlert decides whether an alert is due given the outcome of the credentials
+// check and the last alert sent, returning the state to record along with the alert message.
+// It returns a nil state if no alert is due.
+func nextClientSecretAlert(report *CredentialsReport, previous *clientSecretAlertState, thresholds []int, now time.Time) (*clientSecretAlertState, string) {
+	if report.Credential == nil {
+		if previous != nil && previous.Missing {
+			return nil, ""
+		}
+
+		return &clientSecretAlertState{Missing: true}, fmt.Sprintf("None of the %[1]ss of the MS Teams application match the configured %[1]s. Notifications from MS Teams will stop working once the configured %[1]s is no longer valid. Please check the %[1]s in the plugin configuration.", report.credentialKind())
+	}
+
+	credential := report.Credential
+	daysLeft := int(math.Ceil(credential.EndDateTime.Sub(now).Hours() / 24))
+
+	threshold, due := clientSecretExpiryThreshold(daysLeft, thresholds)
+	if !due {
+		return nil, ""
+	}
+
+	if previous != nil && !previous.Missing && previous.CredentialID == credential.ID && previous.EndDateTime.Equal(credential.EndDateTime) && previous.Threshold <= threshold {
+		// Already alerted at this threshold or a more urgent one.
+		return nil, ""
+	}
+
+	state := &clientSecretAlertState{
+		CredentialID: credential.ID,
+		EndDateTime:  credential.EndDateTime,
+		Threshold:    threshold,
+	}
+
+	endDateTime := credential.EndDateTime.UTC().Format(displayTimeFormat)
+	if daysLeft <= 0 {
+		return state, fmt.Sprintf("The MS Teams %[1]s %[2]q expired on %[3]s. Notifications from MS Teams have stopped working. Please create a new %[1]s for the application and update the plugin configuration.", report.credentialKind(), credential.Name, endDateTime)
+	}
+
+	days := "days"
+	if daysLeft == 1 {
+		days = "day"
+	}
+
+	return state, fmt.Sprintf("The MS Teams %[1]s %[2]q expires in %[3]d %[4]s, on %[5]s. Please create a new %[1]s for the application and update the plugin configuration before then to keep notifications from MS Teams working.", report.credentialKind(), credential.Name, daysLeft, days, endDateTime)
+}
+
+// clientSecretExpiryThreshold returns the most urgent threshold reached with the given days
+// left before the client secret expires. An expired client secret always reaches threshold 0.
+func clientSecretExpiryThreshold(daysLeft int, thresholds []int) (int, bool) {
+	if daysLeft <= 0 {
+		return 0, true
+	}
+
+	threshold, due := 0, false
+	for _, t := range thresholds {
+		if daysLeft <= t && (!due || t < threshold) {
+			threshold, due = t, true
+		}
+	}
+
+	return threshold, due
+}
+
+// alertClientSecretExpiry alerts the system admins, or the configured channel, when the client
+// secret or certificate is about to expire or cannot be found.
+func (p *Plugin) alertClientSecretExpiry(report *CredentialsReport) error {
+	previousData, appErr := p.API.KVGet(clientSecretAlertKey)
+	if appErr != nil {
+		return errors.Wrap(appErr, "error in getting the last client secret alert")
+	}
+
+	var previous *clientSecretAlertState
+	if previousData != nil {
+		previous = &clientSecretAlertState{}
+		if err := json.Unmarshal(previousData, previous); err != nil {
+			p.API.LogWarn("Ignoring invalid client secret alert state", "error", err.Error())
+			previous = nil
+		}
+	}
+
+	state, message := nextClientSecretAlert(report, previous, p.getConfiguration().ClientSecretExpiryAlertThresholds(), time.Now())
+	if state == nil {
+		if previous != nil && report.Credential != nil && !isSameClientSecret(previous, report) {
+			// Start over once the client secret is replaced, or found again.
+			if appErr := p.API.KVDelete(clientSecretAlertKey); appErr != nil {
+				return errors.Wrap(appErr, "error in resetting the client secret alert")
+			}
+		}
+
+		return nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(data, previousData) {
+		return nil
+	}
+
+	// Claim the alert atomically, since the job may run concurrently on multiple nodes.
+	claimed, appErr := p.API.KVSetWithOptions(clientSecretAlertKey, data, model.PluginKVSetOptions{
+		Atomic:   true,
+		OldValue: previousData,
+	})
+	if appErr != nil {
+		return errors.Wrap(appErr, "error in storing the client secret alert")
+	}
+	if !claimed {
+		return nil
+	}
+
+	p.API.LogWarn("Alerting about the client secret", "credential_id", state.CredentialID, "threshold", state.Threshold, "missing", state.Missing)
+
+	return p.sendAdminAlert(message)
+}
+
+func isSameClientSecret(state *clientSecretAlertState, report *CredentialsReport) bool {
+	return !state.Missing && state.CredentialID == report.Credential.ID && state.EndDateTime.Equal(report.Credential.EndDateTime)
+}
+
+// sendAdminAlert posts the message into the configured alert channel, or sends it to all the
+// system admins in a direct message if no channel is configured.
+func (p *Plugin) sendAdminAlert(message string) error {
+	if channelID := p.getConfiguration().ClientSecretExpiryAlertChannelID; channelID != "" {
+		return p.apiClient.Post.CreatePost(&model.Post{
+			ChannelId: channelID,
+			UserId:    p.botUserID,
+			Message:   message,
+		})
+	}
+
+	return p.sendSystemAdminsDirectPost(message)
+}
+
+// sendSystemAdminsDirectPost sends the message to all the system admins in a direct message.
+func (p *Plugin) sendSystemAdminsDirectPost(message string) error {
+	for page := 0; ; page++ {
+		admins, appErr := p.API.GetUsers(&model.UserGetOptions{
+			Role:    model.SystemAdminRoleId,
+			Active:  true,
+			Page:    page,
+			PerPage: systemAdminsPerPage,
+		})
+		if appErr != nil {
+			return errors.Wrap(appErr, "error in getting system admins")
+		}
+
+		for _, admin := range admins {
+			if admin.IsBot {
+				continue
+			}
+
+			if err := p.botSendDirectPost(admin.Id, &model.Post{Message: message}); err != nil {
+				p.API.LogWarn("Unable to send alert to system admin", "user_id", admin.Id, "error", err.Error())
+			}
+		}
+
+		if len(admins) < systemAdminsPerPage {
+			break
+		}
+	}
+
+	return nil
+}
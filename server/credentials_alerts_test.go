@@ -0,0 +1,188 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+func TestNextClientSecretAlert(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	thresholds := []int{30, 14, 7, 1}
+
+	reportExpiringIn := func(d time.Duration) *CredentialsReport {
+		return &CredentialsReport{
+			Credential: &clientmodels.Credential{
+				Name:        "secret",
+				ID:          "id1",
+				EndDateTime: now.Add(d),
+			},
+		}
+	}
+
+	t.Run("not due", func(t *testing.T) {
+		state, message := nextClientSecretAlert(reportExpiringIn(31*24*time.Hour), nil, thresholds, now)
+		assert.Nil(t, state)
+		assert.Empty(t, message)
+	})
+
+	t.Run("first threshold", func(t *testing.T) {
+		state, message := nextClientSecretAlert(reportExpiringIn(29*24*time.Hour+time.Hour), nil, thresholds, now)
+		require.NotNil(t, state)
+		assert.Equal(t, 30, state.Threshold)
+		assert.Equal(t, "id1", state.CredentialID)
+		assert.Contains(t, message, `The MS Teams client secret "secret" expires in 30 days, on Mar 30, 2024 13:00 UTC.`)
+	})
+
+	t.Run("threshold already alerted", func(t *testing.T) {
+		report := reportExpiringIn(20 * 24 * time.Hour)
+		previous := &clientSecretAlertState{CredentialID: "id1", EndDateTime: report.Credential.EndDateTime, Threshold: 30}
+
+		state, _ := nextClientSecretAlert(report, previous, thresholds, now)
+		assert.Nil(t, state)
+	})
+
+	t.Run("next threshold", func(t *testing.T) {
+		report := reportExpiringIn(12 * time.Hour)
+		previous := &clientSecretAlertState{CredentialID: "id1", EndDateTime: report.Credential.EndDateTime, Threshold: 7}
+
+		state, message := nextClientSecretAlert(report, previous, thresholds, now)
+		require.NotNil(t, state)
+		assert.Equal(t, 1, state.Threshold)
+		assert.Contains(t, message, "expires in 1 day,")
+	})
+
+	t.Run("skipped thresholds only alert once", func(t *testing.T) {
+		state, _ := nextClientSecretAlert(reportExpiringIn(5*24*time.Hour), nil, thresholds, now)
+		require.NotNil(t, state)
+		assert.Equal(t, 7, state.Threshold)
+	})
+
+	t.Run("replaced client secret", func(t *testing.T) {
+		report := reportExpiringIn(25 * 24 * time.Hour)
+		previous := &clientSecretAlertState{CredentialID: "id0", EndDateTime: now, Threshold: 1}
+
+		state, _ := nextClientSecretAlert(report, previous, thresholds, now)
+		require.NotNil(t, state)
+		assert.Equal(t, 30, state.Threshold)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		report := reportExpiringIn(-time.Hour)
+		previous := &clientSecretAlertState{CredentialID: "id1", EndDateTime: report.Credential.EndDateTime, Threshold: 1}
+
+		state, message := nextClientSecretAlert(report, previous, thresholds, now)
+		require.NotNil(t, state)
+		assert.Equal(t, 0, state.Threshold)
+		assert.Contains(t, message, `The MS Teams client secret "secret" expired on Mar 1, 2024 11:00 UTC.`)
+
+		state, _ = nextClientSecretAlert(report, state, thresholds, now)
+		assert.Nil(t, state)
+	})
+
+	t.Run("expired without thresholds", func(t *testing.T) {
+		state, _ := nextClientSecretAlert(reportExpiringIn(-time.Hour), nil, nil, now)
+		require.NotNil(t, state)
+		assert.Equal(t, 0, state.Threshold)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		state, message := nextClientSecretAlert(&CredentialsReport{}, nil, thresholds, now)
+		require.NotNil(t, state)
+		assert.True(t, state.Missing)
+		assert.Contains(t, message, "None of the client secrets of the MS Teams application match the configured client secret.")
+
+		state, _ = nextClientSecretAlert(&CredentialsReport{}, state, thresholds, now)
+		assert.Nil(t, state)
+	})
+
+	t.Run("client certificate", func(t *testing.T) {
+		report := reportExpiringIn(6 * 24 * time.Hour)
+		report.Certificate = true
+
+		state, message := nextClientSecretAlert(report, nil, thresholds, now)
+		require.NotNil(t, state)
+		assert.Equal(t, 7, state.Threshold)
+		assert.Contains(t, message, `The MS Teams client certificate "secret" expires in 6 days,`)
+		assert.Contains(t, message, "Please create a new client certificate for the application")
+
+		_, message = nextClientSecretAlert(&CredentialsReport{Certificate: true}, nil, thresholds, now)
+		assert.Contains(t, message, "None of the client certificates of the MS Teams application match the configured client certificate.")
+	})
+}
+
+func TestClientSecretExpiryAlertThresholds(t *testing.T) {
+	c := &configuration{ClientSecretExpiryAlertDays: " 30, 14,,seven, -1, 0, 1"}
+	assert.Equal(t, []int{30, 14, 1}, c.ClientSecretExpiryAlertThresholds())
+
+	c = &configuration{}
+	assert.Empty(t, c.ClientSecretExpiryAlertThresholds())
+}
+
+func TestAlertClientSecretExpiry(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	reset := func(t *testing.T) {
+		t.Helper()
+		th.Reset(t)
+		require.Nil(t, th.p.API.KVDelete(clientSecretAlertKey))
+	}
+
+	t.Run("direct message to system admins", func(t *testing.T) {
+		reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ClientSecretExpiryAlertDays = "30,7"
+			c.ClientSecretExpiryAlertChannelID = ""
+		})
+
+		report := &CredentialsReport{
+			Credential: &clientmodels.Credential{Name: "secret", ID: "id1", EndDateTime: time.Now().Add(6 * 24 * time.Hour)},
+		}
+
+		require.NoError(t, th.p.alertClientSecretExpiry(report))
+		th.assertDMFromUserRe(t, th.p.botUserID, sysadmin.Id, `The MS Teams client secret "secret" expires in 6 days`)
+		th.assertNoDMFromUser(t, th.p.botUserID, user.Id, model.GetMillisForTime(time.Now().Add(-5*time.Second)))
+
+		// The same threshold only fires once.
+		checkTime := model.GetMillis()
+		require.NoError(t, th.p.alertClientSecretExpiry(report))
+		th.assertNoDMFromUser(t, th.p.botUserID, sysadmin.Id, checkTime)
+	})
+
+	t.Run("post into the configured channel", func(t *testing.T) {
+		reset(t)
+		channel := th.SetupPublicChannel(t, team)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ClientSecretExpiryAlertChannelID = channel.Id
+		})
+
+		require.NoError(t, th.p.alertClientSecretExpiry(&CredentialsReport{}))
+
+		postList, appErr := th.p.API.GetPostsForChannel(channel.Id, 0, 10)
+		require.Nil(t, appErr)
+		require.Len(t, postList.Order, 1)
+		post := postList.Posts[postList.Order[0]]
+		assert.Equal(t, th.p.botUserID, post.UserId)
+		assert.Contains(t, post.Message, "None of the client secrets of the MS Teams application match the configured client secret.")
+
+		// Finding the client secret again resets the alerts.
+		require.NoError(t, th.p.alertClientSecretExpiry(&CredentialsReport{
+			Credential: &clientmodels.Credential{ID: "id1", EndDateTime: time.Now().Add(365 * 24 * time.Hour)},
+		}))
+
+		data, appErr := th.p.API.KVGet(clientSecretAlertKey)
+		require.Nil(t, appErr)
+		assert.Nil(t, data)
+	})
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const credentialsCheckResultKey = "credentials_check_result" //#nosec G101 -- This is a false positive
+
+// CredentialsCheckResult is the outcome of the latest credentials check, as shown to system admins.
+type CredentialsCheckResult struct {
+	CheckedAt            int64               `json:"checked_at"`
+	ClientSecret         *ClientSecretStatus `json:"client_secret"`
+	Permissions          []*PermissionStatus `json:"permissions"`
+	RedundantPermissions []*PermissionStatus `json:"redundant_permissions"`
+}
+
+// ClientSecretStatus describes the client secret or certificate matching the configuration.
+type ClientSecretStatus struct {
+	Name        string `json:"name"`
+	ID          string `json:"id"`
+	EndDateTime int64  `json:"end_date_time"`
+	Thumbprint  string `json:"thumbprint,omitempty"`
+}
+
+// PermissionStatus describes an API permission of the application, and whether it is granted.
+type PermissionStatus struct {
+	Name    string `json:"name,omitempty"`
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Feature string `json:"feature,omitempty"`
+	Granted bool   `json:"granted"`
+}
+
+func newCredentialsCheckResult(report *CredentialsReport) *CredentialsCheckResult {
+	result := &CredentialsCheckResult{
+		CheckedAt:            model.GetMillis(),
+		Permissions:          []*PermissionStatus{},
+		RedundantPermissions: []*PermissionStatus{},
+	}
+
+	if report.Credential != nil {
+		result.ClientSecret = &ClientSecretStatus{
+			Name:        report.Credential.Name,
+			ID:          report.Credential.ID,
+			EndDateTime: toMillis(report.Credential.EndDateTime),
+			Thumbprint:  report.Credential.Thumbprint,
+		}
+	}
+
+	missing := make(map[string]bool, len(report.MissingPermissions))
+	for _, permission := range report.MissingPermissions {
+		missing[getResourceAccessKey(permission.ResourceAccess)] = true
+	}
+
+	for _, permission := range report.ExpectedPermissions {
+		result.Permissions = append(result.Permissions, &PermissionStatus{
+			Name:    permission.Name,
+			ID:      permission.ResourceAccess.ID,
+			Type:    describeResourceAccessType(permission.ResourceAccess),
+			Feature: permission.Feature,
+			Granted: !missing[getResourceAccessKey(permission.ResourceAccess)],
+		})
+	}
+
+	for _, resourceAccess := range report.RedundantPermissions {
+		result.RedundantPermissions = append(result.RedundantPermissions, &PermissionStatus{
+			ID:      resourceAccess.ID,
+			Type:    describeResourceAccessType(resourceAccess),
+			Granted: true,
+		})
+	}
+
+	return result
+}
+
+// revokedPermissions returns the permissions granted in the previous result that are no longer
+// granted in the current one.
+func revokedPermissions(previous, current *CredentialsCheckResult) []*PermissionStatus {
+	if previous == nil {
+		return nil
+	}
+
+	wasGranted := make(map[string]bool, len(previous.Permissions))
+	for _, permission := range previous.Permissions {
+		if permission.Granted {
+			wasGranted[permission.Type+":"+permission.ID] = true
+		}
+	}
+
+	var revoked []*PermissionStatus
+	for _, permission := range current.Permissions {
+		if !permission.Granted && wasGranted[permission.Type+":"+permission.ID] {
+			revoked = append(revoked, permission)
+		}
+	}
+
+	return revoked
+}
+
+func (p *Plugin) getCredentialsCheckResult() (*CredentialsCheckResult, error) {
+	data, appErr := p.API.KVGet(credentialsCheckResultKey)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "error in getting the credentials check result")
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	var result CredentialsCheckResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, errors.Wrap(err, "invalid credentials check result")
+	}
+
+	return &result, nil
+}
+
+// saveCredentialsCheckResult records the outcome of the credentials check, letting the system
+// admins know if a required permission was revoked since the previous check.
+func (p *Plugin) saveCredentialsCheckResult(report *CredentialsReport) error {
+	previousData, appErr := p.API.KVGet(credentialsCheckResultKey)
+	if appErr != nil {
+		return errors.Wrap(appErr, "error in getting the credentials check result")
+	}
+
+	var previous *CredentialsCheckResult
+	if previousData != nil {
+		previous = &CredentialsCheckResult{}
+		if err := json.Unmarshal(previousData, previous); err != nil {
+			p.API.LogWarn("Ignoring invalid credentials check result", "error", err.Error())
+			previous = nil
+		}
+	}
+
+	result := newCredentialsCheckResult(report)
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	// Replace the previous result atomically, so that only one node alerts when the check runs
+	// concurrently on multiple nodes.
+	saved, appErr := p.API.KVSetWithOptions(credentialsCheckResultKey, data, model.PluginKVSetOptions{
+		Atomic:   true,
+		OldValue: previousData,
+	})
+	if appErr != nil {
+		return errors.Wrap(appErr, "error in saving the credentials check result")
+	}
+	if !saved {
+		return nil
+	}
+
+	revoked := revokedPermissions(previous, result)
+	if len(revoked) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(revoked))
+	for _, permission := range revoked {
+		names = append(names, fmt.Sprintf("%s (%s)", permission.Name, permission.Type))
+		p.API.LogWarn("Application API permission was revoked", "permission", permission.Name, "resource_id", permission.ID, "type", permission.Type)
+	}
+
+	message := fmt.Sprintf("The MS Teams application is no longer granted the following API permissions required by the plugin: %s. Please grant them again to the application and grant admin consent.", strings.Join(names, ", "))
+
+	return p.sendSystemAdminsDirectPost(message)
+}
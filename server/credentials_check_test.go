@@ -0,0 +1,195 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+func TestNewCredentialsCheckResult(t *testing.T) {
+	expected := getExpectedPermissions(&configuration{AutoLinkUsers: true})
+	extra := clientmodels.ResourceAccess{ID: model.NewId(), Type: ResourceAccessTypeRole}
+	endDateTime := time.Now().Add(time.Hour)
+
+	result := newCredentialsCheckResult(&CredentialsReport{
+		Credential:           &clientmodels.Credential{Name: "secret", ID: "id1", EndDateTime: endDateTime},
+		ExpectedPermissions:  expected,
+		MissingPermissions:   expected[len(expected)-1:],
+		RedundantPermissions: []clientmodels.ResourceAccess{extra},
+	})
+
+	require.NotNil(t, result.ClientSecret)
+	assert.Equal(t, endDateTime.UnixMilli(), result.ClientSecret.EndDateTime)
+
+	require.Len(t, result.Permissions, len(expected))
+	assert.True(t, result.Permissions[0].Granted)
+	userReadAll := result.Permissions[len(expected)-1]
+	assert.False(t, userReadAll.Granted)
+	assert.Equal(t, "https://graph.microsoft.com/User.Read.All", userReadAll.Name)
+	assert.Equal(t, "Role (Application)", userReadAll.Type)
+	assert.Equal(t, "Auto-link users", userReadAll.Feature)
+
+	require.Len(t, result.RedundantPermissions, 1)
+	assert.Equal(t, extra.ID, result.RedundantPermissions[0].ID)
+
+	result = newCredentialsCheckResult(&CredentialsReport{})
+	assert.Nil(t, result.ClientSecret)
+	assert.Empty(t, result.Permissions)
+}
+
+func TestRevokedPermissions(t *testing.T) {
+	previous := &CredentialsCheckResult{
+		Permissions: []*PermissionStatus{
+			{ID: "a", Type: "Role (Application)", Granted: true},
+			{ID: "b", Type: "Role (Application)", Granted: false},
+			{ID: "c", Type: "Scope (Delegated)", Granted: true},
+		},
+	}
+	current := &CredentialsCheckResult{
+		Permissions: []*PermissionStatus{
+			{ID: "a", Type: "Role (Application)", Granted: false},
+			{ID: "b", Type: "Role (Application)", Granted: false},
+			{ID: "c", Type: "Scope (Delegated)", Granted: true},
+			{ID: "d", Type: "Role (Application)", Granted: false},
+		},
+	}
+
+	assert.Empty(t, revokedPermissions(nil, current))
+	assert.Equal(t, []*PermissionStatus{current.Permissions[0]}, revokedPermissions(previous, current))
+}
+
+func TestCredentialsCheck(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	reset := func(t *testing.T) {
+		t.Helper()
+		th.Reset(t)
+		require.Nil(t, th.p.API.KVDelete(credentialsCheckResultKey))
+		require.Nil(t, th.p.API.KVDelete(clientSecretAlertKey))
+	}
+
+	appWith := func(permissions []expectedPermission) *clientmodels.App {
+		app := &clientmodels.App{}
+		for _, permission := range permissions {
+			app.RequiredResources = append(app.RequiredResources, permission.ResourceAccess)
+		}
+		return app
+	}
+
+	t.Run("revoked permission alerts system admins", func(t *testing.T) {
+		reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		expected := getExpectedPermissions(th.p.getConfiguration())
+
+		th.appClientMock.On("GetApp", mock.Anything).Return(appWith(expected), nil).Once()
+		_, err := th.p.runCredentialsCheck()
+		require.NoError(t, err)
+
+		result, err := th.p.getCredentialsCheckResult()
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		for _, permission := range result.Permissions {
+			assert.True(t, permission.Granted, permission.Name)
+		}
+
+		th.appClientMock.On("GetApp", mock.Anything).Return(appWith(expected[1:]), nil).Once()
+		_, err = th.p.runCredentialsCheck()
+		require.NoError(t, err)
+
+		th.assertDMFromUserRe(t, th.p.botUserID, sysadmin.Id, "no longer granted the following API permissions required by the plugin: "+expected[0].Name)
+
+		// Still missing, but already alerted.
+		checkTime := model.GetMillis()
+		th.appClientMock.On("GetApp", mock.Anything).Return(appWith(expected[1:]), nil).Once()
+		_, err = th.p.runCredentialsCheck()
+		require.NoError(t, err)
+		th.assertNoDMFromUser(t, th.p.botUserID, sysadmin.Id, checkTime)
+	})
+
+	t.Run("newly expected permission does not alert", func(t *testing.T) {
+		reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		expected := getExpectedPermissions(th.p.getConfiguration())
+
+		th.appClientMock.On("GetApp", mock.Anything).Return(appWith(expected), nil).Once()
+		_, err := th.p.runCredentialsCheck()
+		require.NoError(t, err)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.AutoLinkUsers = true
+		})
+
+		checkTime := model.GetMillis()
+		th.appClientMock.On("GetApp", mock.Anything).Return(appWith(expected), nil).Once()
+		_, err = th.p.runCredentialsCheck()
+		require.NoError(t, err)
+		th.assertNoDMFromUser(t, th.p.botUserID, sysadmin.Id, checkTime)
+
+		result, err := th.p.getCredentialsCheckResult()
+		require.NoError(t, err)
+		require.Len(t, result.Permissions, len(expected)+1)
+		assert.False(t, result.Permissions[len(expected)].Granted)
+	})
+
+	t.Run("api", func(t *testing.T) {
+		reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+
+		get := func(t *testing.T, userID, path string) (int, *CredentialsCheckResult) {
+			t.Helper()
+			client := th.SetupClient(t, userID)
+
+			request, err := http.NewRequest(http.MethodGet, th.pluginURL(t, path), nil)
+			require.NoError(t, err)
+			request.Header.Set(model.HeaderAuth, client.AuthType+" "+client.AuthToken)
+
+			response, err := http.DefaultClient.Do(request)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, response.Body.Close())
+			})
+
+			if response.StatusCode != http.StatusOK {
+				return response.StatusCode, nil
+			}
+
+			var result CredentialsCheckResult
+			require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+			return response.StatusCode, &result
+		}
+
+		statusCode, _ := get(t, user.Id, "/credentials/check")
+		assert.Equal(t, http.StatusForbidden, statusCode)
+
+		// Runs the check if it never ran.
+		th.appClientMock.On("GetApp", mock.Anything).Return(&clientmodels.App{}, nil).Once()
+		statusCode, result := get(t, sysadmin.Id, "/credentials/check")
+		require.Equal(t, http.StatusOK, statusCode)
+		assert.Nil(t, result.ClientSecret)
+		require.NotEmpty(t, result.Permissions)
+		assert.False(t, result.Permissions[0].Granted)
+
+		// Returns the saved result otherwise.
+		statusCode, saved := get(t, sysadmin.Id, "/credentials/check")
+		require.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, len(result.Permissions), len(saved.Permissions))
+
+		th.appClientMock.On("GetApp", mock.Anything).Return(appWith(getExpectedPermissions(th.p.getConfiguration())), nil).Once()
+		statusCode, refreshed := get(t, sysadmin.Id, "/credentials/check?refresh=true")
+		require.Equal(t, http.StatusOK, statusCode)
+		assert.True(t, refreshed.Permissions[0].Granted)
+	})
+}
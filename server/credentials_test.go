@@ -4,11 +4,23 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
 )
 
@@ -27,6 +39,53 @@ func TestDescribeResourceAccessType(t *testing.T) {
 	}))
 }
 
+func TestGetExpectedPermissions(t *testing.T) {
+	permissions := getExpectedPermissions(&configuration{})
+	for _, permission := range permissions {
+		assert.NotEqual(t, "https://graph.microsoft.com/User.Read.All", permission.Name)
+	}
+
+	withAutoLink := getExpectedPermissions(&configuration{AutoLinkUsers: true})
+	require.Len(t, withAutoLink, len(permissions)+1)
+	assert.Equal(t, "https://graph.microsoft.com/User.Read.All", withAutoLink[len(permissions)].Name)
+	assert.Equal(t, ResourceAccessTypeRole, withAutoLink[len(permissions)].ResourceAccess.Type)
+	assert.Equal(t, "Auto-link users", withAutoLink[len(permissions)].Feature)
+
+	featureOf := func(permissions []expectedPermission, name string) string {
+		for _, permission := range permissions {
+			if permission.Name == name {
+				return permission.Feature
+			}
+		}
+		return "missing"
+	}
+
+	assert.Empty(t, featureOf(permissions, "https://graph.microsoft.com/Chat.Read"))
+	assert.Equal(t, "Chat notifications", featureOf(permissions, "https://graph.microsoft.com/Chat.Read.All"))
+	assert.Equal(t, "Presence subscriptions", featureOf(permissions, "https://graph.microsoft.com/Presence.Read.All"))
+	assert.Equal(t, "Clean up users", featureOf(permissions, "https://graph.microsoft.com/User.RevokeSessions.All"))
+
+	withAllFeatures := getExpectedPermissions(&configuration{
+		AutoLinkUsers:                true,
+		FetchMessagesWithAppClient:   true,
+		DisconnectDisabledTeamsUsers: true,
+	})
+	require.Len(t, withAllFeatures, len(permissions)+1)
+	assert.Equal(t, "Chat notifications, Fetch messages with the application permissions", featureOf(withAllFeatures, "https://graph.microsoft.com/Chat.Read.All"))
+	assert.Equal(t, "Auto-link users, Disconnect users disabled in Microsoft Teams", featureOf(withAllFeatures, "https://graph.microsoft.com/User.Read.All"))
+
+	withDisconnectDisabled := getExpectedPermissions(&configuration{DisconnectDisabledTeamsUsers: true})
+	assert.Equal(t, "Disconnect users disabled in Microsoft Teams", featureOf(withDisconnectDisabled, "https://graph.microsoft.com/User.Read.All"))
+
+	// The permissions are named after the Microsoft Graph resource of the configured cloud.
+	inUSGovernmentDoD := getExpectedPermissions(&configuration{CloudEnvironment: string(msteams.CloudUSGovernmentDoD)})
+	require.Len(t, inUSGovernmentDoD, len(permissions))
+	for i, permission := range inUSGovernmentDoD {
+		assert.Equal(t, permissions[i].ResourceAccess, permission.ResourceAccess)
+		assert.Equal(t, strings.Replace(permissions[i].Name, "https://graph.microsoft.com/", "https://dod-graph.microsoft.us/", 1), permission.Name)
+	}
+}
+
 func TestCheckPermissions(t *testing.T) {
 	th := setupTestHelper(t)
 
@@ -37,7 +96,7 @@ func TestCheckPermissions(t *testing.T) {
 
 		missing, redundant := th.p.checkPermissions(&app)
 
-		assert.Equal(t, getExpectedPermissions(), missing)
+		assert.Equal(t, getExpectedPermissions(th.p.getConfiguration()), missing)
 		assert.Empty(t, redundant)
 	})
 
@@ -47,7 +106,7 @@ func TestCheckPermissions(t *testing.T) {
 		var app clientmodels.App
 
 		var changedResourceAccess clientmodels.ResourceAccess
-		for i, expectedPermission := range getExpectedPermissions() {
+		for i, expectedPermission := range getExpectedPermissions(th.p.getConfiguration()) {
 			if i == 0 {
 				// Skip the first permission altogether
 				continue
@@ -74,8 +133,8 @@ func TestCheckPermissions(t *testing.T) {
 
 		missing, redundant := th.p.checkPermissions(&app)
 		assert.Equal(t, []expectedPermission{
-			getExpectedPermissions()[0],
-			getExpectedPermissions()[1],
+			getExpectedPermissions(th.p.getConfiguration())[0],
+			getExpectedPermissions(th.p.getConfiguration())[1],
 		}, missing)
 		assert.Equal(t, []clientmodels.ResourceAccess{
 			changedResourceAccess,
@@ -88,7 +147,7 @@ func TestCheckPermissions(t *testing.T) {
 
 		var app clientmodels.App
 
-		for _, expectedPermission := range getExpectedPermissions() {
+		for _, expectedPermission := range getExpectedPermissions(th.p.getConfiguration()) {
 			app.RequiredResources = append(app.RequiredResources, expectedPermission.ResourceAccess)
 		}
 
@@ -97,3 +156,86 @@ func TestCheckPermissions(t *testing.T) {
 		assert.Empty(t, redundant)
 	})
 }
+
+// generateClientCertificatePEM returns a self-signed certificate along with its private key.
+func generateClientCertificatePEM(t *testing.T) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "msteams-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func TestParseClientCertificate(t *testing.T) {
+	certificatePEM := generateClientCertificatePEM(t)
+
+	t.Run("none", func(t *testing.T) {
+		config, err := (&configuration{ClientSecret: "secret"}).withClientResources()
+		require.NoError(t, err)
+		assert.Equal(t, msteams.AppCredentials{ClientSecret: "secret"}, config.AppCredentials())
+	})
+
+	t.Run("inline", func(t *testing.T) {
+		config, err := (&configuration{ClientSecret: "secret", ClientCertificate: certificatePEM}).withClientResources()
+		require.NoError(t, err)
+
+		credentials := config.AppCredentials()
+		require.NotNil(t, credentials.Certificate)
+		assert.Empty(t, credentials.ClientSecret)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "certificate.pem")
+		require.NoError(t, os.WriteFile(path, []byte(certificatePEM), 0600))
+
+		certificate, err := (&configuration{ClientCertificateFile: path}).parseClientCertificate()
+		require.NoError(t, err)
+		assert.NotNil(t, certificate)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := (&configuration{ClientCertificateFile: filepath.Join(t.TempDir(), "missing.pem")}).parseClientCertificate()
+		assert.ErrorContains(t, err, "unable to read the client certificate file")
+	})
+
+	t.Run("both inline and file", func(t *testing.T) {
+		_, err := (&configuration{ClientCertificate: certificatePEM, ClientCertificateFile: "/tmp/certificate.pem"}).parseClientCertificate()
+		assert.EqualError(t, err, "only one of the client certificate and the client certificate file should be set")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := (&configuration{ClientCertificate: "not a certificate"}).parseClientCertificate()
+		assert.ErrorContains(t, err, "invalid client certificate")
+	})
+}
+
+func TestCredentialMatchesConfiguration(t *testing.T) {
+	secret := clientmodels.Credential{ID: "id1", Hint: "abc"}
+
+	t.Run("client secret", func(t *testing.T) {
+		config := &configuration{ClientSecret: "abcdef"}
+		assert.True(t, credentialMatchesConfiguration(secret, config))
+		assert.False(t, credentialMatchesConfiguration(clientmodels.Credential{ID: "id2", Hint: "xyz"}, config))
+		assert.False(t, credentialMatchesConfiguration(clientmodels.Credential{ID: "id3", Thumbprint: "ABCDEF"}, config))
+	})
+
+	t.Run("client certificate", func(t *testing.T) {
+		config, err := (&configuration{ClientSecret: "abcdef", ClientCertificate: generateClientCertificatePEM(t)}).withClientResources()
+		require.NoError(t, err)
+
+		assert.True(t, credentialMatchesConfiguration(clientmodels.Credential{ID: "id2", Thumbprint: config.clientCertificate.Thumbprint()}, config))
+		assert.False(t, credentialMatchesConfiguration(clientmodels.Credential{ID: "id3", Thumbprint: "ABCDEF"}, config))
+		assert.False(t, credentialMatchesConfiguration(secret, config))
+	})
+}
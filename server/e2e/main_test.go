@@ -60,7 +60,7 @@ func newManualClient(tenantID, clientID string, logService *pluginapi.LogService
 		return nil
 	}
 
-	client, err := msgraphsdk.NewGraphServiceClientWithCredentials(cred, msteams.TeamsDefaultScopes)
+	client, err := msgraphsdk.NewGraphServiceClientWithCredentials(cred, msteams.CloudPublic.Scopes())
 	if err != nil {
 		fmt.Printf("Error creating client: %v\n", err)
 		return nil
@@ -0,0 +1,110 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"database/sql"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+// ForceDisconnectRequest is the body of a request by a system admin to disconnect users from
+// MS Teams. Users are given by username or email, and are ignored when disconnecting a single user.
+type ForceDisconnectRequest struct {
+	Users  []string `json:"users"`
+	Reason string   `json:"reason"`
+}
+
+// ForceDisconnectResult reports the outcome of disconnecting users from MS Teams.
+type ForceDisconnectResult struct {
+	Disconnected []string `json:"disconnected"`
+	NotConnected []string `json:"not_connected"`
+	NotFound     []string `json:"not_found"`
+	Failed       []string `json:"failed"`
+	FailedLines  []string `json:"failed_lines,omitempty"`
+}
+
+func (r *ForceDisconnectResult) add(userID string, disconnected bool) {
+	if disconnected {
+		r.Disconnected = append(r.Disconnected, userID)
+	} else {
+		r.NotConnected = append(r.NotConnected, userID)
+	}
+}
+
+// forceDisconnectUser disconnects the given user from MS Teams on behalf of a system admin,
+// letting the user know why if a reason is given. It reports false if the user wasn't connected.
+func (p *Plugin) forceDisconnectUser(user *model.User, reason, actorUserID string) (bool, error) {
+	rec := newAuditRecord(auditEventForceDisconnectUser, actorUserID)
+	defer p.API.LogAuditRec(rec)
+	model.AddEventParameterToAuditRec(rec, "user_id", user.Id)
+	model.AddEventParameterToAuditRec(rec, "reason", reason)
+
+	disconnected, err := p.disconnectConnectedUser(user.Id)
+	if err != nil {
+		rec.AddErrorDesc(err.Error())
+		rec.Fail()
+		return false, err
+	}
+
+	rec.AddMeta("disconnected", disconnected)
+	rec.Success()
+
+	if !disconnected {
+		return false, nil
+	}
+
+	p.API.LogInfo("User disconnected by admin", "user_id", user.Id, "admin_user_id", actorUserID)
+
+	if reason != "" {
+		if err := p.SendDisconnectedByAdminMessage(user, reason); err != nil {
+			p.API.LogWarn("Unable to notify user of disconnection", "user_id", user.Id, "error", err.Error())
+		}
+	}
+
+	return true, nil
+}
+
+// forceDisconnectUsers disconnects each of the given users, recording the outcome in the result.
+func (p *Plugin) forceDisconnectUsers(users []*model.User, reason, actorUserID string, result *ForceDisconnectResult) {
+	for _, user := range users {
+		disconnected, err := p.forceDisconnectUser(user, reason, actorUserID)
+		if err != nil {
+			p.API.LogWarn("Unable to disconnect user", "user_id", user.Id, "error", err.Error())
+			result.Failed = append(result.Failed, user.Id)
+			continue
+		}
+
+		result.add(user.Id, disconnected)
+	}
+}
+
+// disconnectConnectedUser disconnects the given user if they have a stored token, reporting
+// whether they did.
+func (p *Plugin) disconnectConnectedUser(userID string) (bool, error) {
+	teamsUserID, err := p.store.MattermostToTeamsUserID(userID)
+	if err == sql.ErrNoRows {
+		// Users who never connected have no mapping.
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "error in getting the Teams user for the Mattermost user")
+	}
+
+	token, err := p.store.GetTokenForMattermostUser(userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrap(err, "error in getting the token for the user")
+	}
+	if token == nil {
+		return false, nil
+	}
+
+	if err := p.disconnectUser(userID, teamsUserID); err != nil {
+		return false, errors.Wrap(err, "error in disconnecting user")
+	}
+
+	return true, nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForceDisconnectUser(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	t.Run("not connected", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+
+		disconnected, err := th.p.forceDisconnectUser(user, "", sysadmin.Id)
+		require.NoError(t, err)
+		assert.False(t, disconnected)
+
+		th.DisconnectUser(t, user.Id)
+
+		disconnected, err = th.p.forceDisconnectUser(user, "", sysadmin.Id)
+		require.NoError(t, err)
+		assert.False(t, disconnected)
+	})
+
+	t.Run("connected, with a reason", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+		require.NoError(t, th.p.setNotificationPreference(user.Id, true))
+		th.SetupWebsocketClientForUser(t, user.Id)
+
+		disconnected, err := th.p.forceDisconnectUser(user, "Leaving the company", sysadmin.Id)
+		require.NoError(t, err)
+		assert.True(t, disconnected)
+
+		th.assertWebsocketEvent(t, user.Id, makePluginWebsocketEventName(WSEventUserDisconnected))
+		th.assertDMFromUserRe(t, th.p.botUserID, user.Id, "disconnected by your administrator.\nReason: Leaving the company")
+
+		connected, err := th.p.IsUserConnected(user.Id)
+		require.NoError(t, err)
+		assert.False(t, connected)
+		assert.False(t, th.p.getNotificationPreference(user.Id))
+
+		teamsUserID, err := th.p.store.MattermostToTeamsUserID(user.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "t"+user.Id, teamsUserID)
+	})
+}
+
+func TestForceDisconnectAPI(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	sendRequest := func(t *testing.T, user *model.User, path, contentType string, body io.Reader) (int, *ForceDisconnectResult) {
+		t.Helper()
+		client1 := th.SetupClient(t, user.Id)
+
+		request, err := http.NewRequest(http.MethodPost, th.pluginURL(t, path), body)
+		require.NoError(t, err)
+
+		request.Header.Set(model.HeaderAuth, client1.AuthType+" "+client1.AuthToken)
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, response.Body.Close())
+		})
+
+		if response.StatusCode != http.StatusOK {
+			return response.StatusCode, nil
+		}
+
+		var result ForceDisconnectResult
+		require.NoError(t, json.NewDecoder(response.Body).Decode(&result))
+
+		return response.StatusCode, &result
+	}
+
+	t.Run("insufficient permissions", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+
+		statusCode, _ := sendRequest(t, user, "/users/"+user.Username+"/disconnect", "", nil)
+		assert.Equal(t, http.StatusForbidden, statusCode)
+
+		connected, err := th.p.IsUserConnected(user.Id)
+		require.NoError(t, err)
+		assert.True(t, connected)
+	})
+
+	t.Run("single user", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+
+		statusCode, _ := sendRequest(t, sysadmin, "/users/unknown/disconnect", "", nil)
+		assert.Equal(t, http.StatusNotFound, statusCode)
+
+		statusCode, result := sendRequest(t, sysadmin, "/users/"+user.Username+"/disconnect", "application/json", bytes.NewBufferString(`{"reason": "Security review"}`))
+		require.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, []string{user.Id}, result.Disconnected)
+		th.assertDMFromUserRe(t, th.p.botUserID, user.Id, "Reason: Security review")
+
+		statusCode, result = sendRequest(t, sysadmin, "/users/"+user.Username+"/disconnect", "", nil)
+		require.Equal(t, http.StatusOK, statusCode)
+		assert.Empty(t, result.Disconnected)
+		assert.Equal(t, []string{user.Id}, result.NotConnected)
+	})
+
+	t.Run("many users", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user1 := th.SetupUser(t, team)
+		user2 := th.SetupUser(t, team)
+		user3 := th.SetupUser(t, team)
+		th.ConnectUser(t, user1.Id)
+		th.ConnectUser(t, user2.Id)
+
+		statusCode, _ := sendRequest(t, sysadmin, "/users/disconnect", "application/json", bytes.NewBufferString(`{"users": []}`))
+		assert.Equal(t, http.StatusBadRequest, statusCode)
+
+		body, err := json.Marshal(&ForceDisconnectRequest{
+			Users: []string{"@" + user1.Username, user2.Email, user3.Username, "unknown"},
+		})
+		require.NoError(t, err)
+
+		statusCode, result := sendRequest(t, sysadmin, "/users/disconnect", "application/json", bytes.NewReader(body))
+		require.Equal(t, http.StatusOK, statusCode)
+		assert.ElementsMatch(t, []string{user1.Id, user2.Id}, result.Disconnected)
+		assert.Equal(t, []string{user3.Id}, result.NotConnected)
+		assert.Equal(t, []string{"unknown"}, result.NotFound)
+		assert.Empty(t, result.Failed)
+	})
+
+	t.Run("csv upload", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user1 := th.SetupUser(t, team)
+		user2 := th.SetupUser(t, team)
+		th.ConnectUser(t, user1.Id)
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("file", "users.csv")
+		require.NoError(t, err)
+		_, err = part.Write([]byte("email\n" + user1.Email + "\n" + user2.Email + "\nunknown@example.com\n"))
+		require.NoError(t, err)
+		require.NoError(t, writer.WriteField("reason", "Offboarding"))
+		require.NoError(t, writer.Close())
+
+		statusCode, result := sendRequest(t, sysadmin, "/users/disconnect/upload", writer.FormDataContentType(), body)
+		require.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, []string{user1.Id}, result.Disconnected)
+		assert.Equal(t, []string{user2.Id}, result.NotConnected)
+		assert.Equal(t, []string{"unknown@example.com"}, result.NotFound)
+		th.assertDMFromUserRe(t, th.p.botUserID, user1.Id, "Reason: Offboarding")
+	})
+}
@@ -7,13 +7,16 @@ import (
 	"database/sql"
 	"errors"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/enescakir/emoji"
 
 	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/client_breakerlayer"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
 )
 
@@ -26,6 +29,15 @@ const (
 	numberOfWorkers             = 50
 	activityQueueSize           = 5000
 	maxFileAttachmentsSupported = 10
+
+	// Activities failing while the circuit breaker of Microsoft Graph is open are parked, and
+	// queued again once it may have closed, for a limited time.
+	maxParkedActivities    = 1000
+	maxActivityParkingTime = 10 * time.Minute
+
+	// Presence changes are applied right away, but published to the other servers at most this
+	// often, in a single cluster event.
+	presenceInvalidationsPublishDelay = 1 * time.Second
 )
 
 type ActivityHandler struct {
@@ -34,6 +46,13 @@ type ActivityHandler struct {
 	quit                 chan bool
 	workersWaitGroup     sync.WaitGroup
 	IgnorePluginHooksMap sync.Map
+
+	parkedLock sync.Mutex
+	parked     map[string]time.Time
+
+	presenceInvalidationsLock      sync.Mutex
+	presenceInvalidations          map[string]bool
+	presenceInvalidationsScheduled bool
 }
 
 func NewActivityHandler(plugin *Plugin) *ActivityHandler {
@@ -54,6 +73,9 @@ func NewActivityHandler(plugin *Plugin) *ActivityHandler {
 		plugin: plugin,
 		queue:  make(chan msteams.Activity, activityQueueSize),
 		quit:   make(chan bool),
+		parked: make(map[string]time.Time),
+
+		presenceInvalidations: make(map[string]bool),
 	}
 }
 
@@ -117,6 +139,43 @@ func (ah *ActivityHandler) Handle(activity msteams.Activity) error {
 	return nil
 }
 
+// HandlePresenceChanges forgets the presence of the given users everywhere, to be fetched again
+// when needed, since presence subscriptions only notify of changes. Presence changes are handled
+// right away rather than queued, so that presence churn in large tenants doesn't hold back chat
+// messages, and are published to the other servers in batches.
+func (ah *ActivityHandler) HandlePresenceChanges(teamsUserIDs []string) {
+	ah.plugin.presenceCache.Invalidate(teamsUserIDs...)
+
+	ah.presenceInvalidationsLock.Lock()
+	defer ah.presenceInvalidationsLock.Unlock()
+
+	for _, teamsUserID := range teamsUserIDs {
+		ah.presenceInvalidations[teamsUserID] = true
+	}
+
+	if !ah.presenceInvalidationsScheduled {
+		ah.presenceInvalidationsScheduled = true
+		time.AfterFunc(presenceInvalidationsPublishDelay, ah.publishPresenceInvalidations)
+	}
+}
+
+// publishPresenceInvalidations publishes the presence changes handled since the last time to the
+// other servers.
+func (ah *ActivityHandler) publishPresenceInvalidations() {
+	ah.presenceInvalidationsLock.Lock()
+	teamsUserIDs := make([]string, 0, len(ah.presenceInvalidations))
+	for teamsUserID := range ah.presenceInvalidations {
+		teamsUserIDs = append(teamsUserIDs, teamsUserID)
+	}
+	clear(ah.presenceInvalidations)
+	ah.presenceInvalidationsScheduled = false
+	ah.presenceInvalidationsLock.Unlock()
+
+	for chunk := range slices.Chunk(teamsUserIDs, msteams.MaxPresenceUserIDs) {
+		sendPresenceUpdate(ah.plugin.GetAPI(), presenceUpdate{InvalidatedUserIDs: chunk})
+	}
+}
+
 func (ah *ActivityHandler) HandleLifecycleEvent(event msteams.Activity) {
 	if event.LifecycleEvent != "reauthorizationRequired" {
 		ah.plugin.GetAPI().LogWarn("Ignoring unknown lifecycle event", "lifecycle_event", event.LifecycleEvent)
@@ -170,9 +229,54 @@ func (ah *ActivityHandler) handleActivity(activity msteams.Activity) {
 		ah.plugin.GetAPI().LogWarn("Unsupported change type", "change_type", activity.ChangeType)
 	}
 
+	if discardedReason == metrics.DiscardedReasonCircuitOpen {
+		if ah.park(activity) {
+			return
+		}
+		ah.plugin.GetAPI().LogWarn("Discarding activity unable to be parked while Microsoft Graph is unavailable", "resource", activity.Resource)
+	} else {
+		ah.unpark(activity)
+	}
+
 	ah.plugin.GetMetrics().ObserveChangeEvent(activity.ChangeType, discardedReason)
 }
 
+// park queues the activity again once the circuit breaker may have closed, unless it was parked
+// for too long already or too many activities are parked.
+func (ah *ActivityHandler) park(activity msteams.Activity) bool {
+	ah.parkedLock.Lock()
+	defer ah.parkedLock.Unlock()
+
+	parkedAt, ok := ah.parked[activity.Resource]
+	if !ok {
+		if len(ah.parked) >= maxParkedActivities {
+			return false
+		}
+		parkedAt = time.Now()
+		ah.parked[activity.Resource] = parkedAt
+	} else if time.Since(parkedAt) > maxActivityParkingTime {
+		delete(ah.parked, activity.Resource)
+		return false
+	}
+
+	time.AfterFunc(client_breakerlayer.OpenDuration, func() {
+		if err := ah.Handle(activity); err != nil {
+			ah.plugin.GetAPI().LogWarn("Unable to queue parked activity", "resource", activity.Resource, "error", err.Error())
+			ah.unpark(activity)
+			ah.plugin.GetMetrics().ObserveChangeEvent(activity.ChangeType, metrics.DiscardedReasonCircuitOpen)
+		}
+	})
+
+	return true
+}
+
+func (ah *ActivityHandler) unpark(activity msteams.Activity) {
+	ah.parkedLock.Lock()
+	defer ah.parkedLock.Unlock()
+
+	delete(ah.parked, activity.Resource)
+}
+
 // handleCreatedActivity handles subscription change events of the created type, i.e. new messages.
 func (ah *ActivityHandler) handleCreatedActivity(activityIds clientmodels.ActivityIds) string {
 	// We're only handling chats at that time.
@@ -180,37 +284,187 @@ func (ah *ActivityHandler) handleCreatedActivity(activityIds clientmodels.Activi
 		return metrics.DiscardedReasonChannelNotificationsUnsupported
 	}
 
+	// Time resolving the notification with and without batching, to compare their latency.
+	mode := metrics.ChatMessageFetchSequential
+	fetch := ah.fetchChatMessage
+	if ah.plugin.getConfiguration().UseAppClientForMessages() {
+		mode = metrics.ChatMessageFetchBatched
+		fetch = ah.fetchChatMessageBatched
+	}
+
+	start := time.Now()
+	fetched, discardedReason := fetch(activityIds)
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	ah.plugin.GetMetrics().ObserveChatMessageFetchDuration(mode, discardedReason, elapsed)
+
+	if discardedReason != metrics.DiscardedReasonNone {
+		return discardedReason
+	}
+
+	// Finally, process the notification of the chat message received.
+	return ah.handleCreatedActivityNotification(fetched.msg, fetched.chat, fetched.presences, fetched.fetchedPresences)
+}
+
+// fetchedChatMessage is what's fetched from Microsoft Graph to handle a new chat message.
+type fetchedChatMessage struct {
+	chat *clientmodels.Chat
+	msg  *clientmodels.Message
+
+	// presences of the chat members other than the sender, fetchedPresences being those just
+	// fetched rather than found in the cache.
+	presences        map[string]clientmodels.Presence
+	fetchedPresences map[string]clientmodels.Presence
+}
+
+// fetchChatMessage fetches the chat with the application client, then the message with the client
+// of a connected chat member, and the presence of the chat members, one request after the other.
+func (ah *ActivityHandler) fetchChatMessage(activityIds clientmodels.ActivityIds) (*fetchedChatMessage, string) {
 	// Use the application client to resolve the chat metadata.
 	chat, err := ah.plugin.GetClientForApp().GetChat(activityIds.ChatID)
+	if client_breakerlayer.IsCircuitOpen(err) {
+		return nil, metrics.DiscardedReasonCircuitOpen
+	}
 	if err != nil || chat == nil {
 		ah.plugin.GetAPI().LogWarn("Failed to get chat", "chat_id", activityIds.ChatID, "error", err)
-		return metrics.DiscardedReasonUnableToGetTeamsData
+		return nil, metrics.DiscardedReasonUnableToGetTeamsData
 	}
 
-	// Find a connected member whose client can be used to fetch the chat message itself.
-	var client msteams.Client
-	for _, member := range chat.Members {
-		client, _ = ah.plugin.GetClientForTeamsUser(member.UserID)
-		if client != nil {
-			break
-		}
-	}
+	client := ah.getClientForChatMember(chat)
 	if client == nil {
-		return metrics.DiscardedReasonNoConnectedUser
+		return nil, metrics.DiscardedReasonNoConnectedUser
 	}
 
 	// Fetch the message itself.
 	msg, err := client.GetChatMessage(chat.ID, activityIds.MessageID)
+	if client_breakerlayer.IsCircuitOpen(err) {
+		return nil, metrics.DiscardedReasonCircuitOpen
+	}
 	if err != nil {
 		ah.plugin.GetAPI().LogWarn("Failed to get message from chat", "chat_id", chat.ID, "message_id", activityIds.MessageID, "error", err)
-		return metrics.DiscardedReasonUnableToGetTeamsData
+		return nil, metrics.DiscardedReasonUnableToGetTeamsData
+	}
+
+	fetched := &fetchedChatMessage{chat: chat, msg: msg}
+	if discardedReason := ah.checkChatMessageSender(fetched); discardedReason != metrics.DiscardedReasonNone {
+		return fetched, discardedReason
+	}
+
+	// Without presence information, notify all the chat members.
+	fetched.presences, fetched.fetchedPresences, err = ah.plugin.getPresencesForUsers(chatMemberIDsExcept(chat, msg.UserID))
+	ah.logPresencesError(fetched, err)
+
+	return fetched, metrics.DiscardedReasonNone
+}
+
+// fetchChatMessageBatched fetches the chat, unless cached, the message and the presence of the chat
+// members missing from the cache with the application client, in a single JSON batch when the chat
+// is cached.
+func (ah *ActivityHandler) fetchChatMessageBatched(activityIds clientmodels.ActivityIds) (*fetchedChatMessage, string) {
+	batch := msteams.NewBatch()
+
+	// The chat members, hence the presences to look up, are only known with the chat.
+	var chatResult *msteams.BatchResult[*clientmodels.Chat]
+	var presencesResult *msteams.BatchResult[map[string]clientmodels.Presence]
+	var presences map[string]clientmodels.Presence
+	chat, ok := ah.plugin.chatCache.Get(activityIds.ChatID)
+	if ok {
+		var missing []string
+		presences, missing = ah.plugin.presenceCache.Get(chatMemberIDsExcept(chat, ""))
+		if len(missing) > 0 && len(missing) <= msteams.MaxPresenceUserIDs {
+			presencesResult = batch.GetPresencesForUsers(missing)
+		}
+	} else {
+		chatResult = batch.GetChat(activityIds.ChatID)
+	}
+	msgResult := batch.GetChatMessage(activityIds.ChatID, activityIds.MessageID)
+
+	client := ah.plugin.GetClientForApp()
+	err := client.SendBatch(batch)
+	if client_breakerlayer.IsCircuitOpen(err) {
+		return nil, metrics.DiscardedReasonCircuitOpen
+	}
+	if err != nil {
+		ah.plugin.GetAPI().LogWarn("Failed to send batch for chat message", "chat_id", activityIds.ChatID, "message_id", activityIds.MessageID, "error", err)
+		return nil, metrics.DiscardedReasonUnableToGetTeamsData
+	}
+
+	// The requests throttled within the batch are sent again on their own, to be retried once
+	// Microsoft allows it.
+	batch.SendPending(client)
+
+	if chatResult != nil {
+		if chatResult.Err != nil || chatResult.Value == nil {
+			ah.plugin.GetAPI().LogWarn("Failed to get chat", "chat_id", activityIds.ChatID, "error", chatResult.Err)
+			return nil, metrics.DiscardedReasonUnableToGetTeamsData
+		}
+
+		chat = chatResult.Value
+		ah.plugin.chatCache.Add(chat.ID, chat)
+	}
+
+	if msgResult.Err != nil {
+		ah.plugin.GetAPI().LogWarn("Failed to get message from chat", "chat_id", chat.ID, "message_id", activityIds.MessageID, "error", msgResult.Err)
+		return nil, metrics.DiscardedReasonUnableToGetTeamsData
+	}
+
+	fetched := &fetchedChatMessage{chat: chat, msg: msgResult.Value}
+	if discardedReason := ah.checkChatMessageSender(fetched); discardedReason != metrics.DiscardedReasonNone {
+		return fetched, discardedReason
 	}
 
-	// Skip messages without a user, if this ever happens.
-	if msg.UserID == "" {
+	// Without presence information, notify all the chat members.
+	if presencesResult == nil {
+		fetched.presences, fetched.fetchedPresences, err = ah.plugin.getPresencesForUsers(chatMemberIDsExcept(chat, fetched.msg.UserID))
+	} else {
+		fetched.presences, fetched.fetchedPresences, err = presences, presencesResult.Value, presencesResult.Err
+		for userID, presence := range fetched.fetchedPresences {
+			fetched.presences[userID] = presence
+		}
+	}
+	ah.logPresencesError(fetched, err)
+
+	return fetched, metrics.DiscardedReasonNone
+}
+
+// checkChatMessageSender skips messages without a user, such as the system messages announcing
+// members added to or removed from the chat: the cached chat members are outdated then.
+func (ah *ActivityHandler) checkChatMessageSender(fetched *fetchedChatMessage) string {
+	if fetched.msg.UserID == "" {
+		ah.plugin.chatCache.Invalidate(fetched.chat.ID)
 		return metrics.DiscardedReasonNotUserEvent
 	}
 
-	// Finally, process the notification of the chat message received.
-	return ah.handleCreatedActivityNotification(msg, chat)
+	return metrics.DiscardedReasonNone
+}
+
+func (ah *ActivityHandler) logPresencesError(fetched *fetchedChatMessage, err error) {
+	if err != nil && !client_breakerlayer.IsCircuitOpen(err) {
+		ah.plugin.GetAPI().LogWarn("Failed to fetch presence information for chat members", "chat_id", fetched.chat.ID, "message_id", fetched.msg.ID, "error", err)
+	}
+}
+
+// chatMemberIDsExcept returns the Teams user IDs of the chat members, except the given user.
+func chatMemberIDsExcept(chat *clientmodels.Chat, exceptUserID string) []string {
+	userIDs := make([]string, 0, len(chat.Members))
+	for _, member := range chat.Members {
+		if member.UserID == exceptUserID {
+			continue
+		}
+
+		userIDs = append(userIDs, member.UserID)
+	}
+
+	return userIDs
+}
+
+// getClientForChatMember returns the client of the first connected member of the chat, or nil if
+// none is connected.
+func (ah *ActivityHandler) getClientForChatMember(chat *clientmodels.Chat) msteams.Client {
+	for _, member := range chat.Members {
+		if client, _ := ah.plugin.GetClientForTeamsUser(member.UserID); client != nil {
+			return client
+		}
+	}
+
+	return nil
 }
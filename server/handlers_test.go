@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/client_breakerlayer"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
 )
 
@@ -45,6 +46,19 @@ func TestHandleCreatedActivity(t *testing.T) {
 		assert.Equal(t, metrics.DiscardedReasonUnableToGetTeamsData, discardReason)
 	})
 
+	t.Run("circuit breaker open", func(t *testing.T) {
+		th.Reset(t)
+
+		activityIds := clientmodels.ActivityIds{
+			ChatID: "chat_id",
+		}
+
+		th.appClientMock.On("GetChat", activityIds.ChatID).Return(nil, &client_breakerlayer.CircuitOpenError{Family: "chats", RetryAt: time.Now().Add(time.Minute)}).Times(1)
+
+		discardReason := th.p.activityHandler.handleCreatedActivity(activityIds)
+		assert.Equal(t, metrics.DiscardedReasonCircuitOpen, discardReason)
+	})
+
 	t.Run("no connected users to get message", func(t *testing.T) {
 		th.Reset(t)
 
@@ -103,6 +117,110 @@ func TestHandleCreatedActivity(t *testing.T) {
 		assert.Equal(t, metrics.DiscardedReasonUnableToGetTeamsData, discardReason)
 	})
 
+	t.Run("auto-link fetches message with app client", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.AutoLinkUsers = true
+		})
+
+		senderUser := th.SetupUser(t, team)
+		user1 := th.SetupUser(t, team)
+		th.DisconnectUser(t, user1.Id)
+
+		activityIds := clientmodels.ActivityIds{
+			ChatID:    "chat_id",
+			MessageID: "message_id",
+		}
+
+		th.appClientMock.On("GetChat", activityIds.ChatID).Return(&clientmodels.Chat{
+			ID: activityIds.ChatID,
+			Members: []clientmodels.ChatMember{
+				{
+					UserID: "t" + senderUser.Id,
+				},
+				{
+					UserID: "t" + user1.Id,
+				},
+			},
+		}, nil).Times(1)
+		th.appClientMock.On("GetChatMessage", activityIds.ChatID, activityIds.MessageID).Return(&clientmodels.Message{}, nil).Times(1)
+
+		discardReason := th.p.activityHandler.handleCreatedActivity(activityIds)
+		assert.Equal(t, metrics.DiscardedReasonNotUserEvent, discardReason)
+	})
+
+	t.Run("fetches message with app client when configured", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.FetchMessagesWithAppClient = true
+		})
+
+		senderUser := th.SetupUser(t, team)
+		user1 := th.SetupUser(t, team)
+		th.ConnectUser(t, user1.Id)
+
+		activityIds := clientmodels.ActivityIds{
+			ChatID:    "chat_id",
+			MessageID: "message_id",
+		}
+
+		th.appClientMock.On("GetChat", activityIds.ChatID).Return(&clientmodels.Chat{
+			ID: activityIds.ChatID,
+			Members: []clientmodels.ChatMember{
+				{
+					UserID: "t" + senderUser.Id,
+				},
+				{
+					UserID: "t" + user1.Id,
+				},
+			},
+		}, nil).Times(1)
+		th.appClientMock.On("GetChatMessage", activityIds.ChatID, activityIds.MessageID).Return(&clientmodels.Message{}, nil).Times(1)
+
+		// The connected member's client isn't used.
+		discardReason := th.p.activityHandler.handleCreatedActivity(activityIds)
+		assert.Equal(t, metrics.DiscardedReasonNotUserEvent, discardReason)
+	})
+
+	t.Run("batches message and presences with app client for cached chat", func(t *testing.T) {
+		th.Reset(t)
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.FetchMessagesWithAppClient = true
+		})
+
+		senderUser := th.SetupUser(t, team)
+		user1 := th.SetupUser(t, team)
+
+		activityIds := clientmodels.ActivityIds{
+			ChatID:    "chat_id",
+			MessageID: "message_id",
+		}
+
+		th.p.chatCache.Add(activityIds.ChatID, &clientmodels.Chat{
+			ID: activityIds.ChatID,
+			Members: []clientmodels.ChatMember{
+				{
+					UserID: "t" + senderUser.Id,
+				},
+				{
+					UserID: "t" + user1.Id,
+				},
+			},
+		})
+		th.appClientMock.On("GetChatMessage", activityIds.ChatID, activityIds.MessageID).Return(&clientmodels.Message{}, nil).Times(1)
+		th.appClientMock.On("GetPresencesForUsers", []string{"t" + senderUser.Id, "t" + user1.Id}).Return(map[string]clientmodels.Presence{}, nil).Times(1)
+
+		// The chat members being cached, their presences are fetched along with the message.
+		discardReason := th.p.activityHandler.handleCreatedActivity(activityIds)
+		assert.Equal(t, metrics.DiscardedReasonNotUserEvent, discardReason)
+		th.appClientMock.AssertNumberOfCalls(t, "SendBatch", 1)
+		th.appClientMock.AssertNotCalled(t, "GetChat", activityIds.ChatID)
+
+		// The chat members may have changed.
+		_, ok := th.p.chatCache.Get(activityIds.ChatID)
+		assert.False(t, ok)
+	})
+
 	t.Run("skipping not user event", func(t *testing.T) {
 		th.Reset(t)
 
@@ -265,5 +383,85 @@ func TestHandleCreatedActivity(t *testing.T) {
 				th.assertDMFromUserRe(t, botUser.Id, user3.Id, "message")
 			})
 		})
+
+		t.Run("presence cached until notified of a change", func(t *testing.T) {
+			th.Reset(t)
+
+			senderUser := th.SetupUser(t, team)
+			th.ConnectUser(t, senderUser.Id)
+
+			user1 := th.SetupUser(t, team)
+			th.ConnectUser(t, user1.Id)
+
+			botUser, err := th.p.apiClient.User.Get(th.p.botUserID)
+			require.NoError(t, err)
+			th.ConnectUser(t, botUser.Id)
+
+			mockTeams := newMockTeamsHelper(th)
+			mockTeams.registerChat("chat_id", []*model.User{user1, senderUser})
+			for _, messageID := range []string{"message_id_1", "message_id_2", "message_id_3"} {
+				mockTeams.registerChatMessage("chat_id", messageID, senderUser, "message")
+			}
+
+			th.appClientMock.On("GetPresencesForUsers", []string{"t" + user1.Id}).Return(map[string]clientmodels.Presence{
+				"t" + user1.Id: {
+					UserID:       "t" + user1.Id,
+					Activity:     PresenceActivityAvailable,
+					Availability: PresenceAvailabilityAvailable,
+				},
+			}, nil).Times(2)
+
+			discardReason := th.p.activityHandler.handleCreatedActivity(clientmodels.ActivityIds{ChatID: "chat_id", MessageID: "message_id_1"})
+			assert.Equal(t, metrics.DiscardedReasonNone, discardReason)
+
+			// The presence is cached for the following message.
+			discardReason = th.p.activityHandler.handleCreatedActivity(clientmodels.ActivityIds{ChatID: "chat_id", MessageID: "message_id_2"})
+			assert.Equal(t, metrics.DiscardedReasonNone, discardReason)
+
+			// Until notified of a change.
+			th.p.activityHandler.HandlePresenceChanges([]string{"t" + user1.Id})
+
+			discardReason = th.p.activityHandler.handleCreatedActivity(clientmodels.ActivityIds{ChatID: "chat_id", MessageID: "message_id_3"})
+			assert.Equal(t, metrics.DiscardedReasonNone, discardReason)
+
+			th.assertNoDMFromUser(t, botUser.Id, user1.Id, model.GetMillisForTime(time.Now().Add(-5*time.Second)))
+		})
+
+		t.Run("presence of users without a subscription not cached", func(t *testing.T) {
+			th.Reset(t)
+
+			senderUser := th.SetupUser(t, team)
+			th.ConnectUser(t, senderUser.Id)
+
+			// Linked without a token, as when auto-linked, so not covered by a presence subscription.
+			user1 := th.SetupUser(t, team)
+			th.DisconnectUser(t, user1.Id)
+
+			botUser, err := th.p.apiClient.User.Get(th.p.botUserID)
+			require.NoError(t, err)
+			th.ConnectUser(t, botUser.Id)
+
+			mockTeams := newMockTeamsHelper(th)
+			mockTeams.registerChat("chat_id", []*model.User{user1, senderUser})
+			for _, messageID := range []string{"message_id_1", "message_id_2"} {
+				mockTeams.registerChatMessage("chat_id", messageID, senderUser, "message")
+			}
+
+			th.appClientMock.On("GetPresencesForUsers", []string{"t" + user1.Id}).Return(map[string]clientmodels.Presence{
+				"t" + user1.Id: {
+					UserID:       "t" + user1.Id,
+					Activity:     PresenceActivityAvailable,
+					Availability: PresenceAvailabilityAvailable,
+				},
+			}, nil).Times(2)
+
+			for _, messageID := range []string{"message_id_1", "message_id_2"} {
+				discardReason := th.p.activityHandler.handleCreatedActivity(clientmodels.ActivityIds{ChatID: "chat_id", MessageID: messageID})
+				assert.Equal(t, metrics.DiscardedReasonNone, discardReason)
+			}
+
+			_, missing := th.p.presenceCache.Get([]string{"t" + user1.Id})
+			assert.Equal(t, []string{"t" + user1.Id}, missing)
+		})
 	})
 }
@@ -7,6 +7,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"regexp"
@@ -20,10 +21,12 @@ import (
 	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
 
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/client_cachelayer"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/mocks"
 	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
 )
@@ -43,7 +46,10 @@ func setupTestHelper(t *testing.T) *testHelper {
 	p := &Plugin{
 		// These mocks are replaced later, but serve the plugin during early initialization
 		msteamsAppClient: &mocks.Client{},
-		clientBuilderWithToken: func(redirectURL, tenantID, clientId, clientSecret string, token *oauth2.Token, apiClient *pluginapi.LogService) msteams.Client {
+		clientBuilderWithToken: func(cloud msteams.Cloud, redirectURL, tenantID, clientId string, credentials msteams.AppCredentials, transport *http.Transport, token *oauth2.Token, apiClient *pluginapi.LogService) msteams.Client {
+			return &mocks.Client{}
+		},
+		appClientBuilder: func(cloud msteams.Cloud, tenantID, clientId string, credentials msteams.AppCredentials, transport *http.Transport, apiClient *pluginapi.LogService) msteams.Client {
 			return &mocks.Client{}
 		},
 	}
@@ -138,6 +144,10 @@ func (th *testHelper) clearDatabase(t *testing.T) {
 	require.NoError(t, err)
 	_, err = db.Exec("DELETE FROM msteamssync_invited_users")
 	require.NoError(t, err)
+	_, err = db.Exec("DELETE FROM msteamssync_expired_invites")
+	require.NoError(t, err)
+	_, err = db.Exec("DELETE FROM msteamssync_missed_messages")
+	require.NoError(t, err)
 	_, err = db.Exec("DELETE FROM msteamssync_posts")
 	require.NoError(t, err)
 	_, err = db.Exec("DELETE FROM msteamssync_subscriptions")
@@ -163,11 +173,22 @@ func (th *testHelper) Reset(t *testing.T) *testHelper {
 	th.appClientMock = appClientMock
 	th.clientMock = clientMock
 
+	// Leave the requests of batches pending, to be sent on their own, so tests need only mock the
+	// batched methods.
+	appClientMock.On("SendBatch", mock.AnythingOfType("*msteams.Batch")).Return(nil).Maybe()
+
 	th.p.msteamsAppClient = appClientMock
-	th.p.clientBuilderWithToken = func(redirectURL, tenantID, clientId, clientSecret string, token *oauth2.Token, apiClient *pluginapi.LogService) msteams.Client {
+	th.p.clientBuilderWithToken = func(cloud msteams.Cloud, redirectURL, tenantID, clientId string, credentials msteams.AppCredentials, transport *http.Transport, token *oauth2.Token, apiClient *pluginapi.LogService) msteams.Client {
 		return clientMock
 	}
+	th.p.appClientBuilder = func(cloud msteams.Cloud, tenantID, clientId string, credentials msteams.AppCredentials, transport *http.Transport, apiClient *pluginapi.LogService) msteams.Client {
+		return appClientMock
+	}
 	th.p.monitor.client = th.p.msteamsAppClient
+	th.p.userCaches.Purge()
+	th.p.chatCache = client_cachelayer.NewChatCache(th.p.GetMetrics(), chatCacheSize, chatCacheTTL)
+	th.p.presenceCache = client_cachelayer.NewPresenceCache(th.p.GetMetrics(), presenceCacheSize, presenceCacheTTL)
+	th.p.monitor.presences = th.p.presenceCache
 
 	var err error
 	th.metricsSnapshot, err = th.p.metricsService.GetRegistry().Gather()
@@ -345,6 +366,26 @@ func (th *testHelper) SetupSysadmin(t *testing.T, team *model.Team) *model.User
 	return user
 }
 
+func (th *testHelper) SetupGroup(t *testing.T, members ...*model.User) *model.Group {
+	t.Helper()
+
+	groupName := model.NewUsername()
+	group, appErr := th.p.API.CreateGroup(&model.Group{
+		Name:           model.NewPointer(groupName),
+		DisplayName:    groupName,
+		Source:         model.GroupSourceCustom,
+		AllowReference: true,
+	})
+	require.Nil(t, appErr)
+
+	for _, member := range members {
+		_, appErr = th.p.API.UpsertGroupMember(group.Id, member.Id)
+		require.Nil(t, appErr)
+	}
+
+	return group
+}
+
 func (th *testHelper) SetupClient(t *testing.T, userID string) *model.Client4 {
 	t.Helper()
 
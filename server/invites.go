@@ -0,0 +1,158 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
+)
+
+type InviteStatus struct {
+	UserID        string `json:"user_id"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	PendingSince  int64  `json:"pending_since"`
+	LastSentAt    int64  `json:"last_sent_at,omitempty"`
+	RemindersSent int    `json:"reminders_sent"`
+	ExpiresAt     int64  `json:"expires_at,omitempty"`
+	ExpiredAt     int64  `json:"expired_at,omitempty"`
+}
+
+type InvitesReport struct {
+	Pending []*InviteStatus `json:"pending"`
+	Expired []*InviteStatus `json:"expired"`
+}
+
+// expireInvites frees the invite pool slots of invites that have been pending for longer than
+// the configured expiry, recording them as expired so the users are not invited again.
+func (p *Plugin) expireInvites() {
+	defer func() {
+		if r := recover(); r != nil {
+			p.GetMetrics().ObserveGoroutineFailure()
+			p.API.LogError("Recovering from panic", "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+
+	if p.getConfiguration().ConnectedUsersInviteExpiryDays == 0 {
+		return
+	}
+
+	done := p.GetMetrics().ObserveWorker(metrics.WorkerExpireInvites)
+	defer done()
+
+	expired, err := p.expireInvitesAt(time.Now())
+	if err != nil {
+		p.API.LogWarn("Failed to expire invites", "error", err.Error())
+		return
+	}
+
+	p.API.LogInfo("Finished the expire invites job", "expired_invites", expired)
+}
+
+func (p *Plugin) expireInvitesAt(currentTime time.Time) (int, error) {
+	expiryDays := p.getConfiguration().ConnectedUsersInviteExpiryDays
+	if expiryDays == 0 {
+		return 0, nil
+	}
+
+	p.connectClusterMutex.Lock()
+	defer p.connectClusterMutex.Unlock()
+
+	invitedUsers, err := p.store.GetInvitedUsers()
+	if err != nil {
+		return 0, errors.Wrap(err, "error in getting invited users")
+	}
+
+	expired := 0
+	for _, invitedUser := range invitedUsers {
+		if currentTime.Before(invitedUser.InvitePendingSince.AddDate(0, 0, expiryDays)) {
+			continue
+		}
+
+		if err := p.store.StoreExpiredInvite(&storemodels.ExpiredInvite{
+			ID:                  invitedUser.ID,
+			InvitePendingSince:  invitedUser.InvitePendingSince,
+			InviteRemindersSent: invitedUser.InviteRemindersSent,
+			InviteExpiredAt:     currentTime,
+		}); err != nil {
+			p.API.LogWarn("Failed to record expired invite", "user_id", invitedUser.ID, "error", err.Error())
+			continue
+		}
+
+		if err := p.store.DeleteUserInvite(invitedUser.ID); err != nil {
+			p.API.LogWarn("Failed to delete expired invite", "user_id", invitedUser.ID, "error", err.Error())
+			continue
+		}
+
+		p.API.LogInfo("Expired user invite", "user_id", invitedUser.ID, "pending_since", invitedUser.InvitePendingSince, "reminders_sent", invitedUser.InviteRemindersSent)
+		expired++
+	}
+
+	return expired, nil
+}
+
+// getInvitesReport returns the status of the pending and expired invites.
+func (p *Plugin) getInvitesReport() (*InvitesReport, error) {
+	expiryDays := p.getConfiguration().ConnectedUsersInviteExpiryDays
+
+	invitedUsers, err := p.store.GetInvitedUsers()
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getting invited users")
+	}
+
+	expiredInvites, err := p.store.GetExpiredInvites()
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getting expired invites")
+	}
+
+	report := &InvitesReport{
+		Pending: []*InviteStatus{},
+		Expired: []*InviteStatus{},
+	}
+
+	for _, invitedUser := range invitedUsers {
+		status := p.newInviteStatus(invitedUser.ID)
+		status.PendingSince = invitedUser.InvitePendingSince.UnixMilli()
+		status.RemindersSent = invitedUser.InviteRemindersSent
+		if !invitedUser.InviteLastSentAt.IsZero() {
+			status.LastSentAt = invitedUser.InviteLastSentAt.UnixMilli()
+		}
+		if expiryDays > 0 {
+			status.ExpiresAt = invitedUser.InvitePendingSince.AddDate(0, 0, expiryDays).UnixMilli()
+		}
+
+		report.Pending = append(report.Pending, status)
+	}
+
+	for _, expiredInvite := range expiredInvites {
+		status := p.newInviteStatus(expiredInvite.ID)
+		status.PendingSince = expiredInvite.InvitePendingSince.UnixMilli()
+		status.RemindersSent = expiredInvite.InviteRemindersSent
+		status.ExpiredAt = expiredInvite.InviteExpiredAt.UnixMilli()
+
+		report.Expired = append(report.Expired, status)
+	}
+
+	return report, nil
+}
+
+func (p *Plugin) newInviteStatus(userID string) *InviteStatus {
+	status := &InviteStatus{UserID: userID}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.API.LogWarn("Unable to get invited user", "user_id", userID, "error", appErr.Error())
+		return status
+	}
+
+	status.Username = user.Username
+	status.Email = user.Email
+
+	return status
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
+)
+
+func TestExpireInvites(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	now := time.Now().Truncate(time.Microsecond)
+
+	storeInvite := func(t *testing.T, userID string, pendingSince time.Time) {
+		t.Helper()
+		err := th.p.GetStore().StoreInvitedUser(&storemodels.InvitedUser{
+			ID:                  userID,
+			InvitePendingSince:  pendingSince,
+			InviteLastSentAt:    pendingSince,
+			InviteRemindersSent: 1,
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("expiry disabled", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		storeInvite(t, user.Id, now.AddDate(0, 0, -365))
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersInviteExpiryDays = 0
+		})
+
+		expired, err := th.p.expireInvitesAt(now)
+		require.NoError(t, err)
+		assert.Equal(t, 0, expired)
+
+		invitedUser, err := th.p.GetStore().GetInvitedUser(user.Id)
+		require.NoError(t, err)
+		assert.NotNil(t, invitedUser)
+	})
+
+	t.Run("only old invites expire", func(t *testing.T) {
+		th.Reset(t)
+		oldUser := th.SetupUser(t, team)
+		newUser := th.SetupUser(t, team)
+		storeInvite(t, oldUser.Id, now.AddDate(0, 0, -31))
+		storeInvite(t, newUser.Id, now.AddDate(0, 0, -29))
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersInviteExpiryDays = 30
+		})
+
+		expired, err := th.p.expireInvitesAt(now)
+		require.NoError(t, err)
+		assert.Equal(t, 1, expired)
+
+		invitedUser, err := th.p.GetStore().GetInvitedUser(oldUser.Id)
+		require.NoError(t, err)
+		assert.Nil(t, invitedUser)
+
+		expiredInvite, err := th.p.GetStore().GetExpiredInvite(oldUser.Id)
+		require.NoError(t, err)
+		require.NotNil(t, expiredInvite)
+		assert.Equal(t, 1, expiredInvite.InviteRemindersSent)
+		assert.True(t, now.Equal(expiredInvite.InviteExpiredAt))
+
+		invitedUser, err = th.p.GetStore().GetInvitedUser(newUser.Id)
+		require.NoError(t, err)
+		assert.NotNil(t, invitedUser)
+
+		count, err := th.p.GetStore().GetInvitedCount()
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
+
+func TestGetInvitesReport(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	t.Run("empty", func(t *testing.T) {
+		th.Reset(t)
+
+		report, err := th.p.getInvitesReport()
+		require.NoError(t, err)
+		assert.Empty(t, report.Pending)
+		assert.Empty(t, report.Expired)
+	})
+
+	t.Run("pending and expired invites", func(t *testing.T) {
+		th.Reset(t)
+		pendingUser := th.SetupUser(t, team)
+		expiredUser := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersInviteExpiryDays = 30
+		})
+
+		pendingSince := time.Now().AddDate(0, 0, -10).Truncate(time.Millisecond)
+		err := th.p.GetStore().StoreInvitedUser(&storemodels.InvitedUser{
+			ID:                  pendingUser.Id,
+			InvitePendingSince:  pendingSince,
+			InviteLastSentAt:    pendingSince.AddDate(0, 0, 7),
+			InviteRemindersSent: 1,
+		})
+		require.NoError(t, err)
+
+		expiredAt := time.Now().Truncate(time.Millisecond)
+		err = th.p.GetStore().StoreExpiredInvite(&storemodels.ExpiredInvite{
+			ID:                  expiredUser.Id,
+			InvitePendingSince:  expiredAt.AddDate(0, 0, -30),
+			InviteRemindersSent: 3,
+			InviteExpiredAt:     expiredAt,
+		})
+		require.NoError(t, err)
+
+		report, err := th.p.getInvitesReport()
+		require.NoError(t, err)
+		assert.Equal(t, []*InviteStatus{{
+			UserID:        pendingUser.Id,
+			Username:      pendingUser.Username,
+			Email:         pendingUser.Email,
+			PendingSince:  pendingSince.UnixMilli(),
+			LastSentAt:    pendingSince.AddDate(0, 0, 7).UnixMilli(),
+			RemindersSent: 1,
+			ExpiresAt:     pendingSince.AddDate(0, 0, 30).UnixMilli(),
+		}}, report.Pending)
+		assert.Equal(t, []*InviteStatus{{
+			UserID:        expiredUser.Id,
+			Username:      expiredUser.Username,
+			Email:         expiredUser.Email,
+			PendingSince:  expiredAt.AddDate(0, 0, -30).UnixMilli(),
+			RemindersSent: 3,
+			ExpiredAt:     expiredAt.UnixMilli(),
+		}}, report.Expired)
+	})
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
+)
+
+const enforceMembershipPerPage = 100
+
+// isUserInAllowedGroupsOrTeams reports whether the user currently belongs to one of the groups
+// or teams configured as allowed to connect. Membership is always evaluated live, so changes
+// made through LDAP group or team sync are picked up immediately.
+func (p *Plugin) isUserInAllowedGroupsOrTeams(mmUserID string) (bool, error) {
+	config := p.getConfiguration()
+
+	for _, teamName := range config.AllowedTeams() {
+		team, appErr := p.API.GetTeamByName(teamName)
+		if appErr != nil {
+			p.API.LogWarn("Unable to find allowed team", "team_name", teamName, "error", appErr.Error())
+			continue
+		}
+
+		member, appErr := p.API.GetTeamMember(team.Id, mmUserID)
+		if appErr != nil && appErr.StatusCode != http.StatusNotFound {
+			return false, errors.Wrapf(appErr, "error in getting team membership")
+		}
+
+		if member != nil && member.DeleteAt == 0 {
+			return true, nil
+		}
+	}
+
+	allowedGroups := config.AllowedGroups()
+	if len(allowedGroups) == 0 {
+		return false, nil
+	}
+
+	groups, appErr := p.API.GetGroupsForUser(mmUserID)
+	if appErr != nil {
+		return false, errors.Wrapf(appErr, "error in getting group memberships")
+	}
+
+	for _, group := range groups {
+		if group.Name != nil && slices.Contains(allowedGroups, strings.ToLower(*group.Name)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// enforceConnectedUsersMembership disconnects connected users who are no longer members of
+// the allowed groups or teams.
+func (p *Plugin) enforceConnectedUsersMembership() {
+	defer func() {
+		if r := recover(); r != nil {
+			p.GetMetrics().ObserveGoroutineFailure()
+			p.API.LogError("Recovering from panic", "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+
+	config := p.getConfiguration()
+	if !config.ConnectedUsersEnforceMembership || !config.HasMembershipRestrictions() {
+		return
+	}
+
+	done := p.GetMetrics().ObserveWorker(metrics.WorkerEnforceMembership)
+	defer done()
+
+	p.API.LogInfo("Running the enforce membership job")
+
+	// Collect all users first, since disconnecting users shifts the pages.
+	var connectedUsers []*storemodels.ConnectedUser
+	for page := 0; ; page++ {
+		users, err := p.store.GetConnectedUsers(page, enforceMembershipPerPage)
+		if err != nil {
+			p.API.LogWarn("Failed to get connected users", "page", page, "error", err.Error())
+			return
+		}
+
+		connectedUsers = append(connectedUsers, users...)
+		if len(users) < enforceMembershipPerPage {
+			break
+		}
+	}
+
+	disconnected := 0
+	for _, connectedUser := range connectedUsers {
+		isAllowed, err := p.isUserWhitelistedOrInAllowedGroupsOrTeams(connectedUser.MattermostUserID)
+		if err != nil {
+			p.API.LogWarn("Failed to check group and team membership", "user_id", connectedUser.MattermostUserID, "error", err.Error())
+			continue
+		}
+
+		if isAllowed {
+			continue
+		}
+
+		if err := p.disconnectUser(connectedUser.MattermostUserID, connectedUser.TeamsUserID); err != nil {
+			p.API.LogWarn("Failed to disconnect user outside allowed groups and teams", "user_id", connectedUser.MattermostUserID, "error", err.Error())
+			continue
+		}
+		disconnected++
+
+		if err := p.botSendDirectPost(connectedUser.MattermostUserID, &model.Post{
+			Message: "Your account has been disconnected from MS Teams because you are no longer a member of a group or team allowed to connect. Please contact your system administrator.",
+		}); err != nil {
+			p.API.LogWarn("Failed to notify user about disconnection", "user_id", connectedUser.MattermostUserID, "error", err.Error())
+		}
+	}
+
+	p.API.LogInfo("Finished the enforce membership job", "disconnected_users", disconnected)
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsUserInAllowedGroupsOrTeams(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+	otherTeam := th.SetupTeam(t)
+
+	t.Run("no allowed groups or teams", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		result, err := th.p.isUserInAllowedGroupsOrTeams(user.Id)
+		require.NoError(t, err)
+		assert.False(t, result)
+	})
+
+	t.Run("member of allowed team", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowedTeams = "unknown-team, " + team.Name
+		})
+
+		result, err := th.p.isUserInAllowedGroupsOrTeams(user.Id)
+		require.NoError(t, err)
+		assert.True(t, result)
+	})
+
+	t.Run("not member of allowed team", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowedTeams = otherTeam.Name
+		})
+
+		result, err := th.p.isUserInAllowedGroupsOrTeams(user.Id)
+		require.NoError(t, err)
+		assert.False(t, result)
+	})
+
+	t.Run("member of allowed group", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		group := th.SetupGroup(t, user)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowedGroups = *group.Name
+		})
+
+		result, err := th.p.isUserInAllowedGroupsOrTeams(user.Id)
+		require.NoError(t, err)
+		assert.True(t, result)
+	})
+
+	t.Run("not member of allowed group", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		otherUser := th.SetupUser(t, team)
+		group := th.SetupGroup(t, otherUser)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowedGroups = *group.Name
+		})
+
+		result, err := th.p.isUserInAllowedGroupsOrTeams(user.Id)
+		require.NoError(t, err)
+		assert.False(t, result)
+	})
+}
+
+func TestEnforceConnectedUsersMembership(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	t.Run("disabled", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersRestricted = true
+			c.ConnectedUsersAllowedGroups = "unknown-group"
+		})
+
+		th.p.enforceConnectedUsersMembership()
+
+		isConnected, err := th.p.IsUserConnected(user.Id)
+		require.NoError(t, err)
+		assert.True(t, isConnected)
+	})
+
+	t.Run("disconnects users outside allowed groups", func(t *testing.T) {
+		th.Reset(t)
+		memberUser := th.SetupUser(t, team)
+		th.ConnectUser(t, memberUser.Id)
+		otherUser := th.SetupUser(t, team)
+		th.ConnectUser(t, otherUser.Id)
+		group := th.SetupGroup(t, memberUser)
+
+		th.SetupWebsocketClientForUser(t, otherUser.Id)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersRestricted = true
+			c.ConnectedUsersAllowedGroups = *group.Name
+			c.ConnectedUsersEnforceMembership = true
+		})
+
+		th.p.enforceConnectedUsersMembership()
+
+		isConnected, err := th.p.IsUserConnected(memberUser.Id)
+		require.NoError(t, err)
+		assert.True(t, isConnected)
+
+		isConnected, err = th.p.IsUserConnected(otherUser.Id)
+		require.NoError(t, err)
+		assert.False(t, isConnected)
+
+		th.assertWebsocketEvent(t, otherUser.Id, makePluginWebsocketEventName(WSEventUserDisconnected))
+		th.assertDMFromUserRe(t, th.p.botUserID, otherUser.Id, "no longer a member of a group or team allowed to connect")
+	})
+	t.Run("keeps whitelisted users outside allowed groups", func(t *testing.T) {
+		th.Reset(t)
+		memberUser := th.SetupUser(t, team)
+		th.ConnectUser(t, memberUser.Id)
+		whitelistedUser := th.SetupUser(t, team)
+		th.ConnectUser(t, whitelistedUser.Id)
+		th.MarkUserWhitelisted(t, whitelistedUser.Id)
+		group := th.SetupGroup(t, memberUser)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersRestricted = true
+			c.ConnectedUsersAllowedGroups = *group.Name
+			c.ConnectedUsersEnforceMembership = true
+		})
+
+		th.p.enforceConnectedUsersMembership()
+
+		isConnected, err := th.p.IsUserConnected(whitelistedUser.Id)
+		require.NoError(t, err)
+		assert.True(t, isConnected)
+	})
+}
@@ -57,11 +57,21 @@ const (
 	DiscardedReasonInternalError                   = "internal_error"
 	DiscardedReasonEmptyMessage                    = "empty_message"
 	DiscardedReasonChatSize                        = "chat_size"
-
-	WorkerMonitor          = "monitor"
-	WorkerActivityHandler  = "activity_handler"
-	WorkerCheckCredentials = "check_credentials" //#nosec G101 -- This is a false positive
-	WorkerMetricsUpdater   = "metrics_updater"
+	DiscardedReasonCircuitOpen                     = "circuit_open"
+
+	ChatMessageFetchSequential = "sequential"
+	ChatMessageFetchBatched    = "batched"
+
+	WorkerMonitor              = "monitor"
+	WorkerActivityHandler      = "activity_handler"
+	WorkerCheckCredentials     = "check_credentials" //#nosec G101 -- This is a false positive
+	WorkerMetricsUpdater       = "metrics_updater"
+	WorkerAutoLinkUsers        = "auto_link_users"
+	WorkerEnforceMembership    = "enforce_membership"
+	WorkerExpireInvites        = "expire_invites"
+	WorkerMissedMessages       = "missed_messages"
+	WorkerReconcileUsers       = "reconcile_users"
+	WorkerCleanupCodeVerifiers = "cleanup_code_verifiers"
 )
 
 type Metrics interface {
@@ -95,7 +105,12 @@ type Metrics interface {
 	DecrementChangeEventQueueLength(changeType string)
 
 	ObserveMSGraphClientMethodDuration(method, success, statusCode string, elapsed float64)
+	ObserveMSGraphThrottledRequest(method string)
+	ObserveMSGraphThrottlingWait(method string, elapsed float64)
+	ObserveMSGraphCircuitBreakerState(family string, state int)
+	ObserveClientCacheRequest(cache string, hit bool)
 	ObserveStoreMethodDuration(method, success string, elapsed float64)
+	ObserveStoreCacheRequest(cache string, hit bool)
 
 	GetRegistry() *prometheus.Registry
 
@@ -109,6 +124,7 @@ type Metrics interface {
 	ObserveSyncMsgReactionDelay(action string, delayMillis int64)
 	ObserveSyncMsgFileDelay(action string, delayMillis int64)
 	ObserveNotification(isGroupChat, hasAttachments bool, discardedReason string)
+	ObserveChatMessageFetchDuration(mode, discardedReason string, elapsed float64)
 }
 
 type InstanceInfo struct {
@@ -127,7 +143,11 @@ type metrics struct {
 
 	apiTime *prometheus.HistogramVec
 
-	msGraphClientTime *prometheus.HistogramVec
+	msGraphClientTime          *prometheus.HistogramVec
+	msGraphThrottledTotal      *prometheus.CounterVec
+	msGraphThrottlingWaitTime  *prometheus.HistogramVec
+	msGraphCircuitBreakerState *prometheus.GaugeVec
+	clientCacheRequestsTotal   *prometheus.CounterVec
 
 	httpRequestsTotal          prometheus.Counter
 	httpErrorsTotal            prometheus.Counter
@@ -160,9 +180,11 @@ type metrics struct {
 	activeWorkersTotal            *prometheus.GaugeVec
 	clientSecretEndDateTime       prometheus.Gauge
 
-	storeTime          *prometheus.HistogramVec
-	workersTime        *prometheus.HistogramVec
-	notificationsTotal *prometheus.CounterVec
+	storeTime               *prometheus.HistogramVec
+	storeCacheRequestsTotal *prometheus.CounterVec
+	workersTime             *prometheus.HistogramVec
+	notificationsTotal      *prometheus.CounterVec
+	chatMessageFetchTime    *prometheus.HistogramVec
 }
 
 // NewMetrics Factory method to create a new metrics collector.
@@ -418,6 +440,43 @@ func NewMetrics(info InstanceInfo) Metrics {
 	)
 	m.registry.MustRegister(m.msGraphClientTime)
 
+	m.msGraphThrottledTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemMSGraph,
+		Name:        "throttled_requests_total",
+		Help:        "The total number of client requests throttled by Microsoft.",
+		ConstLabels: additionalLabels,
+	}, []string{"method"})
+	m.registry.MustRegister(m.msGraphThrottledTotal)
+
+	m.msGraphThrottlingWaitTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemMSGraph,
+		Name:        "throttling_wait_time_seconds",
+		Help:        "Time spent waiting for the request budget or before retrying throttled requests.",
+		ConstLabels: additionalLabels,
+		Buckets:     []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120},
+	}, []string{"method"})
+	m.registry.MustRegister(m.msGraphThrottlingWaitTime)
+
+	m.msGraphCircuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemMSGraph,
+		Name:        "circuit_breaker_state",
+		Help:        "The state of the circuit breaker of each client method family: 0 closed, 1 half-open, 2 open.",
+		ConstLabels: additionalLabels,
+	}, []string{"family"})
+	m.registry.MustRegister(m.msGraphCircuitBreakerState)
+
+	m.clientCacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemMSGraph,
+		Name:        "client_cache_requests_total",
+		Help:        "The total number of lookups in the caches in front of the client, by result.",
+		ConstLabels: additionalLabels,
+	}, []string{"cache", "result"})
+	m.registry.MustRegister(m.clientCacheRequestsTotal)
+
 	m.storeTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace:   MetricsNamespace,
 		Subsystem:   MetricsSubsystemDB,
@@ -427,6 +486,15 @@ func NewMetrics(info InstanceInfo) Metrics {
 	}, []string{"method", "success"})
 	m.registry.MustRegister(m.storeTime)
 
+	m.storeCacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemDB,
+		Name:        "store_cache_requests_total",
+		Help:        "The total number of lookups in the caches in front of the store, by result.",
+		ConstLabels: additionalLabels,
+	}, []string{"cache", "result"})
+	m.registry.MustRegister(m.storeCacheRequestsTotal)
+
 	m.activeWorkersTotal = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace:   MetricsNamespace,
 		Subsystem:   MetricsSubsystemApp,
@@ -463,6 +531,15 @@ func NewMetrics(info InstanceInfo) Metrics {
 	}, []string{"is_group_chat", "has_attachments", "discarded_reason"})
 	m.registry.MustRegister(m.notificationsTotal)
 
+	m.chatMessageFetchTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   MetricsNamespace,
+		Subsystem:   MetricsSubsystemEvents,
+		Name:        "chat_message_fetch_time_seconds",
+		Help:        "Time to fetch the chat, message and presences needed to handle a new chat message, across all the requests made.",
+		ConstLabels: additionalLabels,
+	}, []string{"mode", "discarded_reason"})
+	m.registry.MustRegister(m.chatMessageFetchTime)
+
 	return m
 }
 
@@ -624,12 +701,50 @@ func (m *metrics) ObserveMSGraphClientMethodDuration(method, success, statusCode
 	}
 }
 
+func (m *metrics) ObserveMSGraphThrottledRequest(method string) {
+	if m != nil {
+		m.msGraphThrottledTotal.With(prometheus.Labels{"method": method}).Inc()
+	}
+}
+
+func (m *metrics) ObserveMSGraphThrottlingWait(method string, elapsed float64) {
+	if m != nil {
+		m.msGraphThrottlingWaitTime.With(prometheus.Labels{"method": method}).Observe(elapsed)
+	}
+}
+
+func (m *metrics) ObserveMSGraphCircuitBreakerState(family string, state int) {
+	if m != nil {
+		m.msGraphCircuitBreakerState.With(prometheus.Labels{"family": family}).Set(float64(state))
+	}
+}
+
+func (m *metrics) ObserveClientCacheRequest(cache string, hit bool) {
+	if m != nil {
+		result := "miss"
+		if hit {
+			result = "hit"
+		}
+		m.clientCacheRequestsTotal.With(prometheus.Labels{"cache": cache, "result": result}).Inc()
+	}
+}
+
 func (m *metrics) ObserveStoreMethodDuration(method, success string, elapsed float64) {
 	if m != nil {
 		m.storeTime.With(prometheus.Labels{"method": method, "success": success}).Observe(elapsed)
 	}
 }
 
+func (m *metrics) ObserveStoreCacheRequest(cache string, hit bool) {
+	if m != nil {
+		result := "miss"
+		if hit {
+			result = "hit"
+		}
+		m.storeCacheRequestsTotal.With(prometheus.Labels{"cache": cache, "result": result}).Inc()
+	}
+}
+
 func (m *metrics) IncrementActiveWorkers(worker string) {
 	if m != nil {
 		m.activeWorkersTotal.With(prometheus.Labels{"worker": worker}).Inc()
@@ -702,3 +817,9 @@ func (m *metrics) ObserveNotification(isGroupChat, hasAttachments bool, discarde
 		}).Inc()
 	}
 }
+
+func (m *metrics) ObserveChatMessageFetchDuration(mode, discardedReason string, elapsed float64) {
+	if m != nil {
+		m.chatMessageFetchTime.With(prometheus.Labels{"mode": mode, "discarded_reason": discardedReason}).Observe(elapsed)
+	}
+}
@@ -85,6 +85,16 @@ func (_m *Metrics) ObserveChangeEventQueueRejected() {
 	_m.Called()
 }
 
+// ObserveChatMessageFetchDuration provides a mock function with given fields: mode, discardedReason, elapsed
+func (_m *Metrics) ObserveChatMessageFetchDuration(mode string, discardedReason string, elapsed float64) {
+	_m.Called(mode, discardedReason, elapsed)
+}
+
+// ObserveClientCacheRequest provides a mock function with given fields: cache, hit
+func (_m *Metrics) ObserveClientCacheRequest(cache string, hit bool) {
+	_m.Called(cache, hit)
+}
+
 // ObserveClientSecretEndDateTime provides a mock function with given fields: expireDate
 func (_m *Metrics) ObserveClientSecretEndDateTime(expireDate time.Time) {
 	_m.Called(expireDate)
@@ -125,11 +135,26 @@ func (_m *Metrics) ObserveLinkedChannels(count int64) {
 	_m.Called(count)
 }
 
+// ObserveMSGraphCircuitBreakerState provides a mock function with given fields: family, state
+func (_m *Metrics) ObserveMSGraphCircuitBreakerState(family string, state int) {
+	_m.Called(family, state)
+}
+
 // ObserveMSGraphClientMethodDuration provides a mock function with given fields: method, success, statusCode, elapsed
 func (_m *Metrics) ObserveMSGraphClientMethodDuration(method string, success string, statusCode string, elapsed float64) {
 	_m.Called(method, success, statusCode, elapsed)
 }
 
+// ObserveMSGraphThrottledRequest provides a mock function with given fields: method
+func (_m *Metrics) ObserveMSGraphThrottledRequest(method string) {
+	_m.Called(method)
+}
+
+// ObserveMSGraphThrottlingWait provides a mock function with given fields: method, elapsed
+func (_m *Metrics) ObserveMSGraphThrottlingWait(method string, elapsed float64) {
+	_m.Called(method, elapsed)
+}
+
 // ObserveMessage provides a mock function with given fields: action, source, isDirectOrGroupMessage
 func (_m *Metrics) ObserveMessage(action string, source string, isDirectOrGroupMessage bool) {
 	_m.Called(action, source, isDirectOrGroupMessage)
@@ -165,6 +190,11 @@ func (_m *Metrics) ObserveReaction(action string, source string, isDirectOrGroup
 	_m.Called(action, source, isDirectOrGroupMessage)
 }
 
+// ObserveStoreCacheRequest provides a mock function with given fields: cache, hit
+func (_m *Metrics) ObserveStoreCacheRequest(cache string, hit bool) {
+	_m.Called(cache, hit)
+}
+
 // ObserveStoreMethodDuration provides a mock function with given fields: method, success, elapsed
 func (_m *Metrics) ObserveStoreMethodDuration(method string, success string, elapsed float64) {
 	_m.Called(method, success, elapsed)
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mattermost/mattermost/server/public/model"
 )
 
 type StatusRecorder struct {
@@ -50,3 +51,22 @@ func (a *API) metricsMiddleware(next http.Handler) http.Handler {
 		a.p.GetMetrics().ObserveAPIEndpointDuration(endpoint, r.Method, strconv.Itoa(recorder.Status), elapsed)
 	})
 }
+
+// adminRequired rejects requests from users who are not system administrators.
+func (a *API) adminRequired(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Header.Get("Mattermost-User-ID")
+		if userID == "" {
+			http.Error(w, "not authorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !a.p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+			a.p.API.LogWarn("Insufficient permissions", "user_id", userID)
+			http.Error(w, "not able to authorize the user", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"database/sql"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
+)
+
+const (
+	// missedMessagesCandidatesPerPage is how many of the ranked users are considered at a time.
+	missedMessagesCandidatesPerPage = 100
+	missedMessagesNudgeFrequency    = 24 * time.Hour
+)
+
+// recordMissedMessages records that Teams chat members who are not mapped to Mattermost users
+// missed a message, resolving the members to Mattermost users by email in bulk.
+func (p *Plugin) recordMissedMessages(members []clientmodels.ChatMember, missedAt time.Time) {
+	var emails []string
+	for _, member := range members {
+		if member.Email != "" {
+			emails = append(emails, member.Email)
+		}
+	}
+	if len(emails) == 0 {
+		return
+	}
+
+	// Not every Teams user has a Mattermost account.
+	userIDsByEmail, err := p.store.GetUserIDsByEmails(emails)
+	if err != nil {
+		p.API.LogWarn("Failed to get users by email to record missed messages", "error", err.Error())
+		return
+	}
+	if len(userIDsByEmail) == 0 {
+		return
+	}
+
+	userIDs := make([]string, 0, len(userIDsByEmail))
+	for _, userID := range userIDsByEmail {
+		userIDs = append(userIDs, userID)
+	}
+	users, appErr := p.API.GetUsersByIds(userIDs)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get users to record missed messages", "error", appErr.Error())
+		return
+	}
+
+	usersByID := make(map[string]*model.User, len(users))
+	for _, user := range users {
+		usersByID[user.Id] = user
+	}
+
+	for _, member := range members {
+		user, ok := usersByID[userIDsByEmail[strings.ToLower(member.Email)]]
+		if !ok || user.IsBot || user.IsGuest() || user.DeleteAt != 0 {
+			continue
+		}
+
+		if err := p.store.RecordMissedMessage(user.Id, member.UserID, missedAt); err != nil {
+			p.API.LogWarn("Failed to record missed message", "user_id", user.Id, "teams_user_id", member.UserID, "error", err.Error())
+		}
+	}
+}
+
+// processMissedMessages invites the users who missed the most Teams messages while spaces are
+// available in the invite pool, and lets users who can connect know what they are missing.
+func (p *Plugin) processMissedMessages() {
+	defer func() {
+		if r := recover(); r != nil {
+			p.GetMetrics().ObserveGoroutineFailure()
+			p.API.LogError("Recovering from panic", "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+
+	if !p.getConfiguration().TrackMissedMessages() {
+		return
+	}
+
+	done := p.GetMetrics().ObserveWorker(metrics.WorkerMissedMessages)
+	defer done()
+
+	invited, nudged, err := p.processMissedMessagesAt(time.Now())
+	if err != nil {
+		p.API.LogWarn("Failed to process missed messages", "error", err.Error())
+		return
+	}
+
+	p.API.LogInfo("Finished the missed messages job", "invited_users", invited, "nudged_users", nudged)
+}
+
+// processMissedMessagesAt walks the ranked users until the invite pool is filled, so that users
+// who are already invited or cannot be invited don't hold back those ranked below them.
+func (p *Plugin) processMissedMessagesAt(currentTime time.Time) (int, int, error) {
+	config := p.getConfiguration()
+
+	invited := 0
+	nudged := 0
+	for offset := 0; ; {
+		candidates, err := p.store.ListMissedMessages(offset, missedMessagesCandidatesPerPage)
+		if err != nil {
+			return invited, nudged, errors.Wrap(err, "error in listing missed messages")
+		}
+
+		deleted := 0
+		for _, missedMessages := range candidates {
+			userID := missedMessages.MattermostUserID
+
+			// Users mapped since their messages were missed get notifications from now on.
+			if _, err := p.store.MattermostToTeamsUserID(userID); err == nil {
+				if err := p.store.DeleteMissedMessages(userID); err != nil {
+					p.API.LogWarn("Failed to delete missed messages", "user_id", userID, "error", err.Error())
+				} else {
+					// The next page starts one row earlier.
+					deleted++
+				}
+				continue
+			} else if err != sql.ErrNoRows {
+				p.API.LogWarn("Failed to get the Teams user for the Mattermost user", "user_id", userID, "error", err.Error())
+				continue
+			}
+
+			if config.ConnectedUsersInviteByMissedMessages {
+				wasInvited, err := p.maybeSendInviteMessage(userID, currentTime, true)
+				if err != nil {
+					p.API.LogWarn("Failed to invite user who missed messages", "user_id", userID, "error", err.Error())
+					continue
+				}
+				if wasInvited {
+					invited++
+					continue
+				}
+			}
+
+			if config.ConnectedUsersMissedMessagesNudge {
+				wasNudged, err := p.maybeSendMissedMessagesNudge(missedMessages, currentTime)
+				if err != nil {
+					p.API.LogWarn("Failed to notify user about missed messages", "user_id", userID, "error", err.Error())
+					continue
+				}
+				if wasNudged {
+					nudged++
+				}
+			}
+		}
+
+		if len(candidates) < missedMessagesCandidatesPerPage {
+			break
+		}
+		offset += len(candidates) - deleted
+
+		// Users ranked further down are only considered while they might be invited.
+		if !config.ConnectedUsersInviteByMissedMessages {
+			break
+		}
+		hasRoom, err := p.hasRoomInInvitePool()
+		if err != nil {
+			return invited, nudged, err
+		}
+		if !hasRoom {
+			break
+		}
+	}
+
+	return invited, nudged, nil
+}
+
+// maybeSendMissedMessagesNudge lets a user who is able to connect know how many Teams messages
+// they missed since they were last told, at most once a day and never on weekends.
+func (p *Plugin) maybeSendMissedMessagesNudge(missedMessages *storemodels.MissedMessages, currentTime time.Time) (bool, error) {
+	missed := missedMessages.Count - missedMessages.NudgedCount
+	if missed <= 0 || currentTime.Sub(missedMessages.LastNudgedAt) < missedMessagesNudgeFrequency {
+		return false, nil
+	}
+
+	user, appErr := p.API.GetUser(missedMessages.MattermostUserID)
+	if appErr != nil {
+		return false, errors.Wrap(appErr, "error in getting user")
+	}
+
+	now := currentTime.In(user.GetTimezoneLocation())
+	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+		return false, nil
+	}
+
+	canConnect, err := p.UserHasRightToConnect(user.Id)
+	if err != nil {
+		return false, err
+	}
+	if !canConnect {
+		canConnect, _, err = p.UserCanOpenlyConnect(user.Id)
+		if err != nil {
+			return false, err
+		}
+	}
+	if !canConnect {
+		return false, nil
+	}
+
+	if err := p.SendMissedMessagesMessage(user, missed); err != nil {
+		return false, err
+	}
+
+	if err := p.store.SetMissedMessagesNudged(user.Id, missedMessages.Count, currentTime); err != nil {
+		return false, errors.Wrap(err, "error in storing missed messages notification")
+	}
+
+	return true, nil
+}
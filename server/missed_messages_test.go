@@ -0,0 +1,247 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+func TestRecordMissedMessages(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	now := time.Now()
+
+	t.Run("no email", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.p.recordMissedMessages([]clientmodels.ChatMember{{UserID: "t" + user.Id}}, now)
+
+		missedMessages, err := th.p.store.GetMissedMessages(user.Id)
+		require.NoError(t, err)
+		assert.Nil(t, missedMessages)
+	})
+
+	t.Run("guest user", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupGuestUser(t, team)
+
+		th.p.recordMissedMessages([]clientmodels.ChatMember{{UserID: "t" + user.Id, Email: user.Email}}, now)
+
+		missedMessages, err := th.p.store.GetMissedMessages(user.Id)
+		require.NoError(t, err)
+		assert.Nil(t, missedMessages)
+	})
+
+	t.Run("resolved by email", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+		otherUser := th.SetupUser(t, team)
+
+		for i := 0; i < 2; i++ {
+			th.p.recordMissedMessages([]clientmodels.ChatMember{
+				{UserID: "t" + user.Id, Email: strings.ToUpper(user.Email)},
+				{UserID: "t" + otherUser.Id, Email: otherUser.Email},
+				{UserID: "unknown", Email: "unknown@example.com"},
+			}, now)
+		}
+
+		missedMessages, err := th.p.store.GetMissedMessages(user.Id)
+		require.NoError(t, err)
+		require.NotNil(t, missedMessages)
+		assert.Equal(t, "t"+user.Id, missedMessages.TeamsUserID)
+		assert.Equal(t, 2, missedMessages.Count)
+
+		missedMessages, err = th.p.store.GetMissedMessages(otherUser.Id)
+		require.NoError(t, err)
+		require.NotNil(t, missedMessages)
+		assert.Equal(t, 2, missedMessages.Count)
+	})
+}
+
+func TestProcessMissedMessages(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	botUser, err := th.p.apiClient.User.Get(th.p.botUserID)
+	require.NoError(t, err)
+
+	tuesdayNoon, _ := time.Parse(time.RFC3339, "2024-01-09T12:00:00Z")
+	saturdayEvening, _ := time.Parse(time.RFC3339, "2024-01-06T22:00:00Z")
+
+	recordMissed := func(t *testing.T, user *model.User, count int) {
+		t.Helper()
+		for i := 0; i < count; i++ {
+			err := th.p.store.RecordMissedMessage(user.Id, "t"+user.Id, tuesdayNoon)
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("invite the user missing the most messages", func(t *testing.T) {
+		th.Reset(t)
+		user1 := th.SetupUser(t, team)
+		user2 := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 10
+			c.ConnectedUsersMaxPendingInvites = 1
+			c.ConnectedUsersInviteByMissedMessages = true
+		})
+
+		recordMissed(t, user1, 1)
+		recordMissed(t, user2, 3)
+
+		invited, nudged, err := th.p.processMissedMessagesAt(tuesdayNoon)
+		require.NoError(t, err)
+		assert.Equal(t, 1, invited)
+		assert.Equal(t, 0, nudged)
+
+		th.assertDMFromUserRe(t, botUser.Id, user2.Id, "you've been invited by your administrator")
+		th.assertNoDMFromUser(t, botUser.Id, user1.Id, model.GetMillisForTime(time.Now().Add(-5*time.Second)))
+
+		invitedUser, err := th.p.store.GetInvitedUser(user2.Id)
+		require.NoError(t, err)
+		assert.NotNil(t, invitedUser)
+	})
+
+	t.Run("invite users ranked below a page of users who cannot be invited", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 10
+			c.ConnectedUsersMaxPendingInvites = 1
+			c.ConnectedUsersInviteByMissedMessages = true
+		})
+
+		// Users who no longer exist rank above the user, filling the first page.
+		for i := 0; i < missedMessagesCandidatesPerPage; i++ {
+			userID := model.NewId()
+			for j := 0; j < 2; j++ {
+				require.NoError(t, th.p.store.RecordMissedMessage(userID, "t"+userID, tuesdayNoon))
+			}
+		}
+		recordMissed(t, user, 1)
+
+		invited, _, err := th.p.processMissedMessagesAt(tuesdayNoon)
+		require.NoError(t, err)
+		assert.Equal(t, 1, invited)
+
+		th.assertDMFromUserRe(t, botUser.Id, user.Id, "you've been invited by your administrator")
+	})
+
+	t.Run("pool reserved for ranked users", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 10
+			c.ConnectedUsersMaxPendingInvites = 1
+			c.ConnectedUsersInviteByMissedMessages = true
+		})
+
+		result, err := th.p.MaybeSendInviteMessage(user.Id, tuesdayNoon)
+		require.NoError(t, err)
+		assert.False(t, result)
+	})
+
+	t.Run("skip and forget users mapped since", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 10
+			c.ConnectedUsersMaxPendingInvites = 1
+			c.ConnectedUsersInviteByMissedMessages = true
+		})
+
+		recordMissed(t, user, 2)
+		th.ConnectUser(t, user.Id)
+
+		invited, _, err := th.p.processMissedMessagesAt(tuesdayNoon)
+		require.NoError(t, err)
+		assert.Equal(t, 0, invited)
+
+		missedMessages, err := th.p.store.GetMissedMessages(user.Id)
+		require.NoError(t, err)
+		assert.Nil(t, missedMessages)
+	})
+
+	t.Run("nudge invited user", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 10
+			c.ConnectedUsersMissedMessagesNudge = true
+		})
+
+		th.MarkUserInvited(t, user.Id)
+		recordMissed(t, user, 3)
+
+		_, nudged, err := th.p.processMissedMessagesAt(tuesdayNoon)
+		require.NoError(t, err)
+		assert.Equal(t, 1, nudged)
+		th.assertDMFromUserRe(t, botUser.Id, user.Id, "You missed 3 Teams messages.")
+
+		// No new missed messages, no new nudge.
+		_, nudged, err = th.p.processMissedMessagesAt(tuesdayNoon.Add(25 * time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 0, nudged)
+
+		recordMissed(t, user, 1)
+
+		// Not more than once a day.
+		_, nudged, err = th.p.processMissedMessagesAt(tuesdayNoon.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 0, nudged)
+
+		_, nudged, err = th.p.processMissedMessagesAt(tuesdayNoon.Add(25 * time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 1, nudged)
+		th.assertDMFromUserRe(t, botUser.Id, user.Id, "You missed 1 Teams message.")
+	})
+
+	t.Run("don't nudge on weekends", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 10
+			c.ConnectedUsersMissedMessagesNudge = true
+		})
+
+		recordMissed(t, user, 3)
+
+		_, nudged, err := th.p.processMissedMessagesAt(saturdayEvening)
+		require.NoError(t, err)
+		assert.Equal(t, 0, nudged)
+	})
+
+	t.Run("don't nudge users unable to connect", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		th.setPluginConfigurationTemporarily(t, func(c *configuration) {
+			c.ConnectedUsersAllowed = 10
+			c.ConnectedUsersRestricted = true
+			c.ConnectedUsersMissedMessagesNudge = true
+		})
+
+		recordMissed(t, user, 3)
+
+		_, nudged, err := th.p.processMissedMessagesAt(tuesdayNoon)
+		require.NoError(t, err)
+		assert.Equal(t, 0, nudged)
+	})
+}
@@ -13,12 +13,13 @@ import (
 
 	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/client_cachelayer"
 	"github.com/mattermost/mattermost-plugin-msteams/server/store"
 )
 
 const monitoringSystemJobName = "monitoring_system"
 
-// Monitor is a job that creates and maintains chat and channel subscriptions.
+// Monitor is a job that creates and maintains chat, channel and presence subscriptions.
 //
 // While the job is started on all plugin instances in a cluster, only one instance will actually
 // do the required effort, falling over seamlessly as needed.
@@ -27,6 +28,7 @@ type Monitor struct {
 	store            store.Store
 	api              plugin.API
 	metrics          metrics.Metrics
+	presences        *client_cachelayer.PresenceCache
 	job              *cluster.Job
 	baseURL          string
 	webhookSecret    string
@@ -35,12 +37,13 @@ type Monitor struct {
 }
 
 // New creates a new instance of the Monitor job.
-func NewMonitor(client msteams.Client, store store.Store, api plugin.API, metrics metrics.Metrics, baseURL string, webhookSecret string, useEvaluationAPI bool) *Monitor {
+func NewMonitor(client msteams.Client, store store.Store, api plugin.API, metrics metrics.Metrics, presences *client_cachelayer.PresenceCache, baseURL string, webhookSecret string, useEvaluationAPI bool) *Monitor {
 	return &Monitor{
 		client:           client,
 		store:            store,
 		api:              api,
 		metrics:          metrics,
+		presences:        presences,
 		baseURL:          baseURL,
 		webhookSecret:    webhookSecret,
 		useEvaluationAPI: useEvaluationAPI,
@@ -98,11 +101,12 @@ func (m *Monitor) runMonitoringSystemJob() {
 	done := m.metrics.ObserveWorker(metrics.WorkerMonitor)
 	defer done()
 
-	_, allChatsSubscription, err := m.getMSTeamsSubscriptionsMap()
+	msteamsSubscriptionsMap, allChatsSubscription, err := m.getMSTeamsSubscriptionsMap()
 	if err != nil {
 		m.api.LogError("Unable to fetch subscriptions from MS Teams", "error", err.Error())
 		return
 	}
 
 	m.checkGlobalChatsSubscription(allChatsSubscription)
+	m.checkPresenceSubscriptions(msteamsSubscriptionsMap)
 }
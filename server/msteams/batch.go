@@ -0,0 +1,246 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package msteams
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	abstractions "github.com/microsoft/kiota-abstractions-go"
+	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	"github.com/microsoftgraph/msgraph-sdk-go/communications"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+// MaxBatchRequests is the most requests Microsoft Graph accepts in a single JSON batch.
+const MaxBatchRequests = 20
+
+// Batch gathers requests to be sent together in a single JSON batch with Client.SendBatch,
+// saving the round-trips of sending them one by one.
+type Batch struct {
+	steps []batchStep
+}
+
+// BatchResult is the result of a request of a batch, set once the batch and its pending requests
+// are sent.
+type BatchResult[T any] struct {
+	Value T
+	Err   error
+}
+
+// batchStep is a request of a batch, knowing how to build it, parse its response, or send it
+// on its own instead.
+type batchStep interface {
+	requestInformation(tc *ClientImpl) (*abstractions.RequestInformation, error)
+	complete(response msgraphcore.BatchResponse, itemID string)
+	fail(err error)
+	sendOne(client Client)
+	pending() bool
+}
+
+type batchRequest[T any] struct {
+	result *BatchResult[T]
+	done   bool
+	build  func(tc *ClientImpl) (*abstractions.RequestInformation, error)
+	parse  func(response msgraphcore.BatchResponse, itemID string) (T, error)
+	send   func(client Client) (T, error)
+}
+
+func (r *batchRequest[T]) requestInformation(tc *ClientImpl) (*abstractions.RequestInformation, error) {
+	return r.build(tc)
+}
+
+func (r *batchRequest[T]) complete(response msgraphcore.BatchResponse, itemID string) {
+	r.result.Value, r.result.Err = r.parse(response, itemID)
+	r.done = true
+}
+
+func (r *batchRequest[T]) fail(err error) {
+	r.result.Err = err
+	r.done = true
+}
+
+func (r *batchRequest[T]) sendOne(client Client) {
+	r.result.Value, r.result.Err = r.send(client)
+	r.done = true
+}
+
+func (r *batchRequest[T]) pending() bool {
+	return !r.done
+}
+
+func addBatchRequest[T any](b *Batch, request *batchRequest[T]) *BatchResult[T] {
+	request.result = &BatchResult[T]{}
+	b.steps = append(b.steps, request)
+	return request.result
+}
+
+// NewBatch creates an empty batch.
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Len returns the number of requests in the batch.
+func (b *Batch) Len() int {
+	return len(b.steps)
+}
+
+// GetChat adds a request for the chat, along with its members, as Client.GetChat does.
+func (b *Batch) GetChat(chatID string) *BatchResult[*clientmodels.Chat] {
+	return addBatchRequest(b, &batchRequest[*clientmodels.Chat]{
+		build: func(tc *ClientImpl) (*abstractions.RequestInformation, error) {
+			return tc.client.Chats().ByChatId(chatID).ToGetRequestInformation(tc.ctx, getChatRequestConfiguration())
+		},
+		parse: func(response msgraphcore.BatchResponse, itemID string) (*clientmodels.Chat, error) {
+			res, err := msgraphcore.GetBatchResponseById[models.Chatable](response, itemID, models.CreateChatFromDiscriminatorValue)
+			if err != nil {
+				return nil, NormalizeGraphAPIError(err)
+			}
+			return convertToChat(res, chatID), nil
+		},
+		send: func(client Client) (*clientmodels.Chat, error) {
+			return client.GetChat(chatID)
+		},
+	})
+}
+
+// GetChatMessage adds a request for the chat message, as Client.GetChatMessage does.
+func (b *Batch) GetChatMessage(chatID, messageID string) *BatchResult[*clientmodels.Message] {
+	return addBatchRequest(b, &batchRequest[*clientmodels.Message]{
+		build: func(tc *ClientImpl) (*abstractions.RequestInformation, error) {
+			return tc.client.Chats().ByChatId(chatID).Messages().ByChatMessageId(messageID).ToGetRequestInformation(tc.ctx, nil)
+		},
+		parse: func(response msgraphcore.BatchResponse, itemID string) (*clientmodels.Message, error) {
+			res, err := msgraphcore.GetBatchResponseById[models.ChatMessageable](response, itemID, models.CreateChatMessageFromDiscriminatorValue)
+			if err != nil {
+				return nil, NormalizeGraphAPIError(err)
+			}
+			return convertToMessage(res, "", "", chatID), nil
+		},
+		send: func(client Client) (*clientmodels.Message, error) {
+			return client.GetChatMessage(chatID, messageID)
+		},
+	})
+}
+
+// GetPresencesForUsers adds a request for the presence of up to MaxPresenceUserIDs users, as
+// Client.GetPresencesForUsers does.
+func (b *Batch) GetPresencesForUsers(userIDs []string) *BatchResult[map[string]clientmodels.Presence] {
+	return addBatchRequest(b, &batchRequest[map[string]clientmodels.Presence]{
+		build: func(tc *ClientImpl) (*abstractions.RequestInformation, error) {
+			if len(userIDs) > MaxPresenceUserIDs {
+				return nil, fmt.Errorf("batched presence lookups are limited to %d users, got %d", MaxPresenceUserIDs, len(userIDs))
+			}
+
+			body := communications.NewGetPresencesByUserIdPostRequestBody()
+			body.SetIds(userIDs)
+			return tc.client.Communications().GetPresencesByUserId().ToPostRequestInformation(tc.ctx, body, nil)
+		},
+		parse: func(response msgraphcore.BatchResponse, itemID string) (map[string]clientmodels.Presence, error) {
+			res, err := msgraphcore.GetBatchResponseById[communications.GetPresencesByUserIdPostResponseable](response, itemID, communications.CreateGetPresencesByUserIdPostResponseFromDiscriminatorValue)
+			if err != nil {
+				return nil, NormalizeGraphAPIError(err)
+			}
+
+			presences := make(map[string]clientmodels.Presence, len(userIDs))
+			addPresences(presences, res.GetValue())
+			return presences, nil
+		},
+		send: func(client Client) (map[string]clientmodels.Presence, error) {
+			return client.GetPresencesForUsers(userIDs)
+		},
+	})
+}
+
+// SendPending sends one by one with the given client the requests left pending by
+// Client.SendBatch, i.e. those Microsoft throttled or didn't answer within the batch. Sent on
+// their own through the layers of the client, they are retried and accounted for by the circuit
+// breakers like any other request.
+func (b *Batch) SendPending(client Client) {
+	for _, step := range b.steps {
+		if step.pending() {
+			step.sendOne(client)
+		}
+	}
+}
+
+// SendBatch sends the requests of the batch in a single JSON batch. An error is only returned if
+// the batch as a whole failed, the errors of the individual requests being set in their results.
+// The requests Microsoft throttled or didn't answer are left pending, to be sent with
+// Batch.SendPending.
+func (tc *ClientImpl) SendBatch(batch *Batch) error {
+	if batch.Len() == 0 {
+		return nil
+	}
+	if batch.Len() > MaxBatchRequests {
+		return fmt.Errorf("batches are limited to %d requests, got %d", MaxBatchRequests, batch.Len())
+	}
+
+	batchRequest := msgraphcore.NewBatchRequest(tc.client.GetAdapter())
+	itemIDs := make([]string, 0, batch.Len())
+	for _, step := range batch.steps {
+		requestInformation, err := step.requestInformation(tc)
+		if err != nil {
+			return NormalizeGraphAPIError(err)
+		}
+
+		item, err := batchRequest.AddBatchRequestStep(*requestInformation)
+		if err != nil {
+			return NormalizeGraphAPIError(err)
+		}
+		itemIDs = append(itemIDs, *item.GetId())
+	}
+
+	batchResponse, err := batchRequest.Send(tc.ctx, tc.client.GetAdapter())
+	if err != nil {
+		return NormalizeGraphAPIError(err)
+	}
+
+	for i, step := range batch.steps {
+		item := batchResponse.GetResponseById(itemIDs[i])
+		if item == nil || item.GetStatus() == nil {
+			continue
+		}
+		if status := *item.GetStatus(); status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+			continue
+		}
+		if *item.GetStatus() >= 400 {
+			step.fail(batchItemError(item, time.Now()))
+			continue
+		}
+
+		step.complete(batchResponse, itemIDs[i])
+	}
+
+	return nil
+}
+
+// batchItemError returns the error of a failed request of a batch, whose status code and headers
+// are not known to the errors parsed by the SDK.
+func batchItemError(item msgraphcore.BatchItem, now time.Time) error {
+	graphErr := &GraphAPIError{
+		StatusCode: int(*item.GetStatus()),
+		Message:    "batched request failed",
+	}
+
+	if body, ok := item.GetBody()["error"].(map[string]any); ok {
+		if code, ok := body["code"].(string); ok {
+			graphErr.Code = code
+		}
+		if message, ok := body["message"].(string); ok {
+			graphErr.Message = message
+		}
+	}
+
+	for key, value := range item.GetHeaders() {
+		if http.CanonicalHeaderKey(key) == "Retry-After" {
+			graphErr.RetryAfter = parseRetryAfter(value, now)
+		}
+	}
+
+	return graphErr
+}
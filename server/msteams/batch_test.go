@@ -0,0 +1,123 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package msteams
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+// batchTestClient serves the requests of a batch sent one by one.
+type batchTestClient struct {
+	Client
+	presenceUserIDs []string
+}
+
+func (c *batchTestClient) GetChat(chatID string) (*clientmodels.Chat, error) {
+	return &clientmodels.Chat{ID: chatID}, nil
+}
+
+func (c *batchTestClient) GetChatMessage(chatID, messageID string) (*clientmodels.Message, error) {
+	return nil, &GraphAPIError{StatusCode: http.StatusNotFound, Message: "message not found"}
+}
+
+func (c *batchTestClient) GetPresencesForUsers(userIDs []string) (map[string]clientmodels.Presence, error) {
+	c.presenceUserIDs = userIDs
+	return map[string]clientmodels.Presence{
+		"user_id": {UserID: "user_id", Availability: "Available"},
+	}, nil
+}
+
+func TestBatchSendPending(t *testing.T) {
+	batch := NewBatch()
+	chatResult := batch.GetChat("chat_id")
+	msgResult := batch.GetChatMessage("chat_id", "message_id")
+	presencesResult := batch.GetPresencesForUsers([]string{"user_id"})
+	require.Equal(t, 3, batch.Len())
+
+	// Answered within the batch, so not sent again.
+	batch.steps[0].fail(errors.New("chat failed"))
+
+	client := &batchTestClient{}
+	batch.SendPending(client)
+
+	assert.EqualError(t, chatResult.Err, "chat failed")
+	assert.Nil(t, chatResult.Value)
+
+	var graphErr *GraphAPIError
+	require.ErrorAs(t, msgResult.Err, &graphErr)
+	assert.Equal(t, http.StatusNotFound, graphErr.StatusCode)
+	assert.Nil(t, msgResult.Value)
+
+	require.NoError(t, presencesResult.Err)
+	assert.Equal(t, []string{"user_id"}, client.presenceUserIDs)
+	assert.Equal(t, "Available", presencesResult.Value["user_id"].Availability)
+
+	for _, step := range batch.steps {
+		assert.False(t, step.pending())
+	}
+}
+
+func TestSendBatchLimits(t *testing.T) {
+	tc := &ClientImpl{}
+
+	t.Run("empty batch", func(t *testing.T) {
+		assert.NoError(t, tc.SendBatch(NewBatch()))
+	})
+
+	t.Run("too many requests", func(t *testing.T) {
+		batch := NewBatch()
+		for i := 0; i <= MaxBatchRequests; i++ {
+			batch.GetChat("chat_id")
+		}
+
+		assert.Error(t, tc.SendBatch(batch))
+	})
+}
+
+func TestBatchItemError(t *testing.T) {
+	now := time.Now()
+
+	newItem := func(status int32, headers msgraphcore.RequestHeader, body msgraphcore.RequestBody) msgraphcore.BatchItem {
+		item := msgraphcore.NewBatchItem()
+		item.SetStatus(&status)
+		item.SetHeaders(headers)
+		item.SetBody(body)
+		return item
+	}
+
+	t.Run("throttled", func(t *testing.T) {
+		err := batchItemError(newItem(http.StatusTooManyRequests, msgraphcore.RequestHeader{"retry-after": "30"}, msgraphcore.RequestBody{
+			"error": map[string]any{
+				"code":    "TooManyRequests",
+				"message": "Too many requests",
+			},
+		}), now)
+
+		var graphErr *GraphAPIError
+		require.True(t, errors.As(err, &graphErr))
+		assert.Equal(t, http.StatusTooManyRequests, graphErr.StatusCode)
+		assert.Equal(t, "TooManyRequests", graphErr.Code)
+		assert.Equal(t, "Too many requests", graphErr.Message)
+		assert.Equal(t, 30*time.Second, graphErr.RetryAfter)
+	})
+
+	t.Run("without body", func(t *testing.T) {
+		err := batchItemError(newItem(http.StatusForbidden, nil, nil), now)
+
+		var graphErr *GraphAPIError
+		require.True(t, errors.As(err, &graphErr))
+		assert.Equal(t, http.StatusForbidden, graphErr.StatusCode)
+		assert.Empty(t, graphErr.Code)
+		assert.Zero(t, graphErr.RetryAfter)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +15,8 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -45,6 +48,14 @@ import (
 
 const (
 	subscriptionExpirationTime = 2 * time.Hour
+
+	// presenceSubscriptionExpirationTime is the longest Microsoft Graph allows for the presence
+	// subscriptions.
+	presenceSubscriptionExpirationTime = 1 * time.Hour
+
+	// MaxPresenceUserIDs is the most users Microsoft Graph accepts in a single presence lookup or
+	// subscription.
+	MaxPresenceUserIDs = 650
 )
 
 type ConcurrentGraphRequestAdapter struct {
@@ -72,15 +83,17 @@ func (a *ConcurrentGraphRequestAdapter) GetSerializationWriterFactory() serializ
 var clientMutex sync.Mutex
 
 type ClientImpl struct {
-	client       *msgraphsdk.GraphServiceClient
-	ctx          context.Context
-	tenantID     string
-	clientID     string
-	clientSecret string
-	clientType   string // can be "app" or "token"
-	token        *oauth2.Token
-	logService   *pluginapi.LogService
-	redirectURL  string
+	client      *msgraphsdk.GraphServiceClient
+	ctx         context.Context
+	tenantID    string
+	clientID    string
+	credentials AppCredentials
+	cloud       Cloud
+	transport   *http.Transport
+	clientType  string // can be "app" or "token"
+	token       *oauth2.Token
+	logService  *pluginapi.LogService
+	redirectURL string
 }
 
 type Activity struct {
@@ -112,6 +125,8 @@ type GraphAPIError struct {
 	ClientRequestID string    `json:"client_request_id"`
 	RequestID       string    `json:"request_id"`
 	Timestamp       time.Time `json:"timestamp"`
+	// RetryAfter is how long Microsoft asked to wait before retrying a throttled request.
+	RetryAfter time.Duration `json:"retry_after"`
 }
 
 type ChatMessageAttachmentUser struct {
@@ -164,14 +179,18 @@ func NormalizeGraphAPIError(err error) error {
 		if terr.GetMessage() != nil {
 			graphErr.Message = *terr.GetMessage()
 		}
-		if terr.GetInnerError().GetClientRequestId() != nil {
-			graphErr.ClientRequestID = *terr.GetInnerError().GetClientRequestId()
+		innerError := terr.GetInnerError()
+		if innerError == nil {
+			return
+		}
+		if innerError.GetClientRequestId() != nil {
+			graphErr.ClientRequestID = *innerError.GetClientRequestId()
 		}
-		if terr.GetInnerError().GetRequestId() != nil {
-			graphErr.RequestID = *terr.GetInnerError().GetRequestId()
+		if innerError.GetRequestId() != nil {
+			graphErr.RequestID = *innerError.GetRequestId()
 		}
-		if terr.GetInnerError().GetDate() != nil {
-			graphErr.Timestamp = *terr.GetInnerError().GetDate()
+		if innerError.GetDate() != nil {
+			graphErr.Timestamp = *innerError.GetDate()
 		}
 	}
 
@@ -187,9 +206,37 @@ func NormalizeGraphAPIError(err error) error {
 		}
 	}
 
+	var apiErr abstractions.ApiErrorable
+	if errors.As(err, &apiErr) && apiErr.GetStatusCode() != 0 {
+		graphErr.StatusCode = apiErr.GetStatusCode()
+		if headers := apiErr.GetResponseHeaders(); headers != nil {
+			if values := headers.Get("Retry-After"); len(values) > 0 {
+				graphErr.RetryAfter = parseRetryAfter(values[0], time.Now())
+			}
+		}
+	}
+
 	return graphErr
 }
 
+// parseRetryAfter parses the value of a Retry-After header, either a number of seconds or an
+// HTTP date, returning zero when it is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
 func (at AccessToken) GetToken(_ context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
 	token, err := at.tokenSource.Token()
 	if err != nil {
@@ -201,16 +248,16 @@ func (at AccessToken) GetToken(_ context.Context, _ policy.TokenRequestOptions)
 	}, nil
 }
 
-var TeamsDefaultScopes = []string{"https://graph.microsoft.com/.default"}
-
-func NewApp(tenantID, clientID, clientSecret string, logService *pluginapi.LogService) Client {
+func NewApp(cloud Cloud, tenantID, clientID string, credentials AppCredentials, transport *http.Transport, logService *pluginapi.LogService) Client {
 	return &ClientImpl{
-		ctx:          context.Background(),
-		clientType:   "app",
-		tenantID:     tenantID,
-		clientID:     clientID,
-		clientSecret: clientSecret,
-		logService:   logService,
+		ctx:         context.Background(),
+		clientType:  "app",
+		tenantID:    tenantID,
+		clientID:    clientID,
+		credentials: credentials,
+		cloud:       cloud,
+		transport:   transport,
+		logService:  logService,
 	}
 }
 
@@ -220,39 +267,31 @@ func NewManualClient(tenantID, clientID string, logService *pluginapi.LogService
 		clientType: "token",
 		tenantID:   tenantID,
 		clientID:   clientID,
+		cloud:      CloudPublic,
 		logService: logService,
 		client:     client,
 	}
 }
 
-func NewTokenClient(redirectURL, tenantID, clientID, clientSecret string, token *oauth2.Token, logService *pluginapi.LogService) Client {
+func NewTokenClient(cloud Cloud, redirectURL, tenantID, clientID string, credentials AppCredentials, transport *http.Transport, token *oauth2.Token, logService *pluginapi.LogService) Client {
 	client := &ClientImpl{
-		ctx:          context.Background(),
-		clientType:   "token",
-		tenantID:     tenantID,
-		clientID:     clientID,
-		clientSecret: clientSecret,
-		token:        token,
-		logService:   logService,
-		redirectURL:  redirectURL,
-	}
-
-	conf := &oauth2.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		Scopes:       append(TeamsDefaultScopes, "offline_access"),
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/authorize", tenantID),
-			TokenURL: fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", tenantID),
-		},
-		RedirectURL: redirectURL,
+		ctx:         context.Background(),
+		clientType:  "token",
+		tenantID:    tenantID,
+		clientID:    clientID,
+		credentials: credentials,
+		cloud:       cloud,
+		transport:   transport,
+		token:       token,
+		logService:  logService,
+		redirectURL: redirectURL,
 	}
 
-	httpClient := getHTTPClient()
+	httpClient := getHTTPClient(transport)
 
-	accessToken := AccessToken{tokenSource: conf.TokenSource(context.Background(), client.token)}
+	accessToken := AccessToken{tokenSource: newTokenSource(cloud, redirectURL, tenantID, clientID, credentials, transport, client.token)}
 
-	auth, err := a.NewAzureIdentityAuthenticationProviderWithScopes(accessToken, append(TeamsDefaultScopes, "offline_access"))
+	auth, err := a.NewAzureIdentityAuthenticationProviderWithScopes(accessToken, append(cloud.Scopes(), "offline_access"))
 	if err != nil {
 		logService.Error("Unable to create the client from the token", "error", err)
 		return nil
@@ -263,6 +302,7 @@ func NewTokenClient(redirectURL, tenantID, clientID, clientSecret string, token
 		logService.Error("Unable to create the client from the token", "error", err)
 		return nil
 	}
+	adapter.SetBaseUrl(cloud.GraphBaseURL())
 
 	client.client = msgraphsdk.NewGraphServiceClient(&ConcurrentGraphRequestAdapter{GraphRequestAdapter: *adapter})
 
@@ -270,17 +310,7 @@ func NewTokenClient(redirectURL, tenantID, clientID, clientSecret string, token
 }
 
 func (tc *ClientImpl) RefreshToken(token *oauth2.Token) (*oauth2.Token, error) {
-	conf := &oauth2.Config{
-		ClientID:     tc.clientID,
-		ClientSecret: tc.clientSecret,
-		Scopes:       append(TeamsDefaultScopes, "offline_access"),
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/authorize", tc.tenantID),
-			TokenURL: fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", tc.tenantID),
-		},
-		RedirectURL: tc.redirectURL,
-	}
-	return conf.TokenSource(context.Background(), token).Token()
+	return newTokenSource(tc.cloud, tc.redirectURL, tc.tenantID, tc.clientID, tc.credentials, tc.transport, token).Token()
 }
 
 func (tc *ClientImpl) GetApp(applicationID string) (*clientmodels.App, error) {
@@ -301,6 +331,25 @@ func (tc *ClientImpl) GetApp(applicationID string) (*clientmodels.App, error) {
 		})
 	}
 
+	// Only the certificates used to verify the application's identity are credentials.
+	for _, credential := range application.GetKeyCredentials() {
+		if credential.GetUsage() == nil || *credential.GetUsage() != "Verify" {
+			continue
+		}
+
+		var name string
+		if credential.GetDisplayName() != nil {
+			name = *credential.GetDisplayName()
+		}
+
+		app.Credentials = append(app.Credentials, clientmodels.Credential{
+			ID:          credential.GetKeyId().String(),
+			Name:        name,
+			EndDateTime: *credential.GetEndDateTime(),
+			Thumbprint:  strings.ToUpper(hex.EncodeToString(credential.GetCustomKeyIdentifier())),
+		})
+	}
+
 	for _, requiredResourceAccess := range application.GetRequiredResourceAccess() {
 		for _, requiredResource := range requiredResourceAccess.GetResourceAccess() {
 			app.RequiredResources = append(app.RequiredResources, clientmodels.ResourceAccess{
@@ -319,22 +368,33 @@ func (tc *ClientImpl) Connect() error {
 	case "token":
 		return nil
 	case "app":
-		var err error
-		cred, err = azidentity.NewClientSecretCredential(
-			tc.tenantID,
-			tc.clientID,
-			tc.clientSecret,
-			&azidentity.ClientSecretCredentialOptions{
-				ClientOptions: azcore.ClientOptions{
-					Retry: policy.RetryOptions{
-						MaxRetries:    3,
-						RetryDelay:    4 * time.Second,
-						MaxRetryDelay: 120 * time.Second,
-					},
-					Transport: getAuthClient(),
-				},
+		clientOptions := azcore.ClientOptions{
+			Retry: policy.RetryOptions{
+				MaxRetries:    3,
+				RetryDelay:    4 * time.Second,
+				MaxRetryDelay: 120 * time.Second,
 			},
-		)
+			Transport: getAuthClient(tc.transport),
+			Cloud:     tc.cloud.endpoints().azure,
+		}
+
+		var err error
+		if certificate := tc.credentials.Certificate; certificate != nil {
+			cred, err = azidentity.NewClientCertificateCredential(
+				tc.tenantID,
+				tc.clientID,
+				certificate.certificates,
+				certificate.key,
+				&azidentity.ClientCertificateCredentialOptions{ClientOptions: clientOptions},
+			)
+		} else {
+			cred, err = azidentity.NewClientSecretCredential(
+				tc.tenantID,
+				tc.clientID,
+				tc.credentials.ClientSecret,
+				&azidentity.ClientSecretCredentialOptions{ClientOptions: clientOptions},
+			)
+		}
 		if err != nil {
 			return err
 		}
@@ -343,9 +403,9 @@ func (tc *ClientImpl) Connect() error {
 		return errors.New("not valid client type, this shouldn't happen ever")
 	}
 
-	httpClient := getHTTPClient()
+	httpClient := getHTTPClient(tc.transport)
 
-	auth, err := a.NewAzureIdentityAuthenticationProviderWithScopes(cred, append(TeamsDefaultScopes, "offline_access"))
+	auth, err := a.NewAzureIdentityAuthenticationProviderWithScopes(cred, append(tc.cloud.Scopes(), "offline_access"))
 	if err != nil {
 		return err
 	}
@@ -354,6 +414,7 @@ func (tc *ClientImpl) Connect() error {
 	if err != nil {
 		return err
 	}
+	adapter.SetBaseUrl(tc.cloud.GraphBaseURL())
 
 	clientMutex.Lock()
 	defer clientMutex.Unlock()
@@ -650,7 +711,7 @@ func (tc *ClientImpl) UploadFile(teamID, channelID, filename string, filesize in
 	}
 	req.Header.Add("Content-Length", fmt.Sprintf("%d", filesize))
 	req.Header.Add("Content-Range", fmt.Sprintf("bytes 0-%d/%d", filesize-1, filesize))
-	res, err := http.DefaultClient.Do(req)
+	res, err := httpClient(tc.transport).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -860,7 +921,10 @@ func (tc *ClientImpl) UpdateChatMessage(chatID, msgID, message string, mentions
 }
 
 func (tc *ClientImpl) subscribe(baseURL, webhookSecret, resource, changeType, certificate string) (*clientmodels.Subscription, error) {
-	expirationDateTime := time.Now().Add(subscriptionExpirationTime)
+	return tc.subscribeUntil(baseURL, webhookSecret, resource, changeType, certificate, time.Now().Add(subscriptionExpirationTime))
+}
+
+func (tc *ClientImpl) subscribeUntil(baseURL, webhookSecret, resource, changeType, certificate string, expirationDateTime time.Time) (*clientmodels.Subscription, error) {
 
 	lifecycleNotificationURL := baseURL + "lifecycle"
 	notificationURL := baseURL + "changes"
@@ -932,8 +996,33 @@ func (tc *ClientImpl) SubscribeToUserChats(userID, baseURL, webhookSecret string
 	return tc.subscribe(baseURL, webhookSecret, resource, changeType, certificate)
 }
 
+// SubscribeToPresences subscribes to the presence changes of the given users, up to
+// MaxPresenceUserIDs of them.
+func (tc *ClientImpl) SubscribeToPresences(userIDs []string, baseURL, webhookSecret string) (*clientmodels.Subscription, error) {
+	if len(userIDs) == 0 || len(userIDs) > MaxPresenceUserIDs {
+		return nil, fmt.Errorf("presence subscriptions require between 1 and %d users, got %d", MaxPresenceUserIDs, len(userIDs))
+	}
+
+	quotedUserIDs := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		quotedUserIDs = append(quotedUserIDs, "'"+userID+"'")
+	}
+	resource := fmt.Sprintf("/communications/presences?$filter=id in (%s)", strings.Join(quotedUserIDs, ","))
+	changeType := "updated"
+	return tc.subscribeUntil(baseURL, webhookSecret, resource, changeType, "", time.Now().Add(presenceSubscriptionExpirationTime))
+}
+
 func (tc *ClientImpl) RefreshSubscription(subscriptionID string) (*time.Time, error) {
-	expirationDateTime := time.Now().Add(subscriptionExpirationTime)
+	return tc.refreshSubscription(subscriptionID, time.Now().Add(subscriptionExpirationTime))
+}
+
+// RefreshPresenceSubscription extends the expiry time of a presence subscription, which are
+// shorter lived than the other subscriptions.
+func (tc *ClientImpl) RefreshPresenceSubscription(subscriptionID string) (*time.Time, error) {
+	return tc.refreshSubscription(subscriptionID, time.Now().Add(presenceSubscriptionExpirationTime))
+}
+
+func (tc *ClientImpl) refreshSubscription(subscriptionID string, expirationDateTime time.Time) (*time.Time, error) {
 	updatedSubscription := models.NewSubscription()
 	updatedSubscription.SetExpirationDateTime(&expirationDateTime)
 	if _, err := tc.client.Subscriptions().BySubscriptionId(subscriptionID).Patch(tc.ctx, updatedSubscription, nil); err != nil {
@@ -1095,17 +1184,24 @@ func (tc *ClientImpl) GetChannelsInTeam(teamID, filterQuery string) ([]*clientmo
 }
 
 func (tc *ClientImpl) GetChat(chatID string) (*clientmodels.Chat, error) {
+	res, err := tc.client.Chats().ByChatId(chatID).Get(tc.ctx, getChatRequestConfiguration())
+	if err != nil {
+		return nil, NormalizeGraphAPIError(err)
+	}
+
+	return convertToChat(res, chatID), nil
+}
+
+func getChatRequestConfiguration() *chats.ChatItemRequestBuilderGetRequestConfiguration {
 	requestParameters := &chats.ChatItemRequestBuilderGetQueryParameters{
 		Expand: []string{"members"},
 	}
-	configuration := &chats.ChatItemRequestBuilderGetRequestConfiguration{
+	return &chats.ChatItemRequestBuilderGetRequestConfiguration{
 		QueryParameters: requestParameters,
 	}
-	res, err := tc.client.Chats().ByChatId(chatID).Get(tc.ctx, configuration)
-	if err != nil {
-		return nil, NormalizeGraphAPIError(err)
-	}
+}
 
+func convertToChat(res models.Chatable, chatID string) *clientmodels.Chat {
 	chatType := ""
 	if res.GetChatType() != nil && *res.GetChatType() == models.GROUP_CHATTYPE {
 		chatType = "G"
@@ -1149,7 +1245,7 @@ func (tc *ClientImpl) GetChat(chatID string) (*clientmodels.Chat, error) {
 		topic = *res.GetTopic()
 	}
 
-	return &clientmodels.Chat{ID: chatID, Members: members, Type: chatType, Topic: topic}, nil
+	return &clientmodels.Chat{ID: chatID, Members: members, Type: chatType, Topic: topic}
 }
 
 func convertToMessage(msg models.ChatMessageable, teamID, channelID, chatID string) *clientmodels.Message {
@@ -1377,6 +1473,16 @@ func (tc *ClientImpl) GetUserAvatar(userID string) ([]byte, error) {
 	return photo, nil
 }
 
+// RevokeSignInSessions invalidates the refresh tokens issued to the user, so they can no longer be
+// used to act on behalf of the user.
+func (tc *ClientImpl) RevokeSignInSessions(userID string) error {
+	if _, err := tc.client.Users().ByUserId(userID).RevokeSignInSessions().PostAsRevokeSignInSessionsPostResponse(tc.ctx, nil); err != nil {
+		return NormalizeGraphAPIError(err)
+	}
+
+	return nil
+}
+
 func (tc *ClientImpl) GetUser(userID string) (*clientmodels.User, error) {
 	requestParameters := &users.UserItemRequestBuilderGetQueryParameters{
 		Select: []string{"displayName", "id", "mail", "userPrincipalName", "userType"},
@@ -1539,7 +1645,7 @@ func (tc *ClientImpl) GetFileContentStream(downloadURL string, writer *io.PipeWr
 
 		contentRange := fmt.Sprintf("bytes=%d-%d", rangeStart, rangeStart+rangeIncrement-1)
 		req.Header.Add("Range", contentRange)
-		res, err := http.DefaultClient.Do(req)
+		res, err := httpClient(tc.transport).Do(req)
 		if err != nil {
 			tc.logService.Error("unable to send request for getting file content", "error", err.Error())
 			return
@@ -1622,6 +1728,40 @@ func GetResourceIds(resource string) clientmodels.ActivityIds {
 	return result
 }
 
+var presenceResourceUserIDRE = regexp.MustCompile(`'([^']+)'`)
+
+// GetPresenceUserID returns the user whose presence changed, given the resource of a presence
+// change notification, such as communications/presences('<id>').
+func GetPresenceUserID(resource string) (string, bool) {
+	resource = strings.TrimPrefix(resource, "/")
+	if !strings.HasPrefix(strings.ToLower(resource), "communications/presences") {
+		return "", false
+	}
+
+	match := presenceResourceUserIDRE.FindStringSubmatch(resource)
+	if match == nil {
+		return "", false
+	}
+
+	return match[1], true
+}
+
+// GetPresenceSubscriptionUserIDs returns the users a presence subscription covers, given its
+// resource, or nil if it isn't a presence subscription.
+func GetPresenceSubscriptionUserIDs(resource string) []string {
+	resource = strings.TrimPrefix(resource, "/")
+	if !strings.HasPrefix(strings.ToLower(resource), "communications/presences") {
+		return nil
+	}
+
+	var userIDs []string
+	for _, match := range presenceResourceUserIDRE.FindAllStringSubmatch(resource, -1) {
+		userIDs = append(userIDs, match[1])
+	}
+
+	return userIDs
+}
+
 func (tc *ClientImpl) CreateOrGetChatForUsers(userIDs []string) (*clientmodels.Chat, error) {
 	if len(userIDs) == 2 {
 		return tc.CreateChat(models.ONEONONE_CHATTYPE, userIDs)
@@ -1676,7 +1816,7 @@ func (tc *ClientImpl) CreateChat(chatType models.ChatType, userIDs []string) (*c
 		odataType := "#microsoft.graph.aadUserConversationMember"
 		conversationMember.SetOdataType(&odataType)
 		conversationMember.SetAdditionalData(map[string]interface{}{
-			"user@odata.bind": "https://graph.microsoft.com/v1.0/users('" + userID + "')",
+			"user@odata.bind": tc.cloud.GraphBaseURL() + "/users('" + userID + "')",
 		})
 		conversationMember.SetRoles([]string{"owner"})
 
@@ -2150,17 +2290,25 @@ func (tc *ClientImpl) SendBatchRequestAndGetMessage(batchRequest msgraphcore.Bat
 }
 
 func (tc *ClientImpl) GetPresencesForUsers(userIDs []string) (map[string]clientmodels.Presence, error) {
-	body := communications.NewGetPresencesByUserIdPostRequestBody()
-	body.SetIds(userIDs)
+	presences := make(map[string]clientmodels.Presence, len(userIDs))
 
-	res, err := tc.client.Communications().GetPresencesByUserId().PostAsGetPresencesByUserIdPostResponse(context.Background(), body, nil)
-	if err != nil {
-		return nil, NormalizeGraphAPIError(err)
+	// Microsoft Graph limits the number of users looked up at once.
+	for chunk := range slices.Chunk(userIDs, MaxPresenceUserIDs) {
+		body := communications.NewGetPresencesByUserIdPostRequestBody()
+		body.SetIds(chunk)
+
+		res, err := tc.client.Communications().GetPresencesByUserId().PostAsGetPresencesByUserIdPostResponse(context.Background(), body, nil)
+		if err != nil {
+			return nil, NormalizeGraphAPIError(err)
+		}
+
+		addPresences(presences, res.GetValue())
 	}
 
-	rawPresences := res.GetValue()
-	presences := make(map[string]clientmodels.Presence, len(rawPresences))
+	return presences, nil
+}
 
+func addPresences(presences map[string]clientmodels.Presence, rawPresences []models.Presenceable) {
 	for _, rawPresence := range rawPresences {
 		var presence clientmodels.Presence
 
@@ -2178,21 +2326,10 @@ func (tc *ClientImpl) GetPresencesForUsers(userIDs []string) (map[string]clientm
 
 		presences[presence.UserID] = presence
 	}
-
-	return presences, nil
 }
 
-func GetAuthURL(redirectURL string, tenantID string, clientID string, clientSecret string, state string, codeVerifier string) string {
-	conf := &oauth2.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		Scopes:       append(TeamsDefaultScopes, "offline_access"),
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/authorize", tenantID),
-			TokenURL: fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", tenantID),
-		},
-		RedirectURL: redirectURL,
-	}
+func GetAuthURL(cloud Cloud, redirectURL string, tenantID string, clientID string, clientSecret string, state string, codeVerifier string) string {
+	conf := newOAuth2Config(cloud, redirectURL, tenantID, clientID, clientSecret)
 
 	sha2 := sha256.New()
 	_, _ = io.WriteString(sha2, codeVerifier)
@@ -0,0 +1,334 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client_breakerlayer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+)
+
+const (
+	// window is the period over which the error rate of a method family is computed.
+	window = time.Minute
+	// minRequests avoids opening the circuit over the few requests of a quiet period.
+	minRequests = 20
+	// OpenDuration is how long the requests fail fast once the circuit opened, before probing
+	// Microsoft again.
+	OpenDuration = 30 * time.Second
+)
+
+// State is the state of the circuit breaker of a method family.
+type State int
+
+const (
+	// StateClosed lets the requests through.
+	StateClosed State = iota
+	// StateHalfOpen lets a single request through to probe whether Microsoft recovered.
+	StateHalfOpen
+	// StateOpen fails the requests fast.
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+const familyOther = "other"
+
+// methodFamilies groups the client methods by the Microsoft Graph resources they use, which tend
+// to fail together during incidents.
+var methodFamilies = map[string]string{
+	"CreateOrGetChatForUsers": "chats",
+	"DeleteChatMessage":       "chats",
+	"GetChat":                 "chats",
+	"GetChatMessage":          "chats",
+	"ListChatMessages":        "chats",
+	"SendBatch":               "chats",
+	"SendChat":                "chats",
+	"SetChatReaction":         "chats",
+	"UnsetChatReaction":       "chats",
+	"UpdateChatMessage":       "chats",
+
+	"DeleteMessage":              "channels",
+	"GetChannelInTeam":           "channels",
+	"GetChannelsInTeam":          "channels",
+	"GetMessage":                 "channels",
+	"GetReply":                   "channels",
+	"GetTeam":                    "channels",
+	"GetTeams":                   "channels",
+	"ListChannelMessages":        "channels",
+	"ListChannels":               "channels",
+	"ListTeams":                  "channels",
+	"SendMessage":                "channels",
+	"SendMessageWithAttachments": "channels",
+	"SetReaction":                "channels",
+	"UnsetReaction":              "channels",
+	"UpdateMessage":              "channels",
+
+	"GetPresencesForUsers": "presence",
+
+	"GetMe":                "users",
+	"GetMyID":              "users",
+	"GetUser":              "users",
+	"GetUserAvatar":        "users",
+	"ListUsers":            "users",
+	"RevokeSignInSessions": "users",
+
+	"GetCodeSnippet":            "files",
+	"GetFileContent":            "files",
+	"GetFileSizeAndDownloadURL": "files",
+	"GetHostedFileContent":      "files",
+	"UploadFile":                "files",
+
+	"DeleteSubscription":          "subscriptions",
+	"ListSubscriptions":           "subscriptions",
+	"RefreshPresenceSubscription": "subscriptions",
+	"RefreshSubscription":         "subscriptions",
+	"SubscribeToChannel":          "subscriptions",
+	"SubscribeToChannels":         "subscriptions",
+	"SubscribeToChats":            "subscriptions",
+	"SubscribeToPresences":        "subscriptions",
+	"SubscribeToUserChats":        "subscriptions",
+
+	"Connect":      "auth",
+	"RefreshToken": "auth",
+}
+
+func methodFamily(method string) string {
+	if family, ok := methodFamilies[method]; ok {
+		return family
+	}
+
+	return familyOther
+}
+
+// CircuitOpenError is returned, without calling Microsoft, while the circuit of the method
+// family is open.
+type CircuitOpenError struct {
+	Family  string
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open for the %s requests until %s", e.Family, e.RetryAt.Format(time.RFC3339))
+}
+
+// IsCircuitOpen reports whether the error comes from an open circuit breaker.
+func IsCircuitOpen(err error) bool {
+	var circuitOpenErr *CircuitOpenError
+	return errors.As(err, &circuitOpenErr)
+}
+
+// BreakerStatus describes the circuit breaker of a method family.
+type BreakerStatus struct {
+	Family   string     `json:"family"`
+	State    State      `json:"state"`
+	Requests int        `json:"requests"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+// Breakers holds the circuit breakers of the method families, shared by all the clients.
+type Breakers struct {
+	metrics metrics.Metrics
+
+	// now is overridden by tests.
+	now func() time.Time
+
+	lock      sync.Mutex
+	errorRate int
+	breakers  map[string]*breaker
+}
+
+type breaker struct {
+	state       State
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+}
+
+// NewBreakers creates the circuit breakers, opening once the given percentage of the requests
+// of a method family fail. An error rate of zero disables them.
+func NewBreakers(metrics metrics.Metrics, errorRate int) *Breakers {
+	return &Breakers{
+		metrics:   metrics,
+		now:       time.Now,
+		errorRate: errorRate,
+		breakers:  make(map[string]*breaker),
+	}
+}
+
+// SetErrorRate changes the percentage of failed requests opening the circuits, closing them all.
+func (b *Breakers) SetErrorRate(errorRate int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.errorRate = errorRate
+	for family, br := range b.breakers {
+		*br = breaker{windowStart: b.now()}
+		b.metrics.ObserveMSGraphCircuitBreakerState(family, int(StateClosed))
+	}
+}
+
+// Do makes the request unless the circuit of its method family is open.
+func (b *Breakers) Do(method string, request func() error) error {
+	family := methodFamily(method)
+	probe, err := b.allow(family)
+	if err != nil {
+		return err
+	}
+
+	err = request()
+	b.record(family, probe, isFailure(err))
+
+	return err
+}
+
+// Status returns the state of the circuit breakers of the method families used so far.
+func (b *Breakers) Status() []BreakerStatus {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(b.breakers))
+	for family, br := range b.breakers {
+		status := BreakerStatus{
+			Family:   family,
+			State:    br.state,
+			Requests: br.requests,
+			Failures: br.failures,
+		}
+		if br.state != StateClosed {
+			openedAt := br.openedAt
+			status.OpenedAt = &openedAt
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Family < statuses[j].Family
+	})
+
+	return statuses
+}
+
+// allow reports whether the request may be made, and whether it's the one probing Microsoft while
+// the circuit is half-open.
+func (b *Breakers) allow(family string) (bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.errorRate <= 0 {
+		return false, nil
+	}
+
+	now := b.now()
+	br := b.breaker(family, now)
+	switch br.state {
+	case StateOpen:
+		retryAt := br.openedAt.Add(OpenDuration)
+		if now.Before(retryAt) {
+			return false, &CircuitOpenError{Family: family, RetryAt: retryAt}
+		}
+		b.setState(family, br, StateHalfOpen)
+		br.probing = true
+		return true, nil
+	case StateHalfOpen:
+		// Only one request probes Microsoft at a time.
+		if br.probing {
+			return false, &CircuitOpenError{Family: family, RetryAt: now.Add(OpenDuration)}
+		}
+		br.probing = true
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// record accounts for the outcome of the request. While the circuit is half-open, only the probe's
+// outcome closes or opens it again: requests let through before it opened are ignored.
+func (b *Breakers) record(family string, probe, failed bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.errorRate <= 0 {
+		return
+	}
+
+	now := b.now()
+	br := b.breaker(family, now)
+	switch br.state {
+	case StateHalfOpen:
+		if !probe {
+			return
+		}
+		br.probing = false
+		if failed {
+			br.openedAt = now
+			b.setState(family, br, StateOpen)
+			return
+		}
+		*br = breaker{windowStart: now}
+		b.setState(family, br, StateClosed)
+	case StateClosed:
+		if now.Sub(br.windowStart) > window {
+			br.windowStart = now
+			br.requests = 0
+			br.failures = 0
+		}
+		br.requests++
+		if failed {
+			br.failures++
+		}
+		if br.requests >= minRequests && br.failures*100 >= b.errorRate*br.requests {
+			br.openedAt = now
+			b.setState(family, br, StateOpen)
+		}
+	}
+}
+
+// breaker returns the circuit breaker of the method family, starting closed. The lock must be held.
+func (b *Breakers) breaker(family string, now time.Time) *breaker {
+	br, ok := b.breakers[family]
+	if !ok {
+		br = &breaker{windowStart: now}
+		b.breakers[family] = br
+	}
+
+	return br
+}
+
+func (b *Breakers) setState(family string, br *breaker, state State) {
+	br.state = state
+	b.metrics.ObserveMSGraphCircuitBreakerState(family, int(state))
+}
+
+// isFailure reports whether the error is a sign of Microsoft being unavailable, as opposed to an
+// error specific to the request.
+func isFailure(err error) bool {
+	var graphErr *msteams.GraphAPIError
+	if err == nil || !errors.As(err, &graphErr) {
+		return false
+	}
+
+	return graphErr.StatusCode == 0 || graphErr.StatusCode == http.StatusTooManyRequests || graphErr.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client_breakerlayer
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	metricsmocks "github.com/mattermost/mattermost-plugin-msteams/server/metrics/mocks"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+	clientmocks "github.com/mattermost/mattermost-plugin-msteams/server/msteams/mocks"
+)
+
+var errUnavailable = &msteams.GraphAPIError{StatusCode: http.StatusServiceUnavailable}
+
+// newTestBreakers returns circuit breakers on a fake clock, along with the function advancing it.
+func newTestBreakers(t *testing.T, errorRate int) (*Breakers, *metricsmocks.Metrics, func(time.Duration)) {
+	t.Helper()
+
+	mockMetrics := &metricsmocks.Metrics{}
+	mockMetrics.On("ObserveMSGraphCircuitBreakerState", mock.Anything, mock.Anything).Maybe()
+
+	now := time.Now()
+	breakers := NewBreakers(mockMetrics, errorRate)
+	breakers.now = func() time.Time { return now }
+
+	return breakers, mockMetrics, func(duration time.Duration) {
+		now = now.Add(duration)
+	}
+}
+
+// fail makes the given number of requests of the method, all failing with the given error.
+func fail(t *testing.T, breakers *Breakers, method string, count int, err error) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		require.Equal(t, err, breakers.Do(method, func() error { return err }))
+	}
+}
+
+func TestBreakers(t *testing.T) {
+	t.Run("opens at the error rate", func(t *testing.T) {
+		breakers, mockMetrics, _ := newTestBreakers(t, 50)
+
+		fail(t, breakers, "GetChat", minRequests/2-1, errUnavailable)
+		for i := 0; i < minRequests/2; i++ {
+			require.NoError(t, breakers.Do("GetChatMessage", func() error { return nil }))
+		}
+		assert.Equal(t, StateClosed, breakers.Status()[0].State)
+
+		fail(t, breakers, "GetChat", 1, errUnavailable)
+		assert.Equal(t, StateOpen, breakers.Status()[0].State)
+		mockMetrics.AssertCalled(t, "ObserveMSGraphCircuitBreakerState", "chats", int(StateOpen))
+
+		called := false
+		err := breakers.Do("GetChatMessage", func() error {
+			called = true
+			return nil
+		})
+		assert.False(t, called)
+		assert.True(t, IsCircuitOpen(err))
+
+		var circuitOpenErr *CircuitOpenError
+		require.True(t, errors.As(err, &circuitOpenErr))
+		assert.Equal(t, "chats", circuitOpenErr.Family)
+
+		// Other method families aren't affected.
+		require.NoError(t, breakers.Do("GetPresencesForUsers", func() error { return nil }))
+	})
+
+	t.Run("needs enough requests", func(t *testing.T) {
+		breakers, _, _ := newTestBreakers(t, 50)
+
+		fail(t, breakers, "GetChat", minRequests-1, errUnavailable)
+		assert.Equal(t, StateClosed, breakers.Status()[0].State)
+	})
+
+	t.Run("errors specific to the request", func(t *testing.T) {
+		breakers, _, _ := newTestBreakers(t, 50)
+
+		fail(t, breakers, "GetChat", minRequests, &msteams.GraphAPIError{StatusCode: http.StatusNotFound})
+		fail(t, breakers, "GetChat", minRequests, errors.New("invalid chat"))
+
+		status := breakers.Status()[0]
+		assert.Equal(t, StateClosed, status.State)
+		assert.Equal(t, 2*minRequests, status.Requests)
+		assert.Zero(t, status.Failures)
+	})
+
+	t.Run("window", func(t *testing.T) {
+		breakers, _, advance := newTestBreakers(t, 50)
+
+		fail(t, breakers, "GetChat", minRequests-1, errUnavailable)
+		advance(window + time.Second)
+		fail(t, breakers, "GetChat", 1, errUnavailable)
+
+		status := breakers.Status()[0]
+		assert.Equal(t, StateClosed, status.State)
+		assert.Equal(t, 1, status.Requests)
+	})
+
+	t.Run("half open probe", func(t *testing.T) {
+		breakers, _, advance := newTestBreakers(t, 50)
+
+		fail(t, breakers, "GetChat", minRequests, errUnavailable)
+		require.Equal(t, StateOpen, breakers.Status()[0].State)
+
+		advance(OpenDuration)
+
+		// A single request probes Microsoft, the others keep failing fast meanwhile.
+		err := breakers.Do("GetChat", func() error {
+			assert.Equal(t, StateHalfOpen, breakers.Status()[0].State)
+			assert.True(t, IsCircuitOpen(breakers.Do("GetChat", func() error { return nil })))
+			return errUnavailable
+		})
+		assert.Equal(t, errUnavailable, err)
+		assert.Equal(t, StateOpen, breakers.Status()[0].State)
+		assert.True(t, IsCircuitOpen(breakers.Do("GetChat", func() error { return nil })))
+
+		advance(OpenDuration)
+		require.NoError(t, breakers.Do("GetChat", func() error { return nil }))
+
+		status := breakers.Status()[0]
+		assert.Equal(t, StateClosed, status.State)
+		assert.Zero(t, status.Requests)
+		assert.Nil(t, status.OpenedAt)
+	})
+
+	t.Run("requests made before the probe", func(t *testing.T) {
+		breakers, _, advance := newTestBreakers(t, 50)
+
+		// A slow request is made while closed, and completes while the circuit is half-open.
+		probe, err := breakers.allow("chats")
+		require.NoError(t, err)
+		require.False(t, probe)
+
+		fail(t, breakers, "GetChat", minRequests, errUnavailable)
+		advance(OpenDuration)
+
+		probe, err = breakers.allow("chats")
+		require.NoError(t, err)
+		require.True(t, probe)
+
+		// Its result neither closes the circuit nor lets another request probe.
+		breakers.record("chats", false, false)
+		assert.Equal(t, StateHalfOpen, breakers.Status()[0].State)
+		assert.True(t, IsCircuitOpen(breakers.Do("GetChat", func() error { return nil })))
+
+		breakers.record("chats", true, true)
+		assert.Equal(t, StateOpen, breakers.Status()[0].State)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		breakers, _, _ := newTestBreakers(t, 0)
+
+		fail(t, breakers, "GetChat", 2*minRequests, errUnavailable)
+		assert.Empty(t, breakers.Status())
+	})
+
+	t.Run("set error rate", func(t *testing.T) {
+		breakers, _, _ := newTestBreakers(t, 50)
+
+		fail(t, breakers, "GetChat", minRequests, errUnavailable)
+		require.Equal(t, StateOpen, breakers.Status()[0].State)
+
+		breakers.SetErrorRate(100)
+		assert.Equal(t, StateClosed, breakers.Status()[0].State)
+		require.NoError(t, breakers.Do("GetChat", func() error { return nil }))
+	})
+}
+
+func TestClientBreakerLayer(t *testing.T) {
+	breakers, _, _ := newTestBreakers(t, 50)
+
+	mockClient := &clientmocks.Client{}
+	mockClient.On("GetChat", "chat-id").Return(nil, errUnavailable).Times(minRequests)
+	mockClient.On("GetPresencesForUsers", []string{"user-id"}).Return(map[string]clientmodels.Presence{}, nil).Once()
+
+	client := New(mockClient, breakers)
+	for i := 0; i < minRequests; i++ {
+		_, err := client.GetChat("chat-id")
+		require.Equal(t, errUnavailable, err)
+	}
+
+	_, err := client.GetChat("chat-id")
+	assert.True(t, IsCircuitOpen(err))
+
+	_, err = client.GetPresencesForUsers([]string{"user-id"})
+	require.NoError(t, err)
+
+	mockClient.AssertExpectations(t)
+}
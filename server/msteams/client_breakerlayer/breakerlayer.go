@@ -0,0 +1,497 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Code generated by "make generate"
+// DO NOT EDIT
+
+package client_breakerlayer
+
+import (
+	"io"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"golang.org/x/oauth2"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+type ClientBreakerLayer struct {
+	msteams.Client
+	breakers *Breakers
+}
+
+func (c *ClientBreakerLayer) Connect() error {
+	return c.breakers.Do("Connect", func() error {
+		return c.Client.Connect()
+	})
+}
+
+func (c *ClientBreakerLayer) CreateOrGetChatForUsers(usersIDs []string) (*clientmodels.Chat, error) {
+	var result *clientmodels.Chat
+	err := c.breakers.Do("CreateOrGetChatForUsers", func() error {
+		var err error
+		result, err = c.Client.CreateOrGetChatForUsers(usersIDs)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) DeleteChatMessage(userID string, chatID string, msgID string) error {
+	return c.breakers.Do("DeleteChatMessage", func() error {
+		return c.Client.DeleteChatMessage(userID, chatID, msgID)
+	})
+}
+
+func (c *ClientBreakerLayer) DeleteMessage(teamID string, channelID string, parentID string, msgID string) error {
+	return c.breakers.Do("DeleteMessage", func() error {
+		return c.Client.DeleteMessage(teamID, channelID, parentID, msgID)
+	})
+}
+
+func (c *ClientBreakerLayer) DeleteSubscription(subscriptionID string) error {
+	return c.breakers.Do("DeleteSubscription", func() error {
+		return c.Client.DeleteSubscription(subscriptionID)
+	})
+}
+
+func (c *ClientBreakerLayer) GetApp(applicationID string) (*clientmodels.App, error) {
+	var result *clientmodels.App
+	err := c.breakers.Do("GetApp", func() error {
+		var err error
+		result, err = c.Client.GetApp(applicationID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetChannelInTeam(teamID string, channelID string) (*clientmodels.Channel, error) {
+	var result *clientmodels.Channel
+	err := c.breakers.Do("GetChannelInTeam", func() error {
+		var err error
+		result, err = c.Client.GetChannelInTeam(teamID, channelID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetChannelsInTeam(teamID string, filterQuery string) ([]*clientmodels.Channel, error) {
+	var result []*clientmodels.Channel
+	err := c.breakers.Do("GetChannelsInTeam", func() error {
+		var err error
+		result, err = c.Client.GetChannelsInTeam(teamID, filterQuery)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetChat(chatID string) (*clientmodels.Chat, error) {
+	var result *clientmodels.Chat
+	err := c.breakers.Do("GetChat", func() error {
+		var err error
+		result, err = c.Client.GetChat(chatID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetChatMessage(chatID string, messageID string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("GetChatMessage", func() error {
+		var err error
+		result, err = c.Client.GetChatMessage(chatID, messageID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetCodeSnippet(url string) (string, error) {
+	var result string
+	err := c.breakers.Do("GetCodeSnippet", func() error {
+		var err error
+		result, err = c.Client.GetCodeSnippet(url)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetFileContent(downloadURL string) ([]byte, error) {
+	var result []byte
+	err := c.breakers.Do("GetFileContent", func() error {
+		var err error
+		result, err = c.Client.GetFileContent(downloadURL)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetFileSizeAndDownloadURL(weburl string) (int64, string, error) {
+	var result int64
+	var resultVar1 string
+	err := c.breakers.Do("GetFileSizeAndDownloadURL", func() error {
+		var err error
+		result, resultVar1, err = c.Client.GetFileSizeAndDownloadURL(weburl)
+		return err
+	})
+	return result, resultVar1, err
+}
+
+func (c *ClientBreakerLayer) GetHostedFileContent(activityIDs *clientmodels.ActivityIds) ([]byte, error) {
+	var result []byte
+	err := c.breakers.Do("GetHostedFileContent", func() error {
+		var err error
+		result, err = c.Client.GetHostedFileContent(activityIDs)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetMe() (*clientmodels.User, error) {
+	var result *clientmodels.User
+	err := c.breakers.Do("GetMe", func() error {
+		var err error
+		result, err = c.Client.GetMe()
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetMessage(teamID string, channelID string, messageID string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("GetMessage", func() error {
+		var err error
+		result, err = c.Client.GetMessage(teamID, channelID, messageID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetMyID() (string, error) {
+	var result string
+	err := c.breakers.Do("GetMyID", func() error {
+		var err error
+		result, err = c.Client.GetMyID()
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetPresencesForUsers(userIDs []string) (map[string]clientmodels.Presence, error) {
+	var result map[string]clientmodels.Presence
+	err := c.breakers.Do("GetPresencesForUsers", func() error {
+		var err error
+		result, err = c.Client.GetPresencesForUsers(userIDs)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetReply(teamID string, channelID string, messageID string, replyID string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("GetReply", func() error {
+		var err error
+		result, err = c.Client.GetReply(teamID, channelID, messageID, replyID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetTeam(teamID string) (*clientmodels.Team, error) {
+	var result *clientmodels.Team
+	err := c.breakers.Do("GetTeam", func() error {
+		var err error
+		result, err = c.Client.GetTeam(teamID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetTeams(filterQuery string) ([]*clientmodels.Team, error) {
+	var result []*clientmodels.Team
+	err := c.breakers.Do("GetTeams", func() error {
+		var err error
+		result, err = c.Client.GetTeams(filterQuery)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetUser(userID string) (*clientmodels.User, error) {
+	var result *clientmodels.User
+	err := c.breakers.Do("GetUser", func() error {
+		var err error
+		result, err = c.Client.GetUser(userID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) GetUserAvatar(userID string) ([]byte, error) {
+	var result []byte
+	err := c.breakers.Do("GetUserAvatar", func() error {
+		var err error
+		result, err = c.Client.GetUserAvatar(userID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) ListChannelMessages(teamID string, channelID string, since time.Time) ([]*clientmodels.Message, error) {
+	var result []*clientmodels.Message
+	err := c.breakers.Do("ListChannelMessages", func() error {
+		var err error
+		result, err = c.Client.ListChannelMessages(teamID, channelID, since)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) ListChannels(teamID string) ([]clientmodels.Channel, error) {
+	var result []clientmodels.Channel
+	err := c.breakers.Do("ListChannels", func() error {
+		var err error
+		result, err = c.Client.ListChannels(teamID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) ListChatMessages(chatID string, since time.Time) ([]*clientmodels.Message, error) {
+	var result []*clientmodels.Message
+	err := c.breakers.Do("ListChatMessages", func() error {
+		var err error
+		result, err = c.Client.ListChatMessages(chatID, since)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) ListSubscriptions() ([]*clientmodels.Subscription, error) {
+	var result []*clientmodels.Subscription
+	err := c.breakers.Do("ListSubscriptions", func() error {
+		var err error
+		result, err = c.Client.ListSubscriptions()
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) ListTeams() ([]clientmodels.Team, error) {
+	var result []clientmodels.Team
+	err := c.breakers.Do("ListTeams", func() error {
+		var err error
+		result, err = c.Client.ListTeams()
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) ListUsers() ([]clientmodels.User, error) {
+	var result []clientmodels.User
+	err := c.breakers.Do("ListUsers", func() error {
+		var err error
+		result, err = c.Client.ListUsers()
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) RefreshPresenceSubscription(subscriptionID string) (*time.Time, error) {
+	var result *time.Time
+	err := c.breakers.Do("RefreshPresenceSubscription", func() error {
+		var err error
+		result, err = c.Client.RefreshPresenceSubscription(subscriptionID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) RefreshSubscription(subscriptionID string) (*time.Time, error) {
+	var result *time.Time
+	err := c.breakers.Do("RefreshSubscription", func() error {
+		var err error
+		result, err = c.Client.RefreshSubscription(subscriptionID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) RefreshToken(token *oauth2.Token) (*oauth2.Token, error) {
+	var result *oauth2.Token
+	err := c.breakers.Do("RefreshToken", func() error {
+		var err error
+		result, err = c.Client.RefreshToken(token)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) RevokeSignInSessions(userID string) error {
+	return c.breakers.Do("RevokeSignInSessions", func() error {
+		return c.Client.RevokeSignInSessions(userID)
+	})
+}
+
+func (c *ClientBreakerLayer) SendBatch(batch *msteams.Batch) error {
+	return c.breakers.Do("SendBatch", func() error {
+		return c.Client.SendBatch(batch)
+	})
+}
+
+func (c *ClientBreakerLayer) SendChat(chatID string, message string, parentMessage *clientmodels.Message, attachments []*clientmodels.Attachment, mentions []models.ChatMessageMentionable) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("SendChat", func() error {
+		var err error
+		result, err = c.Client.SendChat(chatID, message, parentMessage, attachments, mentions)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) SendMessage(teamID string, channelID string, parentID string, message string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("SendMessage", func() error {
+		var err error
+		result, err = c.Client.SendMessage(teamID, channelID, parentID, message)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) SendMessageWithAttachments(teamID string, channelID string, parentID string, message string, attachments []*clientmodels.Attachment, mentions []models.ChatMessageMentionable) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("SendMessageWithAttachments", func() error {
+		var err error
+		result, err = c.Client.SendMessageWithAttachments(teamID, channelID, parentID, message, attachments, mentions)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) SetChatReaction(chatID string, messageID string, userID string, emoji string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("SetChatReaction", func() error {
+		var err error
+		result, err = c.Client.SetChatReaction(chatID, messageID, userID, emoji)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) SetReaction(teamID string, channelID string, parentID string, messageID string, userID string, emoji string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("SetReaction", func() error {
+		var err error
+		result, err = c.Client.SetReaction(teamID, channelID, parentID, messageID, userID, emoji)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) SubscribeToChannel(teamID string, channelID string, baseURL string, webhookSecret string, certificate string) (*clientmodels.Subscription, error) {
+	var result *clientmodels.Subscription
+	err := c.breakers.Do("SubscribeToChannel", func() error {
+		var err error
+		result, err = c.Client.SubscribeToChannel(teamID, channelID, baseURL, webhookSecret, certificate)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) SubscribeToChannels(baseURL string, webhookSecret string, pay bool, certificate string) (*clientmodels.Subscription, error) {
+	var result *clientmodels.Subscription
+	err := c.breakers.Do("SubscribeToChannels", func() error {
+		var err error
+		result, err = c.Client.SubscribeToChannels(baseURL, webhookSecret, pay, certificate)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) SubscribeToChats(baseURL string, webhookSecret string, pay bool, certificate string) (*clientmodels.Subscription, error) {
+	var result *clientmodels.Subscription
+	err := c.breakers.Do("SubscribeToChats", func() error {
+		var err error
+		result, err = c.Client.SubscribeToChats(baseURL, webhookSecret, pay, certificate)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) SubscribeToPresences(userIDs []string, baseURL string, webhookSecret string) (*clientmodels.Subscription, error) {
+	var result *clientmodels.Subscription
+	err := c.breakers.Do("SubscribeToPresences", func() error {
+		var err error
+		result, err = c.Client.SubscribeToPresences(userIDs, baseURL, webhookSecret)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) SubscribeToUserChats(user string, baseURL string, webhookSecret string, pay bool, certificate string) (*clientmodels.Subscription, error) {
+	var result *clientmodels.Subscription
+	err := c.breakers.Do("SubscribeToUserChats", func() error {
+		var err error
+		result, err = c.Client.SubscribeToUserChats(user, baseURL, webhookSecret, pay, certificate)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) UnsetChatReaction(chatID string, messageID string, userID string, emoji string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("UnsetChatReaction", func() error {
+		var err error
+		result, err = c.Client.UnsetChatReaction(chatID, messageID, userID, emoji)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) UnsetReaction(teamID string, channelID string, parentID string, messageID string, userID string, emoji string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("UnsetReaction", func() error {
+		var err error
+		result, err = c.Client.UnsetReaction(teamID, channelID, parentID, messageID, userID, emoji)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) UpdateChatMessage(chatID string, msgID string, message string, mentions []models.ChatMessageMentionable) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("UpdateChatMessage", func() error {
+		var err error
+		result, err = c.Client.UpdateChatMessage(chatID, msgID, message, mentions)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) UpdateMessage(teamID string, channelID string, parentID string, msgID string, message string, mentions []models.ChatMessageMentionable) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.breakers.Do("UpdateMessage", func() error {
+		var err error
+		result, err = c.Client.UpdateMessage(teamID, channelID, parentID, msgID, message, mentions)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientBreakerLayer) UploadFile(teamID string, channelID string, filename string, filesize int, mimeType string, data io.Reader, chat *clientmodels.Chat) (*clientmodels.Attachment, error) {
+	var result *clientmodels.Attachment
+	err := c.breakers.Do("UploadFile", func() error {
+		var err error
+		result, err = c.Client.UploadFile(teamID, channelID, filename, filesize, mimeType, data, chat)
+		return err
+	})
+	return result, err
+}
+
+func New(childClient msteams.Client, breakers *Breakers) *ClientBreakerLayer {
+	return &ClientBreakerLayer{
+		Client:   childClient,
+		breakers: breakers,
+	}
+}
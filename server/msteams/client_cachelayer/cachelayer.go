@@ -0,0 +1,90 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client_cachelayer
+
+import (
+	"slices"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+const chatCacheName = "chats"
+
+// ChatCache keeps the recently fetched chats, along with their members, on this server. Entries
+// expire quickly, as chats changed on other servers, or without notification, are only fetched
+// again once expired.
+type ChatCache struct {
+	metrics metrics.Metrics
+	chats   *expirable.LRU[string, *clientmodels.Chat]
+}
+
+// NewChatCache creates a cache holding up to size chats, each for the given time.
+func NewChatCache(metrics metrics.Metrics, size int, ttl time.Duration) *ChatCache {
+	return &ChatCache{
+		metrics: metrics,
+		chats:   expirable.NewLRU[string, *clientmodels.Chat](size, nil, ttl),
+	}
+}
+
+// Invalidate forgets the chat, e.g. when its members changed.
+func (c *ChatCache) Invalidate(chatID string) {
+	c.chats.Remove(chatID)
+}
+
+// Get returns a copy of the cached chat, if any.
+func (c *ChatCache) Get(chatID string) (*clientmodels.Chat, bool) {
+	chat, ok := c.chats.Get(chatID)
+	c.metrics.ObserveClientCacheRequest(chatCacheName, ok)
+	if !ok {
+		return nil, false
+	}
+
+	return copyChat(chat), true
+}
+
+// Add caches a copy of the chat, replacing any previous entry.
+func (c *ChatCache) Add(chatID string, chat *clientmodels.Chat) {
+	c.chats.Add(chatID, copyChat(chat))
+}
+
+// copyChat copies the chat, so that callers modifying it don't affect the cache.
+func copyChat(chat *clientmodels.Chat) *clientmodels.Chat {
+	chatCopy := *chat
+	chatCopy.Members = slices.Clone(chat.Members)
+	return &chatCopy
+}
+
+// ClientCacheLayer serves the chats from the cache, fetching them with the wrapped client on misses.
+type ClientCacheLayer struct {
+	msteams.Client
+	chats *ChatCache
+}
+
+func (c *ClientCacheLayer) GetChat(chatID string) (*clientmodels.Chat, error) {
+	if chat, ok := c.chats.Get(chatID); ok {
+		return chat, nil
+	}
+
+	chat, err := c.Client.GetChat(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat != nil {
+		c.chats.Add(chatID, chat)
+	}
+
+	return chat, nil
+}
+
+func New(childClient msteams.Client, chats *ChatCache) *ClientCacheLayer {
+	return &ClientCacheLayer{
+		Client: childClient,
+		chats:  chats,
+	}
+}
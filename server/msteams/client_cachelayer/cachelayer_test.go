@@ -0,0 +1,120 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client_cachelayer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metricsmocks "github.com/mattermost/mattermost-plugin-msteams/server/metrics/mocks"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+	clientmocks "github.com/mattermost/mattermost-plugin-msteams/server/msteams/mocks"
+)
+
+func newTestChat(chatID string) *clientmodels.Chat {
+	return &clientmodels.Chat{
+		ID:   chatID,
+		Type: "G",
+		Members: []clientmodels.ChatMember{
+			{UserID: "user-1", DisplayName: "User 1"},
+			{UserID: "user-2", DisplayName: "User 2"},
+		},
+	}
+}
+
+func TestClientCacheLayerGetChat(t *testing.T) {
+	setup := func(t *testing.T, size int, ttl time.Duration) (*ClientCacheLayer, *ChatCache, *clientmocks.Client, *metricsmocks.Metrics) {
+		t.Helper()
+
+		mockClient := &clientmocks.Client{}
+		mockMetrics := &metricsmocks.Metrics{}
+		mockMetrics.On("ObserveClientCacheRequest", chatCacheName, true)
+		mockMetrics.On("ObserveClientCacheRequest", chatCacheName, false)
+		t.Cleanup(func() {
+			mockClient.AssertExpectations(t)
+		})
+
+		cache := NewChatCache(mockMetrics, size, ttl)
+		return New(mockClient, cache), cache, mockClient, mockMetrics
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		client, _, mockClient, mockMetrics := setup(t, 10, time.Minute)
+		mockClient.On("GetChat", "chat-id").Return(newTestChat("chat-id"), nil).Once()
+
+		chat, err := client.GetChat("chat-id")
+		require.NoError(t, err)
+		assert.Equal(t, newTestChat("chat-id"), chat)
+
+		// Modifying the returned chat doesn't affect the cache.
+		chat.Members[0].DisplayName = "Changed"
+		chat.Members = append(chat.Members, clientmodels.ChatMember{UserID: "user-3"})
+
+		chat, err = client.GetChat("chat-id")
+		require.NoError(t, err)
+		assert.Equal(t, newTestChat("chat-id"), chat)
+
+		mockMetrics.AssertNumberOfCalls(t, "ObserveClientCacheRequest", 2)
+		mockMetrics.AssertCalled(t, "ObserveClientCacheRequest", chatCacheName, true)
+		mockMetrics.AssertCalled(t, "ObserveClientCacheRequest", chatCacheName, false)
+	})
+
+	t.Run("errors not cached", func(t *testing.T) {
+		client, _, mockClient, _ := setup(t, 10, time.Minute)
+		mockClient.On("GetChat", "chat-id").Return(nil, errors.New("unavailable")).Once()
+		mockClient.On("GetChat", "chat-id").Return(newTestChat("chat-id"), nil).Once()
+
+		_, err := client.GetChat("chat-id")
+		require.Error(t, err)
+
+		chat, err := client.GetChat("chat-id")
+		require.NoError(t, err)
+		assert.Equal(t, "chat-id", chat.ID)
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		client, cache, mockClient, _ := setup(t, 10, time.Minute)
+		mockClient.On("GetChat", "chat-id").Return(newTestChat("chat-id"), nil).Twice()
+
+		_, err := client.GetChat("chat-id")
+		require.NoError(t, err)
+
+		cache.Invalidate("chat-id")
+
+		_, err = client.GetChat("chat-id")
+		require.NoError(t, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		client, _, mockClient, _ := setup(t, 10, 10*time.Millisecond)
+		mockClient.On("GetChat", "chat-id").Return(newTestChat("chat-id"), nil).Twice()
+
+		_, err := client.GetChat("chat-id")
+		require.NoError(t, err)
+
+		time.Sleep(20 * time.Millisecond)
+
+		_, err = client.GetChat("chat-id")
+		require.NoError(t, err)
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		client, _, mockClient, _ := setup(t, 1, time.Minute)
+		mockClient.On("GetChat", "chat-id-1").Return(newTestChat("chat-id-1"), nil).Twice()
+		mockClient.On("GetChat", "chat-id-2").Return(newTestChat("chat-id-2"), nil).Once()
+
+		_, err := client.GetChat("chat-id-1")
+		require.NoError(t, err)
+		_, err = client.GetChat("chat-id-2")
+		require.NoError(t, err)
+
+		// The oldest chat was evicted to make room for the other.
+		_, err = client.GetChat("chat-id-1")
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client_cachelayer
+
+import (
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+const presenceCacheName = "presences"
+
+// PresenceCache keeps the presence of the connected users on this server. Entries are expected to
+// be invalidated as the presence subscriptions notify of changes, expiring meanwhile in case some
+// notifications are missed.
+type PresenceCache struct {
+	metrics   metrics.Metrics
+	presences *expirable.LRU[string, clientmodels.Presence]
+}
+
+// NewPresenceCache creates a cache holding the presence of up to size users, each for the given
+// time.
+func NewPresenceCache(metrics metrics.Metrics, size int, ttl time.Duration) *PresenceCache {
+	return &PresenceCache{
+		metrics:   metrics,
+		presences: expirable.NewLRU[string, clientmodels.Presence](size, nil, ttl),
+	}
+}
+
+// Get returns the cached presences of the given users, along with the users missing from the cache.
+func (c *PresenceCache) Get(userIDs []string) (map[string]clientmodels.Presence, []string) {
+	presences := make(map[string]clientmodels.Presence, len(userIDs))
+	var missing []string
+	for _, userID := range userIDs {
+		presence, ok := c.presences.Get(userID)
+		c.metrics.ObserveClientCacheRequest(presenceCacheName, ok)
+		if !ok {
+			missing = append(missing, userID)
+			continue
+		}
+
+		presences[userID] = presence
+	}
+
+	return presences, missing
+}
+
+// Add caches the given presences, replacing any previous presence of the same users.
+func (c *PresenceCache) Add(presences ...clientmodels.Presence) {
+	for _, presence := range presences {
+		if presence.UserID == "" {
+			continue
+		}
+		c.presences.Add(presence.UserID, presence)
+	}
+}
+
+// Invalidate forgets the presence of the given users, e.g. when notified of a change.
+func (c *PresenceCache) Invalidate(userIDs ...string) {
+	for _, userID := range userIDs {
+		c.presences.Remove(userID)
+	}
+}
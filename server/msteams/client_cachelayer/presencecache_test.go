@@ -0,0 +1,83 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client_cachelayer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	metricsmocks "github.com/mattermost/mattermost-plugin-msteams/server/metrics/mocks"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+func TestPresenceCache(t *testing.T) {
+	setup := func(t *testing.T, size int, ttl time.Duration) (*PresenceCache, *metricsmocks.Metrics) {
+		t.Helper()
+
+		mockMetrics := &metricsmocks.Metrics{}
+		mockMetrics.On("ObserveClientCacheRequest", presenceCacheName, true)
+		mockMetrics.On("ObserveClientCacheRequest", presenceCacheName, false)
+
+		return NewPresenceCache(mockMetrics, size, ttl), mockMetrics
+	}
+
+	available := clientmodels.Presence{UserID: "user-1", Activity: "Available", Availability: "Available"}
+	away := clientmodels.Presence{UserID: "user-2", Activity: "Away", Availability: "Away"}
+
+	t.Run("get", func(t *testing.T) {
+		cache, mockMetrics := setup(t, 10, time.Minute)
+		cache.Add(available, away, clientmodels.Presence{})
+
+		presences, missing := cache.Get([]string{"user-1", "user-2", "user-3"})
+		assert.Equal(t, map[string]clientmodels.Presence{"user-1": available, "user-2": away}, presences)
+		assert.Equal(t, []string{"user-3"}, missing)
+
+		mockMetrics.AssertNumberOfCalls(t, "ObserveClientCacheRequest", 3)
+	})
+
+	t.Run("replaced", func(t *testing.T) {
+		cache, _ := setup(t, 10, time.Minute)
+		cache.Add(available)
+
+		offline := clientmodels.Presence{UserID: "user-1", Activity: "Offline", Availability: "Offline"}
+		cache.Add(offline)
+
+		presences, missing := cache.Get([]string{"user-1"})
+		assert.Equal(t, offline, presences["user-1"])
+		assert.Empty(t, missing)
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		cache, _ := setup(t, 10, time.Minute)
+		cache.Add(available, away)
+
+		cache.Invalidate("user-1")
+
+		presences, missing := cache.Get([]string{"user-1", "user-2"})
+		assert.Equal(t, map[string]clientmodels.Presence{"user-2": away}, presences)
+		assert.Equal(t, []string{"user-1"}, missing)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		cache, _ := setup(t, 10, 10*time.Millisecond)
+		cache.Add(available)
+
+		time.Sleep(20 * time.Millisecond)
+
+		presences, missing := cache.Get([]string{"user-1"})
+		assert.Empty(t, presences)
+		assert.Equal(t, []string{"user-1"}, missing)
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		cache, _ := setup(t, 1, time.Minute)
+		cache.Add(available, away)
+
+		// The oldest presence was evicted to make room for the other.
+		_, missing := cache.Get([]string{"user-1", "user-2"})
+		assert.Equal(t, []string{"user-1"}, missing)
+	})
+}
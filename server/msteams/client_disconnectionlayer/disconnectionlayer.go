@@ -344,6 +344,17 @@ func (c *ClientDisconnectionLayer) ListUsers() ([]clientmodels.User, error) {
 	return result, err
 }
 
+func (c *ClientDisconnectionLayer) RefreshPresenceSubscription(subscriptionID string) (*time.Time, error) {
+	result, err := c.Client.RefreshPresenceSubscription(subscriptionID)
+	if err != nil {
+		var graphErr *msteams.GraphAPIError
+		if msteams.IsOAuthError(err) || (errors.As(err, &graphErr) && graphErr.StatusCode == http.StatusUnauthorized) {
+			c.onDisconnect(c.userID)
+		}
+	}
+	return result, err
+}
+
 func (c *ClientDisconnectionLayer) RefreshSubscription(subscriptionID string) (*time.Time, error) {
 	result, err := c.Client.RefreshSubscription(subscriptionID)
 	if err != nil {
@@ -366,6 +377,28 @@ func (c *ClientDisconnectionLayer) RefreshToken(token *oauth2.Token) (*oauth2.To
 	return result, err
 }
 
+func (c *ClientDisconnectionLayer) RevokeSignInSessions(userID string) error {
+	err := c.Client.RevokeSignInSessions(userID)
+	if err != nil {
+		var graphErr *msteams.GraphAPIError
+		if msteams.IsOAuthError(err) || (errors.As(err, &graphErr) && graphErr.StatusCode == http.StatusUnauthorized) {
+			c.onDisconnect(c.userID)
+		}
+	}
+	return err
+}
+
+func (c *ClientDisconnectionLayer) SendBatch(batch *msteams.Batch) error {
+	err := c.Client.SendBatch(batch)
+	if err != nil {
+		var graphErr *msteams.GraphAPIError
+		if msteams.IsOAuthError(err) || (errors.As(err, &graphErr) && graphErr.StatusCode == http.StatusUnauthorized) {
+			c.onDisconnect(c.userID)
+		}
+	}
+	return err
+}
+
 func (c *ClientDisconnectionLayer) SendChat(chatID string, message string, parentMessage *clientmodels.Message, attachments []*clientmodels.Attachment, mentions []models.ChatMessageMentionable) (*clientmodels.Message, error) {
 	result, err := c.Client.SendChat(chatID, message, parentMessage, attachments, mentions)
 	if err != nil {
@@ -454,6 +487,17 @@ func (c *ClientDisconnectionLayer) SubscribeToChats(baseURL string, webhookSecre
 	return result, err
 }
 
+func (c *ClientDisconnectionLayer) SubscribeToPresences(userIDs []string, baseURL string, webhookSecret string) (*clientmodels.Subscription, error) {
+	result, err := c.Client.SubscribeToPresences(userIDs, baseURL, webhookSecret)
+	if err != nil {
+		var graphErr *msteams.GraphAPIError
+		if msteams.IsOAuthError(err) || (errors.As(err, &graphErr) && graphErr.StatusCode == http.StatusUnauthorized) {
+			c.onDisconnect(c.userID)
+		}
+	}
+	return result, err
+}
+
 func (c *ClientDisconnectionLayer) SubscribeToUserChats(user string, baseURL string, webhookSecret string, pay bool, certificate string) (*clientmodels.Subscription, error) {
 	result, err := c.Client.SubscribeToUserChats(user, baseURL, webhookSecret, pay, certificate)
 	if err != nil {
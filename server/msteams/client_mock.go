@@ -15,7 +15,7 @@ import (
 	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
 )
 
-func getAuthClient() *http.Client {
+func getAuthClient(_ *http.Transport) *http.Client {
 	proxyAddress := "http://mockserver:1080"
 	proxyUrl, _ := url.Parse(proxyAddress)
 	// Setup proxy for the token credential from azidentity
@@ -28,7 +28,7 @@ func getAuthClient() *http.Client {
 	return authClient
 }
 
-func getHTTPClient() *http.Client {
+func getHTTPClient(_ *http.Transport) *http.Client {
 	proxyAddress := "http://mockserver:1080"
 	proxyUrl, _ := url.Parse(proxyAddress)
 
@@ -7,19 +7,32 @@ package msteams
 
 import (
 	"net/http"
+	"slices"
 
 	khttp "github.com/microsoft/kiota-http-go"
 	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
 	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
 )
 
-func getAuthClient() *http.Client {
-	return http.DefaultClient
+func getAuthClient(transport *http.Transport) *http.Client {
+	return httpClient(transport)
 }
 
-func getHTTPClient() *http.Client {
+func getHTTPClient(transport *http.Transport) *http.Client {
 	defaultClientOptions := msgraphsdk.GetDefaultClientOptions()
 	defaultMiddleWare := msgraphcore.GetDefaultMiddlewaresWithOptions(&defaultClientOptions)
 
-	return khttp.GetDefaultClient(defaultMiddleWare...)
+	// Throttled requests and gateway timeouts are retried by the client retry layer, which keeps
+	// track of the budget of the whole tenant, instead of the retry handler of each client.
+	defaultMiddleWare = slices.DeleteFunc(defaultMiddleWare, func(middleware khttp.Middleware) bool {
+		_, isRetryHandler := middleware.(*khttp.RetryHandler)
+		return isRetryHandler
+	})
+
+	client := khttp.GetDefaultClient(defaultMiddleWare...)
+	if transport != nil {
+		client.Transport = khttp.NewCustomTransportWithParentTransport(transport, defaultMiddleWare...)
+	}
+
+	return client
 }
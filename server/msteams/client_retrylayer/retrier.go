@@ -0,0 +1,191 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client_retrylayer
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+)
+
+const (
+	// defaultRequestsPerSecond and defaultBurst bound the requests made to Microsoft for each
+	// tenant, staying below the limits of the Teams APIs.
+	defaultRequestsPerSecond = 20
+	defaultBurst             = 40
+
+	defaultMaxRetries    = 4
+	defaultBaseDelay     = 500 * time.Millisecond
+	defaultMaxDelay      = 30 * time.Second
+	defaultMaxRetryAfter = 2 * time.Minute
+)
+
+// Retrier throttles the requests made to Microsoft by all the clients of a tenant, and retries
+// them when Microsoft throttles them or fails transiently.
+type Retrier struct {
+	metrics metrics.Metrics
+
+	requestsPerSecond float64
+	burst             float64
+	maxRetries        int
+	baseDelay         time.Duration
+	maxDelay          time.Duration
+	maxRetryAfter     time.Duration
+
+	// now and sleep are overridden by tests.
+	now   func() time.Time
+	sleep func(time.Duration)
+
+	lock    sync.Mutex
+	budgets map[string]*budget
+}
+
+// budget is the token bucket of the requests of a tenant, paused while Microsoft asks to back off.
+type budget struct {
+	tokens      float64
+	updatedAt   time.Time
+	pausedUntil time.Time
+}
+
+// NewRetrier creates the retrier shared by the clients, so that the budget of each tenant
+// accounts for all the requests made on its behalf.
+func NewRetrier(metrics metrics.Metrics) *Retrier {
+	return &Retrier{
+		metrics:           metrics,
+		requestsPerSecond: defaultRequestsPerSecond,
+		burst:             defaultBurst,
+		maxRetries:        defaultMaxRetries,
+		baseDelay:         defaultBaseDelay,
+		maxDelay:          defaultMaxDelay,
+		maxRetryAfter:     defaultMaxRetryAfter,
+		now:               time.Now,
+		sleep:             time.Sleep,
+		budgets:           make(map[string]*budget),
+	}
+}
+
+// Do makes the request within the budget of the tenant. When Microsoft throttles it, the whole
+// tenant backs off for the requested time. Requests rejected with a Retry-After are retried,
+// while idempotent requests are also retried on the other transient failures.
+func (r *Retrier) Do(tenantID, method string, idempotent bool, request func() error) error {
+	for attempt := 0; ; attempt++ {
+		if wait := r.reserve(tenantID); wait > 0 {
+			r.wait(method, wait)
+		}
+
+		err := request()
+		statusCode, retryAfter := retryableStatus(err)
+		if statusCode == 0 {
+			return err
+		}
+
+		if statusCode != http.StatusGatewayTimeout {
+			r.metrics.ObserveMSGraphThrottledRequest(method)
+			if retryAfter > 0 {
+				r.pause(tenantID, min(retryAfter, r.maxRetryAfter))
+			}
+		}
+
+		if attempt >= r.maxRetries || retryAfter > r.maxRetryAfter {
+			return err
+		}
+
+		// Microsoft doesn't process the requests it throttles with a Retry-After, so any of them
+		// can be made again once the pause of the tenant is over.
+		if statusCode == http.StatusTooManyRequests && retryAfter > 0 {
+			continue
+		}
+
+		// Other failures might have been processed anyway, so only reads are retried.
+		if !idempotent {
+			return err
+		}
+
+		// The next reservation waits for the pause of the tenant, if Microsoft asked for one.
+		if retryAfter == 0 {
+			r.wait(method, r.backoff(attempt))
+		}
+	}
+}
+
+// reserve takes a request from the budget of the tenant, returning how long to wait before making it.
+func (r *Retrier) reserve(tenantID string) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	b := r.budget(tenantID, now)
+	b.tokens = min(r.burst, b.tokens+now.Sub(b.updatedAt).Seconds()*r.requestsPerSecond)
+	b.updatedAt = now
+	b.tokens--
+
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / r.requestsPerSecond * float64(time.Second))
+	}
+	if pause := b.pausedUntil.Sub(now); pause > wait {
+		wait = pause
+	}
+
+	return wait
+}
+
+// pause holds the requests of the tenant for the given time.
+func (r *Retrier) pause(tenantID string, duration time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.now()
+	b := r.budget(tenantID, now)
+	if until := now.Add(duration); until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
+
+// budget returns the budget of the tenant, starting full. The lock must be held.
+func (r *Retrier) budget(tenantID string, now time.Time) *budget {
+	b, ok := r.budgets[tenantID]
+	if !ok {
+		b = &budget{tokens: r.burst, updatedAt: now}
+		r.budgets[tenantID] = b
+	}
+
+	return b
+}
+
+// backoff returns the jittered exponential delay before the given retry.
+func (r *Retrier) backoff(attempt int) time.Duration {
+	delay := min(r.baseDelay<<attempt, r.maxDelay)
+
+	// Jitter spreads the retries of the goroutines throttled at the same time.
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)) //#nosec G404 -- jitter doesn't need a secure random source
+}
+
+func (r *Retrier) wait(method string, duration time.Duration) {
+	r.sleep(duration)
+	r.metrics.ObserveMSGraphThrottlingWait(method, duration.Seconds())
+}
+
+// retryableStatus returns the status code of the transient failures worth retrying, along with
+// how long Microsoft asked to wait, or zero if the request can't be retried.
+func retryableStatus(err error) (int, time.Duration) {
+	var graphErr *msteams.GraphAPIError
+	if err == nil || !errors.As(err, &graphErr) {
+		return 0, 0
+	}
+
+	switch graphErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return graphErr.StatusCode, graphErr.RetryAfter
+	case http.StatusGatewayTimeout:
+		return graphErr.StatusCode, 0
+	default:
+		return 0, 0
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client_retrylayer
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	metricsmocks "github.com/mattermost/mattermost-plugin-msteams/server/metrics/mocks"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+	clientmocks "github.com/mattermost/mattermost-plugin-msteams/server/msteams/mocks"
+)
+
+// newTestRetrier returns a retrier on a fake clock, advanced by the waits instead of sleeping.
+func newTestRetrier(t *testing.T) (*Retrier, *metricsmocks.Metrics, *[]time.Duration) {
+	t.Helper()
+
+	mockMetrics := &metricsmocks.Metrics{}
+	mockMetrics.On("ObserveMSGraphThrottledRequest", mock.Anything).Maybe()
+	mockMetrics.On("ObserveMSGraphThrottlingWait", mock.Anything, mock.Anything).Maybe()
+
+	now := time.Now()
+	waits := []time.Duration{}
+
+	retrier := NewRetrier(mockMetrics)
+	retrier.now = func() time.Time { return now }
+	retrier.sleep = func(duration time.Duration) {
+		waits = append(waits, duration)
+		now = now.Add(duration)
+	}
+
+	return retrier, mockMetrics, &waits
+}
+
+func throttledError(statusCode int, retryAfter time.Duration) error {
+	return &msteams.GraphAPIError{StatusCode: statusCode, RetryAfter: retryAfter}
+}
+
+func TestRetrierDo(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		retrier, mockMetrics, waits := newTestRetrier(t)
+
+		calls := 0
+		err := retrier.Do("tenant-id", "Client.GetChat", true, func() error {
+			calls++
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Empty(t, *waits)
+		mockMetrics.AssertNotCalled(t, "ObserveMSGraphThrottledRequest", mock.Anything)
+	})
+
+	t.Run("not throttled error", func(t *testing.T) {
+		retrier, _, waits := newTestRetrier(t)
+
+		calls := 0
+		err := retrier.Do("tenant-id", "Client.GetChat", true, func() error {
+			calls++
+			return &msteams.GraphAPIError{StatusCode: http.StatusNotFound}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Empty(t, *waits)
+	})
+
+	t.Run("idempotent request retried with backoff", func(t *testing.T) {
+		retrier, mockMetrics, waits := newTestRetrier(t)
+
+		calls := 0
+		err := retrier.Do("tenant-id", "Client.GetChat", true, func() error {
+			calls++
+			if calls < 3 {
+				return throttledError(http.StatusServiceUnavailable, 0)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		require.Len(t, *waits, 2)
+		assert.GreaterOrEqual(t, (*waits)[0], defaultBaseDelay/2)
+		assert.LessOrEqual(t, (*waits)[0], defaultBaseDelay)
+		assert.GreaterOrEqual(t, (*waits)[1], defaultBaseDelay)
+		assert.LessOrEqual(t, (*waits)[1], 2*defaultBaseDelay)
+		mockMetrics.AssertNumberOfCalls(t, "ObserveMSGraphThrottledRequest", 2)
+		mockMetrics.AssertNumberOfCalls(t, "ObserveMSGraphThrottlingWait", 2)
+	})
+
+	t.Run("retry after", func(t *testing.T) {
+		retrier, _, waits := newTestRetrier(t)
+
+		calls := 0
+		err := retrier.Do("tenant-id", "Client.GetChat", true, func() error {
+			calls++
+			if calls == 1 {
+				return throttledError(http.StatusTooManyRequests, 10*time.Second)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, []time.Duration{10 * time.Second}, *waits)
+	})
+
+	t.Run("gives up after the max retries", func(t *testing.T) {
+		retrier, _, _ := newTestRetrier(t)
+
+		calls := 0
+		err := retrier.Do("tenant-id", "Client.GetChat", true, func() error {
+			calls++
+			return throttledError(http.StatusTooManyRequests, time.Second)
+		})
+		var graphErr *msteams.GraphAPIError
+		require.True(t, errors.As(err, &graphErr))
+		assert.Equal(t, http.StatusTooManyRequests, graphErr.StatusCode)
+		assert.Equal(t, defaultMaxRetries+1, calls)
+	})
+
+	t.Run("retry after too long", func(t *testing.T) {
+		retrier, _, waits := newTestRetrier(t)
+
+		calls := 0
+		err := retrier.Do("tenant-id", "Client.GetChat", true, func() error {
+			calls++
+			return throttledError(http.StatusTooManyRequests, time.Hour)
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Empty(t, *waits)
+
+		// The tenant is paused for the longest supported time only.
+		assert.Equal(t, defaultMaxRetryAfter, retrier.reserve("tenant-id"))
+	})
+
+	t.Run("non idempotent request retried after", func(t *testing.T) {
+		retrier, mockMetrics, waits := newTestRetrier(t)
+
+		calls := 0
+		err := retrier.Do("tenant-id", "Client.SendChat", false, func() error {
+			calls++
+			if calls == 1 {
+				return throttledError(http.StatusTooManyRequests, 5*time.Second)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, []time.Duration{5 * time.Second}, *waits)
+		mockMetrics.AssertCalled(t, "ObserveMSGraphThrottledRequest", "Client.SendChat")
+	})
+
+	t.Run("non idempotent request not retried without retry after", func(t *testing.T) {
+		for _, statusCode := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+			retrier, _, waits := newTestRetrier(t)
+
+			calls := 0
+			err := retrier.Do("tenant-id", "Client.SendChat", false, func() error {
+				calls++
+				return throttledError(statusCode, 0)
+			})
+			assert.Error(t, err)
+			assert.Equal(t, 1, calls)
+			assert.Empty(t, *waits)
+		}
+	})
+
+	t.Run("non idempotent request not retried when unavailable", func(t *testing.T) {
+		retrier, _, waits := newTestRetrier(t)
+
+		calls := 0
+		err := retrier.Do("tenant-id", "Client.SendChat", false, func() error {
+			calls++
+			return throttledError(http.StatusServiceUnavailable, 5*time.Second)
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+		assert.Empty(t, *waits)
+
+		// The following requests of the tenant still wait for Microsoft.
+		require.NoError(t, retrier.Do("tenant-id", "Client.GetChat", true, func() error { return nil }))
+		assert.Equal(t, []time.Duration{5 * time.Second}, *waits)
+
+		// Other tenants aren't affected.
+		require.NoError(t, retrier.Do("other-tenant-id", "Client.GetChat", true, func() error { return nil }))
+		assert.Len(t, *waits, 1)
+	})
+
+	t.Run("gateway timeout retried without pausing the tenant", func(t *testing.T) {
+		retrier, mockMetrics, waits := newTestRetrier(t)
+
+		calls := 0
+		err := retrier.Do("tenant-id", "Client.GetChat", true, func() error {
+			calls++
+			if calls == 1 {
+				return throttledError(http.StatusGatewayTimeout, 10*time.Second)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		require.Len(t, *waits, 1)
+		assert.LessOrEqual(t, (*waits)[0], defaultBaseDelay)
+		mockMetrics.AssertNotCalled(t, "ObserveMSGraphThrottledRequest", mock.Anything)
+		assert.Zero(t, retrier.reserve("tenant-id"))
+	})
+}
+
+func TestRetrierBudget(t *testing.T) {
+	retrier, _, waits := newTestRetrier(t)
+
+	for i := 0; i < defaultBurst; i++ {
+		assert.Zero(t, retrier.reserve("tenant-id"))
+	}
+	assert.Equal(t, time.Second/defaultRequestsPerSecond, retrier.reserve("tenant-id"))
+	assert.Equal(t, 2*time.Second/defaultRequestsPerSecond, retrier.reserve("tenant-id"))
+	assert.Zero(t, retrier.reserve("other-tenant-id"))
+
+	require.NoError(t, retrier.Do("tenant-id", "Client.GetChat", true, func() error { return nil }))
+	assert.Equal(t, []time.Duration{3 * time.Second / defaultRequestsPerSecond}, *waits)
+
+	// The budget refills over time.
+	retrier.sleep(time.Second)
+	assert.Zero(t, retrier.reserve("tenant-id"))
+}
+
+func TestClientRetryLayer(t *testing.T) {
+	retrier, _, waits := newTestRetrier(t)
+
+	mockClient := &clientmocks.Client{}
+	mockClient.On("GetChat", "chat-id").Return(nil, throttledError(http.StatusTooManyRequests, 2*time.Second)).Once()
+	mockClient.On("GetChat", "chat-id").Return(&clientmodels.Chat{ID: "chat-id"}, nil).Once()
+	mockClient.On("DeleteChatMessage", "user-id", "chat-id", "message-id").Return(throttledError(http.StatusServiceUnavailable, 0)).Once()
+
+	client := New(mockClient, "tenant-id", retrier)
+
+	chat, err := client.GetChat("chat-id")
+	require.NoError(t, err)
+	assert.Equal(t, "chat-id", chat.ID)
+	assert.Equal(t, []time.Duration{2 * time.Second}, *waits)
+
+	assert.Error(t, client.DeleteChatMessage("user-id", "chat-id", "message-id"))
+	mockClient.AssertExpectations(t)
+}
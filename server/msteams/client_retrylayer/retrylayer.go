@@ -0,0 +1,499 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Code generated by "make generate"
+// DO NOT EDIT
+
+package client_retrylayer
+
+import (
+	"io"
+	"time"
+
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"golang.org/x/oauth2"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
+)
+
+type ClientRetryLayer struct {
+	msteams.Client
+	tenantID string
+	retrier  *Retrier
+}
+
+func (c *ClientRetryLayer) Connect() error {
+	return c.retrier.Do(c.tenantID, "Client.Connect", false, func() error {
+		return c.Client.Connect()
+	})
+}
+
+func (c *ClientRetryLayer) CreateOrGetChatForUsers(usersIDs []string) (*clientmodels.Chat, error) {
+	var result *clientmodels.Chat
+	err := c.retrier.Do(c.tenantID, "Client.CreateOrGetChatForUsers", false, func() error {
+		var err error
+		result, err = c.Client.CreateOrGetChatForUsers(usersIDs)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) DeleteChatMessage(userID string, chatID string, msgID string) error {
+	return c.retrier.Do(c.tenantID, "Client.DeleteChatMessage", false, func() error {
+		return c.Client.DeleteChatMessage(userID, chatID, msgID)
+	})
+}
+
+func (c *ClientRetryLayer) DeleteMessage(teamID string, channelID string, parentID string, msgID string) error {
+	return c.retrier.Do(c.tenantID, "Client.DeleteMessage", false, func() error {
+		return c.Client.DeleteMessage(teamID, channelID, parentID, msgID)
+	})
+}
+
+func (c *ClientRetryLayer) DeleteSubscription(subscriptionID string) error {
+	return c.retrier.Do(c.tenantID, "Client.DeleteSubscription", false, func() error {
+		return c.Client.DeleteSubscription(subscriptionID)
+	})
+}
+
+func (c *ClientRetryLayer) GetApp(applicationID string) (*clientmodels.App, error) {
+	var result *clientmodels.App
+	err := c.retrier.Do(c.tenantID, "Client.GetApp", true, func() error {
+		var err error
+		result, err = c.Client.GetApp(applicationID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetChannelInTeam(teamID string, channelID string) (*clientmodels.Channel, error) {
+	var result *clientmodels.Channel
+	err := c.retrier.Do(c.tenantID, "Client.GetChannelInTeam", true, func() error {
+		var err error
+		result, err = c.Client.GetChannelInTeam(teamID, channelID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetChannelsInTeam(teamID string, filterQuery string) ([]*clientmodels.Channel, error) {
+	var result []*clientmodels.Channel
+	err := c.retrier.Do(c.tenantID, "Client.GetChannelsInTeam", true, func() error {
+		var err error
+		result, err = c.Client.GetChannelsInTeam(teamID, filterQuery)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetChat(chatID string) (*clientmodels.Chat, error) {
+	var result *clientmodels.Chat
+	err := c.retrier.Do(c.tenantID, "Client.GetChat", true, func() error {
+		var err error
+		result, err = c.Client.GetChat(chatID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetChatMessage(chatID string, messageID string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.GetChatMessage", true, func() error {
+		var err error
+		result, err = c.Client.GetChatMessage(chatID, messageID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetCodeSnippet(url string) (string, error) {
+	var result string
+	err := c.retrier.Do(c.tenantID, "Client.GetCodeSnippet", true, func() error {
+		var err error
+		result, err = c.Client.GetCodeSnippet(url)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetFileContent(downloadURL string) ([]byte, error) {
+	var result []byte
+	err := c.retrier.Do(c.tenantID, "Client.GetFileContent", true, func() error {
+		var err error
+		result, err = c.Client.GetFileContent(downloadURL)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetFileSizeAndDownloadURL(weburl string) (int64, string, error) {
+	var result int64
+	var resultVar1 string
+	err := c.retrier.Do(c.tenantID, "Client.GetFileSizeAndDownloadURL", true, func() error {
+		var err error
+		result, resultVar1, err = c.Client.GetFileSizeAndDownloadURL(weburl)
+		return err
+	})
+	return result, resultVar1, err
+}
+
+func (c *ClientRetryLayer) GetHostedFileContent(activityIDs *clientmodels.ActivityIds) ([]byte, error) {
+	var result []byte
+	err := c.retrier.Do(c.tenantID, "Client.GetHostedFileContent", true, func() error {
+		var err error
+		result, err = c.Client.GetHostedFileContent(activityIDs)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetMe() (*clientmodels.User, error) {
+	var result *clientmodels.User
+	err := c.retrier.Do(c.tenantID, "Client.GetMe", true, func() error {
+		var err error
+		result, err = c.Client.GetMe()
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetMessage(teamID string, channelID string, messageID string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.GetMessage", true, func() error {
+		var err error
+		result, err = c.Client.GetMessage(teamID, channelID, messageID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetMyID() (string, error) {
+	var result string
+	err := c.retrier.Do(c.tenantID, "Client.GetMyID", true, func() error {
+		var err error
+		result, err = c.Client.GetMyID()
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetPresencesForUsers(userIDs []string) (map[string]clientmodels.Presence, error) {
+	var result map[string]clientmodels.Presence
+	err := c.retrier.Do(c.tenantID, "Client.GetPresencesForUsers", true, func() error {
+		var err error
+		result, err = c.Client.GetPresencesForUsers(userIDs)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetReply(teamID string, channelID string, messageID string, replyID string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.GetReply", true, func() error {
+		var err error
+		result, err = c.Client.GetReply(teamID, channelID, messageID, replyID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetTeam(teamID string) (*clientmodels.Team, error) {
+	var result *clientmodels.Team
+	err := c.retrier.Do(c.tenantID, "Client.GetTeam", true, func() error {
+		var err error
+		result, err = c.Client.GetTeam(teamID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetTeams(filterQuery string) ([]*clientmodels.Team, error) {
+	var result []*clientmodels.Team
+	err := c.retrier.Do(c.tenantID, "Client.GetTeams", true, func() error {
+		var err error
+		result, err = c.Client.GetTeams(filterQuery)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetUser(userID string) (*clientmodels.User, error) {
+	var result *clientmodels.User
+	err := c.retrier.Do(c.tenantID, "Client.GetUser", true, func() error {
+		var err error
+		result, err = c.Client.GetUser(userID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) GetUserAvatar(userID string) ([]byte, error) {
+	var result []byte
+	err := c.retrier.Do(c.tenantID, "Client.GetUserAvatar", true, func() error {
+		var err error
+		result, err = c.Client.GetUserAvatar(userID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) ListChannelMessages(teamID string, channelID string, since time.Time) ([]*clientmodels.Message, error) {
+	var result []*clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.ListChannelMessages", true, func() error {
+		var err error
+		result, err = c.Client.ListChannelMessages(teamID, channelID, since)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) ListChannels(teamID string) ([]clientmodels.Channel, error) {
+	var result []clientmodels.Channel
+	err := c.retrier.Do(c.tenantID, "Client.ListChannels", true, func() error {
+		var err error
+		result, err = c.Client.ListChannels(teamID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) ListChatMessages(chatID string, since time.Time) ([]*clientmodels.Message, error) {
+	var result []*clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.ListChatMessages", true, func() error {
+		var err error
+		result, err = c.Client.ListChatMessages(chatID, since)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) ListSubscriptions() ([]*clientmodels.Subscription, error) {
+	var result []*clientmodels.Subscription
+	err := c.retrier.Do(c.tenantID, "Client.ListSubscriptions", true, func() error {
+		var err error
+		result, err = c.Client.ListSubscriptions()
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) ListTeams() ([]clientmodels.Team, error) {
+	var result []clientmodels.Team
+	err := c.retrier.Do(c.tenantID, "Client.ListTeams", true, func() error {
+		var err error
+		result, err = c.Client.ListTeams()
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) ListUsers() ([]clientmodels.User, error) {
+	var result []clientmodels.User
+	err := c.retrier.Do(c.tenantID, "Client.ListUsers", true, func() error {
+		var err error
+		result, err = c.Client.ListUsers()
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) RefreshPresenceSubscription(subscriptionID string) (*time.Time, error) {
+	var result *time.Time
+	err := c.retrier.Do(c.tenantID, "Client.RefreshPresenceSubscription", false, func() error {
+		var err error
+		result, err = c.Client.RefreshPresenceSubscription(subscriptionID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) RefreshSubscription(subscriptionID string) (*time.Time, error) {
+	var result *time.Time
+	err := c.retrier.Do(c.tenantID, "Client.RefreshSubscription", false, func() error {
+		var err error
+		result, err = c.Client.RefreshSubscription(subscriptionID)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) RefreshToken(token *oauth2.Token) (*oauth2.Token, error) {
+	var result *oauth2.Token
+	err := c.retrier.Do(c.tenantID, "Client.RefreshToken", false, func() error {
+		var err error
+		result, err = c.Client.RefreshToken(token)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) RevokeSignInSessions(userID string) error {
+	return c.retrier.Do(c.tenantID, "Client.RevokeSignInSessions", false, func() error {
+		return c.Client.RevokeSignInSessions(userID)
+	})
+}
+
+func (c *ClientRetryLayer) SendBatch(batch *msteams.Batch) error {
+	return c.retrier.Do(c.tenantID, "Client.SendBatch", true, func() error {
+		return c.Client.SendBatch(batch)
+	})
+}
+
+func (c *ClientRetryLayer) SendChat(chatID string, message string, parentMessage *clientmodels.Message, attachments []*clientmodels.Attachment, mentions []models.ChatMessageMentionable) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.SendChat", false, func() error {
+		var err error
+		result, err = c.Client.SendChat(chatID, message, parentMessage, attachments, mentions)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) SendMessage(teamID string, channelID string, parentID string, message string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.SendMessage", false, func() error {
+		var err error
+		result, err = c.Client.SendMessage(teamID, channelID, parentID, message)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) SendMessageWithAttachments(teamID string, channelID string, parentID string, message string, attachments []*clientmodels.Attachment, mentions []models.ChatMessageMentionable) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.SendMessageWithAttachments", false, func() error {
+		var err error
+		result, err = c.Client.SendMessageWithAttachments(teamID, channelID, parentID, message, attachments, mentions)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) SetChatReaction(chatID string, messageID string, userID string, emoji string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.SetChatReaction", false, func() error {
+		var err error
+		result, err = c.Client.SetChatReaction(chatID, messageID, userID, emoji)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) SetReaction(teamID string, channelID string, parentID string, messageID string, userID string, emoji string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.SetReaction", false, func() error {
+		var err error
+		result, err = c.Client.SetReaction(teamID, channelID, parentID, messageID, userID, emoji)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) SubscribeToChannel(teamID string, channelID string, baseURL string, webhookSecret string, certificate string) (*clientmodels.Subscription, error) {
+	var result *clientmodels.Subscription
+	err := c.retrier.Do(c.tenantID, "Client.SubscribeToChannel", false, func() error {
+		var err error
+		result, err = c.Client.SubscribeToChannel(teamID, channelID, baseURL, webhookSecret, certificate)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) SubscribeToChannels(baseURL string, webhookSecret string, pay bool, certificate string) (*clientmodels.Subscription, error) {
+	var result *clientmodels.Subscription
+	err := c.retrier.Do(c.tenantID, "Client.SubscribeToChannels", false, func() error {
+		var err error
+		result, err = c.Client.SubscribeToChannels(baseURL, webhookSecret, pay, certificate)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) SubscribeToChats(baseURL string, webhookSecret string, pay bool, certificate string) (*clientmodels.Subscription, error) {
+	var result *clientmodels.Subscription
+	err := c.retrier.Do(c.tenantID, "Client.SubscribeToChats", false, func() error {
+		var err error
+		result, err = c.Client.SubscribeToChats(baseURL, webhookSecret, pay, certificate)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) SubscribeToPresences(userIDs []string, baseURL string, webhookSecret string) (*clientmodels.Subscription, error) {
+	var result *clientmodels.Subscription
+	err := c.retrier.Do(c.tenantID, "Client.SubscribeToPresences", false, func() error {
+		var err error
+		result, err = c.Client.SubscribeToPresences(userIDs, baseURL, webhookSecret)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) SubscribeToUserChats(user string, baseURL string, webhookSecret string, pay bool, certificate string) (*clientmodels.Subscription, error) {
+	var result *clientmodels.Subscription
+	err := c.retrier.Do(c.tenantID, "Client.SubscribeToUserChats", false, func() error {
+		var err error
+		result, err = c.Client.SubscribeToUserChats(user, baseURL, webhookSecret, pay, certificate)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) UnsetChatReaction(chatID string, messageID string, userID string, emoji string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.UnsetChatReaction", false, func() error {
+		var err error
+		result, err = c.Client.UnsetChatReaction(chatID, messageID, userID, emoji)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) UnsetReaction(teamID string, channelID string, parentID string, messageID string, userID string, emoji string) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.UnsetReaction", false, func() error {
+		var err error
+		result, err = c.Client.UnsetReaction(teamID, channelID, parentID, messageID, userID, emoji)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) UpdateChatMessage(chatID string, msgID string, message string, mentions []models.ChatMessageMentionable) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.UpdateChatMessage", false, func() error {
+		var err error
+		result, err = c.Client.UpdateChatMessage(chatID, msgID, message, mentions)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) UpdateMessage(teamID string, channelID string, parentID string, msgID string, message string, mentions []models.ChatMessageMentionable) (*clientmodels.Message, error) {
+	var result *clientmodels.Message
+	err := c.retrier.Do(c.tenantID, "Client.UpdateMessage", false, func() error {
+		var err error
+		result, err = c.Client.UpdateMessage(teamID, channelID, parentID, msgID, message, mentions)
+		return err
+	})
+	return result, err
+}
+
+func (c *ClientRetryLayer) UploadFile(teamID string, channelID string, filename string, filesize int, mimeType string, data io.Reader, chat *clientmodels.Chat) (*clientmodels.Attachment, error) {
+	var result *clientmodels.Attachment
+	err := c.retrier.Do(c.tenantID, "Client.UploadFile", false, func() error {
+		var err error
+		result, err = c.Client.UploadFile(teamID, channelID, filename, filesize, mimeType, data, chat)
+		return err
+	})
+	return result, err
+}
+
+func New(childClient msteams.Client, tenantID string, retrier *Retrier) *ClientRetryLayer {
+	return &ClientRetryLayer{
+		Client:   childClient,
+		tenantID: tenantID,
+		retrier:  retrier,
+	}
+}
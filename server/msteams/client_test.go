@@ -4,12 +4,17 @@
 package msteams
 
 import (
+	"errors"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
+	abstractions "github.com/microsoft/kiota-abstractions-go"
 	"github.com/microsoftgraph/msgraph-sdk-go/models"
+	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
 )
@@ -203,3 +208,96 @@ func TestGetResourceIds(t *testing.T) {
 		})
 	}
 }
+
+func TestGetPresenceUserID(t *testing.T) {
+	for _, test := range []struct {
+		Name           string
+		Resource       string
+		ExpectedUserID string
+		ExpectedOK     bool
+	}{
+		{
+			Name:           "presence resource",
+			Resource:       "communications/presences('bc07c8b3-8e83-4e52-a4b2-4b8c2c3ef2a4')",
+			ExpectedUserID: "bc07c8b3-8e83-4e52-a4b2-4b8c2c3ef2a4",
+			ExpectedOK:     true,
+		},
+		{
+			Name:           "presence resource with leading slash",
+			Resource:       "/communications/presences('bc07c8b3-8e83-4e52-a4b2-4b8c2c3ef2a4')",
+			ExpectedUserID: "bc07c8b3-8e83-4e52-a4b2-4b8c2c3ef2a4",
+			ExpectedOK:     true,
+		},
+		{
+			Name:     "presence resource without user",
+			Resource: "communications/presences",
+		},
+		{
+			Name:     "chat message resource",
+			Resource: "chats('19:8ea0e38b@unq.gbl.spaces')/messages('1612289765949')",
+		},
+		{
+			Name: "empty resource",
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			userID, ok := GetPresenceUserID(test.Resource)
+			assert.Equal(t, test.ExpectedOK, ok)
+			assert.Equal(t, test.ExpectedUserID, userID)
+		})
+	}
+}
+
+func TestGetPresenceSubscriptionUserIDs(t *testing.T) {
+	assert.Equal(t, []string{"user-1", "user-2"}, GetPresenceSubscriptionUserIDs("/communications/presences?$filter=id in ('user-1','user-2')"))
+	assert.Equal(t, []string{"user-1"}, GetPresenceSubscriptionUserIDs("communications/presences?$filter=id in ('user-1')"))
+	assert.Nil(t, GetPresenceSubscriptionUserIDs("chats/getAllMessages"))
+	assert.Nil(t, GetPresenceSubscriptionUserIDs("/users/user-1/chats/getAllMessages"))
+}
+
+func TestNormalizeGraphAPIError(t *testing.T) {
+	t.Run("throttled", func(t *testing.T) {
+		odataErr := odataerrors.NewODataError()
+		mainErr := odataerrors.NewMainError()
+		mainErr.SetCode(model.NewPointer("TooManyRequests"))
+		mainErr.SetMessage(model.NewPointer("Too many requests"))
+		odataErr.SetErrorEscaped(mainErr)
+		odataErr.SetStatusCode(http.StatusTooManyRequests)
+		headers := abstractions.NewResponseHeaders()
+		headers.Add("Retry-After", "12")
+		odataErr.SetResponseHeaders(headers)
+
+		var graphErr *GraphAPIError
+		require.True(t, errors.As(NormalizeGraphAPIError(odataErr), &graphErr))
+		assert.Equal(t, "TooManyRequests", graphErr.Code)
+		assert.Equal(t, http.StatusTooManyRequests, graphErr.StatusCode)
+		assert.Equal(t, 12*time.Second, graphErr.RetryAfter)
+	})
+
+	t.Run("unexpected status code", func(t *testing.T) {
+		apiErr := &abstractions.ApiError{Message: "no body", ResponseStatusCode: http.StatusServiceUnavailable}
+
+		var graphErr *GraphAPIError
+		require.True(t, errors.As(NormalizeGraphAPIError(apiErr), &graphErr))
+		assert.Equal(t, "no body", graphErr.Message)
+		assert.Equal(t, http.StatusServiceUnavailable, graphErr.StatusCode)
+		assert.Zero(t, graphErr.RetryAfter)
+	})
+
+	t.Run("oauth error", func(t *testing.T) {
+		var graphErr *GraphAPIError
+		require.True(t, errors.As(NormalizeGraphAPIError(errors.New("oauth2: token expired")), &graphErr))
+		assert.Equal(t, http.StatusUnauthorized, graphErr.StatusCode)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 30*time.Second, parseRetryAfter("30", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter("-1", now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+	assert.Zero(t, parseRetryAfter("", now))
+}
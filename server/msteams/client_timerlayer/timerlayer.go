@@ -299,6 +299,7 @@ func (c *ClientTimerLayer) GetFileContentStream(downloadURL string, writer *io.P
 	elapsed := float64(time.Since(start)) / float64(time.Second)
 
 	c.metrics.ObserveMSGraphClientMethodDuration("Client.GetFileContentStream", success, statusCode, elapsed)
+
 }
 
 func (c *ClientTimerLayer) GetFileSizeAndDownloadURL(weburl string) (int64, string, error) {
@@ -675,6 +676,28 @@ func (c *ClientTimerLayer) ListUsers() ([]clientmodels.User, error) {
 	return result, err
 }
 
+func (c *ClientTimerLayer) RefreshPresenceSubscription(subscriptionID string) (*time.Time, error) {
+	statusCode := "2XX"
+	success := "true"
+	start := time.Now()
+
+	result, err := c.Client.RefreshPresenceSubscription(subscriptionID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+
+	if err != nil {
+		success = "false"
+		statusCode = "0"
+		var apiErr *msteams.GraphAPIError
+		if errors.As(err, &apiErr) {
+			statusCode = strconv.Itoa(apiErr.StatusCode)
+		}
+	}
+
+	c.metrics.ObserveMSGraphClientMethodDuration("Client.RefreshPresenceSubscription", success, statusCode, elapsed)
+	return result, err
+}
+
 func (c *ClientTimerLayer) RefreshSubscription(subscriptionID string) (*time.Time, error) {
 	statusCode := "2XX"
 	success := "true"
@@ -719,6 +742,50 @@ func (c *ClientTimerLayer) RefreshToken(token *oauth2.Token) (*oauth2.Token, err
 	return result, err
 }
 
+func (c *ClientTimerLayer) RevokeSignInSessions(userID string) error {
+	statusCode := "2XX"
+	success := "true"
+	start := time.Now()
+
+	err := c.Client.RevokeSignInSessions(userID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+
+	if err != nil {
+		success = "false"
+		statusCode = "0"
+		var apiErr *msteams.GraphAPIError
+		if errors.As(err, &apiErr) {
+			statusCode = strconv.Itoa(apiErr.StatusCode)
+		}
+	}
+
+	c.metrics.ObserveMSGraphClientMethodDuration("Client.RevokeSignInSessions", success, statusCode, elapsed)
+	return err
+}
+
+func (c *ClientTimerLayer) SendBatch(batch *msteams.Batch) error {
+	statusCode := "2XX"
+	success := "true"
+	start := time.Now()
+
+	err := c.Client.SendBatch(batch)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+
+	if err != nil {
+		success = "false"
+		statusCode = "0"
+		var apiErr *msteams.GraphAPIError
+		if errors.As(err, &apiErr) {
+			statusCode = strconv.Itoa(apiErr.StatusCode)
+		}
+	}
+
+	c.metrics.ObserveMSGraphClientMethodDuration("Client.SendBatch", success, statusCode, elapsed)
+	return err
+}
+
 func (c *ClientTimerLayer) SendChat(chatID string, message string, parentMessage *clientmodels.Message, attachments []*clientmodels.Attachment, mentions []models.ChatMessageMentionable) (*clientmodels.Message, error) {
 	statusCode := "2XX"
 	success := "true"
@@ -895,6 +962,28 @@ func (c *ClientTimerLayer) SubscribeToChats(baseURL string, webhookSecret string
 	return result, err
 }
 
+func (c *ClientTimerLayer) SubscribeToPresences(userIDs []string, baseURL string, webhookSecret string) (*clientmodels.Subscription, error) {
+	statusCode := "2XX"
+	success := "true"
+	start := time.Now()
+
+	result, err := c.Client.SubscribeToPresences(userIDs, baseURL, webhookSecret)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+
+	if err != nil {
+		success = "false"
+		statusCode = "0"
+		var apiErr *msteams.GraphAPIError
+		if errors.As(err, &apiErr) {
+			statusCode = strconv.Itoa(apiErr.StatusCode)
+		}
+	}
+
+	c.metrics.ObserveMSGraphClientMethodDuration("Client.SubscribeToPresences", success, statusCode, elapsed)
+	return result, err
+}
+
 func (c *ClientTimerLayer) SubscribeToUserChats(user string, baseURL string, webhookSecret string, pay bool, certificate string) (*clientmodels.Subscription, error) {
 	statusCode := "2XX"
 	success := "true"
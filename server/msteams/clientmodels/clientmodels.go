@@ -105,7 +105,9 @@ type Credential struct {
 	Name        string
 	ID          string
 	EndDateTime time.Time
-	Hint        string
+	// Hint is set for client secrets, and Thumbprint for certificates.
+	Hint       string
+	Thumbprint string
 }
 
 type ResourceAccess struct {
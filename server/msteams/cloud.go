@@ -0,0 +1,113 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package msteams
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+// Cloud identifies the Microsoft cloud the tenant lives in: the global service, or one of the
+// national clouds.
+type Cloud string
+
+const (
+	CloudPublic          Cloud = "public"
+	CloudUSGovernment    Cloud = "usgovernment"
+	CloudUSGovernmentDoD Cloud = "usgovernmentdod"
+	CloudChina           Cloud = "china"
+)
+
+type cloudEndpoints struct {
+	azure     cloud.Configuration
+	loginURL  string
+	graphURL  string
+	teamsHost string
+}
+
+// See https://learn.microsoft.com/en-us/graph/deployments for the endpoints of each cloud.
+var cloudEndpointsByCloud = map[Cloud]cloudEndpoints{
+	CloudPublic: {
+		azure:     cloud.AzurePublic,
+		loginURL:  "https://login.microsoftonline.com",
+		graphURL:  "https://graph.microsoft.com",
+		teamsHost: "teams.microsoft.com",
+	},
+	CloudUSGovernment: {
+		azure:     cloud.AzureGovernment,
+		loginURL:  "https://login.microsoftonline.us",
+		graphURL:  "https://graph.microsoft.us",
+		teamsHost: "gov.teams.microsoft.us",
+	},
+	CloudUSGovernmentDoD: {
+		azure:     cloud.AzureGovernment,
+		loginURL:  "https://login.microsoftonline.us",
+		graphURL:  "https://dod-graph.microsoft.us",
+		teamsHost: "dod.teams.microsoft.us",
+	},
+	CloudChina: {
+		azure:     cloud.AzureChina,
+		loginURL:  "https://login.chinacloudapi.cn",
+		graphURL:  "https://microsoftgraph.chinacloudapi.cn",
+		teamsHost: "teams.microsoftonline.cn",
+	},
+}
+
+// IsValid reports whether the cloud is a known one.
+func (c Cloud) IsValid() bool {
+	_, ok := cloudEndpointsByCloud[c]
+	return ok
+}
+
+func (c Cloud) endpoints() cloudEndpoints {
+	if endpoints, ok := cloudEndpointsByCloud[c]; ok {
+		return endpoints
+	}
+
+	return cloudEndpointsByCloud[CloudPublic]
+}
+
+// AuthorizeURL returns the endpoint to start the delegated OAuth2 flow of the given tenant at.
+func (c Cloud) AuthorizeURL(tenantID string) string {
+	return fmt.Sprintf("%s/%s/oauth2/v2.0/authorize", c.endpoints().loginURL, tenantID)
+}
+
+// TokenURL returns the endpoint to get tokens for the given tenant from.
+func (c Cloud) TokenURL(tenantID string) string {
+	return fmt.Sprintf("%s/%s/oauth2/v2.0/token", c.endpoints().loginURL, tenantID)
+}
+
+// GraphURL returns the root of the Microsoft Graph service.
+func (c Cloud) GraphURL() string {
+	return c.endpoints().graphURL
+}
+
+// GraphBaseURL returns the base URL of the Microsoft Graph API requests.
+func (c Cloud) GraphBaseURL() string {
+	return c.endpoints().graphURL + "/v1.0"
+}
+
+// Scopes returns the default scopes to request Microsoft Graph tokens with.
+func (c Cloud) Scopes() []string {
+	return []string{c.endpoints().graphURL + "/.default"}
+}
+
+// delegatedScopes returns the scopes to request the tokens of connected users with through MSAL,
+// which fails when any requested scope isn't among those granted, as the default scope never is.
+func (c Cloud) delegatedScopes() []string {
+	permissions := []string{"Chat.Read", "ChatMessage.Read", "Files.Read.All", "User.Read"}
+
+	scopes := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		scopes = append(scopes, c.endpoints().graphURL+"/"+permission)
+	}
+
+	return scopes
+}
+
+// ChatMessageLink returns the link opening the chat message in the Teams web client.
+func (c Cloud) ChatMessageLink(chatID, messageID, tenantID string) string {
+	return fmt.Sprintf("https://%s/l/message/%s/%s?tenantId=%s&context={\"contextType\":\"chat\"}", c.endpoints().teamsHost, chatID, messageID, tenantID)
+}
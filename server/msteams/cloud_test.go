@@ -0,0 +1,86 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package msteams
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudEndpoints(t *testing.T) {
+	for _, test := range []struct {
+		Cloud                Cloud
+		ExpectedAuthorizeURL string
+		ExpectedTokenURL     string
+		ExpectedGraphBaseURL string
+		ExpectedScopes       []string
+		ExpectedAuthority    string
+		ExpectedChatLink     string
+	}{
+		{
+			Cloud:                CloudPublic,
+			ExpectedAuthorizeURL: "https://login.microsoftonline.com/tenant-id/oauth2/v2.0/authorize",
+			ExpectedTokenURL:     "https://login.microsoftonline.com/tenant-id/oauth2/v2.0/token",
+			ExpectedGraphBaseURL: "https://graph.microsoft.com/v1.0",
+			ExpectedScopes:       []string{"https://graph.microsoft.com/.default"},
+			ExpectedAuthority:    "https://login.microsoftonline.com/",
+			ExpectedChatLink:     `https://teams.microsoft.com/l/message/chat-id/message-id?tenantId=tenant-id&context={"contextType":"chat"}`,
+		},
+		{
+			Cloud:                CloudUSGovernment,
+			ExpectedAuthorizeURL: "https://login.microsoftonline.us/tenant-id/oauth2/v2.0/authorize",
+			ExpectedTokenURL:     "https://login.microsoftonline.us/tenant-id/oauth2/v2.0/token",
+			ExpectedGraphBaseURL: "https://graph.microsoft.us/v1.0",
+			ExpectedScopes:       []string{"https://graph.microsoft.us/.default"},
+			ExpectedAuthority:    "https://login.microsoftonline.us/",
+			ExpectedChatLink:     `https://gov.teams.microsoft.us/l/message/chat-id/message-id?tenantId=tenant-id&context={"contextType":"chat"}`,
+		},
+		{
+			Cloud:                CloudUSGovernmentDoD,
+			ExpectedAuthorizeURL: "https://login.microsoftonline.us/tenant-id/oauth2/v2.0/authorize",
+			ExpectedTokenURL:     "https://login.microsoftonline.us/tenant-id/oauth2/v2.0/token",
+			ExpectedGraphBaseURL: "https://dod-graph.microsoft.us/v1.0",
+			ExpectedScopes:       []string{"https://dod-graph.microsoft.us/.default"},
+			ExpectedAuthority:    "https://login.microsoftonline.us/",
+			ExpectedChatLink:     `https://dod.teams.microsoft.us/l/message/chat-id/message-id?tenantId=tenant-id&context={"contextType":"chat"}`,
+		},
+		{
+			Cloud:                CloudChina,
+			ExpectedAuthorizeURL: "https://login.chinacloudapi.cn/tenant-id/oauth2/v2.0/authorize",
+			ExpectedTokenURL:     "https://login.chinacloudapi.cn/tenant-id/oauth2/v2.0/token",
+			ExpectedGraphBaseURL: "https://microsoftgraph.chinacloudapi.cn/v1.0",
+			ExpectedScopes:       []string{"https://microsoftgraph.chinacloudapi.cn/.default"},
+			ExpectedAuthority:    "https://login.chinacloudapi.cn/",
+			ExpectedChatLink:     `https://teams.microsoftonline.cn/l/message/chat-id/message-id?tenantId=tenant-id&context={"contextType":"chat"}`,
+		},
+	} {
+		t.Run(string(test.Cloud), func(t *testing.T) {
+			assert.True(t, test.Cloud.IsValid())
+			assert.Equal(t, test.ExpectedAuthorizeURL, test.Cloud.AuthorizeURL("tenant-id"))
+			assert.Equal(t, test.ExpectedTokenURL, test.Cloud.TokenURL("tenant-id"))
+			assert.Equal(t, test.ExpectedGraphBaseURL, test.Cloud.GraphBaseURL())
+			assert.Equal(t, test.ExpectedScopes, test.Cloud.Scopes())
+			assert.Equal(t, test.ExpectedAuthority, test.Cloud.endpoints().azure.ActiveDirectoryAuthorityHost)
+			assert.Equal(t, test.ExpectedChatLink, test.Cloud.ChatMessageLink("chat-id", "message-id", "tenant-id"))
+
+			conf := newOAuth2Config(test.Cloud, "https://example.com/redirect", "tenant-id", "client-id", "secret")
+			assert.Equal(t, test.ExpectedAuthorizeURL, conf.Endpoint.AuthURL)
+			assert.Equal(t, test.ExpectedTokenURL, conf.Endpoint.TokenURL)
+			assert.Equal(t, append(test.ExpectedScopes, "offline_access"), conf.Scopes)
+		})
+	}
+
+	t.Run("unknown cloud", func(t *testing.T) {
+		cloud := Cloud("unknown")
+		assert.False(t, cloud.IsValid())
+		assert.Equal(t, CloudPublic.GraphBaseURL(), cloud.GraphBaseURL())
+	})
+}
+
+func TestGetAuthURLCloud(t *testing.T) {
+	authURL := GetAuthURL(CloudUSGovernment, "https://example.com/redirect", "tenant-id", "client-id", "secret", "state", "verifier")
+	assert.Contains(t, authURL, "https://login.microsoftonline.us/tenant-id/oauth2/v2.0/authorize?")
+	assert.Contains(t, authURL, "graph.microsoft.us%2F.default")
+}
@@ -0,0 +1,308 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package msteams
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha1" //#nosec G505 -- Azure identifies certificates by their SHA-1 thumbprint
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/cache"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/confidential"
+	msalerrors "github.com/AzureAD/microsoft-authentication-library-for-go/apps/errors"
+	"golang.org/x/oauth2"
+)
+
+// msalRefreshTokenPrefix marks the refresh tokens of users connected while authenticating with a
+// client certificate, which hold the MSAL token cache of the user instead, MSAL not exposing the
+// refresh token itself.
+const msalRefreshTokenPrefix = "msal:"
+
+// AppCredentials authenticate the Azure application, either with a client secret or with a
+// client certificate, which takes precedence when given.
+type AppCredentials struct {
+	ClientSecret string
+	Certificate  *ClientCertificate
+}
+
+// IsEmpty reports whether neither a client secret nor a client certificate is given.
+func (c AppCredentials) IsEmpty() bool {
+	return c.ClientSecret == "" && c.Certificate == nil
+}
+
+// ClientCertificate is a certificate registered with the Azure application, along with its
+// private key.
+type ClientCertificate struct {
+	certificates []*x509.Certificate
+	key          *rsa.PrivateKey
+}
+
+// ParseClientCertificate parses the PEM encoded certificate and private key. The certificate
+// matching the private key is the one registered with the application, any other being part of
+// its chain.
+func ParseClientCertificate(data []byte) (*ClientCertificate, error) {
+	certificates, key, err := azidentity.ParseCertificates(data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the private key must be an RSA key")
+	}
+
+	for i, certificate := range certificates {
+		if publicKey, ok := certificate.PublicKey.(*rsa.PublicKey); ok && publicKey.Equal(rsaKey.Public()) {
+			// Put the certificate first, as the one the credential is identified with.
+			certificates[0], certificates[i] = certificates[i], certificates[0]
+			return &ClientCertificate{certificates: certificates, key: rsaKey}, nil
+		}
+	}
+
+	return nil, errors.New("found no certificate matching the private key")
+}
+
+// Thumbprint returns the SHA-1 thumbprint of the certificate, in upper case hexadecimal as shown
+// in the Azure portal.
+func (c *ClientCertificate) Thumbprint() string {
+	return strings.ToUpper(hex.EncodeToString(c.thumbprint()))
+}
+
+func (c *ClientCertificate) thumbprint() []byte {
+	sum := sha1.Sum(c.certificates[0].Raw) //#nosec G401 -- Azure identifies certificates by their SHA-1 thumbprint
+	return sum[:]
+}
+
+// NotAfter returns the time the certificate expires.
+func (c *ClientCertificate) NotAfter() time.Time {
+	return c.certificates[0].NotAfter
+}
+
+// newOAuth2Config returns the configuration of the delegated OAuth2 flow for an application
+// authenticating with a client secret. Client certificates are handled by MSAL instead.
+func newOAuth2Config(cloud Cloud, redirectURL, tenantID, clientID, clientSecret string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       append(cloud.Scopes(), "offline_access"),
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  cloud.AuthorizeURL(tenantID),
+			TokenURL: cloud.TokenURL(tenantID),
+		},
+		RedirectURL: redirectURL,
+	}
+}
+
+// oAuth2Context returns the context to make the token requests of the delegated OAuth2 flow
+// with, through the given transport.
+func oAuth2Context(ctx context.Context, transport *http.Transport) context.Context {
+	if transport == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, oauth2.HTTPClient, httpClient(transport))
+}
+
+// msalTokenCache holds the MSAL token cache of a single user between the token requests.
+type msalTokenCache struct {
+	data []byte
+}
+
+func (c *msalTokenCache) Replace(_ context.Context, cache cache.Unmarshaler, _ cache.ReplaceHints) error {
+	if c.data == nil {
+		return nil
+	}
+
+	return cache.Unmarshal(c.data)
+}
+
+func (c *msalTokenCache) Export(_ context.Context, cache cache.Marshaler, _ cache.ExportHints) error {
+	data, err := cache.Marshal()
+	if err != nil {
+		return err
+	}
+
+	c.data = data
+	return nil
+}
+
+// msalRefreshToken is what's stored in place of the refresh token of users connected while
+// authenticating with a client certificate.
+type msalRefreshToken struct {
+	HomeAccountID string `json:"home_account_id"`
+	Cache         []byte `json:"cache"`
+}
+
+func encodeMSALRefreshToken(homeAccountID string, tokenCache *msalTokenCache) (string, error) {
+	data, err := json.Marshal(&msalRefreshToken{HomeAccountID: homeAccountID, Cache: tokenCache.data})
+	if err != nil {
+		return "", err
+	}
+
+	return msalRefreshTokenPrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeMSALRefreshToken(refreshToken string) (*msalRefreshToken, error) {
+	encoded, ok := strings.CutPrefix(refreshToken, msalRefreshTokenPrefix)
+	if !ok {
+		return nil, errors.New("not issued while authenticating with a client certificate")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored msalRefreshToken
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	return &stored, nil
+}
+
+// msalClient makes the token requests of the delegated flow for an application authenticating
+// with a client certificate.
+type msalClient struct {
+	authority   string
+	clientID    string
+	certificate *ClientCertificate
+	httpClient  *http.Client
+	scopes      []string
+}
+
+func newMSALClient(cloud Cloud, tenantID, clientID string, certificate *ClientCertificate, transport *http.Transport) *msalClient {
+	return &msalClient{
+		authority:   cloud.endpoints().loginURL + "/" + tenantID,
+		clientID:    clientID,
+		certificate: certificate,
+		httpClient:  getAuthClient(transport),
+		scopes:      cloud.delegatedScopes(),
+	}
+}
+
+func (c *msalClient) confidentialClient(tokenCache *msalTokenCache) (confidential.Client, error) {
+	credential, err := confidential.NewCredFromCert(c.certificate.certificates, c.certificate.key)
+	if err != nil {
+		return confidential.Client{}, err
+	}
+
+	// The authority of the configured cloud is known, so there's nothing to discover.
+	return confidential.New(c.authority, c.clientID, credential,
+		confidential.WithCache(tokenCache),
+		confidential.WithHTTPClient(c.httpClient),
+		confidential.WithInstanceDiscovery(false),
+	)
+}
+
+// token converts the result of a token request, storing the token cache as the refresh token.
+func (c *msalClient) token(result confidential.AuthResult, tokenCache *msalTokenCache) (*oauth2.Token, error) {
+	refreshToken, err := encodeMSALRefreshToken(result.Account.HomeAccountID, tokenCache)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Token{
+		AccessToken:  result.AccessToken,
+		TokenType:    "Bearer",
+		RefreshToken: refreshToken,
+		Expiry:       result.ExpiresOn,
+	}, nil
+}
+
+func (c *msalClient) exchangeCode(ctx context.Context, redirectURL, code, codeVerifier string) (*oauth2.Token, error) {
+	tokenCache := &msalTokenCache{}
+	client, err := c.confidentialClient(tokenCache)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := client.AcquireTokenByAuthCode(ctx, code, redirectURL, c.scopes, confidential.WithChallenge(codeVerifier))
+	if err != nil {
+		return nil, err
+	}
+
+	return c.token(result, tokenCache)
+}
+
+func (c *msalClient) refreshToken(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	stored, err := decodeMSALRefreshToken(refreshToken)
+	if err != nil {
+		// The user has to connect again, as with a refresh token rejected by Microsoft.
+		return nil, fmt.Errorf("oauth2: invalid refresh token: %w", err)
+	}
+
+	tokenCache := &msalTokenCache{data: stored.Cache}
+	client, err := c.confidentialClient(tokenCache)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := client.AcquireTokenSilent(ctx, c.scopes, confidential.WithSilentAccount(confidential.Account{HomeAccountID: stored.HomeAccountID}))
+	if err != nil {
+		var callErr msalerrors.CallErr
+		if errors.As(err, &callErr) && callErr.Resp != nil && (callErr.Resp.StatusCode == http.StatusBadRequest || callErr.Resp.StatusCode == http.StatusUnauthorized) {
+			return nil, fmt.Errorf("oauth2: refresh token rejected: %w", err)
+		}
+		return nil, err
+	}
+
+	return c.token(result, tokenCache)
+}
+
+// msalTokenSource refreshes the tokens of a user connected while authenticating with a client
+// certificate.
+type msalTokenSource struct {
+	ctx          context.Context
+	client       *msalClient
+	refreshToken string
+}
+
+func (s *msalTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.client.refreshToken(s.ctx, s.refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	s.refreshToken = token.RefreshToken
+	return token, nil
+}
+
+// newTokenSource returns the source of the delegated tokens of a user, starting with the given
+// token and refreshing it once expired.
+func newTokenSource(cloud Cloud, redirectURL, tenantID, clientID string, credentials AppCredentials, transport *http.Transport, token *oauth2.Token) oauth2.TokenSource {
+	ctx := context.Background()
+	if credentials.Certificate == nil {
+		conf := newOAuth2Config(cloud, redirectURL, tenantID, clientID, credentials.ClientSecret)
+		return conf.TokenSource(oAuth2Context(ctx, transport), token)
+	}
+
+	return oauth2.ReuseTokenSource(token, &msalTokenSource{
+		ctx:          ctx,
+		client:       newMSALClient(cloud, tenantID, clientID, credentials.Certificate, transport),
+		refreshToken: token.RefreshToken,
+	})
+}
+
+// ExchangeCode exchanges the authorization code received at the end of the delegated OAuth2 flow
+// for a token.
+func ExchangeCode(cloud Cloud, redirectURL, tenantID, clientID string, credentials AppCredentials, transport *http.Transport, code, codeVerifier string) (*oauth2.Token, error) {
+	ctx := context.Background()
+	if credentials.Certificate != nil {
+		return newMSALClient(cloud, tenantID, clientID, credentials.Certificate, transport).exchangeCode(ctx, redirectURL, code, codeVerifier)
+	}
+
+	conf := newOAuth2Config(cloud, redirectURL, tenantID, clientID, credentials.ClientSecret)
+	return conf.Exchange(oAuth2Context(ctx, transport), code, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package msteams
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" //#nosec G505 -- Azure identifies certificates by their SHA-1 thumbprint
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func generateCertificatePEM(t *testing.T, key crypto.Signer, notAfter time.Time) []byte {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "msteams-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return append(data, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})...)
+}
+
+func newTestClientCertificate(t *testing.T) (*ClientCertificate, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	certificate, err := ParseClientCertificate(generateCertificatePEM(t, key, time.Now().Add(24*time.Hour)))
+	require.NoError(t, err)
+
+	return certificate, key
+}
+
+func TestParseClientCertificate(t *testing.T) {
+	t.Run("certificate and key", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		notAfter := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+
+		data := generateCertificatePEM(t, key, notAfter)
+		certificate, err := ParseClientCertificate(data)
+		require.NoError(t, err)
+
+		block, _ := pem.Decode(data)
+		sum := sha1.Sum(block.Bytes) //#nosec G401 -- Azure identifies certificates by their SHA-1 thumbprint
+		assert.Equal(t, strings.ToUpper(hex.EncodeToString(sum[:])), certificate.Thumbprint())
+		assert.True(t, notAfter.Equal(certificate.NotAfter()))
+	})
+
+	t.Run("certificate first in the chain", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		data := generateCertificatePEM(t, key, time.Now().Add(time.Hour))
+		other := generateCertificatePEM(t, otherKey, time.Now().Add(time.Hour))
+		otherBlock, _ := pem.Decode(other)
+
+		certificate, err := ParseClientCertificate(append(pem.EncodeToMemory(otherBlock), data...))
+		require.NoError(t, err)
+
+		block, _ := pem.Decode(data)
+		sum := sha1.Sum(block.Bytes) //#nosec G401 -- Azure identifies certificates by their SHA-1 thumbprint
+		assert.Equal(t, strings.ToUpper(hex.EncodeToString(sum[:])), certificate.Thumbprint())
+	})
+
+	t.Run("no private key", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		block, _ := pem.Decode(generateCertificatePEM(t, key, time.Now().Add(time.Hour)))
+
+		_, err = ParseClientCertificate(pem.EncodeToMemory(block))
+		assert.Error(t, err)
+	})
+
+	t.Run("no certificate matching the key", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		certificateBlock, _ := pem.Decode(generateCertificatePEM(t, key, time.Now().Add(time.Hour)))
+		_, rest := pem.Decode(generateCertificatePEM(t, otherKey, time.Now().Add(time.Hour)))
+
+		_, err = ParseClientCertificate(append(pem.EncodeToMemory(certificateBlock), rest...))
+		assert.EqualError(t, err, "found no certificate matching the private key")
+	})
+
+	t.Run("not an RSA key", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		_, err = ParseClientCertificate(generateCertificatePEM(t, key, time.Now().Add(time.Hour)))
+		assert.EqualError(t, err, "the private key must be an RSA key")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseClientCertificate([]byte("not a certificate"))
+		assert.Error(t, err)
+	})
+}
+
+func TestNewOAuth2Config(t *testing.T) {
+	conf := newOAuth2Config(CloudPublic, "https://example.com/redirect", "tenant-id", "client-id", "secret")
+
+	assert.Equal(t, "secret", conf.ClientSecret)
+	assert.Equal(t, oauth2.AuthStyleAutoDetect, conf.Endpoint.AuthStyle)
+	assert.Equal(t, "https://login.microsoftonline.com/tenant-id/oauth2/v2.0/token", conf.Endpoint.TokenURL)
+}
+
+func TestMSALClient(t *testing.T) {
+	certificate, _ := newTestClientCertificate(t)
+
+	var form url.Values
+	tokenRequests := 0
+	rejectRefreshToken := false
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/tenant-id/v2.0/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"authorization_endpoint": "https://" + r.Host + "/tenant-id/oauth2/v2.0/authorize",
+				"token_endpoint":         "https://" + r.Host + "/tenant-id/oauth2/v2.0/token",
+				"issuer":                 "https://" + r.Host + "/tenant-id/v2.0",
+			})
+		case "/tenant-id/oauth2/v2.0/token":
+			require.NoError(t, r.ParseForm())
+			form = r.PostForm
+
+			if rejectRefreshToken {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"The refresh token has been revoked."}`))
+				return
+			}
+
+			tokenRequests++
+			clientInfo := base64.RawURLEncoding.EncodeToString([]byte(`{"uid":"user-id","utid":"tenant-id"}`))
+			idToken := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+				base64.RawURLEncoding.EncodeToString([]byte(`{"oid":"user-id","sub":"subject","tid":"tenant-id","preferred_username":"user@example.com"}`)) + "."
+			// The tokens expire soon enough for MSAL to refresh them rather than use the cached ones.
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"access_token":  fmt.Sprintf("access-token-%d", tokenRequests),
+				"refresh_token": fmt.Sprintf("refresh-token-%d", tokenRequests),
+				"token_type":    "Bearer",
+				"expires_in":    60,
+				"scope":         r.PostForm.Get("scope"),
+				"client_info":   clientInfo,
+				"id_token":      idToken,
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := &msalClient{
+		authority:   server.URL + "/tenant-id",
+		clientID:    "client-id",
+		certificate: certificate,
+		httpClient:  server.Client(),
+		scopes:      CloudPublic.delegatedScopes(),
+	}
+
+	token, err := client.exchangeCode(t.Context(), "https://example.com/redirect", "code", "verifier")
+	require.NoError(t, err)
+	assert.Equal(t, "access-token-1", token.AccessToken)
+	assert.True(t, strings.HasPrefix(token.RefreshToken, msalRefreshTokenPrefix))
+	assert.WithinDuration(t, time.Now().Add(time.Minute), token.Expiry, 10*time.Second)
+
+	assert.Equal(t, "authorization_code", form.Get("grant_type"))
+	assert.Equal(t, "code", form.Get("code"))
+	assert.Equal(t, "verifier", form.Get("code_verifier"))
+	assert.Equal(t, "client-id", form.Get("client_id"))
+	assert.Empty(t, form.Get("client_secret"))
+	assert.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", form.Get("client_assertion_type"))
+	assert.Len(t, strings.Split(form.Get("client_assertion"), "."), 3)
+
+	t.Run("refresh", func(t *testing.T) {
+		refreshed, err := client.refreshToken(t.Context(), token.RefreshToken)
+		require.NoError(t, err)
+		assert.Equal(t, "access-token-2", refreshed.AccessToken)
+		assert.True(t, strings.HasPrefix(refreshed.RefreshToken, msalRefreshTokenPrefix))
+
+		assert.Equal(t, "refresh_token", form.Get("grant_type"))
+		assert.Equal(t, "refresh-token-1", form.Get("refresh_token"))
+		assert.Empty(t, form.Get("client_secret"))
+		assert.Len(t, strings.Split(form.Get("client_assertion"), "."), 3)
+
+		// The refreshed token carries the new refresh token.
+		refreshed, err = client.refreshToken(t.Context(), refreshed.RefreshToken)
+		require.NoError(t, err)
+		assert.Equal(t, "access-token-3", refreshed.AccessToken)
+		assert.Equal(t, "refresh-token-2", form.Get("refresh_token"))
+	})
+
+	t.Run("rejected refresh token", func(t *testing.T) {
+		rejectRefreshToken = true
+		t.Cleanup(func() { rejectRefreshToken = false })
+
+		_, err := client.refreshToken(t.Context(), token.RefreshToken)
+		require.Error(t, err)
+		assert.True(t, IsOAuthError(err))
+	})
+
+	t.Run("refresh token issued with a client secret", func(t *testing.T) {
+		_, err := client.refreshToken(t.Context(), "refresh-token")
+		require.Error(t, err)
+		assert.True(t, IsOAuthError(err))
+	})
+}
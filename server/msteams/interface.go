@@ -30,7 +30,9 @@ type Client interface {
 	SubscribeToChats(baseURL, webhookSecret string, pay bool, certificate string) (*clientmodels.Subscription, error)
 	SubscribeToChannel(teamID, channelID, baseURL, webhookSecret, certificate string) (*clientmodels.Subscription, error)
 	SubscribeToUserChats(user, baseURL, webhookSecret string, pay bool, certificate string) (*clientmodels.Subscription, error)
+	SubscribeToPresences(userIDs []string, baseURL, webhookSecret string) (*clientmodels.Subscription, error)
 	RefreshSubscription(subscriptionID string) (*time.Time, error)
+	RefreshPresenceSubscription(subscriptionID string) (*time.Time, error)
 	DeleteSubscription(subscriptionID string) error
 	ListSubscriptions() ([]*clientmodels.Subscription, error)
 	GetTeam(teamID string) (*clientmodels.Team, error)
@@ -56,10 +58,12 @@ type Client interface {
 	GetCodeSnippet(url string) (string, error)
 	RefreshToken(token *oauth2.Token) (*oauth2.Token, error)
 	ListUsers() ([]clientmodels.User, error)
+	RevokeSignInSessions(userID string) error
 	ListTeams() ([]clientmodels.Team, error)
 	ListChannels(teamID string) ([]clientmodels.Channel, error)
 	ListChannelMessages(teamID, channelID string, since time.Time) ([]*clientmodels.Message, error)
 	ListChatMessages(chatID string, since time.Time) ([]*clientmodels.Message, error)
 	GetApp(applicationID string) (*clientmodels.App, error)
 	GetPresencesForUsers(userIDs []string) (map[string]clientmodels.Presence, error)
+	SendBatch(batch *Batch) error
 }
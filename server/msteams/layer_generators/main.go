@@ -33,6 +33,12 @@ func main() {
 	if err := buildDisconnectionLayer(); err != nil {
 		log.Fatal(err)
 	}
+	if err := buildRetryLayer(); err != nil {
+		log.Fatal(err)
+	}
+	if err := buildBreakerLayer(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func buildTimerLayer() error {
@@ -71,6 +77,48 @@ func buildDisconnectionLayer() error {
 	return os.WriteFile(path.Join("client_disconnectionlayer", "disconnectionlayer.go"), formatedCode, 0600)
 }
 
+func buildRetryLayer() error {
+	code, err := generateLayer("ClientRetryLayer", "retry_layer.go.tmpl")
+	if err != nil {
+		return err
+	}
+
+	formatedCode, err := format.Source(code)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll("client_retrylayer", 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path.Join("client_retrylayer", "retrylayer.go"), formatedCode, 0600)
+}
+
+func buildBreakerLayer() error {
+	code, err := generateLayer("ClientBreakerLayer", "breaker_layer.go.tmpl")
+	if err != nil {
+		return err
+	}
+
+	formatedCode, err := format.Source(code)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll("client_breakerlayer", 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path.Join("client_breakerlayer", "breakerlayer.go"), formatedCode, 0600)
+}
+
+// isIdempotent reports whether the client method only reads from Microsoft, and so can be
+// retried safely. Batches only hold reads.
+func isIdempotent(methodName string) bool {
+	return strings.HasPrefix(methodName, "Get") || strings.HasPrefix(methodName, "List") || methodName == "SendBatch"
+}
+
 type methodParam struct {
 	Name string
 	Type string
@@ -86,6 +134,30 @@ type clientMetadata struct {
 	Methods map[string]methodData
 }
 
+// qualifiedType returns the source of the given type, qualifying the types declared in the msteams
+// package itself so they can be used from the layers' packages.
+func qualifiedType(typeExpr ast.Expr, src []byte) string {
+	var typeName strings.Builder
+	start := typeExpr.Pos() - 1
+	ast.Inspect(typeExpr, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			// Already qualified with its package.
+			return false
+		case *ast.Ident:
+			if x.IsExported() {
+				typeName.Write(src[start : x.Pos()-1])
+				typeName.WriteString("msteams.")
+				start = x.Pos() - 1
+			}
+		}
+		return true
+	})
+	typeName.Write(src[start : typeExpr.End()-1])
+
+	return typeName.String()
+}
+
 func extractMethodMetadata(method *ast.Field, src []byte) methodData {
 	params := []methodParam{}
 	results := []string{}
@@ -94,13 +166,13 @@ func extractMethodMetadata(method *ast.Field, src []byte) methodData {
 			if e.Params != nil {
 				for _, param := range e.Params.List {
 					for _, paramName := range param.Names {
-						params = append(params, methodParam{Name: paramName.Name, Type: string(src[param.Type.Pos()-1 : param.Type.End()-1])})
+						params = append(params, methodParam{Name: paramName.Name, Type: qualifiedType(param.Type, src)})
 					}
 				}
 			}
 			if e.Results != nil {
 				for _, result := range e.Results.List {
-					results = append(results, string(src[result.Type.Pos()-1:result.Type.End()-1]))
+					results = append(results, qualifiedType(result.Type, src))
 				}
 			}
 		}
@@ -189,6 +261,21 @@ func generateLayer(name, templateFile string) ([]byte, error) {
 			}
 			return strings.Join(vars, ", ")
 		},
+		"genResultsDecls": func(results []string) string {
+			decls := []string{}
+			for i, typeName := range results {
+				switch {
+				case isError(typeName):
+					continue
+				case i == 0:
+					decls = append(decls, fmt.Sprintf("var result %s", typeName))
+				default:
+					decls = append(decls, fmt.Sprintf("var resultVar%d %s", i, typeName))
+				}
+			}
+			return strings.Join(decls, "\n")
+		},
+		"isIdempotent": isIdempotent,
 		"errorPresent": func(results []string) bool {
 			for _, typeName := range results {
 				if isError(typeName) {
@@ -11,6 +11,8 @@ import (
 
 	models "github.com/microsoftgraph/msgraph-sdk-go/models"
 
+	msteams "github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+
 	oauth2 "golang.org/x/oauth2"
 
 	time "time"
@@ -658,6 +660,29 @@ func (_m *Client) ListUsers() ([]clientmodels.User, error) {
 	return r0, r1
 }
 
+// RefreshPresenceSubscription provides a mock function with given fields: subscriptionID
+func (_m *Client) RefreshPresenceSubscription(subscriptionID string) (*time.Time, error) {
+	ret := _m.Called(subscriptionID)
+
+	var r0 *time.Time
+	if rf, ok := ret.Get(0).(func(string) *time.Time); ok {
+		r0 = rf(subscriptionID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*time.Time)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(subscriptionID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RefreshSubscription provides a mock function with given fields: subscriptionID
 func (_m *Client) RefreshSubscription(subscriptionID string) (*time.Time, error) {
 	ret := _m.Called(subscriptionID)
@@ -704,6 +729,34 @@ func (_m *Client) RefreshToken(token *oauth2.Token) (*oauth2.Token, error) {
 	return r0, r1
 }
 
+// RevokeSignInSessions provides a mock function with given fields: userID
+func (_m *Client) RevokeSignInSessions(userID string) error {
+	ret := _m.Called(userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SendBatch provides a mock function with given fields: batch
+func (_m *Client) SendBatch(batch *msteams.Batch) error {
+	ret := _m.Called(batch)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*msteams.Batch) error); ok {
+		r0 = rf(batch)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SendChat provides a mock function with given fields: chatID, message, parentMessage, attachments, mentions
 func (_m *Client) SendChat(chatID string, message string, parentMessage *clientmodels.Message, attachments []*clientmodels.Attachment, mentions []models.ChatMessageMentionable) (*clientmodels.Message, error) {
 	ret := _m.Called(chatID, message, parentMessage, attachments, mentions)
@@ -888,6 +941,29 @@ func (_m *Client) SubscribeToChats(baseURL string, webhookSecret string, pay boo
 	return r0, r1
 }
 
+// SubscribeToPresences provides a mock function with given fields: userIDs, baseURL, webhookSecret
+func (_m *Client) SubscribeToPresences(userIDs []string, baseURL string, webhookSecret string) (*clientmodels.Subscription, error) {
+	ret := _m.Called(userIDs, baseURL, webhookSecret)
+
+	var r0 *clientmodels.Subscription
+	if rf, ok := ret.Get(0).(func([]string, string, string) *clientmodels.Subscription); ok {
+		r0 = rf(userIDs, baseURL, webhookSecret)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*clientmodels.Subscription)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]string, string, string) error); ok {
+		r1 = rf(userIDs, baseURL, webhookSecret)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SubscribeToUserChats provides a mock function with given fields: user, baseURL, webhookSecret, pay, certificate
 func (_m *Client) SubscribeToUserChats(user string, baseURL string, webhookSecret string, pay bool, certificate string) (*clientmodels.Subscription, error) {
 	ret := _m.Called(user, baseURL, webhookSecret, pay, certificate)
@@ -0,0 +1,70 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package msteams
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// NewTransport returns the transport to reach Microsoft with: through the given HTTP(S) proxy,
+// except for the hosts in the comma separated no-proxy list, and trusting the given PEM root
+// CAs on top of the system ones. It returns nil when neither a proxy nor root CAs are given, so
+// that the default transport is used.
+func NewTransport(proxyURL, noProxy string, rootCAs []byte) (*http.Transport, error) {
+	if proxyURL == "" && len(rootCAs) == 0 {
+		return nil, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxyURL != "" {
+		parsed, err := url.Parse(proxyURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxyURL)
+		}
+
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  proxyURL,
+			HTTPSProxy: proxyURL,
+			NoProxy:    noProxy,
+		}).ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	if len(rootCAs) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(rootCAs) {
+			return nil, errors.New("found no certificate in the root CAs")
+		}
+
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.RootCAs = pool
+		transport.TLSClientConfig.MinVersion = tls.VersionTLS12
+	}
+
+	return transport, nil
+}
+
+// httpClient returns the client to make the requests to Microsoft outside of the Graph SDK with.
+func httpClient(transport *http.Transport) *http.Client {
+	if transport == nil {
+		return http.DefaultClient
+	}
+
+	return &http.Client{Transport: transport}
+}
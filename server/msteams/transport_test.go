@@ -0,0 +1,79 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package msteams
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransport(t *testing.T) {
+	t.Run("no settings", func(t *testing.T) {
+		transport, err := NewTransport("", "", nil)
+		require.NoError(t, err)
+		assert.Nil(t, transport)
+		assert.Equal(t, http.DefaultClient, httpClient(transport))
+	})
+
+	t.Run("invalid proxy URL", func(t *testing.T) {
+		for _, proxyURL := range []string{"proxy.example.com:3128", "ftp://proxy.example.com", "http://"} {
+			_, err := NewTransport(proxyURL, "", nil)
+			assert.EqualError(t, err, `invalid proxy URL "`+proxyURL+`"`)
+		}
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		transport, err := NewTransport("http://proxy.example.com:3128", "login.microsoftonline.com,.internal.example.com", nil)
+		require.NoError(t, err)
+		require.NotNil(t, transport)
+		if transport.TLSClientConfig != nil {
+			assert.Nil(t, transport.TLSClientConfig.RootCAs)
+		}
+
+		proxyFor := func(rawURL string) string {
+			req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+			require.NoError(t, err)
+			proxyURL, err := transport.Proxy(req)
+			require.NoError(t, err)
+			if proxyURL == nil {
+				return ""
+			}
+			return proxyURL.String()
+		}
+
+		assert.Equal(t, "http://proxy.example.com:3128", proxyFor("https://graph.microsoft.com/v1.0/me"))
+		assert.Equal(t, "", proxyFor("https://login.microsoftonline.com/tenant-id/oauth2/v2.0/token"))
+		assert.Equal(t, "", proxyFor("https://files.internal.example.com/file"))
+	})
+
+	t.Run("root CAs", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(server.Close)
+
+		_, err := httpClient(nil).Get(server.URL)
+		require.Error(t, err)
+
+		rootCAs := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+		transport, err := NewTransport("", "", rootCAs)
+		require.NoError(t, err)
+		require.NotNil(t, transport)
+
+		resp, err := httpClient(transport).Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("invalid root CAs", func(t *testing.T) {
+		_, err := NewTransport("", "", []byte("not a certificate"))
+		assert.EqualError(t, err, "found no certificate in the root CAs")
+	})
+}
@@ -4,58 +4,79 @@
 package main
 
 import (
-	"database/sql"
-	"fmt"
 	"strings"
+	"time"
 
 	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
 	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
 )
 
-func (ah *ActivityHandler) handleCreatedActivityNotification(msg *clientmodels.Message, chat *clientmodels.Chat) string {
+func (ah *ActivityHandler) handleCreatedActivityNotification(msg *clientmodels.Message, chat *clientmodels.Chat, presences, fetchedPresences map[string]clientmodels.Presence) string {
 	if chat == nil {
 		// We're only going to support notifications from chats for now.
 		return metrics.DiscardedReasonChannelNotificationsUnsupported
 	}
 
-	// Get the presence status for each chat member to decide if we should relay into Mattermost.
-	userIDs := make([]string, 0, len(chat.Members))
-	for _, member := range chat.Members {
-		// We won't notify senders about their own posts, so we don't need their presence.
-		if member.UserID == msg.UserID {
-			continue
+	botUserID := ah.plugin.GetBotUserID()
+
+	chatLink := ah.plugin.GetCloud().ChatMessageLink(chat.ID, msg.ID, ah.plugin.GetTenantID())
+	isGroupChat := len(chat.Members) >= 3
+	hasFilesUnknown := false
+
+	// Resolve all the chat members to Mattermost users at once, rather than one by one.
+	teamsUserIDs := chatMemberIDsExcept(chat, msg.UserID)
+	mattermostUserIDs, err := ah.plugin.GetStore().TeamsToMattermostUserIDs(teamsUserIDs)
+	if err != nil {
+		ah.plugin.GetAPI().LogWarn("Failed to map Teams users to Mattermost users", "chat_id", chat.ID, "message_id", msg.ID, "error", err)
+		for range teamsUserIDs {
+			ah.plugin.metricsService.ObserveNotification(isGroupChat, hasFilesUnknown, metrics.DiscardedReasonInternalError)
 		}
+		return metrics.DiscardedReasonNone
+	}
 
-		userIDs = append(userIDs, member.UserID)
+	connectedUserIDs := make([]string, 0, len(mattermostUserIDs))
+	for _, teamsUserID := range teamsUserIDs {
+		if mattermostUserID, ok := mattermostUserIDs[teamsUserID]; ok {
+			connectedUserIDs = append(connectedUserIDs, mattermostUserID)
+		}
 	}
 
-	presences, err := ah.plugin.GetClientForApp().GetPresencesForUsers(userIDs)
+	notificationsEnabled, err := ah.plugin.getNotificationPreferences(connectedUserIDs)
 	if err != nil {
-		ah.plugin.GetAPI().LogWarn("Failed to fetch presence information for chat members", "chat_id", chat.ID, "message_id", msg.ID, "error", err)
+		ah.plugin.GetAPI().LogWarn("Failed to get notification preferences of chat members", "chat_id", chat.ID, "message_id", msg.ID, "error", err)
+		for range connectedUserIDs {
+			ah.plugin.metricsService.ObserveNotification(isGroupChat, hasFilesUnknown, metrics.DiscardedReasonInternalError)
+		}
+		return metrics.DiscardedReasonNone
 	}
 
-	botUserID := ah.plugin.GetBotUserID()
+	// The direct channels missing here are created on demand below.
+	directChannelIDs, err := ah.plugin.GetStore().GetDirectChannelIDs(connectedUserIDs, botUserID)
+	if err != nil {
+		ah.plugin.GetAPI().LogWarn("Failed to get bot DM channels with chat members", "chat_id", chat.ID, "message_id", msg.ID, "error", err)
+	}
 
-	chatLink := fmt.Sprintf("https://teams.microsoft.com/l/message/%s/%s?tenantId=%s&context={\"contextType\":\"chat\"}", chat.ID, msg.ID, ah.plugin.GetTenantID())
-	isGroupChat := len(chat.Members) >= 3
-	hasFilesUnknown := false
+	ah.plugin.cacheConnectedPresences(fetchedPresences)
+
+	var notifiedUserIDs []string
+	var notifiedAt int64
+	var missedMembers []clientmodels.ChatMember
 	for _, member := range chat.Members {
 		// Don't notify senders about their own posts.
 		if member.UserID == msg.UserID {
 			continue
 		}
 
-		mattermostUserID, err := ah.plugin.GetStore().TeamsToMattermostUserID(member.UserID)
-		if err == sql.ErrNoRows {
-			continue
-		} else if err != nil {
-			ah.plugin.GetAPI().LogWarn("Failed to map Teams user to Mattermost user", "teams_user_id", member.UserID, "error", err)
-			ah.plugin.metricsService.ObserveNotification(isGroupChat, hasFilesUnknown, metrics.DiscardedReasonInternalError)
+		mattermostUserID, ok := mattermostUserIDs[member.UserID]
+		if !ok {
+			if ah.plugin.getConfiguration().TrackMissedMessages() && !userPresenceIsActive(presences[member.UserID]) {
+				missedMembers = append(missedMembers, member)
+			}
 			continue
 		}
 
-		if !ah.plugin.getNotificationPreference(mattermostUserID) {
+		if !notificationsEnabled[mattermostUserID] {
 			ah.plugin.GetAPI().LogInfo(
 				"Skipping notification for chat member who disabled notifications",
 				"user_id", mattermostUserID,
@@ -82,14 +103,18 @@ func (ah *ActivityHandler) handleCreatedActivityNotification(msg *clientmodels.M
 			continue
 		}
 
-		channel, err := ah.plugin.apiClient.Channel.GetDirect(mattermostUserID, ah.plugin.botUserID)
-		if err != nil {
-			ah.plugin.GetAPI().LogWarn("Failed to get bot DM channel with user", "user_id", mattermostUserID, "teams_user_id", member.UserID, "error", err)
-			ah.plugin.metricsService.ObserveNotification(isGroupChat, hasFilesUnknown, metrics.DiscardedReasonInternalError)
-			continue
+		channelID, ok := directChannelIDs[mattermostUserID]
+		if !ok {
+			channel, err := ah.plugin.apiClient.Channel.GetDirect(mattermostUserID, ah.plugin.botUserID)
+			if err != nil {
+				ah.plugin.GetAPI().LogWarn("Failed to get bot DM channel with user", "user_id", mattermostUserID, "teams_user_id", member.UserID, "error", err)
+				ah.plugin.metricsService.ObserveNotification(isGroupChat, hasFilesUnknown, metrics.DiscardedReasonInternalError)
+				continue
+			}
+			channelID = channel.Id
 		}
 
-		post, skippedFileAttachments, _ := ah.msgToPost(channel.Id, botUserID, msg, chat, []string{})
+		post, skippedFileAttachments, _ := ah.msgToPost(channelID, botUserID, msg, chat, []string{})
 		attachmentCount := len(post.FileIds)
 		hasFiles := attachmentCount > 0
 
@@ -146,13 +171,21 @@ func (ah *ActivityHandler) handleCreatedActivityNotification(msg *clientmodels.M
 		)
 		ah.plugin.metricsService.ObserveNotification(isGroupChat, hasFiles, metrics.DiscardedReasonNone)
 
-		err = ah.plugin.GetStore().SetUserLastChatReceivedAt(mattermostUserID, storemodels.MilliToMicroSeconds(post.CreateAt))
+		notifiedUserIDs = append(notifiedUserIDs, mattermostUserID)
+		notifiedAt = storemodels.MilliToMicroSeconds(post.CreateAt)
+	}
+
+	if len(missedMembers) > 0 {
+		ah.plugin.recordMissedMessages(missedMembers, time.Now())
+	}
+
+	if len(notifiedUserIDs) > 0 {
+		err = ah.plugin.GetStore().SetUsersLastChatReceivedAt(notifiedUserIDs, notifiedAt)
 		if err != nil {
 			ah.plugin.GetAPI().LogWarn(
 				"Unable to set the last chat received at",
 				"error", err,
-				"user_id", mattermostUserID,
-				"teams_user_id", member.UserID,
+				"user_ids", notifiedUserIDs,
 				"chat_id", chat.ID,
 				"message_id", msg.ID,
 			)
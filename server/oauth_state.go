@@ -0,0 +1,193 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
+)
+
+const (
+	codeVerifierKeyPrefix = "_code_verifier_"
+	codeVerifiersPerPage  = 1000
+
+	// oAuth2StateTimeToLive bounds the time between starting to connect and completing the OAuth flow.
+	oAuth2StateTimeToLive = 5 * time.Minute
+	// oAuth2BotMessageStateTimeToLive bounds how long the state of a connect link sent by the bot
+	// is kept. The state of the bot's own links is issued again when clicked after that.
+	oAuth2BotMessageStateTimeToLive = 7 * 24 * time.Hour
+	codeVerifierTimeToLive          = 10 * time.Minute
+)
+
+// oAuth2StateToken is the state passed through the OAuth flow, identifying the stored state and
+// the user completing the flow.
+type oAuth2StateToken struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func encodeOAuth2StateToken(stateID, userID string) (string, error) {
+	data, err := json.Marshal(&oAuth2StateToken{ID: stateID, UserID: userID})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeOAuth2StateToken(token string) (*oAuth2StateToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid state encoding")
+	}
+
+	var stateToken oAuth2StateToken
+	if err = json.Unmarshal(data, &stateToken); err != nil {
+		return nil, errors.Wrap(err, "invalid state format")
+	}
+
+	if !model.IsValidId(stateToken.ID) || !model.IsValidId(stateToken.UserID) {
+		return nil, errors.New("invalid state")
+	}
+
+	return &stateToken, nil
+}
+
+// newOAuth2State creates an OAuth state for the given user, valid for the given duration.
+func newOAuth2State(userID, origin, channelID, postID string, timeToLive time.Duration) *storemodels.OAuth2State {
+	return &storemodels.OAuth2State{
+		ID:        model.NewId(),
+		UserID:    userID,
+		Origin:    origin,
+		ChannelID: channelID,
+		PostID:    postID,
+		ExpiresAt: time.Now().Add(timeToLive),
+	}
+}
+
+type codeVerifier struct {
+	Verifier  string    `json:"verifier"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (p *Plugin) storeCodeVerifier(userID, verifier string) error {
+	data, err := json.Marshal(&codeVerifier{
+		Verifier:  verifier,
+		ExpiresAt: time.Now().Add(codeVerifierTimeToLive),
+	})
+	if err != nil {
+		return err
+	}
+
+	if appErr := p.API.KVSetWithExpiry(codeVerifierKeyPrefix+userID, data, int64(codeVerifierTimeToLive/time.Second)); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// popCodeVerifier returns the code verifier stored for the user and deletes it, so that it can
+// only be used once.
+func (p *Plugin) popCodeVerifier(userID string) (string, error) {
+	data, appErr := p.API.KVGet(codeVerifierKeyPrefix + userID)
+	if appErr != nil {
+		return "", appErr
+	}
+
+	if appErr = p.API.KVDelete(codeVerifierKeyPrefix + userID); appErr != nil {
+		p.API.LogWarn("Unable to delete the used code verifier", "error", appErr.Error())
+	}
+
+	if data == nil {
+		return "", errors.New("code verifier not found")
+	}
+
+	var verifier codeVerifier
+	if err := json.Unmarshal(data, &verifier); err != nil {
+		return "", errors.Wrap(err, "invalid code verifier")
+	}
+
+	if !time.Now().Before(verifier.ExpiresAt) {
+		return "", errors.New("code verifier expired")
+	}
+
+	return verifier.Verifier, nil
+}
+
+// deleteExpiredCodeVerifiers deletes the code verifiers that have expired, as well as any left
+// behind in the legacy format without an expiry, returning how many were deleted.
+func (p *Plugin) deleteExpiredCodeVerifiers() (int, error) {
+	now := time.Now()
+
+	// Collect the keys first, since deleting while listing would shift the pages.
+	var expiredKeys []string
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, codeVerifiersPerPage)
+		if appErr != nil {
+			return 0, appErr
+		}
+
+		for _, key := range keys {
+			if !strings.HasPrefix(key, codeVerifierKeyPrefix) {
+				continue
+			}
+
+			data, appErr := p.API.KVGet(key)
+			if appErr != nil {
+				return 0, appErr
+			}
+
+			var verifier codeVerifier
+			if data != nil && json.Unmarshal(data, &verifier) == nil && now.Before(verifier.ExpiresAt) {
+				continue
+			}
+
+			expiredKeys = append(expiredKeys, key)
+		}
+
+		if len(keys) < codeVerifiersPerPage {
+			break
+		}
+	}
+
+	for i, key := range expiredKeys {
+		if appErr := p.API.KVDelete(key); appErr != nil {
+			return i, appErr
+		}
+	}
+
+	return len(expiredKeys), nil
+}
+
+// cleanupCodeVerifiers deletes the code verifiers left behind without an expiry by connection
+// attempts that were never completed. The OAuth states and newer code verifiers expire on their own.
+func (p *Plugin) cleanupCodeVerifiers() {
+	defer func() {
+		if r := recover(); r != nil {
+			p.GetMetrics().ObserveGoroutineFailure()
+			p.API.LogError("Recovering from panic", "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+
+	done := p.GetMetrics().ObserveWorker(metrics.WorkerCleanupCodeVerifiers)
+	defer done()
+
+	deleted, err := p.deleteExpiredCodeVerifiers()
+	if err != nil {
+		p.API.LogWarn("Failed to delete expired code verifiers", "error", err.Error())
+	}
+
+	if deleted > 0 {
+		p.API.LogInfo("Deleted expired code verifiers", "code_verifiers", deleted)
+	}
+}
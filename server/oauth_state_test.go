@@ -0,0 +1,116 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuth2StateToken(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		stateID := model.NewId()
+		userID := model.NewId()
+
+		token, err := encodeOAuth2StateToken(stateID, userID)
+		require.NoError(t, err)
+
+		stateToken, err := decodeOAuth2StateToken(token)
+		require.NoError(t, err)
+		assert.Equal(t, stateID, stateToken.ID)
+		assert.Equal(t, userID, stateToken.UserID)
+	})
+
+	t.Run("legacy format", func(t *testing.T) {
+		_, err := decodeOAuth2StateToken(model.NewId() + "_" + model.NewId() + "_fromPreferences:true")
+		assert.Error(t, err)
+	})
+
+	t.Run("not json", func(t *testing.T) {
+		_, err := decodeOAuth2StateToken(base64.RawURLEncoding.EncodeToString([]byte("invalid")))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid ids", func(t *testing.T) {
+		token, err := encodeOAuth2StateToken("invalid_id", model.NewId())
+		require.NoError(t, err)
+
+		_, err = decodeOAuth2StateToken(token)
+		assert.Error(t, err)
+	})
+}
+
+func TestCodeVerifier(t *testing.T) {
+	th := setupTestHelper(t)
+
+	t.Run("stored and used once", func(t *testing.T) {
+		th.Reset(t)
+		userID := model.NewId()
+
+		require.NoError(t, th.p.storeCodeVerifier(userID, "verifier"))
+
+		verifier, err := th.p.popCodeVerifier(userID)
+		require.NoError(t, err)
+		assert.Equal(t, "verifier", verifier)
+
+		_, err = th.p.popCodeVerifier(userID)
+		assert.Error(t, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		th.Reset(t)
+		userID := model.NewId()
+
+		data, err := json.Marshal(&codeVerifier{Verifier: "verifier", ExpiresAt: time.Now().Add(-time.Minute)})
+		require.NoError(t, err)
+		require.Nil(t, th.p.API.KVSet(codeVerifierKeyPrefix+userID, data))
+
+		_, err = th.p.popCodeVerifier(userID)
+		assert.EqualError(t, err, "code verifier expired")
+	})
+
+	t.Run("legacy format", func(t *testing.T) {
+		th.Reset(t)
+		userID := model.NewId()
+
+		require.Nil(t, th.p.API.KVSet(codeVerifierKeyPrefix+userID, []byte("verifier")))
+
+		_, err := th.p.popCodeVerifier(userID)
+		assert.Error(t, err)
+	})
+}
+
+func TestCleanupCodeVerifiers(t *testing.T) {
+	th := setupTestHelper(t)
+	th.Reset(t)
+
+	validUserID := model.NewId()
+	expiredUserID := model.NewId()
+	legacyUserID := model.NewId()
+
+	require.NoError(t, th.p.storeCodeVerifier(validUserID, "verifier"))
+
+	data, err := json.Marshal(&codeVerifier{Verifier: "verifier", ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	require.Nil(t, th.p.API.KVSet(codeVerifierKeyPrefix+expiredUserID, data))
+	require.Nil(t, th.p.API.KVSet(codeVerifierKeyPrefix+legacyUserID, []byte("verifier")))
+
+	th.p.cleanupCodeVerifiers()
+
+	for _, userID := range []string{expiredUserID, legacyUserID} {
+		data, appErr := th.p.API.KVGet(codeVerifierKeyPrefix + userID)
+		require.Nil(t, appErr)
+		assert.Nil(t, data)
+	}
+
+	verifier, err := th.p.popCodeVerifier(validUserID)
+	require.NoError(t, err)
+	assert.Equal(t, "verifier", verifier)
+}
@@ -8,6 +8,7 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -29,9 +30,13 @@ import (
 	"github.com/mattermost/mattermost-plugin-msteams/assets"
 	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/client_breakerlayer"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/client_cachelayer"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/client_disconnectionlayer"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/client_retrylayer"
 	client_timerlayer "github.com/mattermost/mattermost-plugin-msteams/server/msteams/client_timerlayer"
 	"github.com/mattermost/mattermost-plugin-msteams/server/store"
+	"github.com/mattermost/mattermost-plugin-msteams/server/store/cachelayer"
 	sqlstore "github.com/mattermost/mattermost-plugin-msteams/server/store/sqlstore"
 	timerlayer "github.com/mattermost/mattermost-plugin-msteams/server/store/timerlayer"
 )
@@ -45,9 +50,36 @@ const (
 	msteamsUserTypeGuest         = "Guest"
 	metricsJobName               = "metrics"
 	checkCredentialsJobName      = "check_credentials" //#nosec G101 -- This is a false positive
-
-	updateMetricsTaskFrequency = 15 * time.Minute
-	metricsActiveUsersRange    = 7 * 24 * time.Hour
+	autoLinkUsersJobName         = "auto_link_users"
+	enforceMembershipJobName     = "enforce_membership"
+	expireInvitesJobName         = "expire_invites"
+	missedMessagesJobName        = "missed_messages"
+	reconcileUsersJobName        = "reconcile_users"
+	cleanupCodeVerifiersJobName  = "cleanup_code_verifiers"
+
+	updateMetricsTaskFrequency    = 15 * time.Minute
+	autoLinkUsersTaskFrequency    = 1 * time.Hour
+	enforceMembershipFrequency    = 1 * time.Hour
+	expireInvitesFrequency        = 1 * time.Hour
+	missedMessagesFrequency       = 1 * time.Hour
+	reconcileUsersFrequency       = 24 * time.Hour
+	cleanupCodeVerifiersFrequency = 24 * time.Hour
+	metricsActiveUsersRange       = 7 * 24 * time.Hour
+
+	// Chats are cached on each server for a short time only, as their members may change without
+	// notification.
+	chatCacheSize = 10000
+	chatCacheTTL  = 2 * time.Minute
+
+	// The presence of connected users is invalidated as the presence subscriptions notify of
+	// changes, and only expires in case some notifications are missed.
+	presenceCacheSize = 50000
+	presenceCacheTTL  = 10 * time.Minute
+
+	// The users are invalidated across the servers as they change, and only expire in case some
+	// invalidations are missed.
+	userCacheSize = 50000
+	userCacheTTL  = 10 * time.Minute
 )
 
 // Plugin implements the interface expected by the Mattermost server to communicate between the server and plugin processes.
@@ -76,13 +108,25 @@ type Plugin struct {
 	connectClusterMutex       *cluster.Mutex
 	monitor                   *Monitor
 	checkCredentialsJob       *cluster.Job
+	autoLinkUsersJob          *cluster.Job
+	enforceMembershipJob      *cluster.Job
+	expireInvitesJob          *cluster.Job
+	missedMessagesJob         *cluster.Job
+	reconcileUsersJob         *cluster.Job
+	cleanupCodeVerifiersJob   *cluster.Job
 	apiHandler                *API
 
 	activityHandler *ActivityHandler
 
-	clientBuilderWithToken func(string, string, string, string, *oauth2.Token, *pluginapi.LogService) msteams.Client
+	clientBuilderWithToken func(msteams.Cloud, string, string, string, msteams.AppCredentials, *http.Transport, *oauth2.Token, *pluginapi.LogService) msteams.Client
+	appClientBuilder       func(msteams.Cloud, string, string, msteams.AppCredentials, *http.Transport, *pluginapi.LogService) msteams.Client
 	metricsService         metrics.Metrics
 	metricsHandler         http.Handler
+	clientRetrier          *client_retrylayer.Retrier
+	clientBreakers         *client_breakerlayer.Breakers
+	chatCache              *client_cachelayer.ChatCache
+	presenceCache          *client_cachelayer.PresenceCache
+	userCaches             *cachelayer.Caches
 	metricsJob             *cluster.Job
 
 	subCommands      []string
@@ -113,6 +157,10 @@ func (p *Plugin) GetTenantID() string {
 	return p.getConfiguration().TenantID
 }
 
+func (p *Plugin) GetCloud() msteams.Cloud {
+	return p.getConfiguration().Cloud()
+}
+
 func (p *Plugin) GetMaxSizeForCompleteDownload() int {
 	return p.getConfiguration().MaxSizeForCompleteDownload
 }
@@ -192,7 +240,10 @@ func (p *Plugin) GetClientForUser(userID string) (msteams.Client, error) {
 		return nil, errors.New("not connected user")
 	}
 
-	client := p.clientBuilderWithToken(p.GetURL()+"/oauth-redirect", p.getConfiguration().TenantID, p.getConfiguration().ClientID, p.getConfiguration().ClientSecret, token, &p.apiClient.Log)
+	config := p.getConfiguration()
+	client := p.clientBuilderWithToken(config.Cloud(), p.GetURL()+"/oauth-redirect", config.TenantID, config.ClientID, config.AppCredentials(), config.HTTPTransport(), token, &p.apiClient.Log)
+	client = client_retrylayer.New(client, config.TenantID, p.clientRetrier)
+	client = client_breakerlayer.New(client, p.clientBreakers)
 	client = client_timerlayer.New(client, p.GetMetrics())
 	client = client_disconnectionlayer.New(client, userID, p.OnDisconnectedTokenHandler)
 
@@ -231,14 +282,19 @@ func (p *Plugin) connectTeamsAppClient() error {
 		return nil
 	}
 
-	msteamsAppClient := msteams.NewApp(
+	msteamsAppClient := p.appClientBuilder(
+		p.getConfiguration().Cloud(),
 		p.getConfiguration().TenantID,
 		p.getConfiguration().ClientID,
-		p.getConfiguration().ClientSecret,
+		p.getConfiguration().AppCredentials(),
+		p.getConfiguration().HTTPTransport(),
 		&p.apiClient.Log,
 	)
 
-	p.msteamsAppClient = client_timerlayer.New(msteamsAppClient, p.GetMetrics())
+	msteamsAppClient = client_retrylayer.New(msteamsAppClient, p.getConfiguration().TenantID, p.clientRetrier)
+	msteamsAppClient = client_breakerlayer.New(msteamsAppClient, p.clientBreakers)
+	msteamsAppClient = client_timerlayer.New(msteamsAppClient, p.GetMetrics())
+	p.msteamsAppClient = client_cachelayer.New(msteamsAppClient, p.chatCache)
 	err := p.msteamsAppClient.Connect()
 	if err != nil {
 		p.API.LogError("Unable to connect to the app client", "error", err)
@@ -268,6 +324,10 @@ func (p *Plugin) start(isRestart bool) {
 	p.metricsService.ObserveConnectedUsersLimit(int64(p.configuration.ConnectedUsersAllowed))
 	p.metricsService.ObservePendingInvitesLimit(int64(p.configuration.ConnectedUsersMaxPendingInvites))
 
+	if isRestart {
+		p.clientBreakers.SetErrorRate(p.configuration.CircuitBreakerErrorRate)
+	}
+
 	// We don't restart the activity handler since it's stateless.
 	if !isRestart {
 		p.activityHandler.Start()
@@ -278,7 +338,7 @@ func (p *Plugin) start(isRestart bool) {
 		return
 	}
 
-	p.monitor = NewMonitor(p.GetClientForApp(), p.store, p.API, p.GetMetrics(), p.GetURL()+"/", p.getConfiguration().WebhookSecret, p.getConfiguration().EvaluationAPI)
+	p.monitor = NewMonitor(p.GetClientForApp(), p.store, p.API, p.GetMetrics(), p.presenceCache, p.GetURL()+"/", p.getConfiguration().WebhookSecret, p.getConfiguration().EvaluationAPI)
 	if err = p.monitor.Start(); err != nil {
 		p.API.LogError("Unable to start the monitoring system", "error", err.Error())
 	}
@@ -304,6 +364,89 @@ func (p *Plugin) start(isRestart bool) {
 		go p.checkCredentials()
 	}
 
+	if p.getConfiguration().AutoLinkUsers {
+		autoLinkUsersJob, jobErr := cluster.Schedule(
+			p.API,
+			autoLinkUsersJobName,
+			cluster.MakeWaitForRoundedInterval(autoLinkUsersTaskFrequency),
+			p.autoLinkUsers,
+		)
+		if jobErr != nil {
+			p.API.LogError("error in scheduling the auto-link users job", "error", jobErr)
+		} else {
+			p.autoLinkUsersJob = autoLinkUsersJob
+		}
+
+		// Run the job above right away so users don't wait for the first interval to be linked.
+		go p.autoLinkUsers()
+	}
+
+	if p.getConfiguration().ConnectedUsersEnforceMembership {
+		enforceMembershipJob, jobErr := cluster.Schedule(
+			p.API,
+			enforceMembershipJobName,
+			cluster.MakeWaitForRoundedInterval(enforceMembershipFrequency),
+			p.enforceConnectedUsersMembership,
+		)
+		if jobErr != nil {
+			p.API.LogError("error in scheduling the enforce membership job", "error", jobErr)
+		} else {
+			p.enforceMembershipJob = enforceMembershipJob
+		}
+	}
+
+	if p.getConfiguration().ConnectedUsersInviteExpiryDays > 0 {
+		expireInvitesJob, jobErr := cluster.Schedule(
+			p.API,
+			expireInvitesJobName,
+			cluster.MakeWaitForRoundedInterval(expireInvitesFrequency),
+			p.expireInvites,
+		)
+		if jobErr != nil {
+			p.API.LogError("error in scheduling the expire invites job", "error", jobErr)
+		} else {
+			p.expireInvitesJob = expireInvitesJob
+		}
+	}
+
+	if p.getConfiguration().TrackMissedMessages() {
+		missedMessagesJob, jobErr := cluster.Schedule(
+			p.API,
+			missedMessagesJobName,
+			cluster.MakeWaitForRoundedInterval(missedMessagesFrequency),
+			p.processMissedMessages,
+		)
+		if jobErr != nil {
+			p.API.LogError("error in scheduling the missed messages job", "error", jobErr)
+		} else {
+			p.missedMessagesJob = missedMessagesJob
+		}
+	}
+
+	reconcileUsersJob, jobErr := cluster.Schedule(
+		p.API,
+		reconcileUsersJobName,
+		cluster.MakeWaitForRoundedInterval(reconcileUsersFrequency),
+		p.reconcileUsers,
+	)
+	if jobErr != nil {
+		p.API.LogError("error in scheduling the reconcile users job", "error", jobErr)
+	} else {
+		p.reconcileUsersJob = reconcileUsersJob
+	}
+
+	cleanupCodeVerifiersJob, jobErr := cluster.Schedule(
+		p.API,
+		cleanupCodeVerifiersJobName,
+		cluster.MakeWaitForRoundedInterval(cleanupCodeVerifiersFrequency),
+		p.cleanupCodeVerifiers,
+	)
+	if jobErr != nil {
+		p.API.LogError("error in scheduling the cleanup code verifiers job", "error", jobErr)
+	} else {
+		p.cleanupCodeVerifiersJob = cleanupCodeVerifiersJob
+	}
+
 	// Unregister and re-register slash command to reflect any configuration changes.
 	if err = p.API.UnregisterCommand("", "msteams"); err != nil {
 		p.API.LogWarn("Failed to unregister command", "error", err)
@@ -311,6 +454,12 @@ func (p *Plugin) start(isRestart bool) {
 	if err = p.API.RegisterCommand(p.createCommand()); err != nil {
 		p.API.LogError("Failed to register command", "error", err)
 	}
+	if err = p.API.UnregisterCommand("", msteamsAdminCommand); err != nil {
+		p.API.LogWarn("Failed to unregister admin command", "error", err)
+	}
+	if err = p.API.RegisterCommand(p.createAdminCommand()); err != nil {
+		p.API.LogError("Failed to register admin command", "error", err)
+	}
 	p.API.LogDebug("plugin started")
 }
 
@@ -335,6 +484,48 @@ func (p *Plugin) stop(isRestart bool) {
 		p.checkCredentialsJob = nil
 	}
 
+	if p.autoLinkUsersJob != nil {
+		if err := p.autoLinkUsersJob.Close(); err != nil {
+			p.API.LogError("Failed to close background auto-link users job", "error", err)
+		}
+		p.autoLinkUsersJob = nil
+	}
+
+	if p.enforceMembershipJob != nil {
+		if err := p.enforceMembershipJob.Close(); err != nil {
+			p.API.LogError("Failed to close background enforce membership job", "error", err)
+		}
+		p.enforceMembershipJob = nil
+	}
+
+	if p.expireInvitesJob != nil {
+		if err := p.expireInvitesJob.Close(); err != nil {
+			p.API.LogError("Failed to close background expire invites job", "error", err)
+		}
+		p.expireInvitesJob = nil
+	}
+
+	if p.missedMessagesJob != nil {
+		if err := p.missedMessagesJob.Close(); err != nil {
+			p.API.LogError("Failed to close background missed messages job", "error", err)
+		}
+		p.missedMessagesJob = nil
+	}
+
+	if p.reconcileUsersJob != nil {
+		if err := p.reconcileUsersJob.Close(); err != nil {
+			p.API.LogError("Failed to close background reconcile users job", "error", err)
+		}
+		p.reconcileUsersJob = nil
+	}
+
+	if p.cleanupCodeVerifiersJob != nil {
+		if err := p.cleanupCodeVerifiersJob.Close(); err != nil {
+			p.API.LogError("Failed to close background cleanup code verifiers job", "error", err)
+		}
+		p.cleanupCodeVerifiersJob = nil
+	}
+
 	if !isRestart && p.metricsJob != nil {
 		if err := p.metricsJob.Close(); err != nil {
 			p.API.LogError("failed to close metrics job", "error", err)
@@ -391,6 +582,9 @@ func (p *Plugin) onActivate() error {
 	if p.clientBuilderWithToken == nil {
 		p.clientBuilderWithToken = msteams.NewTokenClient
 	}
+	if p.appClientBuilder == nil {
+		p.appClientBuilder = msteams.NewApp
+	}
 	err := p.generatePluginSecrets()
 	if err != nil {
 		return err
@@ -401,6 +595,11 @@ func (p *Plugin) onActivate() error {
 		PluginVersion:  manifest.Version,
 	})
 	p.metricsHandler = metrics.NewMetricsHandler(p.GetMetrics())
+	p.clientRetrier = client_retrylayer.NewRetrier(p.GetMetrics())
+	p.clientBreakers = client_breakerlayer.NewBreakers(p.GetMetrics(), p.getConfiguration().CircuitBreakerErrorRate)
+	p.chatCache = client_cachelayer.NewChatCache(p.GetMetrics(), chatCacheSize, chatCacheTTL)
+	p.presenceCache = client_cachelayer.NewPresenceCache(p.GetMetrics(), presenceCacheSize, presenceCacheTTL)
+	p.userCaches = cachelayer.NewCaches(p.GetMetrics(), userCacheSize, userCacheTTL, p.publishUserCachesInvalidation)
 
 	p.apiClient = pluginapi.NewClient(p.API, p.Driver)
 
@@ -455,7 +654,7 @@ func (p *Plugin) onActivate() error {
 			p.API,
 			func() []byte { return []byte(p.configuration.EncryptionKey) },
 		)
-		p.store = timerlayer.New(store, p.GetMetrics())
+		p.store = cachelayer.New(timerlayer.New(store, p.GetMetrics()), p.userCaches)
 
 		if err = p.store.Init(p.remoteID); err != nil {
 			return err
@@ -499,6 +698,17 @@ func (p *Plugin) OnDeactivate() error {
 	return nil
 }
 
+func (p *Plugin) OnPluginClusterEvent(_ *plugin.Context, ev model.PluginClusterEvent) {
+	switch ev.Id {
+	case presencesClusterEventID:
+		p.handlePresencesClusterEvent(ev.Data)
+	case userCachesClusterEventID:
+		p.handleUserCachesClusterEvent(ev.Data)
+	default:
+		p.API.LogWarn("Ignoring unknown cluster event", "event_id", ev.Id)
+	}
+}
+
 func generateSecret() (string, error) {
 	b := make([]byte, 256)
 	_, err := rand.Read(b)
@@ -580,3 +790,34 @@ func (p *Plugin) updateMetrics() {
 		stat.observeData(data)
 	}
 }
+
+// userCachesClusterEventID identifies the cluster events keeping the user caches of the store on
+// all the servers consistent.
+const userCachesClusterEventID = "user_caches"
+
+// publishUserCachesInvalidation has the other servers forget the users changed on this server.
+func (p *Plugin) publishUserCachesInvalidation(invalidation cachelayer.Invalidation) {
+	data, err := json.Marshal(invalidation)
+	if err != nil {
+		p.API.LogWarn("Failed to marshal user caches invalidation", "error", err.Error())
+		return
+	}
+
+	if err := p.API.PublishPluginClusterEvent(
+		model.PluginClusterEvent{Id: userCachesClusterEventID, Data: data},
+		model.PluginClusterEventSendOptions{SendType: model.PluginClusterEventSendTypeReliable},
+	); err != nil {
+		p.API.LogWarn("Failed to publish user caches invalidation to the cluster", "error", err.Error())
+	}
+}
+
+// handleUserCachesClusterEvent applies the user caches invalidation published by another server.
+func (p *Plugin) handleUserCachesClusterEvent(data []byte) {
+	var invalidation cachelayer.Invalidation
+	if err := json.Unmarshal(data, &invalidation); err != nil {
+		p.API.LogWarn("Failed to unmarshal user caches invalidation", "error", err.Error())
+		return
+	}
+
+	p.userCaches.Invalidate(invalidation)
+}
@@ -26,6 +26,22 @@ func (p *Plugin) getNotificationPreference(userID string) bool {
 	return pref.Value == storemodels.PreferenceValueNotificationOn
 }
 
+// getNotificationPreferences returns whether each of the given users enabled notifications, reading
+// all their preferences at once. Users without a preference are off by default.
+func (p *Plugin) getNotificationPreferences(userIDs []string) (map[string]bool, error) {
+	values, err := p.GetStore().GetPreferencesForUsers(userIDs, PreferenceCategoryPlugin, storemodels.PreferenceNameNotification)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+
+	enabled := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		enabled[userID] = values[userID] == storemodels.PreferenceValueNotificationOn
+	}
+
+	return enabled, nil
+}
+
 func (p *Plugin) setNotificationPreference(userID string, enable bool) error {
 	value := storemodels.PreferenceValueNotificationOff
 	if enable {
@@ -57,6 +57,26 @@ func TestGetNotificationStatus(t *testing.T) {
 	})
 }
 
+func TestGetNotificationPreferences(t *testing.T) {
+	th := setupTestHelper(t)
+
+	team := th.SetupTeam(t)
+	userWithout := th.SetupUser(t, team)
+	userOff := th.SetupUser(t, team)
+	userOn := th.SetupUser(t, team)
+
+	require.Nil(t, th.p.updatePreferenceForUser(userOff.Id, storemodels.PreferenceNameNotification, storemodels.PreferenceValueNotificationOff))
+	require.Nil(t, th.p.updatePreferenceForUser(userOn.Id, storemodels.PreferenceNameNotification, storemodels.PreferenceValueNotificationOn))
+
+	enabled, err := th.p.getNotificationPreferences([]string{userWithout.Id, userOff.Id, userOn.Id})
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{
+		userWithout.Id: false,
+		userOff.Id:     false,
+		userOn.Id:      true,
+	}, enabled)
+}
+
 func TestSetNotificationStatus(t *testing.T) {
 	th := setupTestHelper(t)
 
@@ -4,9 +4,19 @@
 package main
 
 import (
+	"encoding/json"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/client_cachelayer"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
 )
 
+// presencesClusterEventID identifies the cluster events keeping the presence caches of all the
+// servers in sync.
+const presencesClusterEventID = "presences"
+
 const (
 	PresenceActivityAvailable               = "Available"
 	PresenceActivityAway                    = "Away"
@@ -51,3 +61,89 @@ func userPresenceIsActive(presence clientmodels.Presence) bool {
 	// Otherwise, assume the user is online.
 	return true
 }
+
+// presenceUpdate is a change to the cached presences, shared with the other servers.
+type presenceUpdate struct {
+	Presences          []clientmodels.Presence `json:"presences,omitempty"`
+	InvalidatedUserIDs []string                `json:"invalidated_user_ids,omitempty"`
+}
+
+func (u presenceUpdate) apply(cache *client_cachelayer.PresenceCache) {
+	cache.Invalidate(u.InvalidatedUserIDs...)
+	cache.Add(u.Presences...)
+}
+
+// publishPresenceUpdate applies the update to the presence cache of this server, and to those of
+// the other servers through a cluster event.
+func publishPresenceUpdate(api plugin.API, cache *client_cachelayer.PresenceCache, update presenceUpdate) {
+	update.apply(cache)
+	sendPresenceUpdate(api, update)
+}
+
+// sendPresenceUpdate sends the update to the other servers through a cluster event.
+func sendPresenceUpdate(api plugin.API, update presenceUpdate) {
+	data, err := json.Marshal(update)
+	if err != nil {
+		api.LogWarn("Failed to marshal presence update", "error", err.Error())
+		return
+	}
+
+	if err := api.PublishPluginClusterEvent(
+		model.PluginClusterEvent{Id: presencesClusterEventID, Data: data},
+		model.PluginClusterEventSendOptions{SendType: model.PluginClusterEventSendTypeReliable},
+	); err != nil {
+		api.LogWarn("Failed to publish presence update to the cluster", "error", err.Error())
+	}
+}
+
+// handlePresencesClusterEvent applies the presence update published by another server.
+func (p *Plugin) handlePresencesClusterEvent(data []byte) {
+	var update presenceUpdate
+	if err := json.Unmarshal(data, &update); err != nil {
+		p.API.LogWarn("Failed to unmarshal presence update", "error", err.Error())
+		return
+	}
+
+	update.apply(p.presenceCache)
+}
+
+// getPresencesForUsers returns the presence of the given users, from the cache when possible.
+// The presences fetched from Microsoft are returned separately, to be cached with
+// cacheConnectedPresences once the chat members are known.
+func (p *Plugin) getPresencesForUsers(userIDs []string) (presences map[string]clientmodels.Presence, fetched map[string]clientmodels.Presence, err error) {
+	presences, missing := p.presenceCache.Get(userIDs)
+	if len(missing) == 0 {
+		return presences, nil, nil
+	}
+
+	fetched, err = p.GetClientForApp().GetPresencesForUsers(missing)
+	for userID, presence := range fetched {
+		presences[userID] = presence
+	}
+
+	return presences, fetched, err
+}
+
+// cacheConnectedPresences caches the fetched presences of the connected users only, as the
+// presence subscriptions keep them up to date. Other users, including those auto-linked, aren't
+// covered by a subscription, so their presence is always fetched.
+func (p *Plugin) cacheConnectedPresences(fetched map[string]clientmodels.Presence) {
+	if len(fetched) == 0 {
+		return
+	}
+
+	userIDs := make([]string, 0, len(fetched))
+	for userID := range fetched {
+		userIDs = append(userIDs, userID)
+	}
+
+	connectedUserIDs, err := p.GetStore().FilterConnectedTeamsUserIDs(userIDs)
+	if err != nil {
+		p.API.LogWarn("Failed to get the connected users, not caching their presence", "error", err.Error())
+		return
+	}
+
+	for _, userID := range connectedUserIDs {
+		p.presenceCache.Add(fetched[userID])
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+const userDataPostsPerPage = 200
+
+// UserDataExport is everything the plugin holds about a Mattermost user.
+type UserDataExport struct {
+	ExportedAt         int64                       `json:"exported_at"`
+	UserID             string                      `json:"user_id"`
+	Username           string                      `json:"username"`
+	Email              string                      `json:"email"`
+	TeamsUserID        string                      `json:"teams_user_id,omitempty"`
+	Connected          bool                        `json:"connected"`
+	LastConnectAt      int64                       `json:"last_connect_at,omitempty"`
+	LastDisconnectAt   int64                       `json:"last_disconnect_at,omitempty"`
+	LastChatSentAt     int64                       `json:"last_chat_sent_at,omitempty"`
+	LastChatReceivedAt int64                       `json:"last_chat_received_at,omitempty"`
+	Whitelisted        bool                        `json:"whitelisted"`
+	Invite             *UserDataInvite             `json:"invite,omitempty"`
+	ExpiredInvite      *UserDataInvite             `json:"expired_invite,omitempty"`
+	MissedMessages     *UserDataMissedMessages     `json:"missed_messages,omitempty"`
+	Preferences        map[string]string           `json:"preferences"`
+	NotificationPosts  []*UserDataNotificationPost `json:"notification_posts"`
+}
+
+type UserDataInvite struct {
+	PendingSince  int64 `json:"pending_since"`
+	LastSentAt    int64 `json:"last_sent_at,omitempty"`
+	RemindersSent int   `json:"reminders_sent"`
+	ExpiredAt     int64 `json:"expired_at,omitempty"`
+}
+
+type UserDataMissedMessages struct {
+	Count        int   `json:"count"`
+	LastMissedAt int64 `json:"last_missed_at,omitempty"`
+	NudgedCount  int   `json:"nudged_count"`
+	LastNudgedAt int64 `json:"last_nudged_at,omitempty"`
+}
+
+type UserDataNotificationPost struct {
+	PostID   string `json:"post_id"`
+	CreateAt int64  `json:"create_at"`
+	Message  string `json:"message"`
+}
+
+type UserDataErasure struct {
+	UserID               string `json:"user_id"`
+	DeletedNotifications int    `json:"deleted_notification_posts"`
+}
+
+// exportUserData collects the data held about the given user across the plugin tables, the
+// plugin preferences and the direct messages sent by the bot.
+func (p *Plugin) exportUserData(user *model.User, actorUserID string) (*UserDataExport, error) {
+	rec := newAuditRecord(auditEventExportUserData, actorUserID)
+	defer p.API.LogAuditRec(rec)
+	model.AddEventParameterToAuditRec(rec, "user_id", user.Id)
+
+	export, err := p.collectUserData(user)
+	if err != nil {
+		rec.AddErrorDesc(err.Error())
+		rec.Fail()
+		return nil, err
+	}
+
+	rec.Success()
+	return export, nil
+}
+
+func (p *Plugin) collectUserData(user *model.User) (*UserDataExport, error) {
+	export := &UserDataExport{
+		ExportedAt:        model.GetMillis(),
+		UserID:            user.Id,
+		Username:          user.Username,
+		Email:             user.Email,
+		Preferences:       map[string]string{},
+		NotificationPosts: []*UserDataNotificationPost{},
+	}
+
+	userInfo, err := p.store.GetUserInfo(user.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getting user info")
+	}
+	if userInfo != nil {
+		export.TeamsUserID = userInfo.TeamsUserID
+		export.Connected = userInfo.Connected
+		export.LastConnectAt = toMillis(userInfo.LastConnectAt)
+		export.LastDisconnectAt = toMillis(userInfo.LastDisconnectAt)
+		export.LastChatSentAt = toMillis(userInfo.LastChatSentAt)
+		export.LastChatReceivedAt = toMillis(userInfo.LastChatReceivedAt)
+	}
+
+	if export.Whitelisted, err = p.store.IsUserWhitelisted(user.Id); err != nil {
+		return nil, errors.Wrap(err, "error in checking if user is whitelisted")
+	}
+
+	invitedUser, err := p.store.GetInvitedUser(user.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getting user invite")
+	}
+	if invitedUser != nil {
+		export.Invite = &UserDataInvite{
+			PendingSince:  toMillis(invitedUser.InvitePendingSince),
+			LastSentAt:    toMillis(invitedUser.InviteLastSentAt),
+			RemindersSent: invitedUser.InviteRemindersSent,
+		}
+	}
+
+	expiredInvite, err := p.store.GetExpiredInvite(user.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getting expired user invite")
+	}
+	if expiredInvite != nil {
+		export.ExpiredInvite = &UserDataInvite{
+			PendingSince:  toMillis(expiredInvite.InvitePendingSince),
+			RemindersSent: expiredInvite.InviteRemindersSent,
+			ExpiredAt:     toMillis(expiredInvite.InviteExpiredAt),
+		}
+	}
+
+	missedMessages, err := p.store.GetMissedMessages(user.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in getting missed messages")
+	}
+	if missedMessages != nil {
+		export.MissedMessages = &UserDataMissedMessages{
+			Count:        missedMessages.Count,
+			LastMissedAt: toMillis(missedMessages.LastMissedAt),
+			NudgedCount:  missedMessages.NudgedCount,
+			LastNudgedAt: toMillis(missedMessages.LastNudgedAt),
+		}
+	}
+
+	preferences, err := p.getPluginPreferences(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, preference := range preferences {
+		export.Preferences[preference.Name] = preference.Value
+	}
+
+	posts, err := p.getBotDirectPosts(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		export.NotificationPosts = append(export.NotificationPosts, &UserDataNotificationPost{
+			PostID:   post.Id,
+			CreateAt: post.CreateAt,
+			Message:  post.Message,
+		})
+	}
+
+	return export, nil
+}
+
+// eraseUserData removes the data held about the given user across the plugin tables and the
+// plugin preferences, optionally deleting the direct messages sent by the bot.
+func (p *Plugin) eraseUserData(user *model.User, deletePosts bool, actorUserID string) (*UserDataErasure, error) {
+	rec := newAuditRecord(auditEventEraseUserData, actorUserID)
+	defer p.API.LogAuditRec(rec)
+	model.AddEventParameterToAuditRec(rec, "user_id", user.Id)
+	model.AddEventParameterToAuditRec(rec, "delete_posts", deletePosts)
+
+	erasure, err := p.deleteUserData(user, deletePosts)
+	if err != nil {
+		rec.AddErrorDesc(err.Error())
+		rec.Fail()
+		return nil, err
+	}
+
+	rec.AddMeta("deleted_notification_posts", erasure.DeletedNotifications)
+	rec.Success()
+
+	p.API.LogInfo("Erased user data", "user_id", user.Id, "actor_user_id", actorUserID, "deleted_notification_posts", erasure.DeletedNotifications)
+
+	return erasure, nil
+}
+
+func (p *Plugin) deleteUserData(user *model.User, deletePosts bool) (*UserDataErasure, error) {
+	erasure := &UserDataErasure{UserID: user.Id}
+
+	wasConnected, err := p.IsUserConnected(user.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in checking if user is connected")
+	}
+
+	if err = p.store.DeleteUserData(user.Id); err != nil {
+		return nil, errors.Wrap(err, "error in deleting user data")
+	}
+
+	if wasConnected {
+		p.API.PublishWebSocketEvent(WSEventUserDisconnected, map[string]any{}, &model.WebsocketBroadcast{
+			UserId: user.Id,
+		})
+	}
+
+	if appErr := p.API.KVDelete(codeVerifierKeyPrefix + user.Id); appErr != nil {
+		return nil, errors.Wrap(appErr, "error in deleting code verifier")
+	}
+
+	preferences, err := p.getPluginPreferences(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if len(preferences) > 0 {
+		if appErr := p.API.DeletePreferencesForUser(user.Id, preferences); appErr != nil {
+			return nil, errors.Wrap(appErr, "error in deleting preferences")
+		}
+	}
+
+	if !deletePosts {
+		return erasure, nil
+	}
+
+	posts, err := p.getBotDirectPosts(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		if appErr := p.API.DeletePost(post.Id); appErr != nil {
+			return nil, errors.Wrapf(appErr, "error in deleting post %s", post.Id)
+		}
+		erasure.DeletedNotifications++
+	}
+
+	return erasure, nil
+}
+
+func (p *Plugin) getPluginPreferences(userID string) ([]model.Preference, error) {
+	preferences, appErr := p.API.GetPreferencesForUser(userID)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "error in getting preferences")
+	}
+
+	var result []model.Preference
+	for _, preference := range preferences {
+		if preference.Category == PreferenceCategoryPlugin {
+			result = append(result, preference)
+		}
+	}
+
+	return result, nil
+}
+
+// getBotDirectPosts returns the posts made by the bot in its direct channel with the user,
+// oldest first. The channel is looked up without being created, so users the bot never wrote
+// to have no posts.
+func (p *Plugin) getBotDirectPosts(userID string) ([]*model.Post, error) {
+	channel, appErr := p.API.GetChannelByName("", model.GetDMNameFromIds(userID, p.botUserID), false)
+	if appErr != nil {
+		if appErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, errors.Wrap(appErr, "error in getting bot direct channel")
+	}
+
+	var posts []*model.Post
+	for page := 0; ; page++ {
+		postList, appErr := p.API.GetPostsForChannel(channel.Id, page, userDataPostsPerPage)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "error in getting bot direct posts")
+		}
+
+		for _, postID := range postList.Order {
+			if post := postList.Posts[postID]; post != nil && post.UserId == p.botUserID {
+				posts = append(posts, post)
+			}
+		}
+
+		if len(postList.Order) < userDataPostsPerPage {
+			break
+		}
+	}
+
+	// Posts are returned newest first.
+	slices.Reverse(posts)
+
+	return posts, nil
+}
+
+// sendUserDataExport sends the export to the given user as a JSON file in a direct message from
+// the bot.
+func (p *Plugin) sendUserDataExport(userID string, user *model.User, export *UserDataExport) error {
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error in serializing user data")
+	}
+
+	channel, err := p.apiClient.Channel.GetDirect(userID, p.botUserID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get bot DM channel with user_id %s", userID)
+	}
+
+	fileInfo, err := p.apiClient.File.Upload(bytes.NewReader(data), userDataExportFileName(user), channel.Id)
+	if err != nil {
+		return errors.Wrap(err, "error in uploading user data")
+	}
+
+	return p.botSendDirectPost(userID, &model.Post{
+		Message: fmt.Sprintf("Here is the data held about @%s.", user.Username),
+		FileIds: model.StringArray{fileInfo.Id},
+	})
+}
+
+func userDataExportFileName(user *model.User) string {
+	return fmt.Sprintf("msteams-user-data-%s.json", user.Id)
+}
+
+func toMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixMilli()
+}
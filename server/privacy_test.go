@@ -0,0 +1,224 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
+)
+
+func TestExportUserData(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	t.Run("unknown to the plugin", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+
+		export, err := th.p.exportUserData(user, sysadmin.Id)
+		require.NoError(t, err)
+
+		assert.Equal(t, user.Id, export.UserID)
+		assert.Equal(t, user.Username, export.Username)
+		assert.Empty(t, export.TeamsUserID)
+		assert.False(t, export.Connected)
+		assert.False(t, export.Whitelisted)
+		assert.Nil(t, export.Invite)
+		assert.Nil(t, export.ExpiredInvite)
+		assert.Nil(t, export.MissedMessages)
+		assert.Empty(t, export.Preferences)
+		assert.Empty(t, export.NotificationPosts)
+
+		// The bot direct channel isn't created just to look for posts.
+		_, appErr := th.p.API.GetChannelByName("", model.GetDMNameFromIds(user.Id, th.p.botUserID), false)
+		require.NotNil(t, appErr)
+		assert.Equal(t, http.StatusNotFound, appErr.StatusCode)
+	})
+
+	t.Run("connected user", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+		th.MarkUserWhitelisted(t, user.Id)
+		require.NoError(t, th.p.updatePreferenceForUser(user.Id, storemodels.PreferenceNameNotification, storemodels.PreferenceValueNotificationOn))
+		require.NoError(t, th.p.SendMissedMessagesMessage(user, 3))
+
+		export, err := th.p.exportUserData(user, sysadmin.Id)
+		require.NoError(t, err)
+
+		assert.Equal(t, "t"+user.Id, export.TeamsUserID)
+		assert.True(t, export.Connected)
+		assert.NotZero(t, export.LastConnectAt)
+		assert.True(t, export.Whitelisted)
+		assert.Equal(t, map[string]string{storemodels.PreferenceNameNotification: storemodels.PreferenceValueNotificationOn}, export.Preferences)
+		require.Len(t, export.NotificationPosts, 1)
+		assert.Contains(t, export.NotificationPosts[0].Message, "You missed 3 Teams messages.")
+	})
+
+	t.Run("invited user with missed messages", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+		th.MarkUserInvited(t, user.Id)
+		require.NoError(t, th.p.store.RecordMissedMessage(user.Id, "t"+user.Id, time.Now()))
+
+		export, err := th.p.exportUserData(user, sysadmin.Id)
+		require.NoError(t, err)
+
+		require.NotNil(t, export.Invite)
+		assert.NotZero(t, export.Invite.PendingSince)
+		require.NotNil(t, export.MissedMessages)
+		assert.Equal(t, 1, export.MissedMessages.Count)
+	})
+}
+
+func TestEraseUserData(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	t.Run("keeping notifications", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+		th.MarkUserWhitelisted(t, user.Id)
+		require.NoError(t, th.p.store.RecordMissedMessage(user.Id, "t"+user.Id, time.Now()))
+		require.NoError(t, th.p.updatePreferenceForUser(user.Id, storemodels.PreferenceNameNotification, storemodels.PreferenceValueNotificationOn))
+		require.NoError(t, th.p.SendMissedMessagesMessage(user, 3))
+
+		erasure, err := th.p.eraseUserData(user, false, sysadmin.Id)
+		require.NoError(t, err)
+		assert.Equal(t, 0, erasure.DeletedNotifications)
+
+		th.assertUserCleanedUp(t, user.Id)
+
+		userInfo, err := th.p.store.GetUserInfo(user.Id)
+		require.NoError(t, err)
+		assert.Nil(t, userInfo)
+
+		_, appErr := th.p.API.GetPreferenceForUser(user.Id, PreferenceCategoryPlugin, storemodels.PreferenceNameNotification)
+		require.NotNil(t, appErr)
+
+		posts, err := th.p.getBotDirectPosts(user.Id)
+		require.NoError(t, err)
+		assert.Len(t, posts, 1)
+	})
+
+	t.Run("deleting notifications", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+		th.MarkUserInvited(t, user.Id)
+		require.NoError(t, th.p.SendMissedMessagesMessage(user, 3))
+		require.NoError(t, th.p.SendMissedMessagesMessage(user, 5))
+
+		erasure, err := th.p.eraseUserData(user, true, sysadmin.Id)
+		require.NoError(t, err)
+		assert.Equal(t, 2, erasure.DeletedNotifications)
+
+		th.assertUserCleanedUp(t, user.Id)
+
+		posts, err := th.p.getBotDirectPosts(user.Id)
+		require.NoError(t, err)
+		assert.Empty(t, posts)
+	})
+
+	t.Run("deleting notifications of a user the bot never wrote to", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+
+		erasure, err := th.p.eraseUserData(user, true, sysadmin.Id)
+		require.NoError(t, err)
+		assert.Equal(t, 0, erasure.DeletedNotifications)
+
+		_, appErr := th.p.API.GetChannelByName("", model.GetDMNameFromIds(user.Id, th.p.botUserID), false)
+		require.NotNil(t, appErr)
+		assert.Equal(t, http.StatusNotFound, appErr.StatusCode)
+	})
+}
+
+func TestUserDataAPI(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	sendRequest := func(t *testing.T, user *model.User, method, path string) (int, []byte) {
+		t.Helper()
+		client1 := th.SetupClient(t, user.Id)
+
+		request, err := http.NewRequest(method, th.pluginURL(t, path), nil)
+		require.NoError(t, err)
+
+		request.Header.Set(model.HeaderAuth, client1.AuthType+" "+client1.AuthToken)
+
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, response.Body.Close())
+		})
+
+		bodyBytes, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+
+		return response.StatusCode, bodyBytes
+	}
+
+	t.Run("insufficient permissions", func(t *testing.T) {
+		th.Reset(t)
+		user := th.SetupUser(t, team)
+
+		statusCode, _ := sendRequest(t, user, http.MethodGet, "/users/"+user.Id+"/data")
+		assert.Equal(t, http.StatusForbidden, statusCode)
+
+		statusCode, _ = sendRequest(t, user, http.MethodDelete, "/users/"+user.Id+"/data")
+		assert.Equal(t, http.StatusForbidden, statusCode)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+
+		statusCode, _ := sendRequest(t, sysadmin, http.MethodGet, "/users/unknown/data")
+		assert.Equal(t, http.StatusNotFound, statusCode)
+	})
+
+	t.Run("export and erase", func(t *testing.T) {
+		th.Reset(t)
+		sysadmin := th.SetupSysadmin(t, team)
+		user := th.SetupUser(t, team)
+		th.ConnectUser(t, user.Id)
+		require.NoError(t, th.p.SendMissedMessagesMessage(user, 3))
+
+		statusCode, body := sendRequest(t, sysadmin, http.MethodGet, "/users/"+user.Username+"/data")
+		require.Equal(t, http.StatusOK, statusCode)
+
+		var export UserDataExport
+		require.NoError(t, json.Unmarshal(body, &export))
+		assert.Equal(t, user.Id, export.UserID)
+		assert.Equal(t, "t"+user.Id, export.TeamsUserID)
+		assert.True(t, export.Connected)
+		assert.Len(t, export.NotificationPosts, 1)
+
+		statusCode, body = sendRequest(t, sysadmin, http.MethodDelete, "/users/"+user.Email+"/data?delete_posts=true")
+		require.Equal(t, http.StatusOK, statusCode)
+
+		var erasure UserDataErasure
+		require.NoError(t, json.Unmarshal(body, &erasure))
+		assert.Equal(t, user.Id, erasure.UserID)
+		assert.Equal(t, 1, erasure.DeletedNotifications)
+
+		th.assertUserCleanedUp(t, user.Id)
+	})
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package cachelayer
+
+import (
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"golang.org/x/oauth2"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+)
+
+// Invalidation identifies the users whose cached entries are to be forgotten, on this server and,
+// once published, on the other servers too.
+type Invalidation struct {
+	MattermostUserIDs []string `json:"mattermost_user_ids,omitempty"`
+	TeamsUserIDs      []string `json:"teams_user_ids,omitempty"`
+}
+
+// Publish forgets the cached entries of the users on this server, then hands the invalidation to
+// the publisher given at creation, if any, for the other servers to do the same.
+func (c *Caches) Publish(invalidation Invalidation) {
+	c.Invalidate(invalidation)
+	if c.publish != nil {
+		c.publish(invalidation)
+	}
+}
+
+type cacheEntry[V any] struct {
+	value V
+	err   error
+}
+
+// cache is a read-through cache of the results of a store method, keyed by its only parameter.
+type cache[V any] struct {
+	name    string
+	metrics metrics.Metrics
+	entries *expirable.LRU[string, cacheEntry[V]]
+
+	// generation is bumped by invalidations, so that results loaded meanwhile aren't cached.
+	generation atomic.Uint64
+}
+
+func newCache[V any](metrics metrics.Metrics, name string, size int, ttl time.Duration) *cache[V] {
+	return &cache[V]{
+		name:    name,
+		metrics: metrics,
+		entries: expirable.NewLRU[string, cacheEntry[V]](size, nil, ttl),
+	}
+}
+
+// get returns the cached result for the key, loading it on misses. Missing rows are cached too,
+// being the common case for users who never connected.
+func (c *cache[V]) get(key string, load func() (V, error)) (V, error) {
+	if entry, ok := c.entries.Get(key); ok {
+		c.metrics.ObserveStoreCacheRequest(c.name, true)
+		return cloneValue(entry.value), entry.err
+	}
+	c.metrics.ObserveStoreCacheRequest(c.name, false)
+
+	generation := c.generation.Load()
+	value, err := load()
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return value, err
+	}
+
+	if c.generation.Load() == generation {
+		c.entries.Add(key, cacheEntry[V]{value: cloneValue(value), err: err})
+	}
+
+	return value, err
+}
+
+// getMany returns the cached results for the keys, loading the missing ones at once. Keys without
+// a row are left out of the results, and cached as missing rows.
+func (c *cache[V]) getMany(keys []string, load func(keys []string) (map[string]V, error)) (map[string]V, error) {
+	values := make(map[string]V, len(keys))
+	var missing []string
+	for _, key := range keys {
+		entry, ok := c.entries.Get(key)
+		c.metrics.ObserveStoreCacheRequest(c.name, ok)
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+
+		if entry.err == nil {
+			values[key] = cloneValue(entry.value)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	generation := c.generation.Load()
+	loaded, err := load(missing)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheable := c.generation.Load() == generation
+	for _, key := range missing {
+		value, ok := loaded[key]
+		if ok {
+			values[key] = value
+		}
+
+		if cacheable {
+			entry := cacheEntry[V]{value: cloneValue(value)}
+			if !ok {
+				entry.err = sql.ErrNoRows
+			}
+			c.entries.Add(key, entry)
+		}
+	}
+
+	return values, nil
+}
+
+func (c *cache[V]) invalidate(keys ...string) {
+	c.generation.Add(1)
+	for _, key := range keys {
+		c.entries.Remove(key)
+	}
+}
+
+func (c *cache[V]) purge() {
+	c.generation.Add(1)
+	c.entries.Purge()
+}
+
+// cloneValue copies the cached values callers might modify.
+func cloneValue[V any](value V) V {
+	if token, ok := any(value).(*oauth2.Token); ok && token != nil {
+		tokenCopy := *token
+		return any(&tokenCopy).(V)
+	}
+
+	return value
+}
+
+// TeamsToMattermostUserIDs shares the cache of TeamsToMattermostUserID, looking up the users
+// missing from it at once.
+func (s *CacheLayer) TeamsToMattermostUserIDs(userIDs []string) (map[string]string, error) {
+	return s.caches.teamsToMattermostUserID.getMany(userIDs, s.Store.TeamsToMattermostUserIDs)
+}
+
+// userInvalidation returns the invalidation of the given Mattermost user, along with the MS Teams
+// user linked to it before the change, and the given ones.
+func (s *CacheLayer) userInvalidation(mmUserID string, teamsUserIDs ...string) Invalidation {
+	if teamsUserID, err := s.Store.MattermostToTeamsUserID(mmUserID); err == nil {
+		teamsUserIDs = append(teamsUserIDs, teamsUserID)
+	}
+
+	return Invalidation{
+		MattermostUserIDs: []string{mmUserID},
+		TeamsUserIDs:      teamsUserIDs,
+	}
+}
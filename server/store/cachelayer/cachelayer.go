@@ -0,0 +1,112 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Code generated by "make generate"
+// DO NOT EDIT
+
+package cachelayer
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/store"
+
+	"golang.org/x/oauth2"
+)
+
+// Caches holds the caches of the CacheLayer, shared with the other servers through invalidations.
+type Caches struct {
+	publish func(Invalidation)
+
+	getTokenForMSTeamsUser    *cache[*oauth2.Token]
+	getTokenForMattermostUser *cache[*oauth2.Token]
+	mattermostToTeamsUserID   *cache[string]
+	teamsToMattermostUserID   *cache[string]
+}
+
+// NewCaches creates the caches, each holding up to size entries for the given time. The
+// invalidations are handed to publish, if any, for the other servers to apply.
+func NewCaches(metrics metrics.Metrics, size int, ttl time.Duration, publish func(Invalidation)) *Caches {
+	return &Caches{
+		publish:                   publish,
+		getTokenForMSTeamsUser:    newCache[*oauth2.Token](metrics, "GetTokenForMSTeamsUser", size, ttl),
+		getTokenForMattermostUser: newCache[*oauth2.Token](metrics, "GetTokenForMattermostUser", size, ttl),
+		mattermostToTeamsUserID:   newCache[string](metrics, "MattermostToTeamsUserID", size, ttl),
+		teamsToMattermostUserID:   newCache[string](metrics, "TeamsToMattermostUserID", size, ttl),
+	}
+}
+
+// Invalidate forgets the cached entries of the users on this server only, e.g. when published by
+// another server.
+func (c *Caches) Invalidate(invalidation Invalidation) {
+	c.getTokenForMSTeamsUser.invalidate(invalidation.TeamsUserIDs...)
+	c.getTokenForMattermostUser.invalidate(invalidation.MattermostUserIDs...)
+	c.mattermostToTeamsUserID.invalidate(invalidation.MattermostUserIDs...)
+	c.teamsToMattermostUserID.invalidate(invalidation.TeamsUserIDs...)
+}
+
+// Purge forgets all the cached entries on this server.
+func (c *Caches) Purge() {
+	c.getTokenForMSTeamsUser.purge()
+	c.getTokenForMattermostUser.purge()
+	c.mattermostToTeamsUserID.purge()
+	c.teamsToMattermostUserID.purge()
+}
+
+type CacheLayer struct {
+	store.Store
+	caches *Caches
+}
+
+func (s *CacheLayer) DeleteUserData(mmUserID string) error {
+	invalidation := s.userInvalidation(mmUserID)
+	err := s.Store.DeleteUserData(mmUserID)
+	s.caches.Publish(invalidation)
+	return err
+}
+
+func (s *CacheLayer) DeleteUserInfo(mmUserID string) error {
+	invalidation := s.userInvalidation(mmUserID)
+	err := s.Store.DeleteUserInfo(mmUserID)
+	s.caches.Publish(invalidation)
+	return err
+}
+
+func (s *CacheLayer) GetTokenForMSTeamsUser(userID string) (*oauth2.Token, error) {
+	return s.caches.getTokenForMSTeamsUser.get(userID, func() (*oauth2.Token, error) {
+		return s.Store.GetTokenForMSTeamsUser(userID)
+	})
+}
+
+func (s *CacheLayer) GetTokenForMattermostUser(userID string) (*oauth2.Token, error) {
+	return s.caches.getTokenForMattermostUser.get(userID, func() (*oauth2.Token, error) {
+		return s.Store.GetTokenForMattermostUser(userID)
+	})
+}
+
+func (s *CacheLayer) MattermostToTeamsUserID(userID string) (string, error) {
+	return s.caches.mattermostToTeamsUserID.get(userID, func() (string, error) {
+		return s.Store.MattermostToTeamsUserID(userID)
+	})
+}
+
+func (s *CacheLayer) SetUserInfo(userID string, msTeamsUserID string, token *oauth2.Token) error {
+	invalidation := s.userInvalidation(userID, msTeamsUserID)
+	err := s.Store.SetUserInfo(userID, msTeamsUserID, token)
+	s.caches.Publish(invalidation)
+	return err
+}
+
+func (s *CacheLayer) TeamsToMattermostUserID(userID string) (string, error) {
+	return s.caches.teamsToMattermostUserID.get(userID, func() (string, error) {
+		return s.Store.TeamsToMattermostUserID(userID)
+	})
+}
+
+func New(childStore store.Store, caches *Caches) *CacheLayer {
+	return &CacheLayer{
+		Store:  childStore,
+		caches: caches,
+	}
+}
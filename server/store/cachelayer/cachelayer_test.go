@@ -0,0 +1,208 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package cachelayer
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	metricsmocks "github.com/mattermost/mattermost-plugin-msteams/server/metrics/mocks"
+	storemocks "github.com/mattermost/mattermost-plugin-msteams/server/store/mocks"
+)
+
+func TestCacheLayer(t *testing.T) {
+	setup := func(t *testing.T) (*CacheLayer, *storemocks.Store, *[]Invalidation) {
+		t.Helper()
+
+		mockStore := &storemocks.Store{}
+		mockMetrics := &metricsmocks.Metrics{}
+		mockMetrics.On("ObserveStoreCacheRequest", mock.AnythingOfType("string"), mock.AnythingOfType("bool"))
+		t.Cleanup(func() {
+			mockStore.AssertExpectations(t)
+		})
+
+		var published []Invalidation
+		caches := NewCaches(mockMetrics, 10, time.Minute, func(invalidation Invalidation) {
+			published = append(published, invalidation)
+		})
+		return New(mockStore, caches), mockStore, &published
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		store, mockStore, _ := setup(t)
+		mockStore.On("TeamsToMattermostUserID", "teams-user-id").Return("mm-user-id", nil).Once()
+
+		for i := 0; i < 2; i++ {
+			mmUserID, err := store.TeamsToMattermostUserID("teams-user-id")
+			require.NoError(t, err)
+			assert.Equal(t, "mm-user-id", mmUserID)
+		}
+	})
+
+	t.Run("missing rows cached", func(t *testing.T) {
+		store, mockStore, _ := setup(t)
+		mockStore.On("GetTokenForMattermostUser", "mm-user-id").Return(nil, sql.ErrNoRows).Once()
+
+		for i := 0; i < 2; i++ {
+			token, err := store.GetTokenForMattermostUser("mm-user-id")
+			assert.Equal(t, sql.ErrNoRows, err)
+			assert.Nil(t, token)
+		}
+	})
+
+	t.Run("other errors not cached", func(t *testing.T) {
+		store, mockStore, _ := setup(t)
+		mockStore.On("MattermostToTeamsUserID", "mm-user-id").Return("", errors.New("failed")).Once()
+		mockStore.On("MattermostToTeamsUserID", "mm-user-id").Return("teams-user-id", nil).Once()
+
+		_, err := store.MattermostToTeamsUserID("mm-user-id")
+		require.Error(t, err)
+
+		teamsUserID, err := store.MattermostToTeamsUserID("mm-user-id")
+		require.NoError(t, err)
+		assert.Equal(t, "teams-user-id", teamsUserID)
+	})
+
+	t.Run("bulk lookups share the cache", func(t *testing.T) {
+		store, mockStore, _ := setup(t)
+		mockStore.On("TeamsToMattermostUserID", "teams-user-id-1").Return("mm-user-id-1", nil).Once()
+		_, _ = store.TeamsToMattermostUserID("teams-user-id-1")
+
+		// Only the users missing from the cache are looked up.
+		mockStore.On("TeamsToMattermostUserIDs", []string{"teams-user-id-2", "teams-user-id-3"}).Return(map[string]string{
+			"teams-user-id-2": "mm-user-id-2",
+		}, nil).Once()
+
+		for i := 0; i < 2; i++ {
+			mmUserIDs, err := store.TeamsToMattermostUserIDs([]string{"teams-user-id-1", "teams-user-id-2", "teams-user-id-3"})
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{
+				"teams-user-id-1": "mm-user-id-1",
+				"teams-user-id-2": "mm-user-id-2",
+			}, mmUserIDs)
+		}
+
+		_, err := store.TeamsToMattermostUserID("teams-user-id-3")
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	t.Run("bulk lookup errors not cached", func(t *testing.T) {
+		store, mockStore, _ := setup(t)
+		mockStore.On("TeamsToMattermostUserIDs", []string{"teams-user-id"}).Return(nil, errors.New("failed")).Once()
+		mockStore.On("TeamsToMattermostUserIDs", []string{"teams-user-id"}).Return(map[string]string{"teams-user-id": "mm-user-id"}, nil).Once()
+
+		_, err := store.TeamsToMattermostUserIDs([]string{"teams-user-id"})
+		require.Error(t, err)
+
+		mmUserIDs, err := store.TeamsToMattermostUserIDs([]string{"teams-user-id"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"teams-user-id": "mm-user-id"}, mmUserIDs)
+	})
+
+	t.Run("tokens copied", func(t *testing.T) {
+		store, mockStore, _ := setup(t)
+		mockStore.On("GetTokenForMSTeamsUser", "teams-user-id").Return(&oauth2.Token{AccessToken: "token"}, nil).Once()
+
+		token, err := store.GetTokenForMSTeamsUser("teams-user-id")
+		require.NoError(t, err)
+		token.AccessToken = "changed"
+
+		token, err = store.GetTokenForMSTeamsUser("teams-user-id")
+		require.NoError(t, err)
+		assert.Equal(t, "token", token.AccessToken)
+	})
+
+	t.Run("set user info invalidates and publishes", func(t *testing.T) {
+		store, mockStore, published := setup(t)
+		mockStore.On("TeamsToMattermostUserID", "old-teams-user-id").Return("mm-user-id", nil).Once()
+		mockStore.On("TeamsToMattermostUserID", "new-teams-user-id").Return("", sql.ErrNoRows).Once()
+		mockStore.On("GetTokenForMattermostUser", "mm-user-id").Return(nil, sql.ErrNoRows).Once()
+		for _, teamsUserID := range []string{"old-teams-user-id", "new-teams-user-id"} {
+			_, _ = store.TeamsToMattermostUserID(teamsUserID)
+		}
+		_, _ = store.GetTokenForMattermostUser("mm-user-id")
+
+		// The user is linked to another MS Teams user.
+		token := &oauth2.Token{AccessToken: "token"}
+		mockStore.On("MattermostToTeamsUserID", "mm-user-id").Return("old-teams-user-id", nil).Once()
+		mockStore.On("SetUserInfo", "mm-user-id", "new-teams-user-id", token).Return(nil).Once()
+		require.NoError(t, store.SetUserInfo("mm-user-id", "new-teams-user-id", token))
+
+		assert.Equal(t, []Invalidation{{
+			MattermostUserIDs: []string{"mm-user-id"},
+			TeamsUserIDs:      []string{"new-teams-user-id", "old-teams-user-id"},
+		}}, *published)
+
+		mockStore.On("TeamsToMattermostUserID", "old-teams-user-id").Return("", sql.ErrNoRows).Once()
+		mockStore.On("TeamsToMattermostUserID", "new-teams-user-id").Return("mm-user-id", nil).Once()
+		mockStore.On("GetTokenForMattermostUser", "mm-user-id").Return(token, nil).Once()
+
+		_, err := store.TeamsToMattermostUserID("old-teams-user-id")
+		assert.Equal(t, sql.ErrNoRows, err)
+		mmUserID, err := store.TeamsToMattermostUserID("new-teams-user-id")
+		require.NoError(t, err)
+		assert.Equal(t, "mm-user-id", mmUserID)
+		cachedToken, err := store.GetTokenForMattermostUser("mm-user-id")
+		require.NoError(t, err)
+		assert.Equal(t, token, cachedToken)
+	})
+
+	t.Run("delete user info invalidates and publishes", func(t *testing.T) {
+		store, mockStore, published := setup(t)
+		mockStore.On("MattermostToTeamsUserID", "mm-user-id").Return("teams-user-id", nil).Once()
+		_, _ = store.MattermostToTeamsUserID("mm-user-id")
+
+		mockStore.On("MattermostToTeamsUserID", "mm-user-id").Return("teams-user-id", nil).Once()
+		mockStore.On("DeleteUserInfo", "mm-user-id").Return(nil).Once()
+		require.NoError(t, store.DeleteUserInfo("mm-user-id"))
+
+		assert.Equal(t, []Invalidation{{
+			MattermostUserIDs: []string{"mm-user-id"},
+			TeamsUserIDs:      []string{"teams-user-id"},
+		}}, *published)
+
+		mockStore.On("MattermostToTeamsUserID", "mm-user-id").Return("", sql.ErrNoRows).Once()
+		_, err := store.MattermostToTeamsUserID("mm-user-id")
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	t.Run("invalidated by other servers", func(t *testing.T) {
+		store, mockStore, published := setup(t)
+		mockStore.On("MattermostToTeamsUserID", "mm-user-id").Return("teams-user-id", nil).Twice()
+		_, _ = store.MattermostToTeamsUserID("mm-user-id")
+
+		store.caches.Invalidate(Invalidation{MattermostUserIDs: []string{"mm-user-id"}})
+		assert.Empty(t, *published)
+
+		_, _ = store.MattermostToTeamsUserID("mm-user-id")
+	})
+
+	t.Run("purged", func(t *testing.T) {
+		store, mockStore, published := setup(t)
+		mockStore.On("GetTokenForMattermostUser", "mm-user-id").Return(&oauth2.Token{AccessToken: "token"}, nil).Twice()
+		_, _ = store.GetTokenForMattermostUser("mm-user-id")
+
+		store.caches.Purge()
+		assert.Empty(t, *published)
+
+		_, _ = store.GetTokenForMattermostUser("mm-user-id")
+	})
+
+	t.Run("not cached when invalidated while loading", func(t *testing.T) {
+		store, mockStore, _ := setup(t)
+		mockStore.On("MattermostToTeamsUserID", "mm-user-id").Return("teams-user-id", nil).Run(func(mock.Arguments) {
+			store.caches.Invalidate(Invalidation{MattermostUserIDs: []string{"mm-user-id"}})
+		}).Twice()
+
+		_, _ = store.MattermostToTeamsUserID("mm-user-id")
+		_, _ = store.MattermostToTeamsUserID("mm-user-id")
+	})
+}
@@ -15,6 +15,7 @@ import (
 	"log"
 	"os"
 	"path"
+	"slices"
 	"strings"
 	"text/template"
 
@@ -59,6 +60,10 @@ func main() {
 	if err := buildTransactionalStore(); err != nil {
 		log.Fatal(err)
 	}
+
+	if err := buildCacheLayer(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func buildTimerLayer() error {
@@ -87,10 +92,11 @@ func buildTimerLayer() error {
 
 func buildTransactionalStore() error {
 	topLevelFunctionsToSkip := map[string]bool{
-		"Init":              true,
-		"UserHasConnected":  true,
-		"VerifyOAuth2State": true,
-		"StoreOAuth2State":  true,
+		"Init":               true,
+		"UserHasConnected":   true,
+		"VerifyOAuth2State":  true,
+		"ConsumeOAuth2State": true,
+		"StoreOAuth2State":   true,
 	}
 
 	code, err := generateTransactionalStoreLayer(topLevelFunctionsToSkip)
@@ -105,6 +111,85 @@ func buildTransactionalStore() error {
 	return os.WriteFile(path.Join("sqlstore", "public_methods.go"), formatedCode, 0600)
 }
 
+// Keys of the cached store methods, naming the user IDs of an invalidation they're forgotten by.
+const (
+	mattermostUserIDKey = "MattermostUserIDs"
+	teamsUserIDKey      = "TeamsUserIDs"
+)
+
+// cachedMethods are the store methods whose results the cache layer keeps, keyed by their only
+// parameter. They read from the master rather than a replica, which might otherwise still return
+// the results just invalidated, to be cached again.
+var cachedMethods = map[string]string{
+	"TeamsToMattermostUserID":   teamsUserIDKey,
+	"MattermostToTeamsUserID":   mattermostUserIDKey,
+	"GetTokenForMattermostUser": mattermostUserIDKey,
+	"GetTokenForMSTeamsUser":    teamsUserIDKey,
+}
+
+// userIDParams names the parameters of a store method holding the IDs of the user it changes.
+type userIDParams struct {
+	MattermostUserID string
+	TeamsUserID      string
+}
+
+// userInvalidatingMethods are the store methods changing the users, whose cached entries are
+// forgotten afterwards.
+var userInvalidatingMethods = map[string]userIDParams{
+	"SetUserInfo":    {MattermostUserID: "userID", TeamsUserID: "msTeamsUserID"},
+	"DeleteUserInfo": {MattermostUserID: "mmUserID"},
+	"DeleteUserData": {MattermostUserID: "mmUserID"},
+}
+
+func buildCacheLayer() error {
+	metadata, err := extractStoreMetadata(map[string]bool{})
+	if err != nil {
+		return err
+	}
+
+	for methodName, method := range metadata.Methods {
+		if key, ok := cachedMethods[methodName]; ok {
+			if len(method.Params) != 1 || method.Params[0].Type != StringType || len(method.Results) != 2 || !isError(method.Results[1]) {
+				return fmt.Errorf("cached store method %s must take a single string and return a value and an error", methodName)
+			}
+			method.CacheKey = key
+		}
+
+		if params, ok := userInvalidatingMethods[methodName]; ok {
+			if len(method.Results) != 1 || !isError(method.Results[0]) {
+				return fmt.Errorf("user invalidating store method %s must only return an error", methodName)
+			}
+			for _, paramName := range []string{params.MattermostUserID, params.TeamsUserID} {
+				if paramName != "" && !slices.ContainsFunc(method.Params, func(param methodParam) bool { return param.Name == paramName }) {
+					return fmt.Errorf("user invalidating store method %s has no parameter %s", methodName, paramName)
+				}
+			}
+			method.InvalidatedUser = &params
+		}
+
+		metadata.Methods[methodName] = method
+	}
+	metadata.Name = "CacheLayer"
+
+	out := bytes.NewBufferString("")
+	t := template.Must(template.New("cache_layer.go.tmpl").Funcs(getTemplateFuncs()).ParseFiles("generators/cache_layer.go.tmpl"))
+	if err = t.Execute(out, metadata); err != nil {
+		return err
+	}
+
+	formatedCode, err := format.Source(out.Bytes())
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll("cachelayer", 0700)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path.Join("cachelayer", "cachelayer.go"), formatedCode, 0600)
+}
+
 type methodParam struct {
 	Name string
 	Type string
@@ -115,6 +200,10 @@ type methodData struct {
 	Results         []string
 	WithTransaction bool
 	WithReplica     bool
+
+	// CacheKey and InvalidatedUser are only set for the cache layer.
+	CacheKey        string
+	InvalidatedUser *userIDParams
 }
 
 type methodTags struct {
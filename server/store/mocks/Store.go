@@ -16,6 +16,20 @@ type Store struct {
 	mock.Mock
 }
 
+// ConsumeOAuth2State provides a mock function with given fields: stateID, userID
+func (_m *Store) ConsumeOAuth2State(stateID string, userID string) error {
+	ret := _m.Called(stateID, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(stateID, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteLinkByChannelID provides a mock function with given fields: channelID
 func (_m *Store) DeleteLinkByChannelID(channelID string) error {
 	ret := _m.Called(channelID)
@@ -30,6 +44,20 @@ func (_m *Store) DeleteLinkByChannelID(channelID string) error {
 	return r0
 }
 
+// DeleteMissedMessages provides a mock function with given fields: mmUserID
+func (_m *Store) DeleteMissedMessages(mmUserID string) error {
+	ret := _m.Called(mmUserID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(mmUserID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteSubscription provides a mock function with given fields: subscriptionID
 func (_m *Store) DeleteSubscription(subscriptionID string) error {
 	ret := _m.Called(subscriptionID)
@@ -44,6 +72,20 @@ func (_m *Store) DeleteSubscription(subscriptionID string) error {
 	return r0
 }
 
+// DeleteUserData provides a mock function with given fields: mmUserID
+func (_m *Store) DeleteUserData(mmUserID string) error {
+	ret := _m.Called(mmUserID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(mmUserID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteUserFromWhitelist provides a mock function with given fields: userID
 func (_m *Store) DeleteUserFromWhitelist(userID string) error {
 	ret := _m.Called(userID)
@@ -86,6 +128,29 @@ func (_m *Store) DeleteUserInvite(mmUserID string) error {
 	return r0
 }
 
+// FilterConnectedTeamsUserIDs provides a mock function with given fields: teamsUserIDs
+func (_m *Store) FilterConnectedTeamsUserIDs(teamsUserIDs []string) ([]string, error) {
+	ret := _m.Called(teamsUserIDs)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func([]string) []string); ok {
+		r0 = rf(teamsUserIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]string) error); ok {
+		r1 = rf(teamsUserIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetActiveUsersCount provides a mock function with given fields: dur
 func (_m *Store) GetActiveUsersCount(dur time.Duration) (int64, error) {
 	ret := _m.Called(dur)
@@ -220,6 +285,75 @@ func (_m *Store) GetConnectedUsersCount() (int64, error) {
 	return r0, r1
 }
 
+// GetDirectChannelIDs provides a mock function with given fields: mmUserIDs, otherUserID
+func (_m *Store) GetDirectChannelIDs(mmUserIDs []string, otherUserID string) (map[string]string, error) {
+	ret := _m.Called(mmUserIDs, otherUserID)
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func([]string, string) map[string]string); ok {
+		r0 = rf(mmUserIDs, otherUserID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]string, string) error); ok {
+		r1 = rf(mmUserIDs, otherUserID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetExpiredInvite provides a mock function with given fields: mmUserID
+func (_m *Store) GetExpiredInvite(mmUserID string) (*storemodels.ExpiredInvite, error) {
+	ret := _m.Called(mmUserID)
+
+	var r0 *storemodels.ExpiredInvite
+	if rf, ok := ret.Get(0).(func(string) *storemodels.ExpiredInvite); ok {
+		r0 = rf(mmUserID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storemodels.ExpiredInvite)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(mmUserID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetExpiredInvites provides a mock function with given fields:
+func (_m *Store) GetExpiredInvites() ([]*storemodels.ExpiredInvite, error) {
+	ret := _m.Called()
+
+	var r0 []*storemodels.ExpiredInvite
+	if rf, ok := ret.Get(0).(func() []*storemodels.ExpiredInvite); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storemodels.ExpiredInvite)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetGlobalSubscription provides a mock function with given fields: subscriptionID
 func (_m *Store) GetGlobalSubscription(subscriptionID string) (*storemodels.GlobalSubscription, error) {
 	ret := _m.Called(subscriptionID)
@@ -308,6 +442,29 @@ func (_m *Store) GetInvitedUser(mmUserID string) (*storemodels.InvitedUser, erro
 	return r0, r1
 }
 
+// GetInvitedUsers provides a mock function with given fields:
+func (_m *Store) GetInvitedUsers() ([]*storemodels.InvitedUser, error) {
+	ret := _m.Called()
+
+	var r0 []*storemodels.InvitedUser
+	if rf, ok := ret.Get(0).(func() []*storemodels.InvitedUser); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storemodels.InvitedUser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetLinkByChannelID provides a mock function with given fields: channelID
 func (_m *Store) GetLinkByChannelID(channelID string) (*storemodels.ChannelLink, error) {
 	ret := _m.Called(channelID)
@@ -375,6 +532,52 @@ func (_m *Store) GetLinkedChannelsCount() (int64, error) {
 	return r0, r1
 }
 
+// GetLinkedUsers provides a mock function with given fields: page, perPage
+func (_m *Store) GetLinkedUsers(page int, perPage int) ([]*storemodels.ConnectedUser, error) {
+	ret := _m.Called(page, perPage)
+
+	var r0 []*storemodels.ConnectedUser
+	if rf, ok := ret.Get(0).(func(int, int) []*storemodels.ConnectedUser); ok {
+		r0 = rf(page, perPage)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storemodels.ConnectedUser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, int) error); ok {
+		r1 = rf(page, perPage)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMissedMessages provides a mock function with given fields: mmUserID
+func (_m *Store) GetMissedMessages(mmUserID string) (*storemodels.MissedMessages, error) {
+	ret := _m.Called(mmUserID)
+
+	var r0 *storemodels.MissedMessages
+	if rf, ok := ret.Get(0).(func(string) *storemodels.MissedMessages); ok {
+		r0 = rf(mmUserID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storemodels.MissedMessages)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(mmUserID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetPostInfoByMSTeamsID provides a mock function with given fields: chatID, postID
 func (_m *Store) GetPostInfoByMSTeamsID(chatID string, postID string) (*storemodels.PostInfo, error) {
 	ret := _m.Called(chatID, postID)
@@ -421,6 +624,29 @@ func (_m *Store) GetPostInfoByMattermostID(postID string) (*storemodels.PostInfo
 	return r0, r1
 }
 
+// GetPreferencesForUsers provides a mock function with given fields: mmUserIDs, category, name
+func (_m *Store) GetPreferencesForUsers(mmUserIDs []string, category string, name string) (map[string]string, error) {
+	ret := _m.Called(mmUserIDs, category, name)
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func([]string, string, string) map[string]string); ok {
+		r0 = rf(mmUserIDs, category, name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]string, string, string) error); ok {
+		r1 = rf(mmUserIDs, category, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetSubscriptionType provides a mock function with given fields: subscriptionID
 func (_m *Store) GetSubscriptionType(subscriptionID string) (string, error) {
 	ret := _m.Called(subscriptionID)
@@ -511,6 +737,52 @@ func (_m *Store) GetUserConnectStatus(mmUserID string) (*storemodels.UserConnect
 	return r0, r1
 }
 
+// GetUserIDsByEmails provides a mock function with given fields: emails
+func (_m *Store) GetUserIDsByEmails(emails []string) (map[string]string, error) {
+	ret := _m.Called(emails)
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func([]string) map[string]string); ok {
+		r0 = rf(emails)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]string) error); ok {
+		r1 = rf(emails)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserInfo provides a mock function with given fields: mmUserID
+func (_m *Store) GetUserInfo(mmUserID string) (*storemodels.UserInfo, error) {
+	ret := _m.Called(mmUserID)
+
+	var r0 *storemodels.UserInfo
+	if rf, ok := ret.Get(0).(func(string) *storemodels.UserInfo); ok {
+		r0 = rf(mmUserID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storemodels.UserInfo)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(mmUserID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetWhitelistCount provides a mock function with given fields:
 func (_m *Store) GetWhitelistCount() (int, error) {
 	ret := _m.Called()
@@ -532,13 +804,13 @@ func (_m *Store) GetWhitelistCount() (int, error) {
 	return r0, r1
 }
 
-// GetWhitelistEmails provides a mock function with given fields: page, perPage
-func (_m *Store) GetWhitelistEmails(page int, perPage int) ([]string, error) {
-	ret := _m.Called(page, perPage)
+// GetWhitelistEmails provides a mock function with given fields: search, page, perPage
+func (_m *Store) GetWhitelistEmails(search string, page int, perPage int) ([]string, error) {
+	ret := _m.Called(search, page, perPage)
 
 	var r0 []string
-	if rf, ok := ret.Get(0).(func(int, int) []string); ok {
-		r0 = rf(page, perPage)
+	if rf, ok := ret.Get(0).(func(string, int, int) []string); ok {
+		r0 = rf(search, page, perPage)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]string)
@@ -546,8 +818,8 @@ func (_m *Store) GetWhitelistEmails(page int, perPage int) ([]string, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int, int) error); ok {
-		r1 = rf(page, perPage)
+	if rf, ok := ret.Get(1).(func(string, int, int) error); ok {
+		r1 = rf(search, page, perPage)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -765,6 +1037,29 @@ func (_m *Store) ListGlobalSubscriptionsToRefresh() ([]*storemodels.GlobalSubscr
 	return r0, r1
 }
 
+// ListMissedMessages provides a mock function with given fields: offset, limit
+func (_m *Store) ListMissedMessages(offset int, limit int) ([]*storemodels.MissedMessages, error) {
+	ret := _m.Called(offset, limit)
+
+	var r0 []*storemodels.MissedMessages
+	if rf, ok := ret.Get(0).(func(int, int) []*storemodels.MissedMessages); ok {
+		r0 = rf(offset, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storemodels.MissedMessages)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(int, int) error); ok {
+		r1 = rf(offset, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // MattermostToTeamsUserID provides a mock function with given fields: userID
 func (_m *Store) MattermostToTeamsUserID(userID string) (string, error) {
 	ret := _m.Called(userID)
@@ -786,6 +1081,20 @@ func (_m *Store) MattermostToTeamsUserID(userID string) (string, error) {
 	return r0, r1
 }
 
+// RecordMissedMessage provides a mock function with given fields: mmUserID, teamsUserID, missedAt
+func (_m *Store) RecordMissedMessage(mmUserID string, teamsUserID string, missedAt time.Time) error {
+	ret := _m.Called(mmUserID, teamsUserID, missedAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, time.Time) error); ok {
+		r0 = rf(mmUserID, teamsUserID, missedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // RecoverPost provides a mock function with given fields: postID
 func (_m *Store) RecoverPost(postID string) error {
 	ret := _m.Called(postID)
@@ -842,6 +1151,43 @@ func (_m *Store) SaveGlobalSubscription(subscription storemodels.GlobalSubscript
 	return r0
 }
 
+// SearchConnectedUsers provides a mock function with given fields: search, page, perPage
+func (_m *Store) SearchConnectedUsers(search string, page int, perPage int) ([]*storemodels.ConnectedUser, error) {
+	ret := _m.Called(search, page, perPage)
+
+	var r0 []*storemodels.ConnectedUser
+	if rf, ok := ret.Get(0).(func(string, int, int) []*storemodels.ConnectedUser); ok {
+		r0 = rf(search, page, perPage)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*storemodels.ConnectedUser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int, int) error); ok {
+		r1 = rf(search, page, perPage)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SetMissedMessagesNudged provides a mock function with given fields: mmUserID, nudgedCount, nudgedAt
+func (_m *Store) SetMissedMessagesNudged(mmUserID string, nudgedCount int, nudgedAt time.Time) error {
+	ret := _m.Called(mmUserID, nudgedCount, nudgedAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int, time.Time) error); ok {
+		r0 = rf(mmUserID, nudgedCount, nudgedAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SetPostLastUpdateAtByMSTeamsID provides a mock function with given fields: postID, lastUpdateAt
 func (_m *Store) SetPostLastUpdateAtByMSTeamsID(postID string, lastUpdateAt time.Time) error {
 	ret := _m.Called(postID, lastUpdateAt)
@@ -954,6 +1300,20 @@ func (_m *Store) StoreChannelLink(link *storemodels.ChannelLink) error {
 	return r0
 }
 
+// StoreExpiredInvite provides a mock function with given fields: expiredInvite
+func (_m *Store) StoreExpiredInvite(expiredInvite *storemodels.ExpiredInvite) error {
+	ret := _m.Called(expiredInvite)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*storemodels.ExpiredInvite) error); ok {
+		r0 = rf(expiredInvite)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // StoreInvitedUser provides a mock function with given fields: invitedUser
 func (_m *Store) StoreInvitedUser(invitedUser *storemodels.InvitedUser) error {
 	ret := _m.Called(invitedUser)
@@ -969,11 +1329,11 @@ func (_m *Store) StoreInvitedUser(invitedUser *storemodels.InvitedUser) error {
 }
 
 // StoreOAuth2State provides a mock function with given fields: state
-func (_m *Store) StoreOAuth2State(state string) error {
+func (_m *Store) StoreOAuth2State(state *storemodels.OAuth2State) error {
 	ret := _m.Called(state)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
+	if rf, ok := ret.Get(0).(func(*storemodels.OAuth2State) error); ok {
 		r0 = rf(state)
 	} else {
 		r0 = ret.Error(0)
@@ -1017,6 +1377,29 @@ func (_m *Store) TeamsToMattermostUserID(userID string) (string, error) {
 	return r0, r1
 }
 
+// TeamsToMattermostUserIDs provides a mock function with given fields: userIDs
+func (_m *Store) TeamsToMattermostUserIDs(userIDs []string) (map[string]string, error) {
+	ret := _m.Called(userIDs)
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func([]string) map[string]string); ok {
+		r0 = rf(userIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]string) error); ok {
+		r1 = rf(userIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // UpdateSubscriptionExpiresOn provides a mock function with given fields: subscriptionID, expiresOn
 func (_m *Store) UpdateSubscriptionExpiresOn(subscriptionID string, expiresOn time.Time) error {
 	ret := _m.Called(subscriptionID, expiresOn)
@@ -1052,18 +1435,27 @@ func (_m *Store) UserHasConnected(mmUserID string) (bool, error) {
 	return r0, r1
 }
 
-// VerifyOAuth2State provides a mock function with given fields: state
-func (_m *Store) VerifyOAuth2State(state string) error {
-	ret := _m.Called(state)
+// VerifyOAuth2State provides a mock function with given fields: stateID, userID
+func (_m *Store) VerifyOAuth2State(stateID string, userID string) (*storemodels.OAuth2State, error) {
+	ret := _m.Called(stateID, userID)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(state)
+	var r0 *storemodels.OAuth2State
+	if rf, ok := ret.Get(0).(func(string, string) *storemodels.OAuth2State); ok {
+		r0 = rf(stateID, userID)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*storemodels.OAuth2State)
+		}
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(stateID, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 type mockConstructorTestingTNewStore interface {
@@ -99,7 +99,7 @@ func setupTestStore(t *testing.T) (*SQLStore, *plugintest.API) {
 
 	err := createTable(store, "Teams", "Id VARCHAR(255), DisplayName VARCHAR(255)")
 	require.NoError(t, err)
-	err = createTable(store, "Channels", "Id VARCHAR(255), DisplayName VARCHAR(255)")
+	err = createTable(store, "Channels", "Id VARCHAR(255), DisplayName VARCHAR(255), Name VARCHAR(64), Type VARCHAR(1), DeleteAt BIGINT")
 	require.NoError(t, err)
 	err = createTable(store, "Users", "Id VARCHAR(255), FirstName VARCHAR(255), LastName VARCHAR(255), Email VARCHAR(255), remoteid VARCHAR(26), createat BIGINT, deleteat BIGINT")
 	require.NoError(t, err)
@@ -0,0 +1 @@
+ALTER TABLE msteamssync_invited_users ADD COLUMN IF NOT EXISTS inviteRemindersSent INT NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS msteamssync_expired_invites (
+    mmUserID VARCHAR(255) PRIMARY KEY,
+    invitePendingSince BIGINT NOT NULL DEFAULT 0,
+    inviteRemindersSent INT NOT NULL DEFAULT 0,
+    inviteExpiredAt BIGINT NOT NULL DEFAULT 0
+);
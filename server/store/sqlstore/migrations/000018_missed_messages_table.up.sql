@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS msteamssync_missed_messages (
+    mmUserID VARCHAR(255) PRIMARY KEY,
+    msTeamsUserID VARCHAR(255) NOT NULL,
+    missedCount INT NOT NULL DEFAULT 0,
+    lastMissedAt BIGINT NOT NULL DEFAULT 0,
+    nudgedCount INT NOT NULL DEFAULT 0,
+    lastNudgedAt BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_msteamssync_missed_messages_missedcount ON msteamssync_missed_messages (missedCount);
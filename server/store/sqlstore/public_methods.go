@@ -26,10 +26,34 @@ func (s *SQLStore) DeleteLinkByChannelID(channelID string) error {
 	return s.deleteLinkByChannelID(s.db, channelID)
 }
 
+func (s *SQLStore) DeleteMissedMessages(mmUserID string) error {
+	return s.deleteMissedMessages(s.db, mmUserID)
+}
+
 func (s *SQLStore) DeleteSubscription(subscriptionID string) error {
 	return s.deleteSubscription(s.db, subscriptionID)
 }
 
+func (s *SQLStore) DeleteUserData(mmUserID string) error {
+	tx, txErr := s.db.BeginTx(context.Background(), nil)
+	if txErr != nil {
+		return txErr
+	}
+	err := s.deleteUserData(tx, mmUserID)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			s.api.LogError("transaction rollback error", "Error", rollbackErr, "methodName", "DeleteUserData")
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *SQLStore) DeleteUserFromWhitelist(userID string) error {
 	return s.deleteUserFromWhitelist(s.db, userID)
 }
@@ -42,6 +66,10 @@ func (s *SQLStore) DeleteUserInvite(mmUserID string) error {
 	return s.deleteUserInvite(s.db, mmUserID)
 }
 
+func (s *SQLStore) FilterConnectedTeamsUserIDs(teamsUserIDs []string) ([]string, error) {
+	return s.filterConnectedTeamsUserIDs(s.replica, teamsUserIDs)
+}
+
 func (s *SQLStore) GetActiveUsersCount(dur time.Duration) (int64, error) {
 	return s.getActiveUsersCount(s.replica, dur)
 }
@@ -66,6 +94,18 @@ func (s *SQLStore) GetConnectedUsersCount() (int64, error) {
 	return s.getConnectedUsersCount(s.replica)
 }
 
+func (s *SQLStore) GetDirectChannelIDs(mmUserIDs []string, otherUserID string) (map[string]string, error) {
+	return s.getDirectChannelIDs(s.replica, mmUserIDs, otherUserID)
+}
+
+func (s *SQLStore) GetExpiredInvite(mmUserID string) (*storemodels.ExpiredInvite, error) {
+	return s.getExpiredInvite(s.replica, mmUserID)
+}
+
+func (s *SQLStore) GetExpiredInvites() ([]*storemodels.ExpiredInvite, error) {
+	return s.getExpiredInvites(s.replica)
+}
+
 func (s *SQLStore) GetGlobalSubscription(subscriptionID string) (*storemodels.GlobalSubscription, error) {
 	return s.getGlobalSubscription(s.replica, subscriptionID)
 }
@@ -82,6 +122,10 @@ func (s *SQLStore) GetInvitedUser(mmUserID string) (*storemodels.InvitedUser, er
 	return s.getInvitedUser(s.replica, mmUserID)
 }
 
+func (s *SQLStore) GetInvitedUsers() ([]*storemodels.InvitedUser, error) {
+	return s.getInvitedUsers(s.replica)
+}
+
 func (s *SQLStore) GetLinkByChannelID(channelID string) (*storemodels.ChannelLink, error) {
 	return s.getLinkByChannelID(s.replica, channelID)
 }
@@ -94,6 +138,14 @@ func (s *SQLStore) GetLinkedChannelsCount() (int64, error) {
 	return s.getLinkedChannelsCount(s.replica)
 }
 
+func (s *SQLStore) GetLinkedUsers(page int, perPage int) ([]*storemodels.ConnectedUser, error) {
+	return s.getLinkedUsers(s.replica, page, perPage)
+}
+
+func (s *SQLStore) GetMissedMessages(mmUserID string) (*storemodels.MissedMessages, error) {
+	return s.getMissedMessages(s.replica, mmUserID)
+}
+
 func (s *SQLStore) GetPostInfoByMSTeamsID(chatID string, postID string) (*storemodels.PostInfo, error) {
 	return s.getPostInfoByMSTeamsID(s.replica, chatID, postID)
 }
@@ -102,28 +154,40 @@ func (s *SQLStore) GetPostInfoByMattermostID(postID string) (*storemodels.PostIn
 	return s.getPostInfoByMattermostID(s.replica, postID)
 }
 
+func (s *SQLStore) GetPreferencesForUsers(mmUserIDs []string, category string, name string) (map[string]string, error) {
+	return s.getPreferencesForUsers(s.replica, mmUserIDs, category, name)
+}
+
 func (s *SQLStore) GetSubscriptionType(subscriptionID string) (string, error) {
 	return s.getSubscriptionType(s.replica, subscriptionID)
 }
 
 func (s *SQLStore) GetTokenForMSTeamsUser(userID string) (*oauth2.Token, error) {
-	return s.getTokenForMSTeamsUser(s.replica, userID)
+	return s.getTokenForMSTeamsUser(s.db, userID)
 }
 
 func (s *SQLStore) GetTokenForMattermostUser(userID string) (*oauth2.Token, error) {
-	return s.getTokenForMattermostUser(s.replica, userID)
+	return s.getTokenForMattermostUser(s.db, userID)
 }
 
 func (s *SQLStore) GetUserConnectStatus(mmUserID string) (*storemodels.UserConnectStatus, error) {
 	return s.getUserConnectStatus(s.replica, mmUserID)
 }
 
+func (s *SQLStore) GetUserIDsByEmails(emails []string) (map[string]string, error) {
+	return s.getUserIDsByEmails(s.replica, emails)
+}
+
+func (s *SQLStore) GetUserInfo(mmUserID string) (*storemodels.UserInfo, error) {
+	return s.getUserInfo(s.replica, mmUserID)
+}
+
 func (s *SQLStore) GetWhitelistCount() (int, error) {
 	return s.getWhitelistCount(s.replica)
 }
 
-func (s *SQLStore) GetWhitelistEmails(page int, perPage int) ([]string, error) {
-	return s.getWhitelistEmails(s.replica, page, perPage)
+func (s *SQLStore) GetWhitelistEmails(search string, page int, perPage int) ([]string, error) {
+	return s.getWhitelistEmails(s.replica, search, page, perPage)
 }
 
 func (s *SQLStore) IsUserWhitelisted(userID string) (bool, error) {
@@ -162,8 +226,16 @@ func (s *SQLStore) ListGlobalSubscriptionsToRefresh() ([]*storemodels.GlobalSubs
 	return s.listGlobalSubscriptionsToRefresh(s.replica)
 }
 
+func (s *SQLStore) ListMissedMessages(offset int, limit int) ([]*storemodels.MissedMessages, error) {
+	return s.listMissedMessages(s.replica, offset, limit)
+}
+
 func (s *SQLStore) MattermostToTeamsUserID(userID string) (string, error) {
-	return s.mattermostToTeamsUserID(s.replica, userID)
+	return s.mattermostToTeamsUserID(s.db, userID)
+}
+
+func (s *SQLStore) RecordMissedMessage(mmUserID string, teamsUserID string, missedAt time.Time) error {
+	return s.recordMissedMessage(s.db, mmUserID, teamsUserID, missedAt)
 }
 
 func (s *SQLStore) RecoverPost(postID string) error {
@@ -230,6 +302,14 @@ func (s *SQLStore) SaveGlobalSubscription(subscription storemodels.GlobalSubscri
 	return nil
 }
 
+func (s *SQLStore) SearchConnectedUsers(search string, page int, perPage int) ([]*storemodels.ConnectedUser, error) {
+	return s.searchConnectedUsers(s.replica, search, page, perPage)
+}
+
+func (s *SQLStore) SetMissedMessagesNudged(mmUserID string, nudgedCount int, nudgedAt time.Time) error {
+	return s.setMissedMessagesNudged(s.db, mmUserID, nudgedCount, nudgedAt)
+}
+
 func (s *SQLStore) SetPostLastUpdateAtByMSTeamsID(postID string, lastUpdateAt time.Time) error {
 	return s.setPostLastUpdateAtByMSTeamsID(s.db, postID, lastUpdateAt)
 }
@@ -294,6 +374,10 @@ func (s *SQLStore) StoreChannelLink(link *storemodels.ChannelLink) error {
 	return s.storeChannelLink(s.db, link)
 }
 
+func (s *SQLStore) StoreExpiredInvite(expiredInvite *storemodels.ExpiredInvite) error {
+	return s.storeExpiredInvite(s.db, expiredInvite)
+}
+
 func (s *SQLStore) StoreInvitedUser(invitedUser *storemodels.InvitedUser) error {
 	return s.storeInvitedUser(s.db, invitedUser)
 }
@@ -303,7 +387,11 @@ func (s *SQLStore) StoreUserInWhitelist(userID string) error {
 }
 
 func (s *SQLStore) TeamsToMattermostUserID(userID string) (string, error) {
-	return s.teamsToMattermostUserID(s.replica, userID)
+	return s.teamsToMattermostUserID(s.db, userID)
+}
+
+func (s *SQLStore) TeamsToMattermostUserIDs(userIDs []string) (map[string]string, error) {
+	return s.teamsToMattermostUserIDs(s.db, userIDs)
 }
 
 func (s *SQLStore) UpdateSubscriptionExpiresOn(subscriptionID string, expiresOn time.Time) error {
@@ -8,10 +8,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/lib/pq"
+	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
 	"github.com/pkg/errors"
 	"golang.org/x/oauth2"
@@ -27,7 +29,6 @@ const (
 	subscriptionTypeUser            = "user"
 	subscriptionTypeChannel         = "channel"
 	subscriptionTypeAllChats        = "allChats"
-	oAuth2StateTimeToLive           = 300 // seconds
 	oAuth2KeyPrefix                 = "oauth2_"
 	backgroundJobPrefix             = "background_job"
 	systemSettingsTableName         = "msteamssync_system_settings"
@@ -38,6 +39,8 @@ const (
 	whitelistedUsersLegacyTableName = "msteamssync_whitelisted_users" // LEGACY-UNUSED
 	whitelistTableName              = "msteamssync_whitelist"
 	invitedUsersTableName           = "msteamssync_invited_users"
+	expiredInvitesTableName         = "msteamssync_expired_invites"
+	missedMessagesTableName         = "msteamssync_missed_messages"
 	PGUniqueViolationErrorCode      = "23505" // See https://github.com/lib/pq/blob/master/error.go#L178
 )
 
@@ -205,7 +208,6 @@ func (s *SQLStore) storeChannelLink(db sq.BaseRunner, link *storemodels.ChannelL
 	return nil
 }
 
-//db:withReplica
 func (s *SQLStore) teamsToMattermostUserID(db sq.BaseRunner, userID string) (string, error) {
 	query := s.getQueryBuilder(db).Select("mmUserID").From(usersTableName).Where(sq.Eq{"msTeamsUserID": userID})
 	row := query.QueryRow()
@@ -217,7 +219,6 @@ func (s *SQLStore) teamsToMattermostUserID(db sq.BaseRunner, userID string) (str
 	return mmUserID, nil
 }
 
-//db:withReplica
 func (s *SQLStore) mattermostToTeamsUserID(db sq.BaseRunner, userID string) (string, error) {
 	query := s.getQueryBuilder(db).Select("msTeamsUserID").From(usersTableName).Where(sq.Eq{"mmUserID": userID})
 	row := query.QueryRow()
@@ -229,6 +230,157 @@ func (s *SQLStore) mattermostToTeamsUserID(db sq.BaseRunner, userID string) (str
 	return msTeamsUserID, nil
 }
 
+func (s *SQLStore) teamsToMattermostUserIDs(db sq.BaseRunner, userIDs []string) (map[string]string, error) {
+	mmUserIDs := make(map[string]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return mmUserIDs, nil
+	}
+
+	query := s.getQueryBuilder(db).Select("msTeamsUserID, mmUserID").From(usersTableName).Where(sq.Eq{"msTeamsUserID": userIDs})
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var msTeamsUserID, mmUserID string
+		if scanErr := rows.Scan(&msTeamsUserID, &mmUserID); scanErr != nil {
+			return nil, scanErr
+		}
+		mmUserIDs[msTeamsUserID] = mmUserID
+	}
+
+	return mmUserIDs, nil
+}
+
+// filterConnectedTeamsUserIDs returns the given Teams users that are connected with a token,
+// leaving out those only linked, e.g. by auto-linking.
+//
+//db:withReplica
+func (s *SQLStore) filterConnectedTeamsUserIDs(db sq.BaseRunner, teamsUserIDs []string) ([]string, error) {
+	if len(teamsUserIDs) == 0 {
+		return nil, nil
+	}
+
+	query := s.getQueryBuilder(db).
+		Select("msTeamsUserID").
+		From(usersTableName).
+		Where(sq.And{
+			sq.Eq{"msTeamsUserID": teamsUserIDs},
+			sq.NotEq{"token": ""},
+			sq.NotEq{"token": nil},
+		})
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var connectedUserIDs []string
+	for rows.Next() {
+		var teamsUserID string
+		if err := rows.Scan(&teamsUserID); err != nil {
+			return nil, err
+		}
+		connectedUserIDs = append(connectedUserIDs, teamsUserID)
+	}
+
+	return connectedUserIDs, nil
+}
+
+//db:withReplica
+func (s *SQLStore) getPreferencesForUsers(db sq.BaseRunner, mmUserIDs []string, category, name string) (map[string]string, error) {
+	values := make(map[string]string, len(mmUserIDs))
+	if len(mmUserIDs) == 0 {
+		return values, nil
+	}
+
+	query := s.getQueryBuilder(db).Select("UserId, Value").From("Preferences").Where(sq.Eq{"UserId": mmUserIDs, "Category": category, "Name": name})
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+		var value sql.NullString
+		if scanErr := rows.Scan(&userID, &value); scanErr != nil {
+			return nil, scanErr
+		}
+		values[userID] = value.String
+	}
+
+	return values, nil
+}
+
+// getUserIDsByEmails returns the IDs of the active Mattermost users with the given emails, keyed
+// by their lowercase email.
+//
+//db:withReplica
+func (s *SQLStore) getUserIDsByEmails(db sq.BaseRunner, emails []string) (map[string]string, error) {
+	userIDs := make(map[string]string, len(emails))
+	if len(emails) == 0 {
+		return userIDs, nil
+	}
+
+	lowerEmails := make([]string, 0, len(emails))
+	for _, email := range emails {
+		lowerEmails = append(lowerEmails, strings.ToLower(email))
+	}
+
+	query := s.getQueryBuilder(db).Select("Id, Email").From("Users").Where(sq.Eq{"Email": lowerEmails, "DeleteAt": 0})
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, email string
+		if scanErr := rows.Scan(&userID, &email); scanErr != nil {
+			return nil, scanErr
+		}
+		userIDs[strings.ToLower(email)] = userID
+	}
+
+	return userIDs, nil
+}
+
+//db:withReplica
+func (s *SQLStore) getDirectChannelIDs(db sq.BaseRunner, mmUserIDs []string, otherUserID string) (map[string]string, error) {
+	channelIDs := make(map[string]string, len(mmUserIDs))
+	if len(mmUserIDs) == 0 {
+		return channelIDs, nil
+	}
+
+	names := make([]string, 0, len(mmUserIDs))
+	userIDsByName := make(map[string]string, len(mmUserIDs))
+	for _, mmUserID := range mmUserIDs {
+		name := model.GetDMNameFromIds(mmUserID, otherUserID)
+		names = append(names, name)
+		userIDsByName[name] = mmUserID
+	}
+
+	query := s.getQueryBuilder(db).Select("Id, Name").From("Channels").Where(sq.Eq{"Name": names, "Type": model.ChannelTypeDirect, "DeleteAt": 0})
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var channelID, name string
+		if scanErr := rows.Scan(&channelID, &name); scanErr != nil {
+			return nil, scanErr
+		}
+		channelIDs[userIDsByName[name]] = channelID
+	}
+
+	return channelIDs, nil
+}
+
 //db:withReplica
 func (s *SQLStore) getPostInfoByMSTeamsID(db sq.BaseRunner, chatID string, postID string) (*storemodels.PostInfo, error) {
 	query := s.getQueryBuilder(db).Select("mmPostID, msTeamsLastUpdateAt").From(postsTableName).Where(sq.Eq{"msTeamsPostID": postID, "msTeamsChannelID": chatID})
@@ -295,7 +447,6 @@ func (s *SQLStore) linkPosts(db sq.BaseRunner, postInfo storemodels.PostInfo) er
 	return nil
 }
 
-//db:withReplica
 func (s *SQLStore) getTokenForMattermostUser(db sq.BaseRunner, userID string) (*oauth2.Token, error) {
 	query := s.getQueryBuilder(db).Select("token").From(usersTableName).Where(sq.Eq{"mmUserID": userID}).Where(sq.NotEq{"token": ""})
 	row := query.QueryRow()
@@ -326,7 +477,6 @@ func (s *SQLStore) getTokenForMattermostUser(db sq.BaseRunner, userID string) (*
 	return &token, nil
 }
 
-//db:withReplica
 func (s *SQLStore) getTokenForMSTeamsUser(db sq.BaseRunner, userID string) (*oauth2.Token, error) {
 	query := s.getQueryBuilder(db).Select("token").From(usersTableName).Where(sq.Eq{"msTeamsUserID": userID}).Where(sq.NotEq{"token": ""})
 	row := query.QueryRow()
@@ -406,6 +556,81 @@ func (s *SQLStore) getUserConnectStatus(db sq.BaseRunner, mmUserID string) (*sto
 	return result, nil
 }
 
+//db:withReplica
+func (s *SQLStore) getUserInfo(db sq.BaseRunner, mmUserID string) (*storemodels.UserInfo, error) {
+	query := s.getQueryBuilder(db).
+		Select("mmUserID", "msTeamsUserID", "token", "lastConnectAt", "lastDisconnectAt", "LastChatSentAt", "LastChatReceivedAt").
+		From(usersTableName).
+		Where(sq.Eq{"mmUserID": mmUserID})
+
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, nil
+	}
+
+	result := &storemodels.UserInfo{}
+	var encryptedToken string
+	var lastConnectAt int64
+	var lastDisconnectAt int64
+	var lastChatSentAt int64
+	var lastChatReceivedAt int64
+
+	if scanErr := rows.Scan(&result.MattermostUserID, &result.TeamsUserID, &encryptedToken, &lastConnectAt, &lastDisconnectAt, &lastChatSentAt, &lastChatReceivedAt); scanErr != nil {
+		return nil, scanErr
+	}
+
+	if encryptedToken != "" {
+		result.Connected = true
+	}
+
+	if lastConnectAt != 0 {
+		result.LastConnectAt = time.UnixMicro(lastConnectAt)
+	}
+
+	if lastDisconnectAt != 0 {
+		result.LastDisconnectAt = time.UnixMicro(lastDisconnectAt)
+	}
+
+	if lastChatSentAt != 0 {
+		result.LastChatSentAt = time.UnixMicro(lastChatSentAt)
+	}
+
+	if lastChatReceivedAt != 0 {
+		result.LastChatReceivedAt = time.UnixMicro(lastChatReceivedAt)
+	}
+
+	return result, nil
+}
+
+// deleteUserData removes every row held about the given Mattermost user.
+//
+//db:withTransaction
+func (s *SQLStore) deleteUserData(db sq.BaseRunner, mmUserID string) error {
+	teamsUserID, err := s.mattermostToTeamsUserID(db, mmUserID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if teamsUserID != "" {
+		if _, err := s.getQueryBuilder(db).Delete(subscriptionsTableName).Where(sq.Eq{"msTeamsUserID": teamsUserID, "type": subscriptionTypeUser}).Exec(); err != nil {
+			return err
+		}
+	}
+
+	for _, tableName := range []string{usersTableName, invitedUsersTableName, expiredInvitesTableName, whitelistTableName, missedMessagesTableName} {
+		if _, err := s.getQueryBuilder(db).Delete(tableName).Where(sq.Eq{"mmUserID": mmUserID}).Exec(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func computeStatusTimes(status *storemodels.UserConnectStatus, nextIsConnected bool) (int64, int64, error) {
 	var lastConnectAt int64
 	var lastDisconnectAt int64
@@ -756,28 +981,73 @@ func (s *SQLStore) getQueryBuilder(db sq.BaseRunner) sq.StatementBuilderType {
 	return sq.StatementBuilder.PlaceholderFormat(sq.Dollar).RunWith(db)
 }
 
-func (s *SQLStore) VerifyOAuth2State(state string) error {
-	key := hashKey(oAuth2KeyPrefix, state)
-	data, appErr := s.api.KVGet(key)
+// VerifyOAuth2State returns the stored OAuth state with the given ID, provided it was started by
+// the given user and has not expired. The state is left in place until consumed, so that a failed
+// attempt can be retried with the same link.
+func (s *SQLStore) VerifyOAuth2State(stateID, userID string) (*storemodels.OAuth2State, error) {
+	state, _, err := s.getOAuth2State(stateID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// ConsumeOAuth2State verifies the OAuth state as VerifyOAuth2State does and deletes it, so that it
+// can only be used once even by concurrent attempts.
+func (s *SQLStore) ConsumeOAuth2State(stateID, userID string) error {
+	_, data, err := s.getOAuth2State(stateID, userID)
+	if err != nil {
+		return err
+	}
+
+	deleted, appErr := s.api.KVCompareAndDelete(hashKey(oAuth2KeyPrefix, stateID), data)
 	if appErr != nil {
 		return errors.New(appErr.Message)
 	}
+	if !deleted {
+		return errors.New("authentication attempt expired, please try again")
+	}
+
+	return nil
+}
+
+func (s *SQLStore) getOAuth2State(stateID, userID string) (*storemodels.OAuth2State, []byte, error) {
+	data, appErr := s.api.KVGet(hashKey(oAuth2KeyPrefix, stateID))
+	if appErr != nil {
+		return nil, nil, errors.New(appErr.Message)
+	}
 
 	if data == nil {
-		return errors.New("authentication attempt expired, please try again")
+		return nil, nil, errors.New("authentication attempt expired, please try again")
 	}
 
-	if string(data) != state {
-		return errors.New("invalid oauth state, please try again")
+	var state storemodels.OAuth2State
+	if err := json.Unmarshal(data, &state); err != nil || state.ID != stateID || state.UserID != userID {
+		return nil, nil, errors.New("invalid oauth state, please try again")
 	}
 
-	return nil
+	if !time.Now().Before(state.ExpiresAt) {
+		return nil, nil, errors.New("authentication attempt expired, please try again")
+	}
+
+	return &state, data, nil
 }
 
-func (s *SQLStore) StoreOAuth2State(state string) error {
-	key := hashKey(oAuth2KeyPrefix, state)
-	if err := s.api.KVSetWithExpiry(key, []byte(state), oAuth2StateTimeToLive); err != nil {
-		return errors.New(err.Message)
+func (s *SQLStore) StoreOAuth2State(state *storemodels.OAuth2State) error {
+	expireInSeconds := int64(time.Until(state.ExpiresAt).Seconds())
+	if expireInSeconds <= 0 {
+		return errors.New("oauth state already expired")
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	key := hashKey(oAuth2KeyPrefix, state.ID)
+	if appErr := s.api.KVSetWithExpiry(key, data, expireInSeconds); appErr != nil {
+		return errors.New(appErr.Message)
 	}
 
 	return nil
@@ -825,7 +1095,21 @@ func (s *SQLStore) getActiveUsersCount(db sq.BaseRunner, dur time.Duration) (act
 
 //db:withReplica
 func (s *SQLStore) getConnectedUsers(db sq.BaseRunner, page, perPage int) ([]*storemodels.ConnectedUser, error) {
+	return s.searchConnectedUsers(db, "", page, perPage)
+}
+
+//db:withReplica
+func (s *SQLStore) searchConnectedUsers(db sq.BaseRunner, search string, page, perPage int) ([]*storemodels.ConnectedUser, error) {
 	query := s.getQueryBuilder(db).Select("mmuserid, msteamsuserid, Users.FirstName, Users.LastName, Users.Email").From(usersTableName).LeftJoin("Users ON Users.Id = msteamssync_users.mmuserid").Where(sq.NotEq{"token": ""}).OrderBy("Users.FirstName").Offset(offset(page, perPage)).Limit(limit(perPage))
+	if search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		query = query.Where(sq.Or{
+			sq.ILike{"Users.Email": pattern},
+			sq.ILike{"Users.Username": pattern},
+			sq.ILike{"Users.FirstName": pattern},
+			sq.ILike{"Users.LastName": pattern},
+		})
+	}
 	rows, err := query.Query()
 	if err != nil {
 		return nil, err
@@ -846,6 +1130,31 @@ func (s *SQLStore) getConnectedUsers(db sq.BaseRunner, page, perPage int) ([]*st
 	return connectedUsers, nil
 }
 
+// getLinkedUsers returns the users mapped to a Teams user, whether connected, disconnected or
+// auto-linked, without their names.
+//
+//db:withReplica
+func (s *SQLStore) getLinkedUsers(db sq.BaseRunner, page, perPage int) ([]*storemodels.ConnectedUser, error) {
+	query := s.getQueryBuilder(db).Select("mmuserid, msteamsuserid").From(usersTableName).Where(sq.NotEq{"msteamsuserid": ""}).OrderBy("mmuserid").Offset(offset(page, perPage)).Limit(limit(perPage))
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var linkedUsers []*storemodels.ConnectedUser
+	for rows.Next() {
+		linkedUser := &storemodels.ConnectedUser{}
+		if err := rows.Scan(&linkedUser.MattermostUserID, &linkedUser.TeamsUserID); err != nil {
+			return nil, err
+		}
+
+		linkedUsers = append(linkedUsers, linkedUser)
+	}
+
+	return linkedUsers, nil
+}
+
 //db:withReplica
 func (s *SQLStore) getHasConnectedCount(db sq.BaseRunner) (int, error) {
 	query := s.getQueryBuilder(db).
@@ -970,13 +1279,21 @@ func (s *SQLStore) getWhitelistCount(db sq.BaseRunner) (int, error) {
 }
 
 //db:withReplica
-func (s *SQLStore) getWhitelistEmails(db sq.BaseRunner, page, perPage int) ([]string, error) {
+func (s *SQLStore) getWhitelistEmails(db sq.BaseRunner, search string, page, perPage int) ([]string, error) {
 	query := s.getQueryBuilder(db).
 		Select("Users.Email").
 		From(whitelistTableName).
 		LeftJoin("Users ON Users.Id = msteamssync_whitelist.mmuserid").
+		OrderBy("Users.Email").
 		Offset(offset(page, perPage)).
 		Limit(limit(perPage))
+	if search != "" {
+		pattern := "%" + escapeLike(search) + "%"
+		query = query.Where(sq.Or{
+			sq.ILike{"Users.Email": pattern},
+			sq.ILike{"Users.Username": pattern},
+		})
+	}
 	rows, err := query.Query()
 	if err != nil {
 		return nil, err
@@ -1011,9 +1328,9 @@ func (s *SQLStore) storeInvitedUser(db sq.BaseRunner, invitedUser *storemodels.I
 
 	query := s.getQueryBuilder(db).
 		Insert(invitedUsersTableName).
-		Columns("mmUserID", "invitePendingSince", "inviteLastSentAt").
-		Values(invitedUser.ID, pendingSince, lastSentAt).
-		SuffixExpr(sq.Expr("ON CONFLICT (mmUserID) DO UPDATE SET invitePendingSince = ?, inviteLastSentAt = ?", pendingSince, lastSentAt))
+		Columns("mmUserID", "invitePendingSince", "inviteLastSentAt", "inviteRemindersSent").
+		Values(invitedUser.ID, pendingSince, lastSentAt, invitedUser.InviteRemindersSent).
+		SuffixExpr(sq.Expr("ON CONFLICT (mmUserID) DO UPDATE SET invitePendingSince = ?, inviteLastSentAt = ?, inviteRemindersSent = ?", pendingSince, lastSentAt, invitedUser.InviteRemindersSent))
 
 	if _, err := query.Exec(); err != nil {
 		return err
@@ -1023,13 +1340,18 @@ func (s *SQLStore) storeInvitedUser(db sq.BaseRunner, invitedUser *storemodels.I
 		return err
 	}
 
+	// A new invite supersedes any previously expired one.
+	if _, err := s.getQueryBuilder(db).Delete(expiredInvitesTableName).Where(sq.Eq{"mmUserID": invitedUser.ID}).Exec(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 //db:withReplica
 func (s *SQLStore) getInvitedUser(db sq.BaseRunner, mmUserID string) (*storemodels.InvitedUser, error) {
 	query := s.getQueryBuilder(db).
-		Select("mmUserID", "invitePendingSince", "inviteLastSentAt").
+		Select("mmUserID", "invitePendingSince", "inviteLastSentAt", "inviteRemindersSent").
 		From(invitedUsersTableName).
 		Where(sq.Eq{"mmUserID": mmUserID})
 
@@ -1040,28 +1362,141 @@ func (s *SQLStore) getInvitedUser(db sq.BaseRunner, mmUserID string) (*storemode
 	defer rows.Close()
 
 	if rows.Next() {
-		var result = &storemodels.InvitedUser{}
-		var pendingSince int64
-		var lastSentAt int64
+		return scanInvitedUser(rows)
+	}
+
+	return nil, nil
+}
+
+//db:withReplica
+func (s *SQLStore) getInvitedUsers(db sq.BaseRunner) ([]*storemodels.InvitedUser, error) {
+	query := s.getQueryBuilder(db).
+		Select("mmUserID", "invitePendingSince", "inviteLastSentAt", "inviteRemindersSent").
+		From(invitedUsersTableName).
+		OrderBy("invitePendingSince", "mmUserID")
+
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-		if scanErr := rows.Scan(&result.ID, &pendingSince, &lastSentAt); scanErr != nil {
+	var result []*storemodels.InvitedUser
+	for rows.Next() {
+		invitedUser, scanErr := scanInvitedUser(rows)
+		if scanErr != nil {
 			return nil, scanErr
 		}
 
-		if pendingSince != 0 {
-			result.InvitePendingSince = time.UnixMicro(pendingSince)
-		}
+		result = append(result, invitedUser)
+	}
 
-		if lastSentAt != 0 {
-			result.InvitePendingSince = time.UnixMicro(lastSentAt)
-		}
+	return result, nil
+}
+
+func scanInvitedUser(rows *sql.Rows) (*storemodels.InvitedUser, error) {
+	var result = &storemodels.InvitedUser{}
+	var pendingSince sql.NullInt64
+	var lastSentAt sql.NullInt64
+
+	if err := rows.Scan(&result.ID, &pendingSince, &lastSentAt, &result.InviteRemindersSent); err != nil {
+		return nil, err
+	}
+
+	if pendingSince.Int64 != 0 {
+		result.InvitePendingSince = time.UnixMicro(pendingSince.Int64)
+	}
+
+	if lastSentAt.Int64 != 0 {
+		result.InviteLastSentAt = time.UnixMicro(lastSentAt.Int64)
+	}
+
+	return result, nil
+}
+
+func (s *SQLStore) storeExpiredInvite(db sq.BaseRunner, expiredInvite *storemodels.ExpiredInvite) error {
+	pendingSince := expiredInvite.InvitePendingSince.UnixMicro()
+	expiredAt := expiredInvite.InviteExpiredAt.UnixMicro()
+
+	query := s.getQueryBuilder(db).
+		Insert(expiredInvitesTableName).
+		Columns("mmUserID", "invitePendingSince", "inviteRemindersSent", "inviteExpiredAt").
+		Values(expiredInvite.ID, pendingSince, expiredInvite.InviteRemindersSent, expiredAt).
+		SuffixExpr(sq.Expr("ON CONFLICT (mmUserID) DO UPDATE SET invitePendingSince = ?, inviteRemindersSent = ?, inviteExpiredAt = ?", pendingSince, expiredInvite.InviteRemindersSent, expiredAt))
+
+	if _, err := query.Exec(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+//db:withReplica
+func (s *SQLStore) getExpiredInvite(db sq.BaseRunner, mmUserID string) (*storemodels.ExpiredInvite, error) {
+	query := s.getQueryBuilder(db).
+		Select("mmUserID", "invitePendingSince", "inviteRemindersSent", "inviteExpiredAt").
+		From(expiredInvitesTableName).
+		Where(sq.Eq{"mmUserID": mmUserID})
 
-		return result, nil
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanExpiredInvite(rows)
 	}
 
 	return nil, nil
 }
 
+//db:withReplica
+func (s *SQLStore) getExpiredInvites(db sq.BaseRunner) ([]*storemodels.ExpiredInvite, error) {
+	query := s.getQueryBuilder(db).
+		Select("mmUserID", "invitePendingSince", "inviteRemindersSent", "inviteExpiredAt").
+		From(expiredInvitesTableName).
+		OrderBy("inviteExpiredAt DESC", "mmUserID")
+
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*storemodels.ExpiredInvite
+	for rows.Next() {
+		expiredInvite, scanErr := scanExpiredInvite(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+
+		result = append(result, expiredInvite)
+	}
+
+	return result, nil
+}
+
+func scanExpiredInvite(rows *sql.Rows) (*storemodels.ExpiredInvite, error) {
+	var result = &storemodels.ExpiredInvite{}
+	var pendingSince int64
+	var expiredAt int64
+
+	if err := rows.Scan(&result.ID, &pendingSince, &result.InviteRemindersSent, &expiredAt); err != nil {
+		return nil, err
+	}
+
+	if pendingSince != 0 {
+		result.InvitePendingSince = time.UnixMicro(pendingSince)
+	}
+
+	if expiredAt != 0 {
+		result.InviteExpiredAt = time.UnixMicro(expiredAt)
+	}
+
+	return result, nil
+}
+
 func (s *SQLStore) deleteUserInvite(db sq.BaseRunner, mmUserID string) error {
 	if _, err := s.getQueryBuilder(db).Delete(invitedUsersTableName).Where(sq.Eq{"mmUserID": mmUserID}).Exec(); err != nil {
 		return err
@@ -1089,6 +1524,113 @@ func (s *SQLStore) getInvitedCount(db sq.BaseRunner) (int, error) {
 	return result, nil
 }
 
+func (s *SQLStore) recordMissedMessage(db sq.BaseRunner, mmUserID, teamsUserID string, missedAt time.Time) error {
+	query := s.getQueryBuilder(db).
+		Insert(missedMessagesTableName).
+		Columns("mmUserID", "msTeamsUserID", "missedCount", "lastMissedAt").
+		Values(mmUserID, teamsUserID, 1, missedAt.UnixMicro()).
+		SuffixExpr(sq.Expr("ON CONFLICT (mmUserID) DO UPDATE SET msTeamsUserID = ?, missedCount = "+missedMessagesTableName+".missedCount + 1, lastMissedAt = ?", teamsUserID, missedAt.UnixMicro()))
+
+	if _, err := query.Exec(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+//db:withReplica
+func (s *SQLStore) getMissedMessages(db sq.BaseRunner, mmUserID string) (*storemodels.MissedMessages, error) {
+	query := s.getQueryBuilder(db).
+		Select("mmUserID", "msTeamsUserID", "missedCount", "lastMissedAt", "nudgedCount", "lastNudgedAt").
+		From(missedMessagesTableName).
+		Where(sq.Eq{"mmUserID": mmUserID})
+
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanMissedMessages(rows)
+	}
+
+	return nil, nil
+}
+
+// listMissedMessages returns the users who missed the most Teams messages first, skipping the
+// given number of users.
+//
+//db:withReplica
+func (s *SQLStore) listMissedMessages(db sq.BaseRunner, offset, limit int) ([]*storemodels.MissedMessages, error) {
+	query := s.getQueryBuilder(db).
+		Select("mmUserID", "msTeamsUserID", "missedCount", "lastMissedAt", "nudgedCount", "lastNudgedAt").
+		From(missedMessagesTableName).
+		OrderBy("missedCount DESC", "lastMissedAt DESC", "mmUserID").
+		Offset(uint64(offset)).
+		Limit(uint64(limit))
+
+	rows, err := query.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*storemodels.MissedMessages
+	for rows.Next() {
+		missedMessages, scanErr := scanMissedMessages(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+
+		result = append(result, missedMessages)
+	}
+
+	return result, nil
+}
+
+func scanMissedMessages(rows *sql.Rows) (*storemodels.MissedMessages, error) {
+	var result = &storemodels.MissedMessages{}
+	var lastMissedAt int64
+	var lastNudgedAt int64
+
+	if err := rows.Scan(&result.MattermostUserID, &result.TeamsUserID, &result.Count, &lastMissedAt, &result.NudgedCount, &lastNudgedAt); err != nil {
+		return nil, err
+	}
+
+	if lastMissedAt != 0 {
+		result.LastMissedAt = time.UnixMicro(lastMissedAt)
+	}
+
+	if lastNudgedAt != 0 {
+		result.LastNudgedAt = time.UnixMicro(lastNudgedAt)
+	}
+
+	return result, nil
+}
+
+func (s *SQLStore) setMissedMessagesNudged(db sq.BaseRunner, mmUserID string, nudgedCount int, nudgedAt time.Time) error {
+	query := s.getQueryBuilder(db).
+		Update(missedMessagesTableName).
+		Set("nudgedCount", nudgedCount).
+		Set("lastNudgedAt", nudgedAt.UnixMicro()).
+		Where(sq.Eq{"mmUserID": mmUserID})
+
+	if _, err := query.Exec(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLStore) deleteMissedMessages(db sq.BaseRunner, mmUserID string) error {
+	if _, err := s.getQueryBuilder(db).Delete(missedMessagesTableName).Where(sq.Eq{"mmUserID": mmUserID}).Exec(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func hashKey(prefix, hashableKey string) string {
 	if hashableKey == "" {
 		return prefix
@@ -1099,6 +1641,11 @@ func hashKey(prefix, hashableKey string) string {
 	return fmt.Sprintf("%s%x", prefix, h.Sum(nil))
 }
 
+// escapeLike escapes the wildcard characters of a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 // isDuplicate checks whether an error is a duplicate key error, which comes when processes are competing on creating the same
 // tables in the database.
 func isDuplicate(err error) bool {
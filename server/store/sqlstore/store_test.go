@@ -4,6 +4,7 @@
 package sqlstore
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	sq "github.com/Masterminds/squirrel"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
 
@@ -341,6 +343,147 @@ func TestTeamsToMattermostUserIDForInvalidID(t *testing.T) {
 	assert.Contains(getErr.Error(), "no rows in result set")
 }
 
+func TestTeamsToMattermostUserIDs(t *testing.T) {
+	store, _ := setupTestStore(t)
+	store.encryptionKey = func() []byte {
+		return make([]byte, 16)
+	}
+
+	userID1 := model.NewId()
+	require.NoError(t, store.SetUserInfo(userID1, "teams-"+userID1, &oauth2.Token{}))
+	userID2 := model.NewId()
+	require.NoError(t, store.SetUserInfo(userID2, "teams-"+userID2, nil))
+	t.Cleanup(func() {
+		require.NoError(t, store.DeleteUserInfo(userID1))
+		require.NoError(t, store.DeleteUserInfo(userID2))
+	})
+
+	t.Run("no users", func(t *testing.T) {
+		mmUserIDs, err := store.TeamsToMattermostUserIDs(nil)
+		require.NoError(t, err)
+		assert.Empty(t, mmUserIDs)
+	})
+
+	t.Run("unknown users are omitted", func(t *testing.T) {
+		mmUserIDs, err := store.TeamsToMattermostUserIDs([]string{"teams-" + userID1, "teams-" + userID2, "invalidTeamsUserID"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"teams-" + userID1: userID1,
+			"teams-" + userID2: userID2,
+		}, mmUserIDs)
+	})
+}
+
+func TestFilterConnectedTeamsUserIDs(t *testing.T) {
+	store, _ := setupTestStore(t)
+	store.encryptionKey = func() []byte {
+		return make([]byte, 16)
+	}
+
+	connectedUserID := model.NewId()
+	require.NoError(t, store.SetUserInfo(connectedUserID, "teams-"+connectedUserID, &oauth2.Token{AccessToken: "token"}))
+	linkedUserID := model.NewId()
+	require.NoError(t, store.SetUserInfo(linkedUserID, "teams-"+linkedUserID, nil))
+	t.Cleanup(func() {
+		require.NoError(t, store.DeleteUserInfo(connectedUserID))
+		require.NoError(t, store.DeleteUserInfo(linkedUserID))
+	})
+
+	t.Run("no users", func(t *testing.T) {
+		userIDs, err := store.FilterConnectedTeamsUserIDs(nil)
+		require.NoError(t, err)
+		assert.Empty(t, userIDs)
+	})
+
+	t.Run("only connected users are kept", func(t *testing.T) {
+		userIDs, err := store.FilterConnectedTeamsUserIDs([]string{"teams-" + connectedUserID, "teams-" + linkedUserID, "invalidTeamsUserID"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"teams-" + connectedUserID}, userIDs)
+	})
+}
+
+func TestGetPreferencesForUsers(t *testing.T) {
+	store, _ := setupTestStore(t)
+
+	userID1 := model.NewId()
+	userID2 := model.NewId()
+	userID3 := model.NewId()
+	_, err := store.getQueryBuilder(store.db).Insert("Preferences").Columns("UserId, Category, Name, Value").
+		Values(userID1, "category", "name", "on").
+		Values(userID2, "category", "name", "off").
+		Values(userID2, "category", "other", "on").
+		Values(userID3, "other", "name", "on").
+		Exec()
+	require.NoError(t, err)
+
+	values, err := store.GetPreferencesForUsers([]string{userID1, userID2, userID3}, "category", "name")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		userID1: "on",
+		userID2: "off",
+	}, values)
+
+	values, err = store.GetPreferencesForUsers(nil, "category", "name")
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func TestGetDirectChannelIDs(t *testing.T) {
+	store, _ := setupTestStore(t)
+
+	botID := model.NewId()
+	userID1 := model.NewId()
+	userID2 := model.NewId()
+	userID3 := model.NewId()
+	channelID1 := model.NewId()
+	_, err := store.getQueryBuilder(store.db).Insert("Channels").Columns("Id, Name, Type, DeleteAt").
+		Values(channelID1, model.GetDMNameFromIds(userID1, botID), model.ChannelTypeDirect, 0).
+		Values(model.NewId(), model.GetDMNameFromIds(userID2, botID), model.ChannelTypeDirect, model.GetMillis()).
+		Values(model.NewId(), model.GetDMNameFromIds(userID3, userID1), model.ChannelTypeDirect, 0).
+		Exec()
+	require.NoError(t, err)
+
+	// Deleted channels and channels with other users are omitted.
+	channelIDs, err := store.GetDirectChannelIDs([]string{userID1, userID2, userID3}, botID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{userID1: channelID1}, channelIDs)
+}
+
+func TestGetLinkedUsers(t *testing.T) {
+	store, _ := setupTestStore(t)
+	store.encryptionKey = func() []byte {
+		return make([]byte, 16)
+	}
+
+	connectedUserID := model.NewId()
+	require.NoError(t, store.SetUserInfo(connectedUserID, "teams-"+connectedUserID, &oauth2.Token{AccessToken: "token"}))
+	autoLinkedUserID := model.NewId()
+	require.NoError(t, store.SetUserInfo(autoLinkedUserID, "teams-"+autoLinkedUserID, nil))
+
+	linkedUsers, err := store.GetLinkedUsers(0, 100)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*storemodels.ConnectedUser{
+		{MattermostUserID: connectedUserID, TeamsUserID: "teams-" + connectedUserID},
+		{MattermostUserID: autoLinkedUserID, TeamsUserID: "teams-" + autoLinkedUserID},
+	}, linkedUsers)
+}
+
+func TestGetUserIDsByEmails(t *testing.T) {
+	store, _ := setupTestStore(t)
+
+	userID1 := model.NewId()
+	_, err := store.getQueryBuilder(store.db).Insert("Users").Columns("Id, Email, DeleteAt").
+		Values(userID1, "user1@example.com", 0).
+		Values(model.NewId(), "user2@example.com", model.GetMillis()).
+		Exec()
+	require.NoError(t, err)
+
+	// Deactivated and unknown users are omitted.
+	userIDs, err := store.GetUserIDsByEmails([]string{"User1@example.com", "user2@example.com", "user3@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"user1@example.com": userID1}, userIDs)
+}
+
 func TestSetUserInfoAndMattermostToTeamsUserID(t *testing.T) {
 	store, _ := setupTestStore(t)
 	assert := assert.New(t)
@@ -884,21 +1027,74 @@ func TestListGlobalSubscriptions(t *testing.T) {
 
 func TestStoreAndVerifyOAuthState(t *testing.T) {
 	store, api := setupTestStore(t)
-	assert := assert.New(t)
 
-	api.On("GetTeam", "mockMattermostTeamID-1").Return(&model.Team{
-		Name: "mockMattermostTeamID-1",
-	}, nil)
+	userID := model.NewId()
+	state := &storemodels.OAuth2State{
+		ID:        model.NewId(),
+		UserID:    userID,
+		Origin:    storemodels.OAuth2StateOriginBotMessage,
+		ChannelID: model.NewId(),
+		PostID:    model.NewId(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	data, err := json.Marshal(state)
+	require.NoError(t, err)
 
-	state := fmt.Sprintf("%s_%s", model.NewId(), "mockMattermostUserID")
-	key := hashKey(oAuth2KeyPrefix, state)
-	api.On("KVSetWithExpiry", key, []byte(state), int64(oAuth2StateTimeToLive)).Return(nil)
-	err := store.StoreOAuth2State(state)
-	assert.Nil(err)
+	key := hashKey(oAuth2KeyPrefix, state.ID)
+	api.On("KVSetWithExpiry", key, data, mock.AnythingOfType("int64")).Return(nil)
+	require.NoError(t, store.StoreOAuth2State(state))
 
-	api.On("KVGet", key).Return([]byte(state), nil)
-	err = store.VerifyOAuth2State(state)
-	assert.Nil(err)
+	t.Run("valid state", func(t *testing.T) {
+		api.On("KVGet", key).Return(data, nil).Once()
+		storedState, err := store.VerifyOAuth2State(state.ID, userID)
+		require.NoError(t, err)
+		assert.Equal(t, state.ChannelID, storedState.ChannelID)
+		assert.Equal(t, state.PostID, storedState.PostID)
+	})
+
+	t.Run("consume state", func(t *testing.T) {
+		api.On("KVGet", key).Return(data, nil).Once()
+		api.On("KVCompareAndDelete", key, data).Return(true, nil).Once()
+		require.NoError(t, store.ConsumeOAuth2State(state.ID, userID))
+	})
+
+	t.Run("state consumed concurrently", func(t *testing.T) {
+		api.On("KVGet", key).Return(data, nil).Once()
+		api.On("KVCompareAndDelete", key, data).Return(false, nil).Once()
+		err := store.ConsumeOAuth2State(state.ID, userID)
+		assert.EqualError(t, err, "authentication attempt expired, please try again")
+	})
+
+	t.Run("consume state of a different user", func(t *testing.T) {
+		api.On("KVGet", key).Return(data, nil).Once()
+		err := store.ConsumeOAuth2State(state.ID, model.NewId())
+		assert.EqualError(t, err, "invalid oauth state, please try again")
+	})
+
+	t.Run("different user", func(t *testing.T) {
+		api.On("KVGet", key).Return(data, nil).Once()
+		_, err := store.VerifyOAuth2State(state.ID, model.NewId())
+		assert.EqualError(t, err, "invalid oauth state, please try again")
+	})
+
+	t.Run("unknown state", func(t *testing.T) {
+		api.On("KVGet", hashKey(oAuth2KeyPrefix, "unknown")).Return(nil, nil).Once()
+		_, err := store.VerifyOAuth2State("unknown", userID)
+		assert.EqualError(t, err, "authentication attempt expired, please try again")
+	})
+
+	t.Run("expired state", func(t *testing.T) {
+		expiredState := *state
+		expiredState.ExpiresAt = time.Now().Add(-time.Minute)
+		expiredData, err := json.Marshal(&expiredState)
+		require.NoError(t, err)
+
+		api.On("KVGet", key).Return(expiredData, nil).Once()
+		_, err = store.VerifyOAuth2State(state.ID, userID)
+		assert.EqualError(t, err, "authentication attempt expired, please try again")
+
+		assert.Error(t, store.StoreOAuth2State(&expiredState))
+	})
 }
 
 func TestListConnectedUsers(t *testing.T) {
@@ -948,6 +1144,58 @@ func TestListConnectedUsers(t *testing.T) {
 	assert.Nil(delErr)
 }
 
+func TestSearchConnectedUsers(t *testing.T) {
+	store, _ := setupTestStore(t)
+	store.encryptionKey = func() []byte {
+		return make([]byte, 16)
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  "mockAccessToken",
+		RefreshToken: "mockRefreshToken",
+	}
+
+	user1ID := model.NewId()
+	require.NoError(t, store.SetUserInfo(user1ID, "teams-"+user1ID, token))
+	_, err := store.getQueryBuilder(store.db).Insert("Users").Columns("Id, Username, Email, FirstName, LastName").Values(user1ID, "alice_1", "alice@example.com", "Alice", "Smith").Exec()
+	require.NoError(t, err)
+
+	user2ID := model.NewId()
+	require.NoError(t, store.SetUserInfo(user2ID, "teams-"+user2ID, token))
+	_, err = store.getQueryBuilder(store.db).Insert("Users").Columns("Id, Username, Email, FirstName, LastName").Values(user2ID, "bob", "bob@example.com", "Bob", "Jones").Exec()
+	require.NoError(t, err)
+
+	user3ID := model.NewId()
+	require.NoError(t, store.SetUserInfo(user3ID, "teams-"+user3ID, nil))
+	_, err = store.getQueryBuilder(store.db).Insert("Users").Columns("Id, Username, Email, FirstName, LastName").Values(user3ID, "alicia", "alicia@example.com", "Alicia", "Smith").Exec()
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		for _, userID := range []string{user1ID, user2ID, user3ID} {
+			require.NoError(t, store.DeleteUserInfo(userID))
+		}
+	})
+
+	getIDs := func(t *testing.T, search string) []string {
+		t.Helper()
+
+		connectedUsers, err := store.SearchConnectedUsers(search, 0, 100)
+		require.NoError(t, err)
+
+		ids := []string{}
+		for _, connectedUser := range connectedUsers {
+			ids = append(ids, connectedUser.MattermostUserID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{user1ID, user2ID}, getIDs(t, ""))
+	assert.Equal(t, []string{user1ID}, getIDs(t, "ALICE"))
+	assert.Equal(t, []string{user1ID}, getIDs(t, "smith"))
+	assert.Equal(t, []string{user2ID}, getIDs(t, "bob@"))
+	assert.Equal(t, []string{user1ID}, getIDs(t, "e_1"))
+}
+
 func TestWhitelistIO(t *testing.T) {
 	store, _ := setupTestStore(t)
 	assert := assert.New(t)
@@ -1278,3 +1526,188 @@ func TestGetActiveUsersCount(t *testing.T) {
 		assert.EqualValues(4, nb)
 	})
 }
+
+func TestInvitedUsers(t *testing.T) {
+	store, _ := setupTestStore(t)
+
+	cleanup := func() {
+		t.Helper()
+		_, err := store.getQueryBuilder(store.db).Delete(invitedUsersTableName).Where("1=1").Exec()
+		require.Nil(t, err)
+		_, err = store.getQueryBuilder(store.db).Delete(expiredInvitesTableName).Where("1=1").Exec()
+		require.Nil(t, err)
+	}
+	cleanup()
+	defer cleanup()
+
+	pendingSince := time.Now().Add(-48 * time.Hour).Truncate(time.Microsecond)
+	lastSentAt := time.Now().Add(-24 * time.Hour).Truncate(time.Microsecond)
+
+	t.Run("store and get invited user", func(t *testing.T) {
+		user1ID := model.NewId()
+		err := store.StoreInvitedUser(&storemodels.InvitedUser{
+			ID:                  user1ID,
+			InvitePendingSince:  pendingSince,
+			InviteLastSentAt:    lastSentAt,
+			InviteRemindersSent: 2,
+		})
+		require.NoError(t, err)
+
+		invitedUser, err := store.GetInvitedUser(user1ID)
+		require.NoError(t, err)
+		require.NotNil(t, invitedUser)
+		assert.True(t, pendingSince.Equal(invitedUser.InvitePendingSince))
+		assert.True(t, lastSentAt.Equal(invitedUser.InviteLastSentAt))
+		assert.Equal(t, 2, invitedUser.InviteRemindersSent)
+
+		invitedUsers, err := store.GetInvitedUsers()
+		require.NoError(t, err)
+		require.Len(t, invitedUsers, 1)
+		assert.Equal(t, user1ID, invitedUsers[0].ID)
+	})
+
+	t.Run("store, get and supersede expired invite", func(t *testing.T) {
+		user2ID := model.NewId()
+		expiredAt := time.Now().Truncate(time.Microsecond)
+
+		expiredInvite, err := store.GetExpiredInvite(user2ID)
+		require.NoError(t, err)
+		assert.Nil(t, expiredInvite)
+
+		err = store.StoreExpiredInvite(&storemodels.ExpiredInvite{
+			ID:                  user2ID,
+			InvitePendingSince:  pendingSince,
+			InviteRemindersSent: 3,
+			InviteExpiredAt:     expiredAt,
+		})
+		require.NoError(t, err)
+
+		expiredInvite, err = store.GetExpiredInvite(user2ID)
+		require.NoError(t, err)
+		require.NotNil(t, expiredInvite)
+		assert.True(t, pendingSince.Equal(expiredInvite.InvitePendingSince))
+		assert.True(t, expiredAt.Equal(expiredInvite.InviteExpiredAt))
+		assert.Equal(t, 3, expiredInvite.InviteRemindersSent)
+
+		expiredInvites, err := store.GetExpiredInvites()
+		require.NoError(t, err)
+		require.Len(t, expiredInvites, 1)
+
+		err = store.StoreInvitedUser(&storemodels.InvitedUser{ID: user2ID, InvitePendingSince: expiredAt, InviteLastSentAt: expiredAt})
+		require.NoError(t, err)
+
+		expiredInvite, err = store.GetExpiredInvite(user2ID)
+		require.NoError(t, err)
+		assert.Nil(t, expiredInvite)
+	})
+}
+
+func TestMissedMessages(t *testing.T) {
+	store, _ := setupTestStore(t)
+
+	cleanup := func() {
+		t.Helper()
+		_, err := store.getQueryBuilder(store.db).Delete(missedMessagesTableName).Where("1=1").Exec()
+		require.Nil(t, err)
+	}
+	cleanup()
+	defer cleanup()
+
+	user1ID := model.NewId()
+	user2ID := model.NewId()
+	missedAt := time.Now().Truncate(time.Microsecond)
+
+	missedMessages, err := store.GetMissedMessages(user1ID)
+	require.NoError(t, err)
+	assert.Nil(t, missedMessages)
+
+	require.NoError(t, store.RecordMissedMessage(user1ID, "teams-user-1", missedAt))
+	require.NoError(t, store.RecordMissedMessage(user2ID, "teams-user-2", missedAt))
+	require.NoError(t, store.RecordMissedMessage(user2ID, "teams-user-2", missedAt.Add(time.Minute)))
+
+	missedMessages, err = store.GetMissedMessages(user2ID)
+	require.NoError(t, err)
+	require.NotNil(t, missedMessages)
+	assert.Equal(t, "teams-user-2", missedMessages.TeamsUserID)
+	assert.Equal(t, 2, missedMessages.Count)
+	assert.True(t, missedAt.Add(time.Minute).Equal(missedMessages.LastMissedAt))
+
+	ranking, err := store.ListMissedMessages(0, 10)
+	require.NoError(t, err)
+	require.Len(t, ranking, 2)
+	assert.Equal(t, user2ID, ranking[0].MattermostUserID)
+	assert.Equal(t, user1ID, ranking[1].MattermostUserID)
+
+	ranking, err = store.ListMissedMessages(0, 1)
+	require.NoError(t, err)
+	require.Len(t, ranking, 1)
+	assert.Equal(t, user2ID, ranking[0].MattermostUserID)
+
+	ranking, err = store.ListMissedMessages(1, 1)
+	require.NoError(t, err)
+	require.Len(t, ranking, 1)
+	assert.Equal(t, user1ID, ranking[0].MattermostUserID)
+
+	require.NoError(t, store.SetMissedMessagesNudged(user2ID, 2, missedAt))
+	missedMessages, err = store.GetMissedMessages(user2ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, missedMessages.NudgedCount)
+	assert.True(t, missedAt.Equal(missedMessages.LastNudgedAt))
+
+	require.NoError(t, store.DeleteMissedMessages(user2ID))
+	missedMessages, err = store.GetMissedMessages(user2ID)
+	require.NoError(t, err)
+	assert.Nil(t, missedMessages)
+}
+
+func TestUserInfoAndDeleteUserData(t *testing.T) {
+	store, _ := setupTestStore(t)
+	store.encryptionKey = func() []byte {
+		return make([]byte, 16)
+	}
+
+	userID := model.NewId()
+	teamsUserID := model.NewId()
+
+	userInfo, err := store.GetUserInfo(userID)
+	require.NoError(t, err)
+	assert.Nil(t, userInfo)
+
+	require.NoError(t, store.SetUserInfo(userID, teamsUserID, &oauth2.Token{AccessToken: "token", Expiry: time.Now().Add(10 * time.Minute)}))
+	require.NoError(t, store.StoreUserInWhitelist(userID))
+	require.NoError(t, store.StoreInvitedUser(&storemodels.InvitedUser{ID: userID, InvitePendingSince: time.Now(), InviteLastSentAt: time.Now()}))
+	require.NoError(t, store.RecordMissedMessage(userID, teamsUserID, time.Now()))
+	subscriptionID := model.NewId()
+	require.NoError(t, store.SaveChatSubscription(makeChatSubscription(subscriptionID, teamsUserID, time.Now().Add(100*time.Minute))))
+
+	userInfo, err = store.GetUserInfo(userID)
+	require.NoError(t, err)
+	require.NotNil(t, userInfo)
+	assert.Equal(t, teamsUserID, userInfo.TeamsUserID)
+	assert.True(t, userInfo.Connected)
+	assert.False(t, userInfo.LastConnectAt.IsZero())
+
+	require.NoError(t, store.DeleteUserData(userID))
+
+	userInfo, err = store.GetUserInfo(userID)
+	require.NoError(t, err)
+	assert.Nil(t, userInfo)
+
+	whitelisted, err := store.IsUserWhitelisted(userID)
+	require.NoError(t, err)
+	assert.False(t, whitelisted)
+
+	invitedUser, err := store.GetInvitedUser(userID)
+	require.NoError(t, err)
+	assert.Nil(t, invitedUser)
+
+	missedMessages, err := store.GetMissedMessages(userID)
+	require.NoError(t, err)
+	assert.Nil(t, missedMessages)
+
+	_, err = store.GetChatSubscription(subscriptionID)
+	assert.Error(t, err)
+
+	// Deleting again is a no-op.
+	require.NoError(t, store.DeleteUserData(userID))
+}
@@ -19,9 +19,15 @@ type Store interface {
 	// users
 	TeamsToMattermostUserID(userID string) (string, error)
 	MattermostToTeamsUserID(userID string) (string, error)
+	TeamsToMattermostUserIDs(userIDs []string) (map[string]string, error)
+	FilterConnectedTeamsUserIDs(teamsUserIDs []string) ([]string, error)
+	GetPreferencesForUsers(mmUserIDs []string, category, name string) (map[string]string, error)
+	GetUserIDsByEmails(emails []string) (map[string]string, error)
 	GetTokenForMattermostUser(userID string) (*oauth2.Token, error)
 	GetTokenForMSTeamsUser(userID string) (*oauth2.Token, error)
 	GetConnectedUsers(page, perPage int) ([]*storemodels.ConnectedUser, error)
+	SearchConnectedUsers(search string, page, perPage int) ([]*storemodels.ConnectedUser, error)
+	GetLinkedUsers(page, perPage int) ([]*storemodels.ConnectedUser, error)
 	UserHasConnected(mmUserID string) (bool, error)
 	GetUserConnectStatus(mmUserID string) (*storemodels.UserConnectStatus, error)
 	GetHasConnectedCount() (int, error)
@@ -30,23 +36,37 @@ type Store interface {
 	SetUserLastChatSentAt(mmUserID string, sentAt int64) error
 	SetUserLastChatReceivedAt(mmUserID string, receivedAt int64) error
 	SetUsersLastChatReceivedAt(mmUserIDs []string, receivedAt int64) error
+	GetUserInfo(mmUserID string) (*storemodels.UserInfo, error)
+	DeleteUserData(mmUserID string) error
 
 	// auth
-	StoreOAuth2State(state string) error
-	VerifyOAuth2State(state string) error
+	StoreOAuth2State(state *storemodels.OAuth2State) error
+	VerifyOAuth2State(stateID, userID string) (*storemodels.OAuth2State, error)
+	ConsumeOAuth2State(stateID, userID string) error
 
 	// invites & whitelist
 	StoreInvitedUser(invitedUser *storemodels.InvitedUser) error
 	GetInvitedUser(mmUserID string) (*storemodels.InvitedUser, error)
 	DeleteUserInvite(mmUserID string) error
 	GetInvitedCount() (int, error)
+	GetInvitedUsers() ([]*storemodels.InvitedUser, error)
+	StoreExpiredInvite(expiredInvite *storemodels.ExpiredInvite) error
+	GetExpiredInvite(mmUserID string) (*storemodels.ExpiredInvite, error)
+	GetExpiredInvites() ([]*storemodels.ExpiredInvite, error)
 	StoreUserInWhitelist(userID string) error
 	IsUserWhitelisted(userID string) (bool, error)
 	DeleteUserFromWhitelist(userID string) error
 	GetWhitelistCount() (int, error)
-	GetWhitelistEmails(page int, perPage int) ([]string, error)
+	GetWhitelistEmails(search string, page int, perPage int) ([]string, error)
 	SetWhitelist(userIDs []string, batchSize int) error
 
+	// missed messages
+	RecordMissedMessage(mmUserID, teamsUserID string, missedAt time.Time) error
+	GetMissedMessages(mmUserID string) (*storemodels.MissedMessages, error)
+	ListMissedMessages(offset, limit int) ([]*storemodels.MissedMessages, error)
+	SetMissedMessagesNudged(mmUserID string, nudgedCount int, nudgedAt time.Time) error
+	DeleteMissedMessages(mmUserID string) error
+
 	// stats
 	GetLinkedChannelsCount() (linkedChannels int64, err error)
 	GetConnectedUsersCount() (connectedUsers int64, err error)
@@ -54,6 +74,7 @@ type Store interface {
 
 	// links, channels, posts
 	GetLinkByChannelID(channelID string) (*storemodels.ChannelLink, error)
+	GetDirectChannelIDs(mmUserIDs []string, otherUserID string) (map[string]string, error)
 	ListChannelLinks() ([]storemodels.ChannelLink, error)
 	ListChannelLinksWithNames() ([]*storemodels.ChannelLink, error)
 	GetLinkByMSTeamsChannelID(teamID, channelID string) (*storemodels.ChannelLink, error)
@@ -64,6 +64,16 @@ type ConnectedUser struct {
 	Email            string
 }
 
+type UserInfo struct {
+	MattermostUserID   string
+	TeamsUserID        string
+	Connected          bool
+	LastConnectAt      time.Time
+	LastDisconnectAt   time.Time
+	LastChatSentAt     time.Time
+	LastChatReceivedAt time.Time
+}
+
 type UserConnectStatus struct {
 	ID               string
 	Connected        bool
@@ -72,9 +82,41 @@ type UserConnectStatus struct {
 }
 
 type InvitedUser struct {
-	ID                 string
-	InvitePendingSince time.Time
-	InviteLastSentAt   time.Time
+	ID                  string
+	InvitePendingSince  time.Time
+	InviteLastSentAt    time.Time
+	InviteRemindersSent int
+}
+
+type MissedMessages struct {
+	MattermostUserID string
+	TeamsUserID      string
+	Count            int
+	LastMissedAt     time.Time
+	NudgedCount      int
+	LastNudgedAt     time.Time
+}
+
+type ExpiredInvite struct {
+	ID                  string
+	InvitePendingSince  time.Time
+	InviteRemindersSent int
+	InviteExpiredAt     time.Time
+}
+
+const (
+	OAuth2StateOriginPreferences = "preferences"
+	OAuth2StateOriginBotMessage  = "bot_message"
+)
+
+// OAuth2State is a stored OAuth state, recording who started the connection and from where.
+type OAuth2State struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Origin    string    `json:"origin"`
+	ChannelID string    `json:"channel_id,omitempty"`
+	PostID    string    `json:"post_id,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 func MilliToMicroSeconds(milli int64) int64 {
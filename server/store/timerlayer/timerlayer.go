@@ -21,6 +21,20 @@ type TimerLayer struct {
 	metrics metrics.Metrics
 }
 
+func (s *TimerLayer) ConsumeOAuth2State(stateID string, userID string) error {
+	start := time.Now()
+
+	err := s.Store.ConsumeOAuth2State(stateID, userID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.ConsumeOAuth2State", success, elapsed)
+	return err
+}
+
 func (s *TimerLayer) DeleteLinkByChannelID(channelID string) error {
 	start := time.Now()
 
@@ -35,6 +49,20 @@ func (s *TimerLayer) DeleteLinkByChannelID(channelID string) error {
 	return err
 }
 
+func (s *TimerLayer) DeleteMissedMessages(mmUserID string) error {
+	start := time.Now()
+
+	err := s.Store.DeleteMissedMessages(mmUserID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.DeleteMissedMessages", success, elapsed)
+	return err
+}
+
 func (s *TimerLayer) DeleteSubscription(subscriptionID string) error {
 	start := time.Now()
 
@@ -49,6 +77,20 @@ func (s *TimerLayer) DeleteSubscription(subscriptionID string) error {
 	return err
 }
 
+func (s *TimerLayer) DeleteUserData(mmUserID string) error {
+	start := time.Now()
+
+	err := s.Store.DeleteUserData(mmUserID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.DeleteUserData", success, elapsed)
+	return err
+}
+
 func (s *TimerLayer) DeleteUserFromWhitelist(userID string) error {
 	start := time.Now()
 
@@ -91,6 +133,20 @@ func (s *TimerLayer) DeleteUserInvite(mmUserID string) error {
 	return err
 }
 
+func (s *TimerLayer) FilterConnectedTeamsUserIDs(teamsUserIDs []string) ([]string, error) {
+	start := time.Now()
+
+	result, err := s.Store.FilterConnectedTeamsUserIDs(teamsUserIDs)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.FilterConnectedTeamsUserIDs", success, elapsed)
+	return result, err
+}
+
 func (s *TimerLayer) GetActiveUsersCount(dur time.Duration) (int64, error) {
 	start := time.Now()
 
@@ -175,6 +231,48 @@ func (s *TimerLayer) GetConnectedUsersCount() (int64, error) {
 	return result, err
 }
 
+func (s *TimerLayer) GetDirectChannelIDs(mmUserIDs []string, otherUserID string) (map[string]string, error) {
+	start := time.Now()
+
+	result, err := s.Store.GetDirectChannelIDs(mmUserIDs, otherUserID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.GetDirectChannelIDs", success, elapsed)
+	return result, err
+}
+
+func (s *TimerLayer) GetExpiredInvite(mmUserID string) (*storemodels.ExpiredInvite, error) {
+	start := time.Now()
+
+	result, err := s.Store.GetExpiredInvite(mmUserID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.GetExpiredInvite", success, elapsed)
+	return result, err
+}
+
+func (s *TimerLayer) GetExpiredInvites() ([]*storemodels.ExpiredInvite, error) {
+	start := time.Now()
+
+	result, err := s.Store.GetExpiredInvites()
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.GetExpiredInvites", success, elapsed)
+	return result, err
+}
+
 func (s *TimerLayer) GetGlobalSubscription(subscriptionID string) (*storemodels.GlobalSubscription, error) {
 	start := time.Now()
 
@@ -231,6 +329,20 @@ func (s *TimerLayer) GetInvitedUser(mmUserID string) (*storemodels.InvitedUser,
 	return result, err
 }
 
+func (s *TimerLayer) GetInvitedUsers() ([]*storemodels.InvitedUser, error) {
+	start := time.Now()
+
+	result, err := s.Store.GetInvitedUsers()
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.GetInvitedUsers", success, elapsed)
+	return result, err
+}
+
 func (s *TimerLayer) GetLinkByChannelID(channelID string) (*storemodels.ChannelLink, error) {
 	start := time.Now()
 
@@ -273,6 +385,34 @@ func (s *TimerLayer) GetLinkedChannelsCount() (int64, error) {
 	return result, err
 }
 
+func (s *TimerLayer) GetLinkedUsers(page int, perPage int) ([]*storemodels.ConnectedUser, error) {
+	start := time.Now()
+
+	result, err := s.Store.GetLinkedUsers(page, perPage)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.GetLinkedUsers", success, elapsed)
+	return result, err
+}
+
+func (s *TimerLayer) GetMissedMessages(mmUserID string) (*storemodels.MissedMessages, error) {
+	start := time.Now()
+
+	result, err := s.Store.GetMissedMessages(mmUserID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.GetMissedMessages", success, elapsed)
+	return result, err
+}
+
 func (s *TimerLayer) GetPostInfoByMSTeamsID(chatID string, postID string) (*storemodels.PostInfo, error) {
 	start := time.Now()
 
@@ -301,6 +441,20 @@ func (s *TimerLayer) GetPostInfoByMattermostID(postID string) (*storemodels.Post
 	return result, err
 }
 
+func (s *TimerLayer) GetPreferencesForUsers(mmUserIDs []string, category string, name string) (map[string]string, error) {
+	start := time.Now()
+
+	result, err := s.Store.GetPreferencesForUsers(mmUserIDs, category, name)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.GetPreferencesForUsers", success, elapsed)
+	return result, err
+}
+
 func (s *TimerLayer) GetSubscriptionType(subscriptionID string) (string, error) {
 	start := time.Now()
 
@@ -357,6 +511,34 @@ func (s *TimerLayer) GetUserConnectStatus(mmUserID string) (*storemodels.UserCon
 	return result, err
 }
 
+func (s *TimerLayer) GetUserIDsByEmails(emails []string) (map[string]string, error) {
+	start := time.Now()
+
+	result, err := s.Store.GetUserIDsByEmails(emails)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.GetUserIDsByEmails", success, elapsed)
+	return result, err
+}
+
+func (s *TimerLayer) GetUserInfo(mmUserID string) (*storemodels.UserInfo, error) {
+	start := time.Now()
+
+	result, err := s.Store.GetUserInfo(mmUserID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.GetUserInfo", success, elapsed)
+	return result, err
+}
+
 func (s *TimerLayer) GetWhitelistCount() (int, error) {
 	start := time.Now()
 
@@ -371,10 +553,10 @@ func (s *TimerLayer) GetWhitelistCount() (int, error) {
 	return result, err
 }
 
-func (s *TimerLayer) GetWhitelistEmails(page int, perPage int) ([]string, error) {
+func (s *TimerLayer) GetWhitelistEmails(search string, page int, perPage int) ([]string, error) {
 	start := time.Now()
 
-	result, err := s.Store.GetWhitelistEmails(page, perPage)
+	result, err := s.Store.GetWhitelistEmails(search, page, perPage)
 
 	elapsed := float64(time.Since(start)) / float64(time.Second)
 	success := "false"
@@ -525,6 +707,20 @@ func (s *TimerLayer) ListGlobalSubscriptionsToRefresh() ([]*storemodels.GlobalSu
 	return result, err
 }
 
+func (s *TimerLayer) ListMissedMessages(offset int, limit int) ([]*storemodels.MissedMessages, error) {
+	start := time.Now()
+
+	result, err := s.Store.ListMissedMessages(offset, limit)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.ListMissedMessages", success, elapsed)
+	return result, err
+}
+
 func (s *TimerLayer) MattermostToTeamsUserID(userID string) (string, error) {
 	start := time.Now()
 
@@ -539,6 +735,20 @@ func (s *TimerLayer) MattermostToTeamsUserID(userID string) (string, error) {
 	return result, err
 }
 
+func (s *TimerLayer) RecordMissedMessage(mmUserID string, teamsUserID string, missedAt time.Time) error {
+	start := time.Now()
+
+	err := s.Store.RecordMissedMessage(mmUserID, teamsUserID, missedAt)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.RecordMissedMessage", success, elapsed)
+	return err
+}
+
 func (s *TimerLayer) RecoverPost(postID string) error {
 	start := time.Now()
 
@@ -595,6 +805,34 @@ func (s *TimerLayer) SaveGlobalSubscription(subscription storemodels.GlobalSubsc
 	return err
 }
 
+func (s *TimerLayer) SearchConnectedUsers(search string, page int, perPage int) ([]*storemodels.ConnectedUser, error) {
+	start := time.Now()
+
+	result, err := s.Store.SearchConnectedUsers(search, page, perPage)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.SearchConnectedUsers", success, elapsed)
+	return result, err
+}
+
+func (s *TimerLayer) SetMissedMessagesNudged(mmUserID string, nudgedCount int, nudgedAt time.Time) error {
+	start := time.Now()
+
+	err := s.Store.SetMissedMessagesNudged(mmUserID, nudgedCount, nudgedAt)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.SetMissedMessagesNudged", success, elapsed)
+	return err
+}
+
 func (s *TimerLayer) SetPostLastUpdateAtByMSTeamsID(postID string, lastUpdateAt time.Time) error {
 	start := time.Now()
 
@@ -707,6 +945,20 @@ func (s *TimerLayer) StoreChannelLink(link *storemodels.ChannelLink) error {
 	return err
 }
 
+func (s *TimerLayer) StoreExpiredInvite(expiredInvite *storemodels.ExpiredInvite) error {
+	start := time.Now()
+
+	err := s.Store.StoreExpiredInvite(expiredInvite)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.StoreExpiredInvite", success, elapsed)
+	return err
+}
+
 func (s *TimerLayer) StoreInvitedUser(invitedUser *storemodels.InvitedUser) error {
 	start := time.Now()
 
@@ -721,7 +973,7 @@ func (s *TimerLayer) StoreInvitedUser(invitedUser *storemodels.InvitedUser) erro
 	return err
 }
 
-func (s *TimerLayer) StoreOAuth2State(state string) error {
+func (s *TimerLayer) StoreOAuth2State(state *storemodels.OAuth2State) error {
 	start := time.Now()
 
 	err := s.Store.StoreOAuth2State(state)
@@ -763,6 +1015,20 @@ func (s *TimerLayer) TeamsToMattermostUserID(userID string) (string, error) {
 	return result, err
 }
 
+func (s *TimerLayer) TeamsToMattermostUserIDs(userIDs []string) (map[string]string, error) {
+	start := time.Now()
+
+	result, err := s.Store.TeamsToMattermostUserIDs(userIDs)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	success := "false"
+	if err == nil {
+		success = "true"
+	}
+	s.metrics.ObserveStoreMethodDuration("Store.TeamsToMattermostUserIDs", success, elapsed)
+	return result, err
+}
+
 func (s *TimerLayer) UpdateSubscriptionExpiresOn(subscriptionID string, expiresOn time.Time) error {
 	start := time.Now()
 
@@ -791,10 +1057,10 @@ func (s *TimerLayer) UserHasConnected(mmUserID string) (bool, error) {
 	return result, err
 }
 
-func (s *TimerLayer) VerifyOAuth2State(state string) error {
+func (s *TimerLayer) VerifyOAuth2State(stateID string, userID string) (*storemodels.OAuth2State, error) {
 	start := time.Now()
 
-	err := s.Store.VerifyOAuth2State(state)
+	result, err := s.Store.VerifyOAuth2State(stateID, userID)
 
 	elapsed := float64(time.Since(start)) / float64(time.Second)
 	success := "false"
@@ -802,7 +1068,7 @@ func (s *TimerLayer) VerifyOAuth2State(state string) error {
 		success = "true"
 	}
 	s.metrics.ObserveStoreMethodDuration("Store.VerifyOAuth2State", success, elapsed)
-	return err
+	return result, err
 }
 
 func New(childStore store.Store, metrics metrics.Metrics) *TimerLayer {
@@ -4,16 +4,24 @@
 package main
 
 import (
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/mattermost/mattermost-plugin-msteams/server/metrics"
+	"github.com/mattermost/mattermost-plugin-msteams/server/msteams"
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
 	"github.com/mattermost/mattermost-plugin-msteams/server/store/storemodels"
 )
 
+// maxDisconnectedPresenceRatio bounds the share of the users covered by a presence subscription
+// that may have disconnected before recreating it. Their notifications are only ignored until then,
+// which costs less than recreating a subscription of up to MaxPresenceUserIDs users whenever any
+// one of them disconnects.
+const maxDisconnectedPresenceRatio = 0.25
+
 func isExpired(expiresOn time.Time) bool {
 	return expiresOn.Before(time.Now())
 }
@@ -139,6 +147,122 @@ func (m *Monitor) checkGlobalChatsSubscription(remoteSubscription *clientmodels.
 	}
 }
 
+// checkPresenceSubscriptions maintains the presence subscriptions covering the connected users, in
+// chunks of as many users as Microsoft Graph allows, refreshing them as needed and recreating those
+// covering too many disconnected users. The presence of connected users left without a
+// subscription, e.g. after failing to create one, is polled instead.
+func (m *Monitor) checkPresenceSubscriptions(remoteSubscriptions map[string]*clientmodels.Subscription) {
+	connectedUserIDs, err := m.getConnectedTeamsUserIDs()
+	if err != nil {
+		m.api.LogWarn("Unable to get the connected users from store", "error", err.Error())
+		return
+	}
+
+	connected := make(map[string]bool, len(connectedUserIDs))
+	for _, userID := range connectedUserIDs {
+		connected[userID] = true
+	}
+
+	covered := make(map[string]bool, len(connectedUserIDs))
+	for _, remoteSubscription := range remoteSubscriptions {
+		userIDs := msteams.GetPresenceSubscriptionUserIDs(remoteSubscription.Resource)
+		if len(userIDs) == 0 {
+			continue
+		}
+
+		// Delete the subscriptions covering too many users no longer connected. The remaining
+		// users are subscribed to again below.
+		disconnected := 0
+		for _, userID := range userIDs {
+			if !connected[userID] {
+				disconnected++
+			}
+		}
+		if float64(disconnected) > float64(len(userIDs))*maxDisconnectedPresenceRatio || isExpired(remoteSubscription.ExpiresOn) {
+			m.api.LogInfo("Deleting presence subscription", "subscription_id", remoteSubscription.ID)
+			if err = m.deleteSubscription(remoteSubscription.ID); err != nil {
+				m.api.LogWarn("Failed to delete presence subscription", "subscription_id", remoteSubscription.ID, "error", err.Error())
+			}
+			continue
+		}
+
+		if shouldRefresh(remoteSubscription.ExpiresOn) {
+			if _, err = m.client.RefreshPresenceSubscription(remoteSubscription.ID); err != nil {
+				m.api.LogWarn("Failed to refresh presence subscription", "subscription_id", remoteSubscription.ID, "error", err.Error())
+				if err = m.deleteSubscription(remoteSubscription.ID); err != nil {
+					m.api.LogWarn("Failed to delete presence subscription", "subscription_id", remoteSubscription.ID, "error", err.Error())
+				}
+				continue
+			}
+			m.metrics.ObserveSubscription(metrics.SubscriptionRefreshed)
+		}
+
+		for _, userID := range userIDs {
+			covered[userID] = true
+		}
+	}
+
+	var uncoveredUserIDs []string
+	for _, userID := range connectedUserIDs {
+		if !covered[userID] {
+			uncoveredUserIDs = append(uncoveredUserIDs, userID)
+		}
+	}
+
+	var polledUserIDs []string
+	for chunk := range slices.Chunk(uncoveredUserIDs, msteams.MaxPresenceUserIDs) {
+		remoteSubscription, err := m.client.SubscribeToPresences(chunk, m.baseURL, m.webhookSecret)
+		if err != nil {
+			m.api.LogWarn("Failed to create presence subscription, polling instead", "users", len(chunk), "error", err.Error())
+			polledUserIDs = append(polledUserIDs, chunk...)
+			continue
+		}
+
+		m.metrics.ObserveSubscription(metrics.SubscriptionConnected)
+		m.api.LogInfo("Created presence subscription", "subscription_id", remoteSubscription.ID, "users", len(chunk))
+
+		// Any presence cached before the subscription may have changed unnoticed.
+		publishPresenceUpdate(m.api, m.presences, presenceUpdate{InvalidatedUserIDs: chunk})
+	}
+
+	if len(polledUserIDs) == 0 {
+		return
+	}
+
+	presences, err := m.client.GetPresencesForUsers(polledUserIDs)
+	if err != nil {
+		m.api.LogWarn("Failed to poll presences", "users", len(polledUserIDs), "error", err.Error())
+		return
+	}
+
+	update := presenceUpdate{Presences: make([]clientmodels.Presence, 0, len(presences))}
+	for _, presence := range presences {
+		update.Presences = append(update.Presences, presence)
+	}
+	publishPresenceUpdate(m.api, m.presences, update)
+}
+
+// getConnectedTeamsUserIDs returns the Teams user IDs of all the connected users.
+func (m *Monitor) getConnectedTeamsUserIDs() ([]string, error) {
+	var userIDs []string
+	for page := DefaultPage; ; page++ {
+		connectedUsers, err := m.store.GetConnectedUsers(page, MaxPerPage)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, connectedUser := range connectedUsers {
+			if connectedUser.TeamsUserID != "" {
+				userIDs = append(userIDs, connectedUser.TeamsUserID)
+			}
+		}
+
+		if len(connectedUsers) < MaxPerPage {
+			return userIDs, nil
+		}
+	}
+}
+
 // getMSTeamsSubscriptionsMap queries MS Teams and returns a map of subscriptions indexed by
 // subscription id, as well as the global chats subscription if one exists.
 func (m *Monitor) getMSTeamsSubscriptionsMap() (msteamsSubscriptionsMap map[string]*clientmodels.Subscription, allChatsSubscription *clientmodels.Subscription, err error) {
@@ -5,11 +5,14 @@ package main
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/mattermost/mattermost-plugin-msteams/server/msteams/clientmodels"
@@ -217,3 +220,131 @@ func TestMonitorCheckGlobalChatsSubscription(t *testing.T) {
 		expectLocalSubscription(th, t, nil)
 	})
 }
+
+func TestMonitorCheckPresenceSubscriptions(t *testing.T) {
+	th := setupTestHelper(t)
+	team := th.SetupTeam(t)
+
+	baseURL := "http://example.com/plugins/com.mattermost.msteams-sync/"
+
+	presenceSubscription := func(expiresOn time.Time, userIDs ...string) *clientmodels.Subscription {
+		quotedUserIDs := make([]string, 0, len(userIDs))
+		for _, userID := range userIDs {
+			quotedUserIDs = append(quotedUserIDs, "'"+userID+"'")
+		}
+
+		return &clientmodels.Subscription{
+			ID:              model.NewId(),
+			Resource:        fmt.Sprintf("/communications/presences?$filter=id in (%s)", strings.Join(quotedUserIDs, ",")),
+			ExpiresOn:       expiresOn,
+			NotificationURL: baseURL,
+		}
+	}
+
+	matchUserIDs := func(userIDs ...string) any {
+		return mock.MatchedBy(func(actualUserIDs []string) bool {
+			return slices.Equal(slices.Sorted(slices.Values(userIDs)), slices.Sorted(slices.Values(actualUserIDs)))
+		})
+	}
+
+	t.Run("no remote subscription", func(t *testing.T) {
+		th.Reset(t)
+
+		user1 := th.SetupUser(t, team)
+		th.ConnectUser(t, user1.Id)
+		user2 := th.SetupUser(t, team)
+		th.ConnectUser(t, user2.Id)
+
+		th.appClientMock.On("SubscribeToPresences", matchUserIDs("t"+user1.Id, "t"+user2.Id), baseURL, "webhooksecret").Return(presenceSubscription(time.Now().Add(time.Hour), "t"+user1.Id, "t"+user2.Id), nil).Times(1)
+
+		th.p.monitor.checkPresenceSubscriptions(map[string]*clientmodels.Subscription{})
+	})
+
+	t.Run("remote subscription to refresh", func(t *testing.T) {
+		th.Reset(t)
+
+		user1 := th.SetupUser(t, team)
+		th.ConnectUser(t, user1.Id)
+
+		remoteSubscription := presenceSubscription(time.Now().Add(time.Minute), "t"+user1.Id)
+		newExpiresOn := time.Now().Add(time.Hour)
+		th.appClientMock.On("RefreshPresenceSubscription", remoteSubscription.ID).Return(&newExpiresOn, nil).Times(1)
+
+		th.p.monitor.checkPresenceSubscriptions(map[string]*clientmodels.Subscription{remoteSubscription.ID: remoteSubscription})
+	})
+
+	t.Run("remote subscription covering disconnected users", func(t *testing.T) {
+		th.Reset(t)
+
+		user1 := th.SetupUser(t, team)
+		th.ConnectUser(t, user1.Id)
+		user2 := th.SetupUser(t, team)
+		th.ConnectUser(t, user2.Id)
+
+		remoteSubscription := presenceSubscription(time.Now().Add(time.Hour), "t"+user1.Id, "tdisconnected")
+		th.appClientMock.On("DeleteSubscription", remoteSubscription.ID).Return(nil).Times(1)
+		th.appClientMock.On("SubscribeToPresences", matchUserIDs("t"+user1.Id, "t"+user2.Id), baseURL, "webhooksecret").Return(presenceSubscription(time.Now().Add(time.Hour), "t"+user1.Id, "t"+user2.Id), nil).Times(1)
+
+		th.p.monitor.checkPresenceSubscriptions(map[string]*clientmodels.Subscription{remoteSubscription.ID: remoteSubscription})
+	})
+
+	t.Run("remote subscription covering a few disconnected users kept", func(t *testing.T) {
+		th.Reset(t)
+
+		var coveredUserIDs []string
+		for i := 0; i < 4; i++ {
+			user := th.SetupUser(t, team)
+			th.ConnectUser(t, user.Id)
+			coveredUserIDs = append(coveredUserIDs, "t"+user.Id)
+		}
+		user5 := th.SetupUser(t, team)
+		th.ConnectUser(t, user5.Id)
+
+		remoteSubscription := presenceSubscription(time.Now().Add(time.Hour), append(coveredUserIDs, "tdisconnected")...)
+		th.appClientMock.On("SubscribeToPresences", []string{"t" + user5.Id}, baseURL, "webhooksecret").Return(presenceSubscription(time.Now().Add(time.Hour), "t"+user5.Id), nil).Times(1)
+
+		th.p.monitor.checkPresenceSubscriptions(map[string]*clientmodels.Subscription{remoteSubscription.ID: remoteSubscription})
+		th.appClientMock.AssertNotCalled(t, "DeleteSubscription", remoteSubscription.ID)
+	})
+
+	t.Run("other remote subscriptions ignored", func(t *testing.T) {
+		th.Reset(t)
+
+		user1 := th.SetupUser(t, team)
+		th.ConnectUser(t, user1.Id)
+
+		coveringSubscription := presenceSubscription(time.Now().Add(time.Hour), "t"+user1.Id)
+		chatsSubscription := &clientmodels.Subscription{
+			ID:              model.NewId(),
+			Resource:        "chats/getAllMessages",
+			ExpiresOn:       time.Now().Add(time.Hour),
+			NotificationURL: baseURL,
+		}
+
+		th.p.monitor.checkPresenceSubscriptions(map[string]*clientmodels.Subscription{
+			coveringSubscription.ID: coveringSubscription,
+			chatsSubscription.ID:    chatsSubscription,
+		})
+	})
+
+	t.Run("fails to subscribe, polls presences", func(t *testing.T) {
+		th.Reset(t)
+
+		user1 := th.SetupUser(t, team)
+		th.ConnectUser(t, user1.Id)
+
+		presence := clientmodels.Presence{
+			UserID:       "t" + user1.Id,
+			Activity:     PresenceActivityAvailable,
+			Availability: PresenceAvailabilityAvailable,
+		}
+		th.appClientMock.On("SubscribeToPresences", []string{"t" + user1.Id}, baseURL, "webhooksecret").Return(nil, fmt.Errorf("failed to create")).Times(1)
+		th.appClientMock.On("GetPresencesForUsers", []string{"t" + user1.Id}).Return(map[string]clientmodels.Presence{presence.UserID: presence}, nil).Times(1)
+
+		th.p.monitor.checkPresenceSubscriptions(map[string]*clientmodels.Subscription{})
+
+		presences, missing := th.p.presenceCache.Get([]string{presence.UserID})
+		assert.Empty(t, missing)
+		assert.Equal(t, presence, presences[presence.UserID])
+	})
+}
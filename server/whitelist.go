@@ -0,0 +1,146 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/pkg/errors"
+)
+
+type WhitelistEntry struct {
+	UserID      string `json:"user_id"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Whitelisted bool   `json:"whitelisted"`
+}
+
+// emailsCSV is the parsed content of a CSV upload listing users by email, e.g. the whitelist.
+type emailsCSV struct {
+	Users       []*model.User
+	UserIDs     []string
+	Emails      []string
+	Failed      []string
+	FailedLines []string
+	Duplicates  []string
+}
+
+// lookupUser finds a Mattermost user given their username, @username or email.
+func (p *Plugin) lookupUser(usernameOrEmail string) (*model.User, error) {
+	usernameOrEmail = strings.TrimSpace(usernameOrEmail)
+	if usernameOrEmail == "" {
+		return nil, errors.New("no username or email provided")
+	}
+
+	var user *model.User
+	var appErr *model.AppError
+	if strings.Contains(usernameOrEmail, "@") && !strings.HasPrefix(usernameOrEmail, "@") {
+		user, appErr = p.API.GetUserByEmail(usernameOrEmail)
+	} else {
+		user, appErr = p.API.GetUserByUsername(strings.TrimPrefix(usernameOrEmail, "@"))
+	}
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "unable to find user %s", usernameOrEmail)
+	}
+
+	return user, nil
+}
+
+func (p *Plugin) getWhitelistEntry(user *model.User) (*WhitelistEntry, error) {
+	isWhitelisted, err := p.store.IsUserWhitelisted(user.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error in checking if user is whitelisted")
+	}
+
+	return &WhitelistEntry{
+		UserID:      user.Id,
+		Username:    user.Username,
+		Email:       user.Email,
+		Whitelisted: isWhitelisted,
+	}, nil
+}
+
+// parseEmailsCSV reads a CSV file with a single email column, resolving each email to a
+// Mattermost user. Parsing stops once more than UpdateWhitelistCsvParseErrThreshold lines fail
+// to parse.
+func (p *Plugin) parseEmailsCSV(file io.Reader) (*emailsCSV, error) {
+	reader := csv.NewReader(file)
+	columns, err := reader.Read()
+	if err != nil || strings.ToLower(columns[0]) != "email" || len(columns) != 1 {
+		return nil, errors.New("error parsing csv header")
+	}
+
+	result := &emailsCSV{}
+	seen := make(map[string]bool)
+	var i = 1 // offset, start line 1
+	for {
+		i++
+		row, readErr := reader.Read()
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			result.FailedLines = append(result.FailedLines, strconv.Itoa(i))
+			continue
+		}
+		if len(result.FailedLines) > UpdateWhitelistCsvParseErrThreshold {
+			break
+		}
+
+		email := row[0]
+		if seen[strings.ToLower(email)] {
+			result.Duplicates = append(result.Duplicates, email)
+			continue
+		}
+		seen[strings.ToLower(email)] = true
+
+		user, appErr := p.API.GetUserByEmail(email)
+		if appErr != nil {
+			p.API.LogWarn("Error could not find user with email", "line", i)
+			result.Failed = append(result.Failed, email)
+			continue
+		}
+
+		result.Users = append(result.Users, user)
+		result.UserIDs = append(result.UserIDs, user.Id)
+		result.Emails = append(result.Emails, user.Email)
+	}
+
+	return result, nil
+}
+
+// diffWhitelist compares the emails of an upload with the current whitelist, returning the
+// emails that would be added and removed, and how many are left unchanged.
+func diffWhitelist(current, next []string) (added []string, removed []string, unchanged int) {
+	currentSet := make(map[string]bool, len(current))
+	for _, email := range current {
+		currentSet[strings.ToLower(email)] = true
+	}
+
+	nextSet := make(map[string]bool, len(next))
+	for _, email := range next {
+		nextSet[strings.ToLower(email)] = true
+		if currentSet[strings.ToLower(email)] {
+			unchanged++
+		} else {
+			added = append(added, email)
+		}
+	}
+
+	for _, email := range current {
+		if !nextSet[strings.ToLower(email)] {
+			removed = append(removed, email)
+		}
+	}
+
+	slices.Sort(added)
+	slices.Sort(removed)
+
+	return added, removed, unchanged
+}
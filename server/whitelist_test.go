@@ -0,0 +1,50 @@
+// Copyright (c) 2023-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffWhitelist(t *testing.T) {
+	for _, test := range []struct {
+		Name              string
+		Current           []string
+		Next              []string
+		ExpectedAdded     []string
+		ExpectedRemoved   []string
+		ExpectedUnchanged int
+	}{
+		{
+			Name: "empty",
+		},
+		{
+			Name:          "only additions",
+			Next:          []string{"b@example.com", "a@example.com"},
+			ExpectedAdded: []string{"a@example.com", "b@example.com"},
+		},
+		{
+			Name:            "only removals",
+			Current:         []string{"b@example.com", "a@example.com"},
+			ExpectedRemoved: []string{"a@example.com", "b@example.com"},
+		},
+		{
+			Name:              "mixed, ignoring case",
+			Current:           []string{"a@example.com", "B@example.com"},
+			Next:              []string{"b@example.com", "c@example.com"},
+			ExpectedAdded:     []string{"c@example.com"},
+			ExpectedRemoved:   []string{"a@example.com"},
+			ExpectedUnchanged: 1,
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			added, removed, unchanged := diffWhitelist(test.Current, test.Next)
+			assert.Equal(t, test.ExpectedAdded, added)
+			assert.Equal(t, test.ExpectedRemoved, removed)
+			assert.Equal(t, test.ExpectedUnchanged, unchanged)
+		})
+	}
+}
@@ -16,6 +16,61 @@ export interface SiteStats {
 
 export interface ConnectionStatus {
     connected: boolean;
+    auto_linked?: boolean;
+    teams_user_id?: string;
+    teams_display_name?: string;
+    teams_email?: string;
+    connected_at?: number;
+    disconnected_at?: number;
+    notifications_enabled?: boolean;
+    last_notification_at?: number;
+    token_valid?: boolean;
+    token_check_failed?: boolean;
+}
+
+export interface PermissionStatus {
+    name?: string;
+    id: string;
+    type: string;
+    feature?: string;
+    granted: boolean;
+}
+
+export interface CredentialsCheck {
+    checked_at: number;
+    client_secret: {
+        name: string;
+        id: string;
+        end_date_time: number;
+        thumbprint?: string;
+    } | null;
+    permissions: PermissionStatus[];
+    redundant_permissions: PermissionStatus[];
+}
+
+export interface ConnectionTestRequest {
+    tenant_id?: string;
+    client_id?: string;
+    client_secret?: string;
+    client_certificate?: string;
+}
+
+export interface ConnectionTestReport {
+    success: boolean;
+    checks: Array<{
+        name: string;
+        passed: boolean;
+        skipped?: boolean;
+        error?: string;
+    }>;
+}
+
+export interface CircuitBreakerStatus {
+    family: string;
+    state: 'closed' | 'half_open' | 'open';
+    requests: number;
+    failures: number;
+    opened_at?: string;
 }
 
 class ClientClass {
@@ -41,6 +96,22 @@ class ClientClass {
         return data as SiteStats;
     };
 
+    fetchCredentialsCheck = async (refresh = false): Promise<CredentialsCheck | null> => {
+        const data = await this.doGet(`${this.url}/credentials/check${refresh ? '?refresh=true' : ''}`);
+        if (!data) {
+            return null;
+        }
+        return data as CredentialsCheck;
+    };
+
+    testConnection = async (request: ConnectionTestRequest): Promise<ConnectionTestReport> => {
+        return this.doPost(`${this.url}/connection/test`, request);
+    };
+
+    fetchCircuitBreakers = async (): Promise<CircuitBreakerStatus[]> => {
+        return this.doGet(`${this.url}/circuit-breakers`);
+    };
+
     connectionStatus = async (): Promise<ConnectionStatus> => {
         const data = await this.doGet(`${this.url}/connection-status`);
         if (!data) {